@@ -0,0 +1,113 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/constants"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
+	"github.com/vulcanize/vulcanizedb/utils"
+)
+
+var (
+	backFillContracts []string
+	backFillBatchSize uint64
+)
+
+// backFillStorageCmd represents the backFillStorage command
+var backFillStorageCmd = &cobra.Command{
+	Use:   "backFillStorage",
+	Short: "Back-fills storage diffs over a range of blocks for a precomposed plugin's storage transformers",
+	Long: `Fetches the storage diffs in a range of blocks from the node at client.ipcPath and runs
+them through the storage transformers of the plugin named by exporter.name, without
+starting the live watchers:
+
+./vulcanizedb backFillStorage --config=<config.toml> --starting-block-number=<block> --ending-block-number=<block>
+
+The starting block defaults to the minimum deployment block of the configured contracts and
+the ending block defaults to the chain head. Use --contracts to back-fill a subset of the
+plugin's contracts. Progress is recorded as each bin of blocks completes, so an interrupted
+back-fill resumes with the bins that were not completed.
+
+The node must support statediff_stateDiffAt, or trace_replayBlockTransactions when
+storageDiffs.source is parity.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		backFillStorage(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backFillStorageCmd)
+	backFillStorageCmd.Flags().Int64VarP(&startingBlockNumber, "starting-block-number", "s", 0, "first block to back-fill, defaults to the minimum contract deployment block")
+	backFillStorageCmd.Flags().Int64VarP(&endingBlockNumber, "ending-block-number", "e", 0, "last block to back-fill, defaults to the chain head")
+	backFillStorageCmd.Flags().StringSliceVar(&backFillContracts, "contracts", nil, "addresses of the contracts to back-fill, defaults to every contract with a storage transformer in the plugin")
+	backFillStorageCmd.Flags().Uint64Var(&backFillBatchSize, "batch-size", storage.DefaultMaxBatchSize, "number of blocks fetched in each bin")
+}
+
+func backFillStorage(cmd *cobra.Command) {
+	prepConfig()
+	exporter := loadExporter()
+	_, ethStorageInitializers, _, _ := exporter.Export()
+	if len(ethStorageInitializers) == 0 {
+		logWithCommand.Fatal("plugin does not export any storage transformers")
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	sw := newStorageWatcher(nil, &db)
+	addStorageTransformers(sw, exporter, ethStorageInitializers)
+
+	hashedAddresses := make([]common.Hash, 0, len(sw.KeccakAddressTransformers))
+	if len(backFillContracts) == 0 {
+		for hashedAddress := range sw.KeccakAddressTransformers {
+			hashedAddresses = append(hashedAddresses, hashedAddress)
+		}
+	}
+	for _, contract := range backFillContracts {
+		hashedAddress := crypto.Keccak256Hash(common.HexToAddress(contract).Bytes())
+		if _, ok := sw.KeccakAddressTransformers[hashedAddress]; !ok {
+			logWithCommand.Fatalf("plugin has no storage transformer for contract %s", contract)
+		}
+		hashedAddresses = append(hashedAddresses, hashedAddress)
+	}
+
+	startingBlock := uint64(startingBlockNumber)
+	if !cmd.Flags().Changed("starting-block-number") {
+		startingBlock = constants.GetMinDeploymentBlock()
+	}
+	endingBlock := uint64(endingBlockNumber)
+	if !cmd.Flags().Changed("ending-block-number") {
+		lastBlock, lastBlockErr := blockChain.LastBlock()
+		if lastBlockErr != nil {
+			logWithCommand.Fatalf("failed to get the chain head: %s", lastBlockErr.Error())
+		}
+		endingBlock = lastBlock.Uint64()
+	}
+
+	logWithCommand.Infof("back-filling storage diffs for %d contracts from block %d to %d", len(hashedAddresses), startingBlock, endingBlock)
+	backFillErr := sw.BackFillRange(startingBlock, endingBlock, newStorageBackFiller(backFillBatchSize), hashedAddresses)
+	if backFillErr != nil {
+		logWithCommand.Fatalf("storage back-fill failed: %s", backFillErr.Error())
+	}
+	logWithCommand.Info("storage back-fill finished")
+}
@@ -17,9 +17,11 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -27,6 +29,7 @@ import (
 
 	"github.com/vulcanize/vulcanizedb/pkg/config"
 	p2 "github.com/vulcanize/vulcanizedb/pkg/plugin"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 )
 
 // composeCmd represents the compose command
@@ -59,28 +62,40 @@ var composeCmd = &cobra.Command{
         path = "path/to/transformer1"
         type = "eth_event"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "0"
     [exporter.transformer2]
         path = "path/to/transformer2"
         type = "eth_contract"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "0"
     [exporter.transformer3]
         path = "path/to/transformer3"
         type = "eth_event"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "0"
     [exporter.transformer4]
         path = "path/to/transformer4"
         type = "eth_storage"
         repository = "github.com/account2/repo2"
+        directory = "~/src/repo2"
         migrations = "to/db/migrations"
         rank = "1"
+        schema = "repo2"
 
 
+Each repository is either pinned at a module version, or read from a local
+directory holding its source.
+
+The optional schema value names the schema a migration directory's migrations
+create their unqualified objects in, defaulting to public; each directory should
+have its own schema, and transformers sharing a directory must give the same one.
+
 Note: If any of the plugin transformer need additional
 configuration variables include them in the .toml file as well
 
@@ -94,13 +109,31 @@ type variable for each transformer in the config. Currently there are watchers
 of event data from an eth node (eth_event) and storage data from an eth node 
 (eth_storage), and a more generic interface for accepting contract_watcher pkg
 based transformers which can perform both event watching and public method 
-polling (eth_contract).
+polling (eth_contract). Transformers that subscribe to a super node themselves
+(eth_super_node) are constructed with the filters in their exporter.<name>.subscription
+table, keyed like the subscription table of streamSubscribe, e.g.
+    [exporter.transformer5]
+        path = "path/to/transformer5"
+        type = "eth_super_node"
+        repository = "github.com/account/repo"
+        version = "v1.2.3"
+        migrations = "db/migrations"
+        rank = "0"
+        [exporter.transformer5.subscription]
+            startingBlock = 0
+        [exporter.transformer5.subscription.receiptFilter]
+            contracts = ["0x89d24A6b4CcB1B6fAA2625fE562bDD9a23260359"]
+and subscribe to the super node at subscription.path.
 
 Transformers of different types can be ran together in the same command using a 
 single config file or in separate command instances using different config files
 
 Specify config location when executing the command:
-./vulcanizedb compose --config=./environments/config_name.toml`,
+./vulcanizedb compose --config=./environments/config_name.toml
+
+Check the config, transformer packages and migrations, and report every problem found
+without writing the plugin or committing to the db:
+./vulcanizedb compose --dry-run --config=./environments/config_name.toml`,
 	Run: func(cmd *cobra.Command, args []string) {
 		subCommand = cmd.CalledAs()
 		logWithCommand = *log.WithField("SubCommand", subCommand)
@@ -108,7 +141,13 @@ Specify config location when executing the command:
 	},
 }
 
+var composeDryRun bool
+
 func compose() {
+	if composeDryRun {
+		dryRunCompose()
+		return
+	}
 	// Build plugin generator config
 	prepConfig()
 
@@ -124,7 +163,6 @@ func compose() {
 		logWithCommand.Debug("generating plugin failed")
 		logWithCommand.Fatal(err)
 	}
-	// TODO: Embed versioning info in the .so files so we know which version of vulcanizedb to run them with
 	_, pluginPath, err := genConfig.GetPluginPaths()
 	if err != nil {
 		logWithCommand.Debug("getting plugin path failed")
@@ -136,58 +174,144 @@ func compose() {
 
 func init() {
 	rootCmd.AddCommand(composeCmd)
+	composeCmd.Flags().BoolVar(&composeDryRun, "dry-run", false, "check the config, transformer packages and migrations without composing the plugin or migrating the db")
+}
+
+// dryRunCompose reports every problem that would stop the plugin from being composed, without writing to plugins/ or
+// committing anything to the db
+func dryRunCompose() {
+	logWithCommand.Info("checking plugin")
+	var checks []helpers.Check
+	var problems []string
+	genConfig, problems = readPluginConfig()
+	for _, problem := range problems {
+		checks = append(checks, helpers.Check{Subject: "config", Description: "exporter config", Problem: problem})
+	}
+	if len(genConfig.Transformers) > 0 {
+		generator, err := p2.NewGenerator(genConfig, databaseConfig)
+		if err != nil {
+			logWithCommand.Fatal(err)
+		}
+		generatorChecks, err := generator.DryRun()
+		if err != nil {
+			logWithCommand.Fatalf("dry run failed: %s", err.Error())
+		}
+		checks = append(checks, generatorChecks...)
+	} else if len(problems) < 1 {
+		checks = append(checks, helpers.Check{Subject: "config", Description: "exporter config", Problem: "no transformers configured"})
+	}
+
+	failed := 0
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SUBJECT\tCHECK\tRESULT")
+	for _, check := range checks {
+		result := "ok"
+		if !check.OK() {
+			failed++
+			// Keep multi-line problems, e.g. compiler output, on the check's row
+			result = strings.Join(strings.Fields(check.Problem), " ")
+		} else if check.Warning != "" {
+			result = "warning: " + check.Warning
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", check.Subject, check.Description, result)
+	}
+	flushErr := writer.Flush()
+	if flushErr != nil {
+		logWithCommand.Fatalf("failed to write dry run report: %s", flushErr.Error())
+	}
+	if failed > 0 {
+		logWithCommand.Fatalf("dry run found %d problems", failed)
+	}
 }
 
 func prepConfig() {
 	logWithCommand.Info("configuring plugin")
+	var problems []string
+	genConfig, problems = readPluginConfig()
+	if len(problems) > 0 {
+		logWithCommand.Fatal(problems[0])
+	}
+}
+
+// readPluginConfig reads the exporter config, skipping transformers whose config is invalid.
+// It returns a description of each problem, so that they can all be reported at once.
+func readPluginConfig() (config.Plugin, []string) {
+	var problems []string
 	names := viper.GetStringSlice("exporter.transformerNames")
 	transformers := make(map[string]config.Transformer)
 	for _, name := range names {
 		logWithCommand.Debug("Configuring " + name + " transformer")
-		transformer := viper.GetStringMapString("exporter." + name)
-		p, pOK := transformer["path"]
-		if !pOK || p == "" {
-			logWithCommand.Fatal(name, " transformer config is missing `path` value")
-		}
-		r, rOK := transformer["repository"]
-		if !rOK || r == "" {
-			logWithCommand.Fatal(name, " transformer config is missing `repository` value")
-		}
-		m, mOK := transformer["migrations"]
-		if !mOK || m == "" {
-			logWithCommand.Fatal(name, " transformer config is missing `migrations` value")
-		}
-		mr, mrOK := transformer["rank"]
-		if !mrOK || mr == "" {
-			logWithCommand.Fatal(name, " transformer config is missing `rank` value")
-		}
-		rank, err := strconv.ParseUint(mr, 10, 64)
-		if err != nil {
-			logWithCommand.Fatal(name, " migration `rank` can't be converted to an unsigned integer")
-		}
-		t, tOK := transformer["type"]
-		if !tOK {
-			logWithCommand.Fatal(name, " transformer config is missing `type` value")
-		}
-		transformerType := config.GetTransformerType(t)
-		if transformerType == config.UnknownTransformerType {
-			logWithCommand.Fatal(errors.New(`unknown transformer type in exporter config accepted types are "eth_event", "eth_storage"`))
+		transformer, problem := readTransformerConfig(name)
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
 		}
+		transformers[name] = transformer
+	}
 
-		transformers[name] = config.Transformer{
-			Path:           p,
-			Type:           transformerType,
-			RepositoryPath: r,
-			MigrationPath:  m,
-			MigrationRank:  rank,
-		}
+	mode := config.GetMode(viper.GetString("exporter.mode"))
+	if mode == config.UnknownMode {
+		problems = append(problems, `unknown exporter mode in exporter config accepted modes are "plugin", "rpc", "static"`)
 	}
 
-	genConfig = config.Plugin{
+	return config.Plugin{
+		Mode:         mode,
+		Race:         viper.GetBool("exporter.race"),
 		Transformers: transformers,
 		FilePath:     "$GOPATH/src/github.com/vulcanize/vulcanizedb/plugins",
 		FileName:     viper.GetString("exporter.name"),
 		Save:         viper.GetBool("exporter.save"),
 		Home:         viper.GetString("exporter.home"),
+	}, problems
+}
+
+func readTransformerConfig(name string) (config.Transformer, string) {
+	transformer := viper.GetStringMapString("exporter." + name)
+	p, pOK := transformer["path"]
+	if !pOK || p == "" {
+		return config.Transformer{}, name + " transformer config is missing `path` value"
+	}
+	r, rOK := transformer["repository"]
+	if !rOK || r == "" {
+		return config.Transformer{}, name + " transformer config is missing `repository` value"
+	}
+	m, mOK := transformer["migrations"]
+	if !mOK || m == "" {
+		return config.Transformer{}, name + " transformer config is missing `migrations` value"
+	}
+	mr, mrOK := transformer["rank"]
+	if !mrOK || mr == "" {
+		return config.Transformer{}, name + " transformer config is missing `rank` value"
+	}
+	rank, err := strconv.ParseUint(mr, 10, 64)
+	if err != nil {
+		return config.Transformer{}, name + " migration `rank` can't be converted to an unsigned integer"
+	}
+	t, tOK := transformer["type"]
+	if !tOK {
+		return config.Transformer{}, name + " transformer config is missing `type` value"
+	}
+	transformerType := config.GetTransformerType(t)
+	if transformerType == config.UnknownTransformerType {
+		return config.Transformer{}, name + ` has an unknown transformer type, accepted types are "eth_event", "eth_storage", "eth_contract", "eth_super_node"`
+	}
+	v, d := transformer["version"], transformer["directory"]
+	if (v == "") == (d == "") {
+		return config.Transformer{}, name + " transformer config needs either a `version` to pin its repository at or a `directory` to read it from"
+	}
+
+	transformerConfig := config.Transformer{
+		Path:                p,
+		Type:                transformerType,
+		RepositoryPath:      r,
+		RepositoryVersion:   v,
+		RepositoryDirectory: d,
+		MigrationPath:       m,
+		MigrationRank:       rank,
+		MigrationSchema:     transformer["schema"],
+	}
+	if transformerType == config.EthSuperNode {
+		transformerConfig.Subscription = readSubscription("exporter." + name + ".subscription")
 	}
+	return transformerConfig, ""
 }
@@ -17,18 +17,23 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"plugin"
-	syn "sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/event"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/watcher"
-	"github.com/vulcanize/vulcanizedb/pkg/fs"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 	p2 "github.com/vulcanize/vulcanizedb/pkg/plugin"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 	"github.com/vulcanize/vulcanizedb/utils"
@@ -64,27 +69,42 @@ var composeAndExecuteCmd = &cobra.Command{
         path = "path/to/transformer1"
         type = "eth_event"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "0"
     [exporter.transformer2]
         path = "path/to/transformer2"
         type = "eth_contract"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "2"
     [exporter.transformer3]
         path = "path/to/transformer3"
         type = "eth_event"
         repository = "github.com/account/repo"
+        version = "v1.2.3"
         migrations = "db/migrations"
         rank = "0"
     [exporter.transformer4]
         path = "path/to/transformer4"
         type = "eth_storage"
         repository = "github.com/account2/repo2"
+        directory = "~/src/repo2"
         migrations = "to/db/migrations"
         rank = "1"
 
+[abiTransformers]
+    transformerNames = [
+        "transformer5",
+    ]
+    [abiTransformers.transformer5]
+        contracts = ["0x89d24A6b4CcB1B6fAA2625fE562bDD9a23260359"]
+        abi = '[{"anonymous":false,"inputs":[...],"name":"Transfer","type":"event"}]'
+        events = ["Transfer"]
+        schema = "dai"
+        startingBlock = 4719568
+
 
 Note: If any of the plugin transformer need additional
 configuration variables include them in the .toml file as well
@@ -99,11 +119,30 @@ type variable for each transformer in the config. Currently there are watchers
 of event data from an eth node (eth_event) and storage data from an eth node 
 (eth_storage), and a more generic interface for accepting contract_watcher pkg
 based transformers which can perform both event watching and public method 
-polling (eth_contract).
+polling (eth_contract). Transformers that subscribe to a super node themselves
+(eth_super_node) are constructed with the filters in their exporter.<name>.subscription
+table, keyed like the subscription table of streamSubscribe, e.g.
+    [exporter.transformer5]
+        path = "path/to/transformer5"
+        type = "eth_super_node"
+        repository = "github.com/account/repo"
+        version = "v1.2.3"
+        migrations = "db/migrations"
+        rank = "0"
+        [exporter.transformer5.subscription]
+            startingBlock = 0
+        [exporter.transformer5.subscription.receiptFilter]
+            contracts = ["0x89d24A6b4CcB1B6fAA2625fE562bDD9a23260359"]
+and subscribe to the super node at subscription.path.
 
 Transformers of different types can be ran together in the same command using a 
 single config file or in separate command instances using different config files
 
+Event transformers can also be configured without writing any Go under
+abiTransformers: a table is created for each listed event of the contract abi,
+with a column per event input, and its logs are decoded into that table.
+No plugin is composed if only abi transformers are configured.
+
 Specify config location when executing the command:
 ./vulcanizedb composeAndExecute --config=./environments/config_name.toml`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -117,6 +156,110 @@ func composeAndExecute() {
 	// Build plugin generator config
 	prepConfig()
 
+	abiConfigs := prepAbiTransformerConfigs()
+	if len(genConfig.Transformers) < 1 && len(abiConfigs) < 1 {
+		logWithCommand.Fatal("no exporter or abi transformers are configured")
+	}
+
+	// Plugin transformers are composed and loaded only when they are configured, abi transformers don't need a plugin
+	var ethEventInitializers []transformer.EventTransformerInitializer
+	var ethStorageInitializers []transformer.StorageTransformerInitializer
+	var ethContractInitializers []transformer.ContractTransformerInitializer
+	var ethSuperNodeInitializers []transformer.SuperNodeTransformerInitializer
+	var exporter Exporter
+	var pluginPath string
+	if len(genConfig.Transformers) > 0 {
+		exporter, pluginPath = composeAndLoadPlugin()
+		// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer,
+		// ContractTransformerInitializer and SuperNodeTransformerInitializer sets
+		ethEventInitializers, ethStorageInitializers, ethContractInitializers, ethSuperNodeInitializers = exporter.Export()
+	}
+
+	// Setup bc and db objects
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+
+	// Create the tables for abi transformers and add them to the exported event transformers
+	abiInitializers := loadAbiTransformers(&db, abiConfigs)
+	ethEventInitializers = append(ethEventInitializers, abiInitializers...)
+
+	// Execute over transformer sets returned by the exporter
+	// Watchers are stopped after their current unit of work on SIGINT/SIGTERM or when any watcher fails
+	runner := newWatcherRunner()
+	if len(ethEventInitializers) > 0 {
+		var ew *watcher.EventWatcher
+		switch eventLogsSource {
+		case "super_node":
+			log.Debug("extracting event logs from super node subscription")
+			superNodeStreamer := streamer.NewSuperNodeStreamer(getRPCClient())
+			ew = watcher.NewSuperNodeEventWatcher(&db, blockChain, superNodeStreamer, confirmationDepth)
+		default:
+			log.Debug("extracting event logs from eth_getLogs")
+			ew = watcher.NewEventWatcher(&db, blockChain, confirmationDepth)
+		}
+		err := ew.AddTransformers(ethEventInitializers)
+		if err != nil {
+			logWithCommand.Fatalf("failed to add event transformer initializers to watcher: %s", err.Error())
+		}
+		runner.run(func(ctx context.Context) error { return watchEthEvents(ctx, ew) })
+	}
+
+	if len(ethStorageInitializers) > 0 {
+		switch storageDiffsSource {
+		case "geth":
+			log.Debug("fetching storage diffs from geth pub sub")
+			rpcClient, _ := getClients()
+			stateDiffStreamer := streamer.NewStateDiffStreamer(rpcClient)
+			storageFetcher := fetcher.NewGethRPCStorageFetcher(stateDiffStreamer)
+			sw := newStorageWatcher(storageFetcher, &db)
+			addStorageTransformers(sw, exporter, ethStorageInitializers)
+			runner.run(func(ctx context.Context) error { return watchEthStorage(ctx, sw) })
+		case "super_node":
+			log.Debug("fetching storage diffs from super node subscription")
+			superNodeStreamer := streamer.NewSuperNodeStreamer(getRPCClient())
+			storageFetcher := fetcher.NewSuperNodeStorageFetcher(superNodeStreamer)
+			sw := newStorageWatcher(storageFetcher, &db)
+			storageFetcher.WatchedAddresses = sw.WatchedAddresses
+			addStorageTransformers(sw, exporter, ethStorageInitializers)
+			runner.run(func(ctx context.Context) error { return watchEthStorage(ctx, sw) })
+		case "parity":
+			log.Debug("fetching storage diffs from parity trace_replayBlockTransactions")
+			rpcClient, _ := getClients()
+			storageFetcher := fetcher.NewParityStorageFetcher(rpcClient)
+			sw := newStorageWatcher(storageFetcher, &db)
+			addStorageTransformers(sw, exporter, ethStorageInitializers)
+			runner.run(func(ctx context.Context) error { return watchEthStorage(ctx, sw) })
+		default:
+			log.Debug("fetching storage diffs from csv")
+			storageFetcher := newCsvStorageFetcher(&db)
+			sw := newStorageWatcher(storageFetcher, &db)
+			addStorageTransformers(sw, exporter, ethStorageInitializers)
+			runner.run(func(ctx context.Context) error { return watchEthStorage(ctx, sw) })
+		}
+	}
+
+	if len(ethContractInitializers) > 0 {
+		gw := watcher.NewContractWatcher(&db, blockChain)
+		gw.AddTransformers(ethContractInitializers)
+		runner.run(func(ctx context.Context) error { return watchEthContract(ctx, &gw) })
+	}
+
+	if len(ethSuperNodeInitializers) > 0 {
+		snw := newSuperNodeWatcher(&db, exporter, ethSuperNodeInitializers)
+		runner.run(func(ctx context.Context) error { return watchEthSuperNode(ctx, &snw) })
+	}
+
+	watchErr := runner.wait()
+	if !genConfig.Save && pluginPath != "" {
+		helpers.ClearFiles(pluginPath)
+	}
+	if watchErr != nil {
+		logWithCommand.Fatalf("watcher failed: %s", watchErr.Error())
+	}
+}
+
+// Generates, builds and links the plugin for the exporter transformers, returning its Exporter and path
+func composeAndLoadPlugin() (Exporter, string) {
 	// Generate code to build the plugin according to the config file
 	logWithCommand.Info("generating plugin")
 	generator, err := p2.NewGenerator(genConfig, databaseConfig)
@@ -134,8 +277,11 @@ func composeAndExecute() {
 	if err != nil {
 		logWithCommand.Fatal(err)
 	}
-	if !genConfig.Save {
-		defer helpers.ClearFiles(pluginPath)
+	if genConfig.Mode == config.RPCMode {
+		return loadRemoteExporter(pluginPath, ""), pluginPath
+	}
+	if genConfig.Mode == config.StaticMode {
+		logWithCommand.Fatalf(`exporter.mode = "static" composed the binary %s, which can't be linked as a plugin; run "%s execute" to execute its transformers`, pluginPath, pluginPath)
 	}
 	logWithCommand.Info("linking plugin ", pluginPath)
 	plug, err := plugin.Open(pluginPath)
@@ -158,60 +304,69 @@ func composeAndExecute() {
 		logWithCommand.Debug("plugged-in symbol not of type Exporter")
 		os.Exit(1)
 	}
+	return exporter, pluginPath
+}
 
-	// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer, and ContractTransformerInitializer sets
-	ethEventInitializers, ethStorageInitializers, ethContractInitializers := exporter.Export()
-
-	// Setup bc and db objects
-	blockChain := getBlockChain()
-	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
-
-	// Execute over transformer sets returned by the exporter
-	// Use WaitGroup to wait on both goroutines
-	var wg syn.WaitGroup
-	if len(ethEventInitializers) > 0 {
-		ew := watcher.NewEventWatcher(&db, blockChain)
-		err := ew.AddTransformers(ethEventInitializers)
-		if err != nil {
-			logWithCommand.Fatalf("failed to add event transformer initializers to watcher: %s", err.Error())
+// Builds the configs for event transformers derived from a contract abi
+func prepAbiTransformerConfigs() []event.AbiTransformerConfig {
+	names := viper.GetStringSlice("abiTransformers.transformerNames")
+	configs := make([]event.AbiTransformerConfig, 0, len(names))
+	for _, name := range names {
+		logWithCommand.Debug("Configuring " + name + " abi transformer")
+		key := "abiTransformers." + name
+		contracts := viper.GetStringSlice(key + ".contracts")
+		if len(contracts) < 1 {
+			logWithCommand.Fatal(name, " abi transformer config is missing `contracts` value")
 		}
-		wg.Add(1)
-		go watchEthEvents(&ew, &wg)
-	}
-
-	if len(ethStorageInitializers) > 0 {
-		switch storageDiffsSource {
-		case "geth":
-			log.Debug("fetching storage diffs from geth pub sub")
-			rpcClient, _ := getClients()
-			stateDiffStreamer := streamer.NewStateDiffStreamer(rpcClient)
-			storageFetcher := fetcher.NewGethRPCStorageFetcher(stateDiffStreamer)
-			sw := watcher.NewStorageWatcher(storageFetcher, &db)
-			sw.AddTransformers(ethStorageInitializers)
-			wg.Add(1)
-			go watchEthStorage(sw, &wg)
-		default:
-			log.Debug("fetching storage diffs from csv")
-			tailer := fs.FileTailer{Path: storageDiffsPath}
-			storageFetcher := fetcher.NewCsvTailStorageFetcher(tailer)
-			sw := watcher.NewStorageWatcher(storageFetcher, &db)
-			sw.AddTransformers(ethStorageInitializers)
-			wg.Add(1)
-			go watchEthStorage(sw, &wg)
+		contractAbi := viper.GetString(key + ".abi")
+		if contractAbi == "" {
+			logWithCommand.Fatal(name, " abi transformer config is missing `abi` value")
+		}
+		events := viper.GetStringSlice(key + ".events")
+		if len(events) < 1 {
+			logWithCommand.Fatal(name, " abi transformer config is missing `events` value")
+		}
+		endingBlock := int64(-1)
+		if viper.IsSet(key + ".endingBlock") {
+			endingBlock = viper.GetInt64(key + ".endingBlock")
 		}
+		configs = append(configs, event.AbiTransformerConfig{
+			TransformerName:     name,
+			Schema:              viper.GetString(key + ".schema"),
+			ContractAddresses:   contracts,
+			ContractAbi:         contractAbi,
+			Events:              events,
+			StartingBlockNumber: viper.GetInt64(key + ".startingBlock"),
+			EndingBlockNumber:   endingBlock,
+		})
 	}
+	return configs
+}
 
-	if len(ethContractInitializers) > 0 {
-		gw := watcher.NewContractWatcher(&db, blockChain)
-		gw.AddTransformers(ethContractInitializers)
-		wg.Add(1)
-		go watchEthContract(&gw, &wg)
+// Creates the tables for the abi transformers and returns their initializers
+func loadAbiTransformers(db *postgres.DB, configs []event.AbiTransformerConfig) []transformer.EventTransformerInitializer {
+	var initializers []transformer.EventTransformerInitializer
+	for _, config := range configs {
+		logWithCommand.Info("running migration for abi transformer ", config.TransformerName)
+		migrationErr := event.RunAbiMigration(db, config)
+		if migrationErr != nil {
+			logWithCommand.Fatal(migrationErr)
+		}
+		abiTransformers, transformersErr := event.NewAbiTransformers(config)
+		if transformersErr != nil {
+			logWithCommand.Fatal(transformersErr)
+		}
+		for _, abiTransformer := range abiTransformers {
+			initializers = append(initializers, abiTransformer.NewTransformer)
+		}
 	}
-	wg.Wait()
+	return initializers
 }
 
 func init() {
 	rootCmd.AddCommand(composeAndExecuteCmd)
 	composeAndExecuteCmd.Flags().BoolVarP(&recheckHeadersArg, "recheck-headers", "r", false, "whether to re-check headers for watched events")
 	composeAndExecuteCmd.Flags().DurationVarP(&queueRecheckInterval, "queue-recheck-interval", "q", 5*time.Minute, "interval duration for rechecking queued storage diffs (ex: 5m30s)")
+	composeAndExecuteCmd.Flags().IntVar(&maxQueueAttempts, "max-queue-attempts", storage.DefaultRetryPolicy.MaxAttempts, "number of failed attempts after which a queued storage diff is moved to poisoned storage, 0 to retry indefinitely")
+	composeAndExecuteCmd.Flags().Int64VarP(&confirmationDepth, "confirmation-depth", "d", 0, "number of blocks a header must be behind the chain head before its logs are delegated to event transformers")
 }
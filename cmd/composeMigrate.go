@@ -0,0 +1,146 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manager"
+)
+
+var (
+	migrateBaseline bool
+	migrateGroup    string
+	migrateSteps    int
+)
+
+// composeMigrateCmd represents the compose migrate command
+var composeMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manages the db migrations of the plugin's transformers",
+	Long: `Each transformer repository's migration directory is a migration group, versioned
+in its own table in the plugin_migrations schema along with the checksum of each applied
+migration file, so that groups are migrated and rolled back independently of each other
+and of vulcanizedb's own migrations. compose applies pending migrations, as does:
+
+./vulcanizedb compose migrate up --config=<config.toml>
+
+Databases whose plugin migrations were applied before they were grouped can record the
+current migrations as applied without running them; until then, up refuses to migrate a
+group without a version table whose first migration was already applied:
+
+./vulcanizedb compose migrate up --baseline --config=<config.toml>
+
+List the migrations of each group, and whether they are applied, pending, changed since
+they were applied, or missing from the group's directory:
+
+./vulcanizedb compose migrate status --config=<config.toml>
+
+Roll back the latest migration of a group, given by its name or repository, or of the
+highest ranked group by default:
+
+./vulcanizedb compose migrate down --group=github.com/account/repo --steps=1 --config=<config.toml>`,
+}
+
+var composeMigrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Lists the state of the plugin's migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CommandPath()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		migrateStatus()
+	},
+}
+
+var composeMigrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Applies the plugin's pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CommandPath()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		migrateUp()
+	},
+}
+
+var composeMigrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Rolls back the latest migrations of one of the plugin's migration groups",
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CommandPath()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		migrateDown()
+	},
+}
+
+func init() {
+	composeCmd.AddCommand(composeMigrateCmd)
+	composeMigrateCmd.AddCommand(composeMigrateStatusCmd, composeMigrateUpCmd, composeMigrateDownCmd)
+	composeMigrateUpCmd.Flags().BoolVar(&migrateBaseline, "baseline", false, "record pending migrations as applied without running them")
+	composeMigrateDownCmd.Flags().StringVar(&migrateGroup, "group", "", "name or repository of the migration group to roll back, defaults to the highest ranked group")
+	composeMigrateDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "number of migrations to roll back")
+}
+
+func newMigrationManager() manager.MigrationManager {
+	prepConfig()
+	return manager.NewMigrationManager(genConfig, databaseConfig)
+}
+
+func migrateStatus() {
+	statuses, err := newMigrationManager().Status()
+	if err != nil {
+		logWithCommand.Fatalf("failed to get migration status: %s", err.Error())
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "GROUP\tREPOSITORY\tMIGRATION\tSTATE")
+	for _, status := range statuses {
+		for _, migration := range status.Migrations {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", status.Group, status.Repository, migration.Name, migration.State)
+		}
+	}
+	flushErr := writer.Flush()
+	if flushErr != nil {
+		logWithCommand.Fatalf("failed to write migration status: %s", flushErr.Error())
+	}
+}
+
+func migrateUp() {
+	migrationManager := newMigrationManager()
+	var err error
+	if migrateBaseline {
+		err = migrationManager.Baseline()
+	} else {
+		err = migrationManager.RunMigrations()
+	}
+	if err != nil {
+		logWithCommand.Fatalf("failed to migrate: %s", err.Error())
+	}
+}
+
+func migrateDown() {
+	if migrateSteps < 1 {
+		logWithCommand.Fatal("--steps must be at least 1")
+	}
+	err := newMigrationManager().Down(migrateGroup, migrateSteps)
+	if err != nil {
+		logWithCommand.Fatalf("failed to roll back migrations: %s", err.Error())
+	}
+}
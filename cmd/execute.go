@@ -17,22 +17,36 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"plugin"
+	"reflect"
+	"runtime/debug"
+	"strings"
 	syn "sync"
+	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/vulcanize/vulcanizedb/libraries/shared/constants"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/remote"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/watcher"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
 	"github.com/vulcanize/vulcanizedb/pkg/fs"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manifest"
 	"github.com/vulcanize/vulcanizedb/utils"
 )
 
@@ -57,6 +71,16 @@ The exporter.name is the name (without extension) of the plugin to be loaded.
 The plugin file needs to be located in the /plugins directory and this command assumes 
 the db migrations remain from when the plugin was composed. Additionally, the plugin 
 must have been composed by the same version of vulcanizedb or else it will not be compatible.
+Before linking the plugin, its manifest is checked against this binary and the configured
+transformers, and any differences are reported.
+Transformers of type eth_super_node are each constructed with the filters in their
+exporter.<name>.subscription table and subscribe to the super node at subscription.path;
+one that fails is restarted.
+With exporter.mode = "rpc" the composed executable is started instead of linking a plugin,
+or the transformer process serving on exporter.socket is called. A static binary composed with
+exporter.mode = "static" uses its compiled-in transformers.
+While executing, the config file is watched and transformers in the plugin are added or dropped
+as they are added to or removed from exporter.transformerNames.
 Specify config location when executing the command:
 ./vulcanizedb execute --config=./environments/config_name.toml`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -69,7 +93,107 @@ Specify config location when executing the command:
 func execute() {
 	// Build plugin generator config
 	prepConfig()
+	exporter := loadExporter()
 
+	// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer,
+	// ContractTransformerInitializer and SuperNodeTransformerInitializer sets
+	ethEventInitializers, ethStorageInitializers, ethContractInitializers, ethSuperNodeInitializers := exporter.Export()
+
+	// Setup bc and db objects
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+
+	// Execute over transformer sets returned by the exporter
+	// Watchers are stopped after their current unit of work on SIGINT/SIGTERM or when any watcher fails
+	runner := newWatcherRunner()
+	var ew *watcher.EventWatcher
+	if len(ethEventInitializers) > 0 {
+		switch eventLogsSource {
+		case "super_node":
+			log.Debug("extracting event logs from super node subscription")
+			superNodeStreamer := streamer.NewSuperNodeStreamer(getRPCClient())
+			ew = watcher.NewSuperNodeEventWatcher(&db, blockChain, superNodeStreamer, confirmationDepth)
+		default:
+			log.Debug("extracting event logs from eth_getLogs")
+			ew = watcher.NewEventWatcher(&db, blockChain, confirmationDepth)
+		}
+		err := ew.AddTransformers(ethEventInitializers)
+		if err != nil {
+			logWithCommand.Fatalf("failed to add event transformer initializers to watcher: %s", err.Error())
+		}
+		runner.run(func(ctx context.Context) error { return watchEthEvents(ctx, ew) })
+	}
+
+	var sw *watcher.StorageWatcher
+	if len(ethStorageInitializers) > 0 {
+		switch storageDiffsSource {
+		case "geth":
+			log.Debug("fetching storage diffs from geth pub sub")
+			wsClient := getWSClient()
+			stateDiffStreamer := streamer.NewStateDiffStreamer(wsClient)
+			storageFetcher := fetcher.NewGethRPCStorageFetcher(stateDiffStreamer)
+			sw = newStorageWatcher(storageFetcher, &db)
+		case "super_node":
+			log.Debug("fetching storage diffs from super node subscription")
+			superNodeStreamer := streamer.NewSuperNodeStreamer(getRPCClient())
+			storageFetcher := fetcher.NewSuperNodeStorageFetcher(superNodeStreamer)
+			sw = newStorageWatcher(storageFetcher, &db)
+			storageFetcher.WatchedAddresses = sw.WatchedAddresses
+		case "parity":
+			log.Debug("fetching storage diffs from parity trace_replayBlockTransactions")
+			rpcClient, _ := getClients()
+			storageFetcher := fetcher.NewParityStorageFetcher(rpcClient)
+			sw = newStorageWatcher(storageFetcher, &db)
+		default:
+			log.Debug("fetching storage diffs from csv")
+			storageFetcher := newCsvStorageFetcher(&db)
+			sw = newStorageWatcher(storageFetcher, &db)
+		}
+		addStorageTransformers(sw, exporter, ethStorageInitializers)
+		runner.run(func(ctx context.Context) error { return watchEthStorage(ctx, sw) })
+	}
+
+	if len(ethContractInitializers) > 0 {
+		gw := watcher.NewContractWatcher(&db, blockChain)
+		gw.AddTransformers(ethContractInitializers)
+		runner.run(func(ctx context.Context) error { return watchEthContract(ctx, &gw) })
+	}
+
+	if len(ethSuperNodeInitializers) > 0 {
+		snw := newSuperNodeWatcher(&db, exporter, ethSuperNodeInitializers)
+		runner.run(func(ctx context.Context) error { return watchEthSuperNode(ctx, &snw) })
+	}
+
+	// Add and drop transformers as exporter.transformerNames changes in the config file
+	namedExporter, ok := exporter.(NamedExporter)
+	if ok {
+		reloader := newTransformerReloader(&db, namedExporter, ew, sw)
+		watchConfig(reloader)
+	} else {
+		logWithCommand.Warn("plugin does not export transformer names, recompose it to reload transformers when the config changes")
+	}
+
+	watchErr := runner.wait()
+	if watchErr != nil {
+		logWithCommand.Fatalf("watcher failed: %s", watchErr.Error())
+	}
+}
+
+// compiledExporter is the Exporter of a static binary composed with exporter.mode = "static"
+var compiledExporter Exporter
+
+// SetExporter compiles an Exporter into the binary, which commands then use instead of loading a plugin.
+// It is called by the main function of a static binary before Execute.
+func SetExporter(exporter Exporter) {
+	compiledExporter = exporter
+}
+
+// loadExporter returns the compiled-in Exporter, or links the plugin named by exporter.name and returns its Exporter
+func loadExporter() Exporter {
+	if compiledExporter != nil {
+		logWithCommand.Info("using transformers compiled into the binary")
+		return compiledExporter
+	}
 	// Get the plugin path and load the plugin
 	_, pluginPath, err := genConfig.GetPluginPaths()
 	if err != nil {
@@ -77,6 +201,13 @@ func execute() {
 	}
 
 	fmt.Printf("Executing plugin %s", pluginPath)
+	if genConfig.Mode == config.RPCMode {
+		return loadRemoteExporter(pluginPath, viper.GetString("exporter.socket"))
+	}
+	if genConfig.Mode == config.StaticMode {
+		logWithCommand.Fatalf(`exporter.mode = "static" runs transformers compiled into the composed binary %s, which can't be linked as a plugin; run "%s %s" instead`, pluginPath, pluginPath, subCommand)
+	}
+	checkPluginManifest(pluginPath)
 	logWithCommand.Info("linking plugin ", pluginPath)
 	plug, err := plugin.Open(pluginPath)
 	if err != nil {
@@ -97,70 +228,259 @@ func execute() {
 	if !ok {
 		logWithCommand.Fatal("plugged-in symbol not of type Exporter")
 	}
+	return exporter
+}
 
-	// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer, and ContractTransformerInitializer sets
-	ethEventInitializers, ethStorageInitializers, ethContractInitializers := exporter.Export()
-
-	// Setup bc and db objects
-	blockChain := getBlockChain()
-	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
-
-	// Execute over transformer sets returned by the exporter
-	// Use WaitGroup to wait on both goroutines
-	var wg syn.WaitGroup
-	if len(ethEventInitializers) > 0 {
-		ew := watcher.NewEventWatcher(&db, blockChain)
-		err = ew.AddTransformers(ethEventInitializers)
-		if err != nil {
-			logWithCommand.Fatalf("failed to add event transformer initializers to watcher: %s", err.Error())
-		}
-		wg.Add(1)
-		go watchEthEvents(&ew, &wg)
+// loadRemoteExporter dials the transformer process serving on socket, or starts the executable if no socket is given
+func loadRemoteExporter(executablePath, socket string) Exporter {
+	settings := remote.Settings{Database: databaseConfig, ClientIPCPath: ipc, SuperNodePath: superNodePath()}
+	var exporter *remote.Exporter
+	var err error
+	if socket != "" {
+		logWithCommand.Info("connecting to transformer process on ", socket)
+		exporter, err = remote.Dial(socket, settings)
+	} else {
+		logWithCommand.Info("starting transformer process ", executablePath)
+		exporter, err = remote.Start(executablePath, settings)
 	}
-
-	if len(ethStorageInitializers) > 0 {
-		switch storageDiffsSource {
-		case "geth":
-			log.Debug("fetching storage diffs from geth pub sub")
-			wsClient := getWSClient()
-			stateDiffStreamer := streamer.NewStateDiffStreamer(wsClient)
-			storageFetcher := fetcher.NewGethRPCStorageFetcher(stateDiffStreamer)
-			sw := watcher.NewStorageWatcher(storageFetcher, &db)
-			sw.AddTransformers(ethStorageInitializers)
-			wg.Add(1)
-			go watchEthStorage(sw, &wg)
-		default:
-			log.Debug("fetching storage diffs from csv")
-			tailer := fs.FileTailer{Path: storageDiffsPath}
-			storageFetcher := fetcher.NewCsvTailStorageFetcher(tailer)
-			sw := watcher.NewStorageWatcher(storageFetcher, &db)
-			sw.AddTransformers(ethStorageInitializers)
-			wg.Add(1)
-			go watchEthStorage(sw, &wg)
-		}
+	if err != nil {
+		logWithCommand.Warn("loading transformers from transformer process failed")
+		logWithCommand.Fatal(err)
 	}
+	return exporter
+}
 
-	if len(ethContractInitializers) > 0 {
-		gw := watcher.NewContractWatcher(&db, blockChain)
-		gw.AddTransformers(ethContractInitializers)
-		wg.Add(1)
-		go watchEthContract(&gw, &wg)
+// checkPluginManifest exits with the differences between what the plugin was composed with and this binary and config,
+// since linking an incompatible plugin fails with an opaque error or crashes
+func checkPluginManifest(pluginPath string) {
+	pluginManifest, err := manifest.Read(pluginPath)
+	if err == manifest.ErrNoManifest {
+		logWithCommand.Warn("plugin has no manifest to check its compatibility, recompose it to add one")
+		return
+	}
+	if err != nil {
+		logWithCommand.Fatalf("failed to read plugin manifest: %s", err.Error())
 	}
-	wg.Wait()
+	info, _ := debug.ReadBuildInfo()
+	mismatches := manifest.Compare(pluginManifest, manifest.New(genConfig, info))
+	if len(mismatches) == 0 {
+		return
+	}
+	differences := make([]string, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		differences = append(differences, mismatch.String())
+	}
+	logWithCommand.Fatalf("plugin %s is not compatible:\n\t%s", pluginPath, strings.Join(differences, "\n\t"))
 }
 
 func init() {
 	rootCmd.AddCommand(executeCmd)
 	executeCmd.Flags().BoolVarP(&recheckHeadersArg, "recheck-headers", "r", false, "whether to re-check headers for watched events")
 	executeCmd.Flags().DurationVarP(&queueRecheckInterval, "queue-recheck-interval", "q", 5*time.Minute, "interval duration for rechecking queued storage diffs (ex: 5m30s)")
+	executeCmd.Flags().IntVar(&maxQueueAttempts, "max-queue-attempts", storage.DefaultRetryPolicy.MaxAttempts, "number of failed attempts after which a queued storage diff is moved to poisoned storage, 0 to retry indefinitely")
+	executeCmd.Flags().Int64VarP(&confirmationDepth, "confirmation-depth", "d", 0, "number of blocks a header must be behind the chain head before its logs are delegated to event transformers")
 }
 
 type Exporter interface {
-	Export() ([]transformer.EventTransformerInitializer, []transformer.StorageTransformerInitializer, []transformer.ContractTransformerInitializer)
+	Export() ([]transformer.EventTransformerInitializer, []transformer.StorageTransformerInitializer, []transformer.ContractTransformerInitializer, []transformer.SuperNodeTransformerInitializer)
+}
+
+// NamedExporter is implemented by plugins that also export the names of their transformer initializers,
+// index-aligned with Export, so that transformers can be added and dropped while executing
+type NamedExporter interface {
+	Exporter
+	ExportNames() ([]string, []string, []string, []string)
+}
+
+// transformerReloader keeps the watchers' transformers in line with exporter.transformerNames in the config file
+// Transformers can only be added back from the loaded plugin; new transformers still require recomposing it
+type transformerReloader struct {
+	db                  *postgres.DB
+	eventWatcher        *watcher.EventWatcher
+	storageWatcher      *watcher.StorageWatcher
+	eventInitializers   map[string]transformer.EventTransformerInitializer
+	storageInitializers map[string]transformer.StorageTransformerInitializer
+	fixedTransformers   map[string]config.TransformerType             // contract and super node transformers, which can't be reloaded
+	activeEvents        map[string]transformer.EventTransformerConfig // plugin name => config the transformer was added with
+	activeStorage       map[string]common.Hash                        // plugin name => keccak hash of the transformer's address
+	lock                syn.Mutex
+}
+
+// Assumes every transformer exported by the plugin has already been added to the watchers, storage transformers by name
+func newTransformerReloader(db *postgres.DB, exporter NamedExporter, ew *watcher.EventWatcher, sw *watcher.StorageWatcher) *transformerReloader {
+	ethEventInitializers, ethStorageInitializers, _, _ := exporter.Export()
+	eventNames, storageNames, contractNames, superNodeNames := exporter.ExportNames()
+	reloader := &transformerReloader{
+		db:                  db,
+		eventWatcher:        ew,
+		storageWatcher:      sw,
+		eventInitializers:   make(map[string]transformer.EventTransformerInitializer),
+		storageInitializers: make(map[string]transformer.StorageTransformerInitializer),
+		fixedTransformers:   make(map[string]config.TransformerType),
+		activeEvents:        make(map[string]transformer.EventTransformerConfig),
+		activeStorage:       make(map[string]common.Hash),
+	}
+	for i, name := range eventNames {
+		reloader.eventInitializers[name] = ethEventInitializers[i]
+		reloader.activeEvents[name] = ethEventInitializers[i](db).GetConfig()
+	}
+	for i, name := range storageNames {
+		reloader.storageInitializers[name] = ethStorageInitializers[i]
+		reloader.activeStorage[name] = ethStorageInitializers[i](db).KeccakContractAddress()
+	}
+	for _, name := range contractNames {
+		reloader.fixedTransformers[name] = config.EthContract
+	}
+	for _, name := range superNodeNames {
+		reloader.fixedTransformers[name] = config.EthSuperNode
+	}
+	return reloader
+}
+
+// watchConfig reloads transformers whenever the config file is written
+func watchConfig(reloader *transformerReloader) {
+	if viper.ConfigFileUsed() == "" {
+		logWithCommand.Info("no config file to watch, transformers will not be reloaded")
+		return
+	}
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		logWithCommand.Infof("config file %s changed, reloading transformers", e.Name)
+		reloader.reload(viper.GetStringSlice("exporter.transformerNames"))
+	})
+	viper.WatchConfig()
+}
+
+// reload adds, replaces and drops transformers so that exactly the named transformers are executing
+// Transformers are replaced if their initializer now returns a different config, e.g. from changed contract addresses
+func (reloader *transformerReloader) reload(transformerNames []string) {
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+	desired := make(map[string]bool)
+	for _, name := range transformerNames {
+		desired[name] = true
+		_, isEvent := reloader.eventInitializers[name]
+		_, isStorage := reloader.storageInitializers[name]
+		_, isFixed := reloader.fixedTransformers[name]
+		if !isEvent && !isStorage && !isFixed {
+			logWithCommand.Warnf("transformer %s is not in the loaded plugin, recompose the plugin to add it", name)
+		}
+	}
+	for name, transformerType := range reloader.fixedTransformers {
+		if !desired[name] {
+			logWithCommand.Warnf("%s transformer %s can't be dropped while executing", transformerType, name)
+		}
+	}
+	reloader.reloadEventTransformers(desired)
+	reloader.reloadStorageTransformers(desired)
+}
+
+func (reloader *transformerReloader) reloadEventTransformers(desired map[string]bool) {
+	for name, initializer := range reloader.eventInitializers {
+		activeConfig, active := reloader.activeEvents[name]
+		if !desired[name] {
+			if active {
+				logWithCommand.Infof("dropping event transformer %s", name)
+				reloader.eventWatcher.RemoveTransformers([]string{activeConfig.TransformerName})
+				delete(reloader.activeEvents, name)
+			}
+			continue
+		}
+		config := initializer(reloader.db).GetConfig()
+		if active && reflect.DeepEqual(config, activeConfig) {
+			continue
+		}
+		if active {
+			logWithCommand.Infof("replacing event transformer %s", name)
+			reloader.eventWatcher.RemoveTransformers([]string{activeConfig.TransformerName})
+			delete(reloader.activeEvents, name)
+		} else {
+			logWithCommand.Infof("adding event transformer %s", name)
+		}
+		addErr := reloader.eventWatcher.AddTransformers([]transformer.EventTransformerInitializer{initializer})
+		if addErr != nil {
+			logWithCommand.Errorf("failed to add event transformer %s: %s", name, addErr.Error())
+			reloader.eventWatcher.RemoveTransformers([]string{config.TransformerName})
+			continue
+		}
+		reloader.activeEvents[name] = config
+	}
+}
+
+func (reloader *transformerReloader) reloadStorageTransformers(desired map[string]bool) {
+	for name, initializer := range reloader.storageInitializers {
+		activeAddress, active := reloader.activeStorage[name]
+		if !desired[name] {
+			if active {
+				logWithCommand.Infof("dropping storage transformer %s", name)
+				reloader.storageWatcher.RemoveTransformers([]string{name})
+				delete(reloader.activeStorage, name)
+			}
+			continue
+		}
+		address := initializer(reloader.db).KeccakContractAddress()
+		if active && address == activeAddress {
+			continue
+		}
+		if active {
+			logWithCommand.Infof("replacing storage transformer %s", name)
+			reloader.storageWatcher.RemoveTransformers([]string{name})
+		} else {
+			logWithCommand.Infof("adding storage transformer %s", name)
+		}
+		reloader.storageWatcher.AddNamedTransformers([]string{name}, []transformer.StorageTransformerInitializer{initializer})
+		reloader.activeStorage[name] = address
+	}
+}
+
+// watcherRunner runs watchers concurrently with a shared context that is cancelled
+// on SIGINT/SIGTERM or as soon as any of the watchers returns an error
+type watcherRunner struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      syn.WaitGroup
+	errOnce syn.Once
+	err     error
+}
+
+func newWatcherRunner() *watcherRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigs)
+		select {
+		case sig := <-sigs:
+			logWithCommand.Infof("received %s, shutting down watchers", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return &watcherRunner{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (runner *watcherRunner) run(watch func(ctx context.Context) error) {
+	runner.wg.Add(1)
+	go func() {
+		defer runner.wg.Done()
+		err := watch(runner.ctx)
+		if err != nil {
+			runner.errOnce.Do(func() { runner.err = err })
+			runner.cancel()
+		}
+	}()
 }
 
-func watchEthEvents(w *watcher.EventWatcher, wg *syn.WaitGroup) {
-	defer wg.Done()
+// wait blocks until every watcher has stopped and returns the first watcher error, if any
+func (runner *watcherRunner) wait() error {
+	runner.wg.Wait()
+	runner.cancel()
+	return runner.err
+}
+
+func watchEthEvents(ctx context.Context, w *watcher.EventWatcher) error {
 	// Execute over the EventTransformerInitializer set using the watcher
 	logWithCommand.Info("executing event transformers")
 	var recheck constants.TransformerExecution
@@ -169,39 +489,121 @@ func watchEthEvents(w *watcher.EventWatcher, wg *syn.WaitGroup) {
 	} else {
 		recheck = constants.HeaderUnchecked
 	}
-	err := w.Execute(recheck)
+	err := w.Execute(ctx, recheck)
 	if err != nil {
-		logWithCommand.Fatalf("error executing event watcher: %s", err.Error())
+		return fmt.Errorf("error executing event watcher: %s", err.Error())
 	}
+	return nil
 }
 
-func watchEthStorage(w watcher.IStorageWatcher, wg *syn.WaitGroup) {
-	defer wg.Done()
+// addStorageTransformers names storage transformers with the plugin's exported names, so that diffs queued by a
+// transformer are retried by it whichever command runs the plugin
+func addStorageTransformers(sw *watcher.StorageWatcher, exporter Exporter, initializers []transformer.StorageTransformerInitializer) {
+	namedExporter, ok := exporter.(NamedExporter)
+	if !ok {
+		sw.AddTransformers(initializers)
+		return
+	}
+	_, storageNames, _, _ := namedExporter.ExportNames()
+	sw.AddNamedTransformers(storageNames, initializers)
+}
+
+// newStorageWatcher creates a storage watcher whose queue poisons diffs after the configured number of attempts
+func newStorageWatcher(storageFetcher fetcher.IStorageFetcher, db *postgres.DB) *watcher.StorageWatcher {
+	sw := watcher.NewStorageWatcher(storageFetcher, db)
+	queue := storage.NewStorageQueue(db)
+	queue.Policy.MaxAttempts = maxQueueAttempts
+	sw.Queue = queue
+	return sw
+}
+
+// newCsvStorageFetcher reads diffs from the configured csv file or directory, resuming from its checkpoint
+func newCsvStorageFetcher(db *postgres.DB) fetcher.CsvStorageFetcher {
+	var rejects fs.Appender
+	if rejectsPath != "" {
+		rejects = fs.FileAppender{Path: rejectsPath}
+	}
+	checkpoints := repositories.NewStorageDiffCheckpointRepository(db)
+	return fetcher.NewCsvStorageFetcher(storageDiffsPath, fs.NewSegmentFollower(storageDiffsPath), checkpoints, rejects)
+}
+
+func watchEthStorage(ctx context.Context, w watcher.IStorageWatcher) error {
 	// Execute over the StorageTransformerInitializer set using the storage watcher
 	logWithCommand.Info("executing storage transformers")
 	on := viper.GetBool("storageBackFill.on")
 	if on {
-		backFillStorage(w)
+		startStorageBackFill(w)
+	}
+	err := w.Execute(ctx, queueRecheckInterval, on)
+	if err != nil {
+		return fmt.Errorf("error executing storage watcher: %s", err.Error())
 	}
-	w.Execute(queueRecheckInterval, on)
+	return nil
 }
 
-func backFillStorage(w watcher.IStorageWatcher) {
-	rpcClient, _ := getClients()
+func startStorageBackFill(w watcher.IStorageWatcher) {
 	// find min deployment block
 	minDeploymentBlock := constants.GetMinDeploymentBlock()
-	stateDiffFetcher := fetcher.NewStateDiffFetcher(rpcClient)
-	backFiller := storage.NewStorageBackFiller(stateDiffFetcher, storage.DefaultMaxBatchSize)
+	backFiller := newStorageBackFiller(storage.DefaultMaxBatchSize)
 	go w.BackFill(minDeploymentBlock, backFiller)
 }
 
-func watchEthContract(w *watcher.ContractWatcher, wg *syn.WaitGroup) {
-	defer wg.Done()
+// newStorageBackFiller fetches state diffs from the node at client.ipcPath, which must support
+// statediff_stateDiffAt, or trace_replayBlockTransactions if the storage diffs source is parity
+func newStorageBackFiller(batchSize uint64) storage.BackFiller {
+	rpcClient, _ := getClients()
+	var stateDiffFetcher fetcher.StateDiffFetcher
+	if storageDiffsSource == "parity" {
+		stateDiffFetcher = fetcher.NewParityStateDiffFetcher(rpcClient)
+	} else {
+		stateDiffFetcher = fetcher.NewStateDiffFetcher(rpcClient)
+	}
+	return storage.NewStorageBackFiller(stateDiffFetcher, batchSize)
+}
+
+func watchEthContract(ctx context.Context, w *watcher.ContractWatcher) error {
 	// Execute over the ContractTransformerInitializer set using the contract watcher
 	logWithCommand.Info("executing contract_watcher transformers")
 	ticker := time.NewTicker(pollingInterval)
 	defer ticker.Stop()
-	for range ticker.C {
-		w.Execute()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.Execute(ctx)
+		}
+	}
+}
+
+// newSuperNodeWatcher constructs the exporter's super node transformers, each with the subscription in its config
+func newSuperNodeWatcher(db *postgres.DB, exporter Exporter, initializers []transformer.SuperNodeTransformerInitializer) watcher.SuperNodeWatcher {
+	namedExporter, ok := exporter.(NamedExporter)
+	if !ok {
+		logWithCommand.Fatal("plugin does not export transformer names, recompose it to execute super node transformers")
+	}
+	_, _, _, names := namedExporter.ExportNames()
+	if len(names) != len(initializers) {
+		logWithCommand.Fatal("plugin's super node transformer names do not match its initializers")
+	}
+	w := watcher.NewSuperNodeWatcher(db, getRPCClient())
+	for i, name := range names {
+		transformerConfig, configured := genConfig.Transformers[name]
+		if !configured {
+			logWithCommand.Warnf("super node transformer %s is not configured, skipping it", name)
+			continue
+		}
+		w.AddTransformer(name, initializers[i], transformerConfig.Subscription)
+	}
+	return w
+}
+
+func watchEthSuperNode(ctx context.Context, w *watcher.SuperNodeWatcher) error {
+	// Execute over the SuperNodeTransformerInitializer set using the super node watcher
+	logWithCommand.Info("executing super node transformers")
+	err := w.Execute(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing super node watcher: %s", err.Error())
 	}
+	return nil
 }
@@ -0,0 +1,124 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/utils"
+)
+
+var (
+	replayDiffID int64
+	replayAll    bool
+)
+
+// poisonedStorageCmd represents the poisonedStorage command
+var poisonedStorageCmd = &cobra.Command{
+	Use:   "poisonedStorage",
+	Short: "Inspects and replays storage diffs that exhausted their retries",
+	Long: `Lists the storage diffs that were moved to poisoned storage after failing
+to transform too many times, along with the error from their last attempt:
+
+./vulcanizedb poisonedStorage --config=<config.toml>
+
+Replayed diffs are moved back into the storage queue with their attempts reset,
+and are retried by a running execute process on its next queue recheck:
+
+./vulcanizedb poisonedStorage --config=<config.toml> --replay-diff-id=<id>
+./vulcanizedb poisonedStorage --config=<config.toml> --replay-all`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		poisonedStorage()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(poisonedStorageCmd)
+	poisonedStorageCmd.Flags().Int64Var(&replayDiffID, "replay-diff-id", 0, "id of a poisoned storage diff to move back into the queue")
+	poisonedStorageCmd.Flags().BoolVar(&replayAll, "replay-all", false, "move every poisoned storage diff back into the queue")
+}
+
+func poisonedStorage() {
+	db := utils.LoadPostgres(databaseConfig, core.Node{})
+	queue := storage.NewStorageQueue(&db)
+	switch {
+	case replayAll:
+		replayed := 0
+		for {
+			diffs, getErr := queue.GetPoisoned(storage.DefaultQueuePageSize, 0)
+			if getErr != nil {
+				logWithCommand.Fatalf("failed to get poisoned storage diffs: %s", getErr.Error())
+			}
+			if len(diffs) == 0 {
+				break
+			}
+			replayedIDs := make(map[int64]bool)
+			for _, diff := range diffs {
+				// Replay moves a diff back for every transformer it was poisoned for
+				if replayedIDs[diff.ID] {
+					continue
+				}
+				replayedIDs[diff.ID] = true
+				replayErr := queue.Replay(diff.ID)
+				if replayErr != nil {
+					logWithCommand.Fatalf("failed to replay storage diff %d: %s", diff.ID, replayErr.Error())
+				}
+				replayed++
+			}
+		}
+		logWithCommand.Infof("replayed %d poisoned storage diffs", replayed)
+	case replayDiffID != 0:
+		replayErr := queue.Replay(replayDiffID)
+		if replayErr != nil {
+			logWithCommand.Fatalf("failed to replay storage diff %d: %s", replayDiffID, replayErr.Error())
+		}
+		logWithCommand.Infof("replayed poisoned storage diff %d", replayDiffID)
+	default:
+		listPoisonedStorage(queue)
+	}
+}
+
+func listPoisonedStorage(queue storage.StorageQueue) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DIFF ID\tBLOCK HEIGHT\tHASHED ADDRESS\tSTORAGE KEY\tTRANSFORMER\tATTEMPTS\tPOISONED AT\tLAST ERROR")
+	for offset := 0; ; offset += storage.DefaultQueuePageSize {
+		diffs, getErr := queue.GetPoisoned(storage.DefaultQueuePageSize, offset)
+		if getErr != nil {
+			logWithCommand.Fatalf("failed to get poisoned storage diffs: %s", getErr.Error())
+		}
+		for _, diff := range diffs {
+			fmt.Fprintf(writer, "%d\t%d\t%s\t%s\t%s\t%d\t%s\t%s\n", diff.ID, diff.BlockHeight, diff.HashedAddress.Hex(),
+				diff.StorageKey.Hex(), diff.Transformer, diff.Attempts, diff.PoisonedAt.Format("2006-01-02 15:04:05"), diff.LastError)
+		}
+		if len(diffs) < storage.DefaultQueuePageSize {
+			break
+		}
+	}
+	flushErr := writer.Flush()
+	if flushErr != nil {
+		logWithCommand.Fatalf("failed to write poisoned storage diffs: %s", flushErr.Error())
+	}
+}
@@ -0,0 +1,175 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/query"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/utils"
+)
+
+var (
+	queryContract    string
+	queryStorageKey  string
+	queryName        string
+	queryBlockNumber int64
+	serveQueries     bool
+)
+
+// queryStorageCmd represents the queryStorage command
+var queryStorageCmd = &cobra.Command{
+	Use:   "queryStorage",
+	Short: "Queries historical contract storage values from storage_diff",
+	Long: `Prints the value of a contract's storage key as of a block:
+
+./vulcanizedb queryStorage --config=<config.toml> --contract=<address> --key=<slot> --block-number=<block>
+
+or every change to the key, if no block number is given:
+
+./vulcanizedb queryStorage --config=<config.toml> --contract=<address> --key=<slot>
+
+The key is the storage slot or its keccak hash. If exporter.name is configured, values of contracts
+with a storage transformer in the plugin are named and decoded with the transformer's storage keys lookup,
+e.g. balances[0xabc...]; other values are raw. Those values can also be queried by name instead of key:
+
+./vulcanizedb queryStorage --config=<config.toml> --contract=<address> --name='balances[0xabc...]'
+
+With --serve, the same queries are served as storage_valueAt, storage_history, storage_namedValueAt
+and storage_namedHistory JSON-RPC methods
+on the server.ipcPath and server.wsEndpoint configured for the super node.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		queryStorage(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryStorageCmd)
+	queryStorageCmd.Flags().StringVar(&queryContract, "contract", "", "address of the contract to query")
+	queryStorageCmd.Flags().StringVar(&queryStorageKey, "key", "", "storage slot, or its keccak hash, to query")
+	queryStorageCmd.Flags().StringVar(&queryName, "name", "", "name of the value to query as decoded by the keys lookup, e.g. balances[0xabc...], instead of --key")
+	queryStorageCmd.Flags().Int64VarP(&queryBlockNumber, "block-number", "b", 0, "block at which to query the value, prints every change if not set")
+	queryStorageCmd.Flags().BoolVar(&serveQueries, "serve", false, "serve storage queries over JSON-RPC instead of printing one")
+}
+
+func queryStorage(cmd *cobra.Command) {
+	db := utils.LoadPostgres(databaseConfig, core.Node{})
+	querier := query.NewQuerier(&db, loadKeysLookups(&db))
+	if serveQueries {
+		serveStorageQueries(querier)
+		return
+	}
+
+	if !common.IsHexAddress(queryContract) || (queryStorageKey == "") == (queryName == "") {
+		logWithCommand.Fatal("--contract and one of --key or --name are required")
+	}
+	contract := common.HexToAddress(queryContract)
+	key := common.HexToHash(queryStorageKey)
+	atBlock := cmd.Flags().Changed("block-number")
+	var result interface{}
+	var queryErr error
+	switch {
+	case queryName != "" && atBlock:
+		result, queryErr = querier.NamedValueAt(contract, queryName, queryBlockNumber)
+	case queryName != "":
+		result, queryErr = querier.NamedHistory(contract, queryName)
+	case atBlock:
+		result, queryErr = querier.ValueAt(contract, key, queryBlockNumber)
+	default:
+		result, queryErr = querier.History(contract, key)
+	}
+	if queryErr != nil {
+		logWithCommand.Fatalf("storage query failed: %s", queryErr.Error())
+	}
+	output, marshalErr := json.MarshalIndent(result, "", "  ")
+	if marshalErr != nil {
+		logWithCommand.Fatal(marshalErr)
+	}
+	fmt.Println(string(output))
+}
+
+// loadKeysLookups returns the keys lookups of the plugin's storage transformers, if a plugin is configured
+func loadKeysLookups(db *postgres.DB) map[common.Hash]storage.KeysLookup {
+	lookups := make(map[common.Hash]storage.KeysLookup)
+	if viper.GetString("exporter.name") == "" {
+		logWithCommand.Info("no plugin configured, storage values will not be decoded")
+		return lookups
+	}
+	prepConfig()
+	exporter := loadExporter()
+	_, ethStorageInitializers, _, _ := exporter.Export()
+	for _, initializer := range ethStorageInitializers {
+		storageTransformer, ok := initializer(db).(storage.Transformer)
+		if !ok {
+			continue
+		}
+		hashedAddresses, loadErr := storageTransformer.KeccakContractAddresses()
+		if loadErr != nil {
+			logWithCommand.Warnf("error loading addresses of storage transformer %s: %s",
+				storageTransformer.HashedAddress.Hex(), loadErr.Error())
+			continue
+		}
+		for _, hashedAddress := range hashedAddresses {
+			lookups[hashedAddress] = storageTransformer.StorageKeysLookup
+		}
+	}
+	return lookups
+}
+
+func serveStorageQueries(querier *query.Querier) {
+	ipcPath := viper.GetString("server.ipcPath")
+	if ipcPath == "" {
+		home, homeDirErr := os.UserHomeDir()
+		if homeDirErr != nil {
+			logWithCommand.Fatal(homeDirErr)
+		}
+		ipcPath = filepath.Join(home, ".vulcanize/vulcanize.ipc")
+	}
+	_, _, ipcErr := rpc.StartIPCEndpoint(ipcPath, query.APIs(querier))
+	if ipcErr != nil {
+		logWithCommand.Fatal(ipcErr)
+	}
+	wsEndpoint := viper.GetString("server.wsEndpoint")
+	if wsEndpoint == "" {
+		wsEndpoint = "127.0.0.1:8080"
+	}
+	_, _, wsErr := rpc.StartWSEndpoint(wsEndpoint, query.APIs(querier), []string{query.APIName}, nil, true)
+	if wsErr != nil {
+		logWithCommand.Fatal(wsErr)
+	}
+	logWithCommand.Infof("serving storage queries on %s and %s", ipcPath, wsEndpoint)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+}
@@ -38,20 +38,24 @@ import (
 
 var (
 	cfgFile              string
+	confirmationDepth    int64
 	databaseConfig       config.Database
 	genConfig            config.Plugin
 	subscriptionConfig   config.Subscription
 	ipc                  string
 	levelDbPath          string
 	queueRecheckInterval time.Duration
+	maxQueueAttempts     int
 	startingBlockNumber  int64
 	storageDiffsPath     string
+	rejectsPath          string
 	syncAll              bool
 	endingBlockNumber    int64
 	recheckHeadersArg    bool
 	subCommand           string
 	logWithCommand       log.Entry
 	storageDiffsSource   string
+	eventLogsSource      string
 )
 
 const (
@@ -84,7 +88,9 @@ func setViperConfigs() {
 	ipc = viper.GetString("client.ipcpath")
 	levelDbPath = viper.GetString("client.leveldbpath")
 	storageDiffsPath = viper.GetString("filesystem.storageDiffsPath")
+	rejectsPath = viper.GetString("filesystem.storageDiffsRejectsPath")
 	storageDiffsSource = viper.GetString("storageDiffs.source")
+	eventLogsSource = viper.GetString("eventLogs.source")
 	databaseConfig = config.Database{
 		Name:     viper.GetString("database.name"),
 		Hostname: viper.GetString("database.hostname"),
@@ -122,8 +128,9 @@ func init() {
 	rootCmd.PersistentFlags().String("database-password", "", "database password")
 	rootCmd.PersistentFlags().String("client-ipcPath", "", "location of geth.ipc file")
 	rootCmd.PersistentFlags().String("client-levelDbPath", "", "location of levelDb chaindata")
-	rootCmd.PersistentFlags().String("filesystem-storageDiffsPath", "", "location of storage diffs csv file")
-	rootCmd.PersistentFlags().String("storageDiffs-source", "csv", "where to get the state diffs: csv or geth")
+	rootCmd.PersistentFlags().String("filesystem-storageDiffsPath", "", "location of storage diffs csv file, or directory of csv segments")
+	rootCmd.PersistentFlags().String("filesystem-storageDiffsRejectsPath", "", "file to append storage diffs csv rows that can't be parsed to")
+	rootCmd.PersistentFlags().String("storageDiffs-source", "csv", "where to get the state diffs: csv, geth, super_node or parity")
 	rootCmd.PersistentFlags().String("exporter-name", "exporter", "name of exporter plugin")
 	rootCmd.PersistentFlags().String("log-level", log.InfoLevel.String(), "Log level (trace, debug, info, warn, error, fatal, panic")
 
@@ -135,6 +142,7 @@ func init() {
 	viper.BindPFlag("client.ipcPath", rootCmd.PersistentFlags().Lookup("client-ipcPath"))
 	viper.BindPFlag("client.levelDbPath", rootCmd.PersistentFlags().Lookup("client-levelDbPath"))
 	viper.BindPFlag("filesystem.storageDiffsPath", rootCmd.PersistentFlags().Lookup("filesystem-storageDiffsPath"))
+	viper.BindPFlag("filesystem.storageDiffsRejectsPath", rootCmd.PersistentFlags().Lookup("filesystem-storageDiffsRejectsPath"))
 	viper.BindPFlag("storageDiffs.source", rootCmd.PersistentFlags().Lookup("storageDiffs-source"))
 	viper.BindPFlag("exporter.fileName", rootCmd.PersistentFlags().Lookup("exporter-name"))
 	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
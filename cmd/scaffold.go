@@ -0,0 +1,120 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/scaffold"
+)
+
+var (
+	scaffoldAbiPath       string
+	scaffoldName          string
+	scaffoldEvents        []string
+	scaffoldVariables     []string
+	scaffoldAddresses     []string
+	scaffoldStartingBlock int64
+	scaffoldRepository    string
+	scaffoldRepositoryDir string
+	scaffoldOutputPath    string
+	scaffoldMigrationPath string
+)
+
+// scaffoldCmd represents the scaffold command
+var scaffoldCmd = &cobra.Command{
+	Use:   "scaffold",
+	Short: "Generates transformer packages for a contract's events and storage variables",
+	Long: `Generates a transformer package in a transformer repository, using the
+libraries/shared/factories/event framework, for each of the given events of a contract:
+
+./vulcanizedb scaffold --name=token --abi=token.json --events=Transfer,Approval \
+    --addresses=0x... --starting-block=8000000 \
+    --repository=github.com/account/repo --repository-dir=$GOPATH/src/github.com/account/repo
+
+Given variables, it also generates a libraries/shared/factories/storage transformer for
+the contract's value state variables. Each is assumed to take up its own slot in the order
+given, unless the slot is given with the name:
+
+./vulcanizedb scaffold --name=token --abi=token.json --variables=totalSupply,owner:3 \
+    --addresses=0x... --repository=github.com/account/repo
+
+Each package has a config, converter or keys loader, repository, initializer and a
+Ginkgo test suite; a migration creating a table for each event and variable, in a schema
+named after the contract, is added to the repository's migrations. The exporter config for
+compose is printed, ready to add to a config file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		scaffoldTransformers()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scaffoldCmd)
+	scaffoldCmd.Flags().StringVar(&scaffoldAbiPath, "abi", "", "path to the contract's abi json file")
+	scaffoldCmd.Flags().StringVar(&scaffoldName, "name", "", "name of the contract, used as the schema of the scaffolded tables")
+	scaffoldCmd.Flags().StringSliceVar(&scaffoldEvents, "events", nil, "events to scaffold event transformers for")
+	scaffoldCmd.Flags().StringSliceVar(&scaffoldVariables, "variables", nil, "state variables, as name or name:slot, to scaffold a storage transformer for")
+	scaffoldCmd.Flags().StringSliceVar(&scaffoldAddresses, "addresses", nil, "addresses of the contract")
+	scaffoldCmd.Flags().Int64Var(&scaffoldStartingBlock, "starting-block", 0, "block to start watching events from")
+	scaffoldCmd.Flags().StringVar(&scaffoldRepository, "repository", "", "import path of the transformer repository")
+	scaffoldCmd.Flags().StringVar(&scaffoldRepositoryDir, "repository-dir", ".", "directory of the transformer repository")
+	scaffoldCmd.Flags().StringVar(&scaffoldOutputPath, "output", "transformers", "directory to write the transformer packages to, relative to the repository")
+	scaffoldCmd.Flags().StringVar(&scaffoldMigrationPath, "migrations", "db/migrations", "migration directory of the repository, relative to the repository")
+}
+
+func scaffoldTransformers() {
+	if scaffoldAbiPath == "" {
+		logWithCommand.Fatal("scaffold needs the contract abi, given with --abi")
+	}
+	contractAbi, readErr := eth.ReadAbiFile(scaffoldAbiPath)
+	if readErr != nil {
+		logWithCommand.Fatalf("failed to read abi: %s", readErr.Error())
+	}
+	variables := make([]scaffold.Variable, 0, len(scaffoldVariables))
+	for _, str := range scaffoldVariables {
+		variable, parseErr := scaffold.ParseVariable(str)
+		if parseErr != nil {
+			logWithCommand.Fatal(parseErr)
+		}
+		variables = append(variables, variable)
+	}
+	result, err := scaffold.Generate(scaffold.Config{
+		Name:          scaffoldName,
+		Abi:           contractAbi,
+		Events:        scaffoldEvents,
+		Variables:     variables,
+		Addresses:     scaffoldAddresses,
+		StartingBlock: scaffoldStartingBlock,
+		Repository:    scaffoldRepository,
+		RepositoryDir: scaffoldRepositoryDir,
+		OutputPath:    scaffoldOutputPath,
+		MigrationPath: scaffoldMigrationPath,
+	})
+	if err != nil {
+		logWithCommand.Fatalf("failed to scaffold transformers: %s", err.Error())
+	}
+	for _, file := range result.Files {
+		logWithCommand.Info("wrote ", file)
+	}
+	fmt.Print(result.Toml)
+}
@@ -165,65 +165,75 @@ func streamSubscribe() {
 
 func configureSubscription() {
 	logWithCommand.Info("loading subscription config")
-	subscriptionConfig = config.Subscription{
+	subscriptionConfig = readSubscription("subscription")
+}
+
+// readSubscription reads the subscription filters in the config table at key
+func readSubscription(key string) config.Subscription {
+	return config.Subscription{
 		// Below default to false, which means we do not backfill by default
-		BackFill:     viper.GetBool("subscription.backfill"),
-		BackFillOnly: viper.GetBool("subscription.backfillOnly"),
+		BackFill:     viper.GetBool(key + ".backfill"),
+		BackFillOnly: viper.GetBool(key + ".backfillOnly"),
 
 		// Below default to 0
 		// 0 start means we start at the beginning and 0 end means we continue indefinitely
-		StartingBlock: big.NewInt(viper.GetInt64("subscription.startingBlock")),
-		EndingBlock:   big.NewInt(viper.GetInt64("subscription.endingBlock")),
+		StartingBlock: big.NewInt(viper.GetInt64(key + ".startingBlock")),
+		EndingBlock:   big.NewInt(viper.GetInt64(key + ".endingBlock")),
 
 		// Below default to false, which means we get all headers by default
 		HeaderFilter: config.HeaderFilter{
-			Off:    viper.GetBool("subscription.headerFilter.off"),
-			Uncles: viper.GetBool("subscription.headerFilter.uncles"),
+			Off:    viper.GetBool(key + ".headerFilter.off"),
+			Uncles: viper.GetBool(key + ".headerFilter.uncles"),
 		},
 
 		// Below defaults to false and two slices of length 0
 		// Which means we get all transactions by default
 		TrxFilter: config.TrxFilter{
-			Off: viper.GetBool("subscription.trxFilter.off"),
-			Src: viper.GetStringSlice("subscription.trxFilter.src"),
-			Dst: viper.GetStringSlice("subscription.trxFilter.dst"),
+			Off: viper.GetBool(key + ".trxFilter.off"),
+			Src: viper.GetStringSlice(key + ".trxFilter.src"),
+			Dst: viper.GetStringSlice(key + ".trxFilter.dst"),
 		},
 
 		// Below defaults to false and one slice of length 0
 		// Which means we get all receipts by default
 		ReceiptFilter: config.ReceiptFilter{
-			Off:       viper.GetBool("subscription.receiptFilter.off"),
-			Contracts: viper.GetStringSlice("subscription.receiptFilter.contracts"),
-			Topic0s:   viper.GetStringSlice("subscription.receiptFilter.topic0s"),
+			Off:       viper.GetBool(key + ".receiptFilter.off"),
+			Contracts: viper.GetStringSlice(key + ".receiptFilter.contracts"),
+			Topic0s:   viper.GetStringSlice(key + ".receiptFilter.topic0s"),
 		},
 
 		// Below defaults to two false, and a slice of length 0
 		// Which means we get all state leafs by default, but no intermediate nodes
 		StateFilter: config.StateFilter{
-			Off:               viper.GetBool("subscription.stateFilter.off"),
-			IntermediateNodes: viper.GetBool("subscription.stateFilter.intermediateNodes"),
-			Addresses:         viper.GetStringSlice("subscription.stateFilter.addresses"),
+			Off:               viper.GetBool(key + ".stateFilter.off"),
+			IntermediateNodes: viper.GetBool(key + ".stateFilter.intermediateNodes"),
+			Addresses:         viper.GetStringSlice(key + ".stateFilter.addresses"),
 		},
 
 		// Below defaults to two false, and two slices of length 0
 		// Which means we get all storage leafs by default, but no intermediate nodes
 		StorageFilter: config.StorageFilter{
-			Off:               viper.GetBool("subscription.storageFilter.off"),
-			IntermediateNodes: viper.GetBool("subscription.storageFilter.intermediateNodes"),
-			Addresses:         viper.GetStringSlice("subscription.storageFilter.addresses"),
-			StorageKeys:       viper.GetStringSlice("subscription.storageFilter.storageKeys"),
+			Off:               viper.GetBool(key + ".storageFilter.off"),
+			IntermediateNodes: viper.GetBool(key + ".storageFilter.intermediateNodes"),
+			Addresses:         viper.GetStringSlice(key + ".storageFilter.addresses"),
+			StorageKeys:       viper.GetStringSlice(key + ".storageFilter.storageKeys"),
 		},
 	}
 }
 
 func getRPCClient() core.RPCClient {
-	vulcPath := viper.GetString("subscription.path")
-	if vulcPath == "" {
-		vulcPath = "ws://127.0.0.1:8080" // default to and try the default ws url if no path is provided
-	}
+	vulcPath := superNodePath()
 	rawRPCClient, err := rpc.Dial(vulcPath)
 	if err != nil {
 		logWithCommand.Fatal(err)
 	}
 	return client.NewRPCClient(rawRPCClient, vulcPath)
 }
+
+func superNodePath() string {
+	vulcPath := viper.GetString("subscription.path")
+	if vulcPath == "" {
+		vulcPath = "ws://127.0.0.1:8080" // default to and try the default ws url if no path is provided
+	}
+	return vulcPath
+}
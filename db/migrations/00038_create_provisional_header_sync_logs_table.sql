@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- log_id and header_id deliberately have no foreign keys so that a record survives
+-- its log being cascade deleted by a reorg, allowing the transformer to revert it
+CREATE TABLE public.provisional_header_sync_logs
+(
+    id               SERIAL PRIMARY KEY,
+    log_id           INTEGER     NOT NULL,
+    header_id        INTEGER     NOT NULL,
+    transformer_name VARCHAR     NOT NULL,
+    block_number     BIGINT      NOT NULL,
+    raw              JSONB       NOT NULL,
+    UNIQUE (log_id, transformer_name)
+);
+
+CREATE INDEX provisional_header_sync_logs_block_number ON public.provisional_header_sync_logs (block_number);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX public.provisional_header_sync_logs_block_number;
+DROP TABLE public.provisional_header_sync_logs;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE public.queued_storage
+    ADD COLUMN attempts        INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN last_error      TEXT,
+    ADD COLUMN next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX queued_storage_next_attempt_at ON public.queued_storage (next_attempt_at);
+
+CREATE TABLE public.poisoned_storage
+(
+    id          SERIAL PRIMARY KEY,
+    diff_id     BIGINT UNIQUE NOT NULL REFERENCES public.storage_diff (id),
+    attempts    INTEGER       NOT NULL,
+    last_error  TEXT,
+    poisoned_at TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE public.poisoned_storage;
+DROP INDEX public.queued_storage_next_attempt_at;
+ALTER TABLE public.queued_storage
+    DROP COLUMN attempts,
+    DROP COLUMN last_error,
+    DROP COLUMN next_attempt_at;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Flags diffs whose block was removed by a reorg, after the rows derived from them have been reverted
+ALTER TABLE public.storage_diff
+    ADD COLUMN non_canonical BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE public.storage_diff
+    DROP COLUMN non_canonical;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Records each bin of blocks whose storage diffs have been back-filled for a contract, so back-fill can resume
+CREATE TABLE public.storage_backfill_progress
+(
+    id             SERIAL PRIMARY KEY,
+    hashed_address BYTEA       NOT NULL,
+    starting_block BIGINT      NOT NULL,
+    ending_block   BIGINT      NOT NULL,
+    completed_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (hashed_address, starting_block, ending_block)
+);
+
+-- +goose Down
+DROP TABLE public.storage_backfill_progress;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Records how far each CSV storage diff source has been read, so reading can resume after a restart
+CREATE TABLE public.storage_diff_checkpoints
+(
+    id          SERIAL PRIMARY KEY,
+    source      TEXT        NOT NULL UNIQUE,
+    segment     TEXT        NOT NULL,
+    byte_offset BIGINT      NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE public.storage_diff_checkpoints;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE public.queued_storage
+    ADD COLUMN transformer TEXT NOT NULL DEFAULT '',
+    DROP CONSTRAINT queued_storage_diff_id_key,
+    ADD CONSTRAINT queued_storage_diff_id_transformer_key UNIQUE (diff_id, transformer);
+
+ALTER TABLE public.poisoned_storage
+    ADD COLUMN transformer TEXT NOT NULL DEFAULT '',
+    DROP CONSTRAINT poisoned_storage_diff_id_key,
+    ADD CONSTRAINT poisoned_storage_diff_id_transformer_key UNIQUE (diff_id, transformer);
+
+-- +goose Down
+DELETE FROM public.poisoned_storage a USING public.poisoned_storage b
+WHERE a.diff_id = b.diff_id
+  AND a.id > b.id;
+ALTER TABLE public.poisoned_storage
+    DROP CONSTRAINT poisoned_storage_diff_id_transformer_key,
+    DROP COLUMN transformer,
+    ADD CONSTRAINT poisoned_storage_diff_id_key UNIQUE (diff_id);
+
+DELETE FROM public.queued_storage a USING public.queued_storage b
+WHERE a.diff_id = b.diff_id
+  AND a.id > b.id;
+ALTER TABLE public.queued_storage
+    DROP CONSTRAINT queued_storage_diff_id_transformer_key,
+    DROP COLUMN transformer,
+    ADD CONSTRAINT queued_storage_diff_id_key UNIQUE (diff_id);
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE public.receipt_cids
+    ADD COLUMN tx_index INTEGER,
+    ADD COLUMN log_index INTEGER;
+
+-- +goose Down
+ALTER TABLE public.receipt_cids
+    DROP COLUMN log_index,
+    DROP COLUMN tx_index;
@@ -549,18 +549,90 @@ CREATE SEQUENCE public.nodes_id_seq
 
 --
 -- Name: nodes_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+-- Name: poisoned_storage; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.poisoned_storage (
+    id integer NOT NULL,
+    diff_id bigint NOT NULL,
+    attempts integer NOT NULL,
+    last_error text,
+    poisoned_at timestamp with time zone DEFAULT now() NOT NULL,
+    transformer text DEFAULT ''::text NOT NULL
+);
+
+
+--
+-- Name: poisoned_storage_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.poisoned_storage_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: poisoned_storage_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.poisoned_storage_id_seq OWNED BY public.poisoned_storage.id;
+
+
 --
 
 ALTER SEQUENCE public.nodes_id_seq OWNED BY public.eth_nodes.id;
 
 
+--
+-- Name: provisional_header_sync_logs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.provisional_header_sync_logs (
+    id integer NOT NULL,
+    log_id integer NOT NULL,
+    header_id integer NOT NULL,
+    transformer_name character varying NOT NULL,
+    block_number bigint NOT NULL,
+    raw jsonb NOT NULL
+);
+
+
+--
+-- Name: provisional_header_sync_logs_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.provisional_header_sync_logs_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: provisional_header_sync_logs_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.provisional_header_sync_logs_id_seq OWNED BY public.provisional_header_sync_logs.id;
+
+
 --
 -- Name: queued_storage; Type: TABLE; Schema: public; Owner: -
 --
 
 CREATE TABLE public.queued_storage (
     id integer NOT NULL,
-    diff_id bigint NOT NULL
+    diff_id bigint NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    transformer text DEFAULT ''::text NOT NULL
 );
 
 
@@ -593,7 +665,9 @@ CREATE TABLE public.receipt_cids (
     tx_id integer NOT NULL,
     cid text NOT NULL,
     contract character varying(66),
-    topic0s character varying(66)[]
+    topic0s character varying(66)[],
+    tx_index integer,
+    log_index integer
 );
 
 
@@ -650,6 +724,39 @@ CREATE SEQUENCE public.state_cids_id_seq
 ALTER SEQUENCE public.state_cids_id_seq OWNED BY public.state_cids.id;
 
 
+--
+-- Name: storage_backfill_progress; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_backfill_progress (
+    id integer NOT NULL,
+    hashed_address bytea NOT NULL,
+    starting_block bigint NOT NULL,
+    ending_block bigint NOT NULL,
+    completed_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: storage_backfill_progress_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.storage_backfill_progress_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: storage_backfill_progress_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.storage_backfill_progress_id_seq OWNED BY public.storage_backfill_progress.id;
+
+
 --
 -- Name: storage_cids; Type: TABLE; Schema: public; Owner: -
 --
@@ -693,10 +800,44 @@ CREATE TABLE public.storage_diff (
     block_hash bytea,
     hashed_address bytea,
     storage_key bytea,
-    storage_value bytea
+    storage_value bytea,
+    non_canonical boolean DEFAULT false NOT NULL
 );
 
 
+--
+-- Name: storage_diff_checkpoints; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_diff_checkpoints (
+    id integer NOT NULL,
+    source text NOT NULL,
+    segment text NOT NULL,
+    byte_offset bigint NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: storage_diff_checkpoints_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.storage_diff_checkpoints_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: storage_diff_checkpoints_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.storage_diff_checkpoints_id_seq OWNED BY public.storage_diff_checkpoints.id;
+
+
 --
 -- Name: storage_diff_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
@@ -966,11 +1107,25 @@ ALTER TABLE ONLY public.headers ALTER COLUMN id SET DEFAULT nextval('public.head
 
 --
 -- Name: log_filters id; Type: DEFAULT; Schema: public; Owner: -
+--
+-- Name: poisoned_storage id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.poisoned_storage ALTER COLUMN id SET DEFAULT nextval('public.poisoned_storage_id_seq'::regclass);
+
+
 --
 
 ALTER TABLE ONLY public.log_filters ALTER COLUMN id SET DEFAULT nextval('public.log_filters_id_seq'::regclass);
 
 
+--
+-- Name: provisional_header_sync_logs id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.provisional_header_sync_logs ALTER COLUMN id SET DEFAULT nextval('public.provisional_header_sync_logs_id_seq'::regclass);
+
+
 --
 -- Name: queued_storage id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -992,6 +1147,13 @@ ALTER TABLE ONLY public.receipt_cids ALTER COLUMN id SET DEFAULT nextval('public
 ALTER TABLE ONLY public.state_cids ALTER COLUMN id SET DEFAULT nextval('public.state_cids_id_seq'::regclass);
 
 
+--
+-- Name: storage_backfill_progress id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_progress ALTER COLUMN id SET DEFAULT nextval('public.storage_backfill_progress_id_seq'::regclass);
+
+
 --
 -- Name: storage_cids id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -1006,6 +1168,13 @@ ALTER TABLE ONLY public.storage_cids ALTER COLUMN id SET DEFAULT nextval('public
 ALTER TABLE ONLY public.storage_diff ALTER COLUMN id SET DEFAULT nextval('public.storage_diff_id_seq'::regclass);
 
 
+--
+-- Name: storage_diff_checkpoints id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_diff_checkpoints ALTER COLUMN id SET DEFAULT nextval('public.storage_diff_checkpoints_id_seq'::regclass);
+
+
 --
 -- Name: transaction_cids id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -1220,6 +1389,22 @@ ALTER TABLE ONLY public.log_filters
 
 --
 -- Name: eth_nodes nodes_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+-- Name: poisoned_storage poisoned_storage_diff_id_transformer_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.poisoned_storage
+    ADD CONSTRAINT poisoned_storage_diff_id_transformer_key UNIQUE (diff_id, transformer);
+
+
+--
+-- Name: poisoned_storage poisoned_storage_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.poisoned_storage
+    ADD CONSTRAINT poisoned_storage_pkey PRIMARY KEY (id);
+
+
 --
 
 ALTER TABLE ONLY public.eth_nodes
@@ -1227,11 +1412,27 @@ ALTER TABLE ONLY public.eth_nodes
 
 
 --
--- Name: queued_storage queued_storage_diff_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: provisional_header_sync_logs provisional_header_sync_logs_log_id_transformer_name_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.provisional_header_sync_logs
+    ADD CONSTRAINT provisional_header_sync_logs_log_id_transformer_name_key UNIQUE (log_id, transformer_name);
+
+
+--
+-- Name: provisional_header_sync_logs provisional_header_sync_logs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.provisional_header_sync_logs
+    ADD CONSTRAINT provisional_header_sync_logs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: queued_storage queued_storage_diff_id_transformer_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
 ALTER TABLE ONLY public.queued_storage
-    ADD CONSTRAINT queued_storage_diff_id_key UNIQUE (diff_id);
+    ADD CONSTRAINT queued_storage_diff_id_transformer_key UNIQUE (diff_id, transformer);
 
 
 --
@@ -1266,6 +1467,22 @@ ALTER TABLE ONLY public.state_cids
     ADD CONSTRAINT state_cids_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: storage_backfill_progress storage_backfill_progress_hashed_address_starting_block_en_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_progress
+    ADD CONSTRAINT storage_backfill_progress_hashed_address_starting_block_en_key UNIQUE (hashed_address, starting_block, ending_block);
+
+
+--
+-- Name: storage_backfill_progress storage_backfill_progress_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_progress
+    ADD CONSTRAINT storage_backfill_progress_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: storage_cids storage_cids_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -1298,6 +1515,22 @@ ALTER TABLE ONLY public.storage_diff
     ADD CONSTRAINT storage_diff_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: storage_diff_checkpoints storage_diff_checkpoints_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_diff_checkpoints
+    ADD CONSTRAINT storage_diff_checkpoints_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: storage_diff_checkpoints storage_diff_checkpoints_source_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_diff_checkpoints
+    ADD CONSTRAINT storage_diff_checkpoints_source_key UNIQUE (source);
+
+
 --
 -- Name: transaction_cids transaction_cids_header_id_tx_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -1417,6 +1650,20 @@ CREATE INDEX node_id_index ON public.eth_blocks USING btree (eth_node_id);
 CREATE INDEX number_index ON public.eth_blocks USING btree (number);
 
 
+--
+-- Name: provisional_header_sync_logs_block_number; Type: INDEX; Schema: public; Owner: -
+--
+-- Name: queued_storage_next_attempt_at; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX queued_storage_next_attempt_at ON public.queued_storage USING btree (next_attempt_at);
+
+
+--
+
+CREATE INDEX provisional_header_sync_logs_block_number ON public.provisional_header_sync_logs USING btree (block_number);
+
+
 --
 -- Name: tx_from_index; Type: INDEX; Schema: public; Owner: -
 --
@@ -1521,6 +1768,14 @@ ALTER TABLE ONLY public.headers
 
 --
 -- Name: eth_blocks node_fk; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+-- Name: poisoned_storage poisoned_storage_diff_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.poisoned_storage
+    ADD CONSTRAINT poisoned_storage_diff_id_fkey FOREIGN KEY (diff_id) REFERENCES public.storage_diff(id);
+
+
 --
 
 ALTER TABLE ONLY public.eth_blocks
@@ -46,7 +46,7 @@ var _ = Describe("Poller", func() {
 	Describe("Full sync mode", func() {
 		BeforeEach(func() {
 			db, bc = test_helpers.SetupDBandBC()
-			contractPoller = poller.NewPoller(bc, db, types.FullSync)
+			contractPoller = poller.NewPoller(bc, db, types.FullSync, poller.Settings{})
 		})
 
 		Describe("PollContract", func() {
@@ -133,7 +133,7 @@ var _ = Describe("Poller", func() {
 	Describe("Header sync mode", func() {
 		BeforeEach(func() {
 			db, bc = test_helpers.SetupDBandBC()
-			contractPoller = poller.NewPoller(bc, db, types.HeaderSync)
+			contractPoller = poller.NewPoller(bc, db, types.HeaderSync, poller.Settings{})
 		})
 
 		Describe("PollContract", func() {
@@ -225,7 +225,7 @@ var _ = Describe("Poller", func() {
 
 				test_helpers.TearDown(db)
 				db, bc = test_helpers.SetupDBandBC()
-				contractPoller = poller.NewPoller(bc, db, types.HeaderSync)
+				contractPoller = poller.NewPoller(bc, db, types.HeaderSync, poller.Settings{})
 
 				con.Piping = true
 				err = contractPoller.PollContract(*con, 6921968)
@@ -26,6 +26,7 @@ import (
 
 type Chunker interface {
 	AddConfig(transformerConfig transformer.EventTransformerConfig)
+	RemoveConfig(transformerName string)
 	ChunkLogs(logs []core.HeaderSyncLog) map[string][]core.HeaderSyncLog
 }
 
@@ -52,6 +53,24 @@ func (chunker *LogChunker) AddConfig(transformerConfig transformer.EventTransfor
 	}
 }
 
+// Stops considering logs for the named transformer.
+func (chunker *LogChunker) RemoveConfig(transformerName string) {
+	for address, names := range chunker.AddressToNames {
+		var remainingNames []string
+		for _, name := range names {
+			if name != transformerName {
+				remainingNames = append(remainingNames, name)
+			}
+		}
+		if len(remainingNames) > 0 {
+			chunker.AddressToNames[address] = remainingNames
+		} else {
+			delete(chunker.AddressToNames, address)
+		}
+	}
+	delete(chunker.NameToTopic0, transformerName)
+}
+
 // Goes through a slice of logs, associating relevant logs (matching addresses and topic) with transformers
 func (chunker *LogChunker) ChunkLogs(logs []core.HeaderSyncLog) map[string][]core.HeaderSyncLog {
 	chunks := map[string][]core.HeaderSyncLog{}
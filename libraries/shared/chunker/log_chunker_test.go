@@ -97,6 +97,21 @@ var _ = Describe("Log chunker", func() {
 		})
 	})
 
+	Describe("RemoveConfig", func() {
+		It("removes the transformer from the lookup maps", func() {
+			chunker.RemoveConfig("TransformerA")
+
+			Expect(chunker.AddressToNames).To(Equal(map[string][]string{
+				"0x00000000000000000000000000000000000000a2": {"TransformerC"},
+				"0x00000000000000000000000000000000000000b1": {"TransformerB"},
+			}))
+			Expect(chunker.NameToTopic0).To(Equal(map[string]common.Hash{
+				"TransformerB": common.HexToHash("0xB"),
+				"TransformerC": common.HexToHash("0xC"),
+			}))
+		})
+	})
+
 	Describe("ChunkLogs", func() {
 		It("only associates logs with relevant topic0 and address to transformers", func() {
 			logs := []core.HeaderSyncLog{log1, log2, log3, log4, log5}
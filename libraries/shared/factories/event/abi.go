@@ -0,0 +1,230 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+)
+
+var validIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// AbiTransformerConfig configures a set of event transformers whose tables and models are derived from a contract ABI,
+// so that events can be transformed without writing a converter, repository or migration
+type AbiTransformerConfig struct {
+	TransformerName     string
+	Schema              string // Defaults to the transformer name
+	ContractAddresses   []string
+	ContractAbi         string
+	Events              []string
+	StartingBlockNumber int64
+	EndingBlockNumber   int64
+}
+
+// abiEventTable describes the table an ABI event is persisted to
+type abiEventTable struct {
+	event   abi.Event
+	schema  SchemaName
+	table   TableName
+	columns []abiColumn
+}
+
+type abiColumn struct {
+	name     ColumnName
+	argument string
+	pgType   string
+}
+
+// NewAbiTransformers creates an event transformer for each of the configured events
+func NewAbiTransformers(config AbiTransformerConfig) ([]Transformer, error) {
+	parsedAbi, tables, err := abiEventTables(config)
+	if err != nil {
+		return nil, err
+	}
+	transformers := make([]Transformer, 0, len(tables))
+	for _, table := range tables {
+		transformers = append(transformers, Transformer{
+			Config: transformer.EventTransformerConfig{
+				TransformerName:     fmt.Sprintf("%s_%s", config.TransformerName, table.table),
+				ContractAddresses:   config.ContractAddresses,
+				ContractAbi:         config.ContractAbi,
+				Topic:               table.event.Id().Hex(),
+				StartingBlockNumber: config.StartingBlockNumber,
+				EndingBlockNumber:   config.EndingBlockNumber,
+			},
+			Converter: &AbiConverter{
+				Abi:     parsedAbi,
+				Event:   table.event,
+				Schema:  table.schema,
+				Table:   table.table,
+				columns: table.columns,
+			},
+			Repository: &AbiRepository{},
+		})
+	}
+	return transformers, nil
+}
+
+// GenerateAbiMigration creates the SQL for the schema and tables of the configured events
+// The statements are idempotent, so they can be run each time the transformers are loaded
+func GenerateAbiMigration(config AbiTransformerConfig) (string, error) {
+	_, tables, err := abiEventTables(config)
+	if err != nil {
+		return "", err
+	}
+	var migration strings.Builder
+	fmt.Fprintf(&migration, "CREATE SCHEMA IF NOT EXISTS %s;\n", abiSchema(config))
+	for _, table := range tables {
+		tableID := fmt.Sprintf("%s.%s", table.schema, table.table)
+		fmt.Fprintf(&migration, "\nCREATE TABLE IF NOT EXISTS %s\n(\n", tableID)
+		migration.WriteString("    id        SERIAL PRIMARY KEY,\n")
+		migration.WriteString("    header_id INTEGER NOT NULL REFERENCES public.headers (id) ON DELETE CASCADE,\n")
+		migration.WriteString("    log_id    INTEGER NOT NULL REFERENCES public.header_sync_logs (id) ON DELETE CASCADE,\n")
+		for _, column := range table.columns {
+			fmt.Fprintf(&migration, "    %s %s,\n", column.name, column.pgType)
+		}
+		migration.WriteString("    UNIQUE (header_id, log_id)\n);\n")
+		fmt.Fprintf(&migration, "\nCREATE INDEX IF NOT EXISTS %s_header_index ON %s (header_id);\n", table.table, tableID)
+		fmt.Fprintf(&migration, "CREATE INDEX IF NOT EXISTS %s_log_index ON %s (log_id);\n", table.table, tableID)
+	}
+	return migration.String(), nil
+}
+
+// RunAbiMigration creates the schema and tables of the configured events if they do not already exist
+func RunAbiMigration(db *postgres.DB, config AbiTransformerConfig) error {
+	migration, generateErr := GenerateAbiMigration(config)
+	if generateErr != nil {
+		return generateErr
+	}
+	tx, txErr := db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	_, execErr := tx.Exec(migration)
+	if execErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			return fmt.Errorf("failed to rollback migration for %s: %s", config.TransformerName, rollbackErr.Error())
+		}
+		return fmt.Errorf("migration for %s failed: %s", config.TransformerName, execErr.Error())
+	}
+	return tx.Commit()
+}
+
+func abiEventTables(config AbiTransformerConfig) (abi.ABI, []abiEventTable, error) {
+	if len(config.Events) < 1 {
+		return abi.ABI{}, nil, fmt.Errorf("abi transformer %s is not configured with any events", config.TransformerName)
+	}
+	schema := abiSchema(config)
+	if !validIdentifier.MatchString(schema) {
+		return abi.ABI{}, nil, fmt.Errorf("abi transformer %s has an invalid schema name: %s", config.TransformerName, schema)
+	}
+	parsedAbi, parseErr := eth.ParseAbi(config.ContractAbi)
+	if parseErr != nil {
+		return abi.ABI{}, nil, parseErr
+	}
+
+	tables := make([]abiEventTable, 0, len(config.Events))
+	for _, eventName := range config.Events {
+		event, ok := parsedAbi.Events[eventName]
+		if !ok {
+			return abi.ABI{}, nil, fmt.Errorf("event %s not found in abi for transformer %s", eventName, config.TransformerName)
+		}
+		// Unnamed inputs cannot be unpacked into a map, so they are named by position
+		for i := range event.Inputs {
+			if event.Inputs[i].Name == "" {
+				event.Inputs[i].Name = fmt.Sprintf("arg%d", i)
+			}
+		}
+		parsedAbi.Events[eventName] = event
+
+		table := TableName(strings.ToLower(event.Name) + "_event")
+		if !validIdentifier.MatchString(string(table)) {
+			return abi.ABI{}, nil, fmt.Errorf("event %s can't be used as a table name", event.Name)
+		}
+		columns := make([]abiColumn, 0, len(event.Inputs))
+		columnInputs := make(map[ColumnName]string, len(event.Inputs))
+		for _, input := range event.Inputs {
+			// Trailing underscores keep argument names from clashing with reserved words and fixed columns
+			column := ColumnName(strings.ToLower(input.Name) + "_")
+			if !validIdentifier.MatchString(string(column)) {
+				return abi.ABI{}, nil, fmt.Errorf("input %s of event %s can't be used as a column name", input.Name, event.Name)
+			}
+			if other, ok := columnInputs[column]; ok {
+				return abi.ABI{}, nil, fmt.Errorf("inputs %s and %s of event %s would both be stored in column %s",
+					other, input.Name, event.Name, column)
+			}
+			columnInputs[column] = input.Name
+			columns = append(columns, abiColumn{
+				name:     column,
+				argument: input.Name,
+				pgType:   abiPgType(input),
+			})
+		}
+		tables = append(tables, abiEventTable{
+			event:   event,
+			schema:  SchemaName(schema),
+			table:   table,
+			columns: columns,
+		})
+	}
+	return parsedAbi, tables, nil
+}
+
+func abiSchema(config AbiTransformerConfig) string {
+	if config.Schema == "" {
+		return strings.ToLower(config.TransformerName)
+	}
+	return strings.ToLower(config.Schema)
+}
+
+// Postgres type for an event input; indexed dynamic values are only available as the hash stored in their topic
+func abiPgType(input abi.Argument) string {
+	if input.Indexed && HashedWhenIndexed(input.Type) {
+		return "CHARACTER VARYING(66)"
+	}
+	switch input.Type.T {
+	case abi.HashTy, abi.AddressTy:
+		return "CHARACTER VARYING(66)"
+	case abi.IntTy, abi.UintTy:
+		return "NUMERIC"
+	case abi.BoolTy:
+		return "BOOLEAN"
+	case abi.BytesTy, abi.FixedBytesTy, abi.FunctionTy:
+		return "BYTEA"
+	case abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+// HashedWhenIndexed reports whether an indexed input of the type is stored in its topic as the hash of its value
+func HashedWhenIndexed(t abi.Type) bool {
+	switch t.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,123 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// AbiConverter unpacks logs of an ABI event into InsertionModels with a column per event input
+type AbiConverter struct {
+	Abi     abi.ABI
+	Event   abi.Event
+	Schema  SchemaName
+	Table   TableName
+	columns []abiColumn
+}
+
+// ToModels uses the ABI the converter was created with, so contractAbi is unused
+func (converter *AbiConverter) ToModels(contractAbi string, logs []core.HeaderSyncLog) ([]InsertionModel, error) {
+	boundContract := bind.NewBoundContract(common.Address{}, converter.Abi, nil, nil, nil)
+	orderedColumns := []ColumnName{HeaderFK, LogFK}
+	for _, column := range converter.columns {
+		orderedColumns = append(orderedColumns, column.name)
+	}
+
+	models := make([]InsertionModel, 0, len(logs))
+	for _, log := range logs {
+		if len(log.Log.Topics) < 1 || log.Log.Topics[0] != converter.Event.Id() {
+			return nil, fmt.Errorf("log %d is not a %s event", log.ID, converter.Event.Name)
+		}
+		values := make(map[string]interface{})
+		unpackErr := boundContract.UnpackLogIntoMap(values, converter.Event.Name, log.Log)
+		if unpackErr != nil {
+			return nil, fmt.Errorf("error unpacking %s log %d: %s", converter.Event.Name, log.ID, unpackErr.Error())
+		}
+
+		columnValues := ColumnValues{
+			HeaderFK: log.HeaderID,
+			LogFK:    log.ID,
+		}
+		for _, column := range converter.columns {
+			value, convertErr := toPgValue(values[column.argument])
+			if convertErr != nil {
+				return nil, fmt.Errorf("error converting %s of %s log %d: %s", column.argument, converter.Event.Name, log.ID, convertErr.Error())
+			}
+			columnValues[column.name] = value
+		}
+
+		models = append(models, InsertionModel{
+			SchemaName:     converter.Schema,
+			TableName:      converter.Table,
+			OrderedColumns: orderedColumns,
+			ColumnValues:   columnValues,
+		})
+	}
+	return models, nil
+}
+
+func (converter *AbiConverter) SetDB(db *postgres.DB) {}
+
+// Resolves unpacked ABI values to the types the postgres driver accepts
+func toPgValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String(), nil
+	case common.Address:
+		return v.Hex(), nil
+	case common.Hash:
+		return v.Hex(), nil
+	case string, bool, []byte:
+		return v, nil
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value), nil
+	case reflect.Array:
+		// Fixed bytes and function values are unpacked as byte arrays
+		if reflected.Type().Elem().Kind() == reflect.Uint8 {
+			bytes := make([]byte, reflected.Len())
+			reflect.Copy(reflect.ValueOf(bytes), reflected)
+			return bytes, nil
+		}
+		return toJSON(value)
+	case reflect.Slice, reflect.Struct:
+		return toJSON(value)
+	default:
+		return nil, ErrUnsupportedValue(value)
+	}
+}
+
+func toJSON(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
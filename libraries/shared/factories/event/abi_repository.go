@@ -0,0 +1,32 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import "github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+
+// AbiRepository persists the InsertionModels of an AbiConverter
+type AbiRepository struct {
+	db *postgres.DB
+}
+
+func (repository *AbiRepository) Create(models []InsertionModel) error {
+	return Create(models, repository.db)
+}
+
+func (repository *AbiRepository) SetDB(db *postgres.DB) {
+	repository.db = db
+}
@@ -0,0 +1,223 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package event_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/event"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+const testAbi = `[
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"src","type":"address"},{"indexed":true,"name":"dst","type":"address"},{"indexed":false,"name":"wad","type":"uint256"}],"name":"Transfer","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"id","type":"bytes32"},{"indexed":false,"name":"memo","type":"string"},{"indexed":false,"name":"","type":"uint8"},{"indexed":false,"name":"flag","type":"bool"},{"indexed":false,"name":"amounts","type":"uint256[]"}],"name":"Note","type":"event"}
+]`
+
+var _ = Describe("ABI transformers", func() {
+	var config event.AbiTransformerConfig
+
+	BeforeEach(func() {
+		config = event.AbiTransformerConfig{
+			TransformerName:     "token",
+			ContractAddresses:   []string{fakes.FakeAddress.Hex()},
+			ContractAbi:         testAbi,
+			Events:              []string{"Transfer", "Note"},
+			StartingBlockNumber: 100,
+			EndingBlockNumber:   -1,
+		}
+	})
+
+	Describe("NewAbiTransformers", func() {
+		It("creates a transformer for each configured event", func() {
+			parsedAbi, parseErr := eth.ParseAbi(testAbi)
+			Expect(parseErr).NotTo(HaveOccurred())
+
+			transformers, err := event.NewAbiTransformers(config)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(transformers)).To(Equal(2))
+			transferConfig := transformers[0].GetConfig()
+			Expect(transferConfig.TransformerName).To(Equal("token_transfer_event"))
+			Expect(transferConfig.Topic).To(Equal(parsedAbi.Events["Transfer"].Id().Hex()))
+			Expect(transferConfig.ContractAddresses).To(Equal(config.ContractAddresses))
+			Expect(transferConfig.StartingBlockNumber).To(Equal(int64(100)))
+			Expect(transformers[1].GetConfig().Topic).To(Equal(parsedAbi.Events["Note"].Id().Hex()))
+		})
+
+		It("returns error if an event is not in the ABI", func() {
+			config.Events = []string{"Missing"}
+
+			_, err := event.NewAbiTransformers(config)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("event Missing not found"))
+		})
+
+		It("returns error if no events are configured", func() {
+			config.Events = nil
+
+			_, err := event.NewAbiTransformers(config)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns error if the schema is not a valid identifier", func() {
+			config.Schema = "token; DROP TABLE headers"
+
+			_, err := event.NewAbiTransformers(config)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid schema name"))
+		})
+
+		It("returns error if an input is not a valid column name", func() {
+			config.ContractAbi = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"$value","type":"uint256"}],"name":"Deposit","type":"event"}]`
+			config.Events = []string{"Deposit"}
+
+			_, err := event.NewAbiTransformers(config)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("input $value of event Deposit can't be used as a column name"))
+		})
+
+		It("returns error if inputs would be stored in the same column", func() {
+			config.ContractAbi = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"Value","type":"uint256"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Deposit","type":"event"}]`
+			config.Events = []string{"Deposit"}
+
+			_, err := event.NewAbiTransformers(config)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("inputs Value and value of event Deposit would both be stored in column value_"))
+		})
+	})
+
+	Describe("GenerateAbiMigration", func() {
+		It("creates a table per event with a column per input", func() {
+			migration, err := event.GenerateAbiMigration(config)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migration).To(ContainSubstring("CREATE SCHEMA IF NOT EXISTS token;"))
+			Expect(migration).To(ContainSubstring("CREATE TABLE IF NOT EXISTS token.transfer_event"))
+			Expect(migration).To(ContainSubstring("src_ CHARACTER VARYING(66),"))
+			Expect(migration).To(ContainSubstring("wad_ NUMERIC,"))
+			Expect(migration).To(ContainSubstring("CREATE TABLE IF NOT EXISTS token.note_event"))
+			Expect(migration).To(ContainSubstring("id_ BYTEA,"))
+			Expect(migration).To(ContainSubstring("memo_ TEXT,"))
+			Expect(migration).To(ContainSubstring("arg2_ NUMERIC,"))
+			Expect(migration).To(ContainSubstring("flag_ BOOLEAN,"))
+			Expect(migration).To(ContainSubstring("amounts_ JSONB,"))
+			Expect(migration).To(ContainSubstring("UNIQUE (header_id, log_id)"))
+		})
+
+		It("uses the configured schema", func() {
+			config.Schema = "Tokens"
+
+			migration, err := event.GenerateAbiMigration(config)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migration).To(ContainSubstring("CREATE TABLE IF NOT EXISTS tokens.transfer_event"))
+		})
+	})
+
+	Describe("AbiConverter", func() {
+		var (
+			transformers []event.Transformer
+			parsedAbi    abi.ABI
+		)
+
+		BeforeEach(func() {
+			var parseErr, err error
+			parsedAbi, parseErr = eth.ParseAbi(testAbi)
+			Expect(parseErr).NotTo(HaveOccurred())
+			transformers, err = event.NewAbiTransformers(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("converts indexed and non-indexed inputs into column values", func() {
+			transferEvent := parsedAbi.Events["Transfer"]
+			data, packErr := transferEvent.Inputs.NonIndexed().Pack(big.NewInt(123))
+			Expect(packErr).NotTo(HaveOccurred())
+			log := core.HeaderSyncLog{
+				ID:       1,
+				HeaderID: 2,
+				Log: types.Log{
+					Topics: []common.Hash{
+						transferEvent.Id(),
+						common.BytesToHash(common.HexToAddress("0x1").Bytes()),
+						common.BytesToHash(common.HexToAddress("0x2").Bytes()),
+					},
+					Data: data,
+				},
+			}
+
+			models, err := transformers[0].Converter.ToModels(testAbi, []core.HeaderSyncLog{log})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(models).To(Equal([]event.InsertionModel{{
+				SchemaName:     "token",
+				TableName:      "transfer_event",
+				OrderedColumns: []event.ColumnName{event.HeaderFK, event.LogFK, "src_", "dst_", "wad_"},
+				ColumnValues: event.ColumnValues{
+					event.HeaderFK: int64(2),
+					event.LogFK:    int64(1),
+					"src_":         common.HexToAddress("0x1").Hex(),
+					"dst_":         common.HexToAddress("0x2").Hex(),
+					"wad_":         "123",
+				},
+			}}))
+		})
+
+		It("converts dynamic and unnamed inputs", func() {
+			noteEvent := parsedAbi.Events["Note"]
+			data, packErr := noteEvent.Inputs.NonIndexed().Pack("hello", uint8(3), true, []*big.Int{big.NewInt(1), big.NewInt(2)})
+			Expect(packErr).NotTo(HaveOccurred())
+			id := common.HexToHash("0xabc")
+			log := core.HeaderSyncLog{
+				ID:       1,
+				HeaderID: 2,
+				Log:      types.Log{Topics: []common.Hash{noteEvent.Id(), id}, Data: data},
+			}
+
+			models, err := transformers[1].Converter.ToModels(testAbi, []core.HeaderSyncLog{log})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(models)).To(Equal(1))
+			Expect(models[0].ColumnValues["id_"]).To(Equal(id.Bytes()))
+			Expect(models[0].ColumnValues["memo_"]).To(Equal("hello"))
+			Expect(models[0].ColumnValues["arg2_"]).To(Equal("3"))
+			Expect(models[0].ColumnValues["flag_"]).To(Equal(true))
+			Expect(models[0].ColumnValues["amounts_"]).To(Equal("[1,2]"))
+		})
+
+		It("returns error if a log is not of the converter's event", func() {
+			log := core.HeaderSyncLog{Log: types.Log{Topics: []common.Hash{parsedAbi.Events["Note"].Id()}}}
+
+			_, err := transformers[0].Converter.ToModels(testAbi, []core.HeaderSyncLog{log})
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not a Transfer event"))
+		})
+	})
+})
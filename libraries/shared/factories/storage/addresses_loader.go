@@ -0,0 +1,56 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// AddressesLoader loads the addresses of contracts sharing a transformer's storage layout
+type AddressesLoader interface {
+	LoadAddresses() ([]common.Address, error)
+	SetDB(db *postgres.DB)
+}
+
+// QueryAddressesLoader loads addresses with a query returning one hex encoded address per row,
+// e.g. the contracts created by a factory that a plugin's event transformers have persisted
+type QueryAddressesLoader struct {
+	db    *postgres.DB
+	query string
+}
+
+func NewQueryAddressesLoader(query string) *QueryAddressesLoader {
+	return &QueryAddressesLoader{query: query}
+}
+
+func (loader *QueryAddressesLoader) LoadAddresses() ([]common.Address, error) {
+	var hexAddresses []string
+	selectErr := loader.db.Select(&hexAddresses, loader.query)
+	if selectErr != nil {
+		return nil, selectErr
+	}
+	addresses := make([]common.Address, 0, len(hexAddresses))
+	for _, hexAddress := range hexAddresses {
+		addresses = append(addresses, common.HexToAddress(hexAddress))
+	}
+	return addresses, nil
+}
+
+func (loader *QueryAddressesLoader) SetDB(db *postgres.DB) {
+	loader.db = db
+}
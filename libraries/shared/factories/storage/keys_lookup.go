@@ -17,6 +17,8 @@
 package storage
 
 import (
+	"sync"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
@@ -24,11 +26,14 @@ import (
 
 type KeysLookup interface {
 	Lookup(key common.Hash) (utils.StorageValueMetadata, error)
+	Mappings() (map[common.Hash]utils.StorageValueMetadata, error)
 	SetDB(db *postgres.DB)
 }
 
+// keysLookup is safe for concurrent use; mappings are replaced, never modified, on refresh
 type keysLookup struct {
 	loader   KeysLoader
+	mutex    sync.RWMutex
 	mappings map[common.Hash]utils.StorageValueMetadata
 }
 
@@ -37,30 +42,59 @@ func NewKeysLookup(loader KeysLoader) KeysLookup {
 }
 
 func (lookup *keysLookup) Lookup(key common.Hash) (utils.StorageValueMetadata, error) {
+	lookup.mutex.RLock()
 	metadata, ok := lookup.mappings[key]
+	lookup.mutex.RUnlock()
+	if ok {
+		return metadata, nil
+	}
+
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
+	// Another caller may have refreshed the mappings while we waited for the lock
+	metadata, ok = lookup.mappings[key]
+	if ok {
+		return metadata, nil
+	}
+	refreshErr := lookup.refreshMappings()
+	if refreshErr != nil {
+		return metadata, refreshErr
+	}
+	metadata, ok = lookup.mappings[key]
 	if !ok {
-		refreshErr := lookup.refreshMappings()
-		if refreshErr != nil {
-			return metadata, refreshErr
-		}
-		metadata, ok = lookup.mappings[key]
-		if !ok {
-			return metadata, utils.ErrStorageKeyNotFound{Key: key.Hex()}
-		}
+		return metadata, utils.ErrStorageKeyNotFound{Key: key.Hex()}
 	}
 	return metadata, nil
 }
 
+// Mappings refreshes and returns every known key, in both its plain and hashed form; the returned map must not be modified
+func (lookup *keysLookup) Mappings() (map[common.Hash]utils.StorageValueMetadata, error) {
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
+	refreshErr := lookup.refreshMappings()
+	if refreshErr != nil {
+		return nil, refreshErr
+	}
+	return lookup.mappings, nil
+}
+
+// refreshMappings must be called holding the write lock
 func (lookup *keysLookup) refreshMappings() error {
-	var err error
-	lookup.mappings, err = lookup.loader.LoadMappings()
+	loaded, err := lookup.loader.LoadMappings()
 	if err != nil {
 		return err
 	}
-	lookup.mappings = utils.AddHashedKeys(lookup.mappings)
+	// Copy the loaded mappings, since adding the hashed keys modifies the map it's given
+	mappings := make(map[common.Hash]utils.StorageValueMetadata, len(loaded)*2)
+	for key, metadata := range loaded {
+		mappings[key] = metadata
+	}
+	lookup.mappings = utils.AddHashedKeys(mappings)
 	return nil
 }
 
 func (lookup *keysLookup) SetDB(db *postgres.DB) {
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
 	lookup.loader.SetDB(db)
 }
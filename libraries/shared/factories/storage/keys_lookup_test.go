@@ -17,6 +17,8 @@
 package storage_test
 
 import (
+	"sync"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	. "github.com/onsi/ginkgo"
@@ -103,6 +105,49 @@ var _ = Describe("Storage keys lookup", func() {
 		})
 	})
 
+	Describe("Mappings", func() {
+		It("returns refreshed plain and hashed keys", func() {
+			loader.StorageKeyMappings = map[common.Hash]utils.StorageValueMetadata{fakes.FakeHash: fakeMetadata}
+
+			mappings, err := lookup.Mappings()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loader.LoadMappingsCallCount).To(Equal(1))
+			Expect(mappings).To(HaveKeyWithValue(fakes.FakeHash, fakeMetadata))
+			Expect(mappings).To(HaveKeyWithValue(crypto.Keccak256Hash(fakes.FakeHash.Bytes()), fakeMetadata))
+		})
+
+		It("returns error if refreshing keys fails", func() {
+			loader.LoadMappingsError = fakes.FakeError
+
+			_, err := lookup.Mappings()
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
+	It("can be used concurrently", func() {
+		loader.StorageKeyMappings = map[common.Hash]utils.StorageValueMetadata{fakes.FakeHash: fakeMetadata}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				_, err := lookup.Lookup(common.HexToHash("0x123"))
+				Expect(err).To(HaveOccurred())
+			}()
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				mappings, err := lookup.Mappings()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mappings).To(HaveLen(2))
+			}()
+		}
+		wg.Wait()
+	})
+
 	Describe("SetDB", func() {
 		It("sets the db on the loader", func() {
 			lookup.SetDB(test_config.NewTestDB(test_config.NewTestNode()))
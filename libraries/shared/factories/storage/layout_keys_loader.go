@@ -0,0 +1,554 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+var staticArrayLength = regexp.MustCompile(`\[(\d+)\]$`)
+
+// storageLayout is the storageLayout output of solc
+type storageLayout struct {
+	Storage []storageLayoutEntry         `json:"storage"`
+	Types   map[string]storageLayoutType `json:"types"`
+}
+
+// storageLayoutEntry is a state variable or struct member
+type storageLayoutEntry struct {
+	Label  string `json:"label"`
+	Offset int    `json:"offset"`
+	Slot   string `json:"slot"`
+	Type   string `json:"type"`
+}
+
+type storageLayoutType struct {
+	Base          string               `json:"base"`
+	Encoding      string               `json:"encoding"`
+	Key           string               `json:"key"`
+	Label         string               `json:"label"`
+	Members       []storageLayoutEntry `json:"members"`
+	NumberOfBytes string               `json:"numberOfBytes"`
+	Value         string               `json:"value"`
+}
+
+// LayoutKeysLoader derives storage value metadata from a contract's storage layout, so that a storage transformer
+// doesn't need a hand-written KeysLoader. Keys of mappings are read from the MappingKeysSource configured for the
+// mapping's name; mappings without a source and dynamic arrays are not recognized.
+type LayoutKeysLoader struct {
+	db          *postgres.DB
+	layout      storageLayout
+	mappingKeys map[string]MappingKeysSource
+}
+
+// NewLayoutKeysLoader creates a loader from solc's storageLayout JSON. mappingKeys are keyed by the name of the mapping,
+// with struct members named by their path, e.g. "ilks" or "ilks.members"
+func NewLayoutKeysLoader(storageLayoutJSON string, mappingKeys map[string]MappingKeysSource) (KeysLoader, error) {
+	var layout storageLayout
+	unmarshalErr := json.Unmarshal([]byte(storageLayoutJSON), &layout)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("error parsing storage layout: %s", unmarshalErr.Error())
+	}
+	loader := &LayoutKeysLoader{layout: layout, mappingKeys: mappingKeys}
+	for _, entry := range layout.Storage {
+		if _, ok := new(big.Int).SetString(entry.Slot, 10); !ok {
+			return nil, fmt.Errorf("invalid slot %s for %s", entry.Slot, entry.Label)
+		}
+		validateErr := loader.validateType(entry.Type)
+		if validateErr != nil {
+			return nil, fmt.Errorf("error loading layout of %s: %s", entry.Label, validateErr.Error())
+		}
+	}
+	return loader, nil
+}
+
+func (loader *LayoutKeysLoader) SetDB(db *postgres.DB) {
+	loader.db = db
+}
+
+func (loader *LayoutKeysLoader) LoadMappings() (map[common.Hash]utils.StorageValueMetadata, error) {
+	walker := layoutWalker{
+		loader:     loader,
+		items:      make(map[common.Hash][]layoutItem),
+		sourceRows: make(map[string]mappingKeysRows),
+	}
+	for _, entry := range loader.layout.Storage {
+		slot, _ := new(big.Int).SetString(entry.Slot, 10)
+		walkErr := walker.walkType(entry.Type, slot, entry.Offset, entry.Label, entry.Label, nil)
+		if walkErr != nil {
+			return nil, walkErr
+		}
+	}
+	return walker.metadata(), nil
+}
+
+// Checks that every value and mapping key in the type can be decoded
+func (loader *LayoutKeysLoader) validateType(typeID string) error {
+	t, ok := loader.layout.Types[typeID]
+	if !ok {
+		return fmt.Errorf("type %s not found in layout", typeID)
+	}
+	switch t.Encoding {
+	case "inplace":
+		if len(t.Members) > 0 {
+			for _, member := range t.Members {
+				if _, ok := new(big.Int).SetString(member.Slot, 10); !ok {
+					return fmt.Errorf("invalid slot %s for %s", member.Slot, member.Label)
+				}
+				memberErr := loader.validateType(member.Type)
+				if memberErr != nil {
+					return memberErr
+				}
+			}
+			return nil
+		}
+		if t.Base != "" {
+			if !staticArrayLength.MatchString(t.Label) {
+				return fmt.Errorf("can't find the length of array %s", t.Label)
+			}
+			return loader.validateType(t.Base)
+		}
+		_, valueTypeErr := layoutValueType(t)
+		return valueTypeErr
+	case "mapping":
+		keyType, ok := loader.layout.Types[t.Key]
+		if !ok {
+			return fmt.Errorf("type %s not found in layout", t.Key)
+		}
+		if !supportedMappingKey(keyType.Label) {
+			return fmt.Errorf("unsupported mapping key type %s", keyType.Label)
+		}
+		return loader.validateType(t.Value)
+	case "bytes":
+		if t.Label != "string" && t.Label != "bytes" {
+			return fmt.Errorf("unsupported bytes type %s", t.Label)
+		}
+		return nil
+	case "dynamic_array":
+		return nil
+	default:
+		return fmt.Errorf("unsupported encoding %s for type %s", t.Encoding, t.Label)
+	}
+}
+
+// layoutValueType is the type a value is decoded as
+func layoutValueType(t storageLayoutType) (utils.ValueType, error) {
+	switch {
+	case t.Label == "bool":
+		return utils.Bool, nil
+	case t.Label == "address", t.Label == "address payable", strings.HasPrefix(t.Label, "contract "):
+		return utils.Address, nil
+	case strings.HasPrefix(t.Label, "enum "):
+		size, sizeErr := strconv.Atoi(t.NumberOfBytes)
+		if sizeErr != nil {
+			return 0, fmt.Errorf("invalid size of %s", t.Label)
+		}
+		return utils.Uint(size * 8), nil
+	}
+	if bits, ok := labelSize(t.Label, "uint", 8); ok {
+		return utils.Uint(bits), nil
+	}
+	if bits, ok := labelSize(t.Label, "int", 8); ok {
+		return utils.Int(bits), nil
+	}
+	if size, ok := labelSize(t.Label, "bytes", 1); ok && size <= 32 {
+		return utils.FixedBytes(size), nil
+	}
+	return 0, fmt.Errorf("unsupported value type %s", t.Label)
+}
+
+// Size in the label of a sized type, e.g. 64 for uint64, which must be a multiple of step up to 256
+func labelSize(label, prefix string, step int) (int, bool) {
+	if !strings.HasPrefix(label, prefix) {
+		return 0, false
+	}
+	size, err := strconv.Atoi(strings.TrimPrefix(label, prefix))
+	if err != nil || size < step || size > 256 || size%step != 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// MappingKeysSource supplies the keys of a mapping's entries, e.g. from previously transformed event arguments.
+// Each row holds the keys of one entry, preceded by the keys of any enclosing mappings, outermost first.
+// Columns name the keys in the entry's storage value metadata.
+type MappingKeysSource interface {
+	MappingKeys(db *postgres.DB) (columns []string, rows [][]string, err error)
+}
+
+type queryMappingKeys struct {
+	query string
+}
+
+// NewQueryMappingKeys creates a MappingKeysSource from a query selecting a column per key
+// Rows with a null key are ignored
+func NewQueryMappingKeys(query string) MappingKeysSource {
+	return queryMappingKeys{query: query}
+}
+
+func (source queryMappingKeys) MappingKeys(db *postgres.DB) ([]string, [][]string, error) {
+	rows, queryErr := db.Queryx(source.query)
+	if queryErr != nil {
+		return nil, nil, queryErr
+	}
+	defer rows.Close()
+	columns, columnsErr := rows.Columns()
+	if columnsErr != nil {
+		return nil, nil, columnsErr
+	}
+	var result [][]string
+	for rows.Next() {
+		values := make([]*string, len(columns))
+		scanDestinations := make([]interface{}, len(columns))
+		for i := range values {
+			scanDestinations[i] = &values[i]
+		}
+		scanErr := rows.Scan(scanDestinations...)
+		if scanErr != nil {
+			return nil, nil, scanErr
+		}
+		row, ok := notNull(values)
+		if ok {
+			result = append(result, row)
+		}
+	}
+	return columns, result, rows.Err()
+}
+
+func notNull(values []*string) ([]string, bool) {
+	row := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			return nil, false
+		}
+		row = append(row, *value)
+	}
+	return row, true
+}
+
+type mappingKeysRows struct {
+	columns []string
+	rows    [][]string
+}
+
+// layoutKey is a mapping key or static array index identifying a value
+type layoutKey struct {
+	name    utils.Key
+	value   string
+	keyType string
+	encoded []byte // nil for array indexes
+}
+
+type layoutItem struct {
+	name      string
+	path      string // name including array indexes, used for items packed into a slot
+	keys      []layoutKey
+	offset    int
+	valueType utils.ValueType
+}
+
+// layoutWalker collects the items stored in each slot while walking the types of a layout
+type layoutWalker struct {
+	loader     *LayoutKeysLoader
+	items      map[common.Hash][]layoutItem
+	sourceRows map[string]mappingKeysRows
+}
+
+func (walker *layoutWalker) walkType(typeID string, slot *big.Int, offset int, name, path string, keys []layoutKey) error {
+	t := walker.loader.layout.Types[typeID]
+	switch t.Encoding {
+	case "inplace":
+		if len(t.Members) > 0 {
+			return walker.walkStruct(t, slot, name, path, keys)
+		}
+		if t.Base != "" {
+			return walker.walkArray(t, slot, name, path, keys)
+		}
+		valueType, _ := layoutValueType(t)
+		slotHash := common.BigToHash(slot)
+		walker.items[slotHash] = append(walker.items[slotHash], layoutItem{
+			name:      name,
+			path:      path,
+			keys:      keys,
+			offset:    offset,
+			valueType: valueType,
+		})
+		return nil
+	case "mapping":
+		return walker.walkMapping(t, slot, name, path, keys)
+	case "bytes":
+		valueType := utils.Bytes
+		if t.Label == "string" {
+			valueType = utils.String
+		}
+		slotHash := common.BigToHash(slot)
+		walker.items[slotHash] = append(walker.items[slotHash], layoutItem{
+			name:      name,
+			path:      path,
+			keys:      keys,
+			valueType: valueType,
+		})
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (walker *layoutWalker) walkStruct(t storageLayoutType, slot *big.Int, name, path string, keys []layoutKey) error {
+	for _, member := range t.Members {
+		memberSlot, _ := new(big.Int).SetString(member.Slot, 10)
+		memberSlot = math.U256(memberSlot.Add(memberSlot, slot))
+		memberErr := walker.walkType(member.Type, memberSlot, member.Offset, name+"."+member.Label, path+"."+member.Label, keys)
+		if memberErr != nil {
+			return memberErr
+		}
+	}
+	return nil
+}
+
+// Elements of a static array are packed into slots like consecutive variables, each element indexed by an indexN key,
+// where N is the depth of the array within nested arrays
+func (walker *layoutWalker) walkArray(t storageLayoutType, slot *big.Int, name, path string, keys []layoutKey) error {
+	length, _ := strconv.Atoi(staticArrayLength.FindStringSubmatch(t.Label)[1])
+	elementSize, sizeErr := strconv.Atoi(walker.loader.layout.Types[t.Base].NumberOfBytes)
+	if sizeErr != nil {
+		return fmt.Errorf("invalid size of %s elements: %s", t.Label, sizeErr.Error())
+	}
+	indexKey := utils.Key(fmt.Sprintf("index%d", countIndexes(keys)))
+	for i := 0; i < length; i++ {
+		elementSlot, elementOffset := new(big.Int), 0
+		if elementSize <= 16 {
+			perSlot := 32 / elementSize
+			elementSlot.SetInt64(int64(i / perSlot))
+			elementOffset = (i % perSlot) * elementSize
+		} else {
+			elementSlot.SetInt64(int64(i * ((elementSize + 31) / 32)))
+		}
+		elementSlot = math.U256(elementSlot.Add(elementSlot, slot))
+		index := strconv.Itoa(i)
+		elementKeys := append(append([]layoutKey{}, keys...), layoutKey{name: indexKey, value: index})
+		elementErr := walker.walkType(t.Base, elementSlot, elementOffset, name, fmt.Sprintf("%s[%s]", path, index), elementKeys)
+		if elementErr != nil {
+			return elementErr
+		}
+	}
+	return nil
+}
+
+// Walks the value of each known entry of a mapping, along with any mappings directly nested in its values
+func (walker *layoutWalker) walkMapping(t storageLayoutType, slot *big.Int, name, path string, keys []layoutKey) error {
+	keyTypes := []string{walker.loader.layout.Types[t.Key].Label}
+	valueType := t.Value
+	for walker.loader.layout.Types[valueType].Encoding == "mapping" {
+		nested := walker.loader.layout.Types[valueType]
+		keyTypes = append(keyTypes, walker.loader.layout.Types[nested.Key].Label)
+		valueType = nested.Value
+	}
+
+	source, sourceErr := walker.mappingKeysRows(name)
+	if sourceErr != nil {
+		return sourceErr
+	}
+	enclosingKeys := mappingKeys(keys)
+	expectedColumns := len(enclosingKeys) + len(keyTypes)
+	if len(source.rows) > 0 && len(source.columns) != expectedColumns {
+		return fmt.Errorf("mapping keys of %s have %d columns, expected %d", name, len(source.columns), expectedColumns)
+	}
+
+	for _, row := range source.rows {
+		if !enclosedBy(row, enclosingKeys) {
+			continue
+		}
+		entrySlot := slot
+		entryKeys := append([]layoutKey{}, keys...)
+		for i, keyType := range keyTypes {
+			column := len(enclosingKeys) + i
+			encoded, encodeErr := encodeMappingKey(keyType, row[column])
+			if encodeErr != nil {
+				return fmt.Errorf("error encoding key %s of %s: %s", row[column], name, encodeErr.Error())
+			}
+			entrySlot = crypto.Keccak256Hash(encoded, common.BigToHash(entrySlot).Bytes()).Big()
+			entryKeys = append(entryKeys, layoutKey{
+				name:    utils.Key(source.columns[column]),
+				value:   row[column],
+				keyType: keyType,
+				encoded: encoded,
+			})
+		}
+		entryErr := walker.walkType(valueType, entrySlot, 0, name, path, entryKeys)
+		if entryErr != nil {
+			return entryErr
+		}
+	}
+	return nil
+}
+
+// Mapping keys are read once per load, since a mapping in a struct or array is walked for each of its enclosing values
+func (walker *layoutWalker) mappingKeysRows(name string) (mappingKeysRows, error) {
+	rows, ok := walker.sourceRows[name]
+	if ok {
+		return rows, nil
+	}
+	source, ok := walker.loader.mappingKeys[name]
+	if !ok {
+		return mappingKeysRows{}, nil
+	}
+	columns, sourceRows, err := source.MappingKeys(walker.loader.db)
+	if err != nil {
+		return mappingKeysRows{}, fmt.Errorf("error getting mapping keys of %s: %s", name, err.Error())
+	}
+	rows = mappingKeysRows{columns: columns, rows: sourceRows}
+	walker.sourceRows[name] = rows
+	return rows, nil
+}
+
+// Builds the metadata for each slot, with slots holding several items described as packed slots
+func (walker *layoutWalker) metadata() map[common.Hash]utils.StorageValueMetadata {
+	mappings := make(map[common.Hash]utils.StorageValueMetadata)
+	for slot, items := range walker.items {
+		if len(items) == 1 && (items[0].valueType == utils.Bytes || items[0].valueType == utils.String) {
+			mappings[slot] = utils.GetStorageValueMetadataForDynamicValue(items[0].name, keysMap(items[0].keys), items[0].valueType, slot)
+			continue
+		}
+		if len(items) == 1 {
+			mappings[slot] = utils.GetStorageValueMetadata(items[0].name, keysMap(items[0].keys), items[0].valueType)
+			continue
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].offset < items[j].offset })
+		paths := make([]string, 0, len(items))
+		packedNames := make(map[int]string)
+		packedTypes := make(map[int]utils.ValueType)
+		for position, item := range items {
+			paths = append(paths, item.path)
+			packedNames[position] = item.path
+			packedTypes[position] = item.valueType
+		}
+		mappings[slot] = utils.GetStorageValueMetadataForPackedSlot(strings.Join(paths, ","), sharedKeys(items),
+			utils.PackedSlot, packedNames, packedTypes)
+	}
+	return mappings
+}
+
+// Encodes a mapping key as it is hashed with the mapping's slot
+func encodeMappingKey(label, key string) ([]byte, error) {
+	switch {
+	case label == "address", label == "address payable", strings.HasPrefix(label, "contract "):
+		if !common.IsHexAddress(key) {
+			return nil, fmt.Errorf("invalid address")
+		}
+		return common.LeftPadBytes(common.HexToAddress(key).Bytes(), 32), nil
+	case label == "bool":
+		switch key {
+		case "true", "1":
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		case "false", "0":
+			return make([]byte, 32), nil
+		}
+		return nil, fmt.Errorf("invalid bool")
+	case label == "string":
+		return []byte(key), nil
+	case label == "bytes":
+		return common.FromHex(key), nil
+	case strings.HasPrefix(label, "bytes"):
+		return common.RightPadBytes(common.FromHex(key), 32), nil
+	case strings.HasPrefix(label, "uint"), strings.HasPrefix(label, "int"), strings.HasPrefix(label, "enum "):
+		n, ok := new(big.Int).SetString(key, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer")
+		}
+		return common.LeftPadBytes(math.U256(n).Bytes(), 32), nil
+	default:
+		return nil, fmt.Errorf("unsupported mapping key type %s", label)
+	}
+}
+
+func supportedMappingKey(label string) bool {
+	switch {
+	case label == "address", label == "address payable", label == "bool", label == "string",
+		strings.HasPrefix(label, "contract "), strings.HasPrefix(label, "bytes"),
+		strings.HasPrefix(label, "uint"), strings.HasPrefix(label, "int"), strings.HasPrefix(label, "enum "):
+		return true
+	default:
+		return false
+	}
+}
+
+func countIndexes(keys []layoutKey) int {
+	count := 0
+	for _, key := range keys {
+		if key.encoded == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func mappingKeys(keys []layoutKey) []layoutKey {
+	var result []layoutKey
+	for _, key := range keys {
+		if key.encoded != nil {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// Whether a row of mapping keys belongs to the entry of the enclosing mappings being walked
+func enclosedBy(row []string, enclosingKeys []layoutKey) bool {
+	for i, key := range enclosingKeys {
+		encoded, encodeErr := encodeMappingKey(key.keyType, row[i])
+		if encodeErr != nil || !bytes.Equal(encoded, key.encoded) {
+			return false
+		}
+	}
+	return true
+}
+
+func keysMap(keys []layoutKey) map[utils.Key]string {
+	result := make(map[utils.Key]string)
+	for _, key := range keys {
+		result[key.name] = key.value
+	}
+	return result
+}
+
+// Keys shared by all items in a packed slot; array indexes differ between elements packed together
+func sharedKeys(items []layoutItem) map[utils.Key]string {
+	result := keysMap(items[0].keys)
+	for _, item := range items[1:] {
+		itemKeys := keysMap(item.keys)
+		for name, value := range result {
+			if itemKeys[name] != value {
+				delete(result, name)
+			}
+		}
+	}
+	return result
+}
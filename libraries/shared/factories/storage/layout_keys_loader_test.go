@@ -0,0 +1,249 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+// Layout of:
+//
+//	contract Vat {
+//	    struct Ilk { uint256 Art; uint256 rate; }
+//	    uint256 debt;
+//	    address owner;
+//	    uint48 era;
+//	    mapping(address => uint256) dai;
+//	    mapping(address => mapping(address => uint256)) can;
+//	    mapping(bytes32 => Ilk) ilks;
+//	    uint128[2] pair;
+//	    string name;
+//	}
+const testStorageLayout = `{
+	"storage": [
+		{"label": "debt", "offset": 0, "slot": "0", "type": "t_uint256"},
+		{"label": "owner", "offset": 0, "slot": "1", "type": "t_address"},
+		{"label": "era", "offset": 20, "slot": "1", "type": "t_uint48"},
+		{"label": "dai", "offset": 0, "slot": "2", "type": "t_mapping(t_address,t_uint256)"},
+		{"label": "can", "offset": 0, "slot": "3", "type": "t_mapping(t_address,t_mapping(t_address,t_uint256))"},
+		{"label": "ilks", "offset": 0, "slot": "4", "type": "t_mapping(t_bytes32,t_struct(Ilk)1_storage)"},
+		{"label": "pair", "offset": 0, "slot": "5", "type": "t_array(t_uint128)2_storage"},
+		{"label": "name", "offset": 0, "slot": "6", "type": "t_string_storage"}
+	],
+	"types": {
+		"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+		"t_array(t_uint128)2_storage": {"base": "t_uint128", "encoding": "inplace", "label": "uint128[2]", "numberOfBytes": "32"},
+		"t_bytes32": {"encoding": "inplace", "label": "bytes32", "numberOfBytes": "32"},
+		"t_mapping(t_address,t_mapping(t_address,t_uint256))": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => mapping(address => uint256))", "numberOfBytes": "32", "value": "t_mapping(t_address,t_uint256)"},
+		"t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+		"t_mapping(t_bytes32,t_struct(Ilk)1_storage)": {"encoding": "mapping", "key": "t_bytes32", "label": "mapping(bytes32 => struct Vat.Ilk)", "numberOfBytes": "32", "value": "t_struct(Ilk)1_storage"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_struct(Ilk)1_storage": {"encoding": "inplace", "label": "struct Vat.Ilk", "members": [
+			{"label": "Art", "offset": 0, "slot": "0", "type": "t_uint256"},
+			{"label": "rate", "offset": 0, "slot": "1", "type": "t_uint256"}
+		], "numberOfBytes": "64"},
+		"t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+		"t_uint48": {"encoding": "inplace", "label": "uint48", "numberOfBytes": "6"}
+	}
+}`
+
+var _ = Describe("Storage layout keys loader", func() {
+	var (
+		daiKeys, canKeys, ilkKeys *mocks.MockMappingKeysSource
+		loader                    storage.KeysLoader
+		guy                       = "0x" + strings.Repeat("ab", 20)
+		usr                       = "0x" + strings.Repeat("cd", 20)
+		ilk                       = "0x4554482d41000000000000000000000000000000000000000000000000000000"
+	)
+
+	BeforeEach(func() {
+		daiKeys = &mocks.MockMappingKeysSource{}
+		canKeys = &mocks.MockMappingKeysSource{}
+		ilkKeys = &mocks.MockMappingKeysSource{}
+		var err error
+		loader, err = storage.NewLayoutKeysLoader(testStorageLayout, map[string]storage.MappingKeysSource{
+			"dai":  daiKeys,
+			"can":  canKeys,
+			"ilks": ilkKeys,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns metadata for variables stored in their own slot", func() {
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings[common.HexToHash(utils.IndexZero)]).To(Equal(
+			utils.GetStorageValueMetadata("debt", map[utils.Key]string{}, utils.Uint256)))
+	})
+
+	It("returns packed slot metadata for variables sharing a slot", func() {
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings[common.HexToHash(utils.IndexOne)]).To(Equal(utils.GetStorageValueMetadataForPackedSlot(
+			"owner,era", map[utils.Key]string{}, utils.PackedSlot,
+			map[int]string{0: "owner", 1: "era"},
+			map[int]utils.ValueType{0: utils.Address, 1: utils.Uint48})))
+	})
+
+	It("returns packed slot metadata for static array elements sharing a slot", func() {
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings[common.HexToHash(utils.IndexFive)]).To(Equal(utils.GetStorageValueMetadataForPackedSlot(
+			"pair[0],pair[1]", map[utils.Key]string{}, utils.PackedSlot,
+			map[int]string{0: "pair[0]", 1: "pair[1]"},
+			map[int]utils.ValueType{0: utils.Uint128, 1: utils.Uint128})))
+	})
+
+	It("returns dynamic value metadata for strings", func() {
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings[common.HexToHash(utils.IndexSix)]).To(Equal(utils.GetStorageValueMetadataForDynamicValue(
+			"name", map[utils.Key]string{}, utils.String, common.HexToHash(utils.IndexSix))))
+	})
+
+	It("does not return metadata for mappings without known keys", func() {
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(mappings)).To(Equal(4))
+	})
+
+	It("returns metadata for known mapping entries", func() {
+		daiKeys.Columns = []string{"guy"}
+		daiKeys.Rows = [][]string{{guy}}
+
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		daiKey := crypto.Keccak256Hash(common.HexToHash(guy).Bytes(), common.HexToHash(utils.IndexTwo).Bytes())
+		Expect(mappings[daiKey]).To(Equal(
+			utils.GetStorageValueMetadata("dai", map[utils.Key]string{"guy": guy}, utils.Uint256)))
+	})
+
+	It("returns metadata for known nested mapping entries", func() {
+		canKeys.Columns = []string{"bit", "usr"}
+		canKeys.Rows = [][]string{{guy, usr}}
+
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		canKey := utils.GetStorageKeyForNestedMapping(utils.IndexThree, common.HexToHash(guy).Hex()[2:], common.HexToHash(usr).Hex())
+		Expect(mappings[canKey]).To(Equal(
+			utils.GetStorageValueMetadata("can", map[utils.Key]string{"bit": guy, "usr": usr}, utils.Uint256)))
+	})
+
+	It("returns metadata for members of structs in mappings", func() {
+		ilkKeys.Columns = []string{"ilk"}
+		ilkKeys.Rows = [][]string{{ilk}}
+
+		mappings, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		artKey := utils.GetStorageKeyForMapping(utils.IndexFour, ilk)
+		Expect(mappings[artKey]).To(Equal(
+			utils.GetStorageValueMetadata("ilks.Art", map[utils.Key]string{"ilk": ilk}, utils.Uint256)))
+		rateKey := utils.GetIncrementedStorageKey(artKey, 1)
+		Expect(mappings[rateKey]).To(Equal(
+			utils.GetStorageValueMetadata("ilks.rate", map[utils.Key]string{"ilk": ilk}, utils.Uint256)))
+	})
+
+	It("reads mapping keys with the loader's db", func() {
+		db := &postgres.DB{}
+		loader.SetDB(db)
+
+		_, err := loader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(daiKeys.PassedDB).To(BeIdenticalTo(db))
+	})
+
+	It("returns error if getting mapping keys fails", func() {
+		daiKeys.ReturnError = fakes.FakeError
+
+		_, err := loader.LoadMappings()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+	})
+
+	It("returns error if mapping keys don't have a column per key", func() {
+		canKeys.Columns = []string{"bit"}
+		canKeys.Rows = [][]string{{guy}}
+
+		_, err := loader.LoadMappings()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("expected 2"))
+	})
+
+	It("returns error if a mapping key can't be encoded", func() {
+		daiKeys.Columns = []string{"guy"}
+		daiKeys.Rows = [][]string{{"not an address"}}
+
+		_, err := loader.LoadMappings()
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns metadata for sized integers, bools and fixed bytes", func() {
+		layout := strings.Replace(testStorageLayout, `"label": "uint48", "numberOfBytes": "6"`, `"label": "int48", "numberOfBytes": "6"`, 1)
+		layout = strings.Replace(layout, `"label": "address", "numberOfBytes": "20"`, `"label": "bytes20", "numberOfBytes": "20"`, 1)
+		sizedLoader, loaderErr := storage.NewLayoutKeysLoader(layout, nil)
+		Expect(loaderErr).NotTo(HaveOccurred())
+
+		mappings, err := sizedLoader.LoadMappings()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings[common.HexToHash(utils.IndexOne)].PackedTypes).To(Equal(
+			map[int]utils.ValueType{0: utils.FixedBytes(20), 1: utils.Int(48)}))
+	})
+
+	It("returns error if the layout includes an unsupported value type", func() {
+		unsupportedLayout := strings.Replace(testStorageLayout, `"label": "uint48"`, `"label": "fixed128x18"`, 1)
+
+		_, err := storage.NewLayoutKeysLoader(unsupportedLayout, nil)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported value type fixed128x18"))
+	})
+
+	It("can be used by a keys lookup", func() {
+		daiKeys.Columns = []string{"guy"}
+		daiKeys.Rows = [][]string{{guy}}
+		lookup := storage.NewKeysLookup(loader)
+
+		metadata, err := lookup.Lookup(crypto.Keccak256Hash(common.HexToHash(guy).Bytes(), common.HexToHash(utils.IndexTwo).Bytes()))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(metadata.Name).To(Equal("dai"))
+	})
+})
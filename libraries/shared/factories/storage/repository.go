@@ -17,6 +17,7 @@
 package storage
 
 import (
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 )
@@ -25,3 +26,17 @@ type Repository interface {
 	Create(diffID int64, metadata utils.StorageValueMetadata, value interface{}) error
 	SetDB(db *postgres.DB)
 }
+
+// RevertibleRepository is a Repository that can delete the values it created from a diff,
+// allowing the transformer to revert diffs whose block was removed by a reorg
+type RevertibleRepository interface {
+	Repository
+	Delete(diffID int64) error
+}
+
+// AddressRepository is a Repository for a transformer watching several contracts with the same layout,
+// which records the keccak hashed address of the contract each value came from
+type AddressRepository interface {
+	Repository
+	CreateForAddress(diffID int64, hashedAddress common.Hash, metadata utils.StorageValueMetadata, value interface{}) error
+}
@@ -17,21 +17,39 @@
 package storage
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
 )
 
 type Transformer struct {
-	HashedAddress     common.Hash
-	StorageKeysLookup KeysLookup
-	Repository        Repository
+	HashedAddress      common.Hash
+	StorageKeysLookup  KeysLookup
+	Repository         Repository
+	LongValues         *utils.LongValueAssembler                 // assembles long bytes and string values, created loading from storage_diff if nil
+	Addresses          AddressesLoader                           // contracts sharing the layout, watched instead of HashedAddress if set
+	contractLongValues map[common.Hash]*utils.LongValueAssembler // long values of each contract watched with Addresses
+	contractLongLock   *sync.Mutex                               // guards contractLongValues, shared by copies of the transformer
 }
 
 func (transformer Transformer) NewTransformer(db *postgres.DB) transformer.StorageTransformer {
 	transformer.StorageKeysLookup.SetDB(db)
 	transformer.Repository.SetDB(db)
+	if transformer.Addresses != nil {
+		transformer.Addresses.SetDB(db)
+		transformer.contractLongValues = make(map[common.Hash]*utils.LongValueAssembler)
+		transformer.contractLongLock = &sync.Mutex{}
+	}
+	if transformer.LongValues == nil {
+		transformer.LongValues = utils.NewLongValueAssembler()
+		transformer.LongValues.Loader = repositories.NewStorageDiffRepository(db)
+	}
 	return transformer
 }
 
@@ -39,14 +57,123 @@ func (transformer Transformer) KeccakContractAddress() common.Hash {
 	return transformer.HashedAddress
 }
 
+// KeccakContractAddresses returns the hashed addresses of the contracts the transformer watches.
+// HashedAddress still identifies the transformer when it watches Addresses.
+func (transformer Transformer) KeccakContractAddresses() ([]common.Hash, error) {
+	if transformer.Addresses == nil {
+		return []common.Hash{transformer.HashedAddress}, nil
+	}
+	addresses, loadErr := transformer.Addresses.LoadAddresses()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	hashedAddresses := make([]common.Hash, 0, len(addresses))
+	for _, address := range addresses {
+		hashedAddresses = append(hashedAddresses, crypto.Keccak256Hash(address.Bytes()))
+	}
+	return hashedAddresses, nil
+}
+
 func (transformer Transformer) Execute(diff utils.PersistedStorageDiff) error {
+	longValues := transformer.longValues(diff.HashedAddress)
+	if longValues != nil {
+		rebuildErr := transformer.rebuildLongValues(longValues, diff)
+		if rebuildErr != nil {
+			return rebuildErr
+		}
+		metadata, value, complete, isData, dataErr := longValues.AddData(diff)
+		if dataErr != nil {
+			return dataErr
+		}
+		if isData {
+			return transformer.createIfComplete(diff, metadata, value, complete)
+		}
+	}
 	metadata, lookupErr := transformer.StorageKeysLookup.Lookup(diff.StorageKey)
 	if lookupErr != nil {
 		return lookupErr
 	}
+	if longValues != nil && (metadata.Type == utils.Bytes || metadata.Type == utils.String) {
+		value, complete, addErr := longValues.AddValue(diff, metadata)
+		if addErr != nil {
+			return addErr
+		}
+		return transformer.createIfComplete(diff, metadata, value, complete)
+	}
 	value, decodeErr := utils.Decode(diff, metadata)
 	if decodeErr != nil {
 		return decodeErr
 	}
+	return transformer.create(diff, metadata, value)
+}
+
+// Revert deletes the values created from the given diffs, returning an error if the repository can't delete values
+func (transformer Transformer) Revert(diffs []utils.PersistedStorageDiff) error {
+	repository, ok := transformer.Repository.(RevertibleRepository)
+	if !ok {
+		return fmt.Errorf("storage repository for %s cannot delete values from %d non-canonical diffs",
+			transformer.HashedAddress.Hex(), len(diffs))
+	}
+	for _, diff := range diffs {
+		deleteErr := repository.Delete(diff.ID)
+		if deleteErr != nil {
+			return deleteErr
+		}
+	}
+	return nil
+}
+
+// Contracts watched with Addresses each assemble their own long values, since they share slots
+func (transformer Transformer) longValues(hashedAddress common.Hash) *utils.LongValueAssembler {
+	if transformer.contractLongValues == nil {
+		return transformer.LongValues
+	}
+	transformer.contractLongLock.Lock()
+	defer transformer.contractLongLock.Unlock()
+	assembler, ok := transformer.contractLongValues[hashedAddress]
+	if !ok {
+		assembler = utils.NewLongValueAssembler()
+		if transformer.LongValues != nil {
+			assembler.Loader = transformer.LongValues.Loader
+		}
+		transformer.contractLongValues[hashedAddress] = assembler
+	}
+	return assembler
+}
+
+// Long values seen before a restart are rebuilt from storage_diff the first time a contract's diffs are transformed,
+// so that diffs of their data slots are recognized
+func (transformer Transformer) rebuildLongValues(longValues *utils.LongValueAssembler, diff utils.PersistedStorageDiff) error {
+	if longValues.Loader == nil || longValues.Rebuilt() {
+		return nil
+	}
+	mappings, mappingsErr := transformer.StorageKeysLookup.Mappings()
+	if mappingsErr != nil {
+		return mappingsErr
+	}
+	var metadata []utils.StorageValueMetadata
+	valueSlots := make(map[common.Hash]bool)
+	for _, valueMetadata := range mappings {
+		if (valueMetadata.Type == utils.Bytes || valueMetadata.Type == utils.String) && !valueSlots[valueMetadata.Slot] {
+			valueSlots[valueMetadata.Slot] = true
+			metadata = append(metadata, valueMetadata)
+		}
+	}
+	return longValues.Rebuild(diff, metadata)
+}
+
+// A long value is only persisted once all of its data slots have been seen
+func (transformer Transformer) createIfComplete(diff utils.PersistedStorageDiff, metadata utils.StorageValueMetadata, value interface{}, complete bool) error {
+	if !complete {
+		return nil
+	}
+	return transformer.create(diff, metadata, value)
+}
+
+// Repositories shared by several contracts are told which contract a value came from
+func (transformer Transformer) create(diff utils.PersistedStorageDiff, metadata utils.StorageValueMetadata, value interface{}) error {
+	if repository, ok := transformer.Repository.(AddressRepository); ok {
+		return repository.CreateForAddress(diff.ID, diff.HashedAddress, metadata, value)
+	}
 	return transformer.Repository.Create(diff.ID, metadata, value)
 }
@@ -17,14 +17,18 @@
 package storage_test
 
 import (
+	"math/big"
 	"math/rand"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/test_data"
 	"github.com/vulcanize/vulcanizedb/pkg/fakes"
 )
 
@@ -52,6 +56,39 @@ var _ = Describe("Storage transformer", func() {
 		Expect(t.KeccakContractAddress()).To(Equal(fakeAddress))
 	})
 
+	Describe("KeccakContractAddresses", func() {
+		It("returns the contract address if the transformer doesn't load addresses", func() {
+			fakeAddress := utils.HexToKeccak256Hash("0x12345")
+			t.HashedAddress = fakeAddress
+
+			hashedAddresses, err := t.KeccakContractAddresses()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hashedAddresses).To(Equal([]common.Hash{fakeAddress}))
+		})
+
+		It("returns the hashed addresses of the loaded contracts", func() {
+			addresses := []common.Address{common.HexToAddress("0x12345"), common.HexToAddress("0x67890")}
+			t.Addresses = &mocks.MockAddressesLoader{Addresses: addresses}
+
+			hashedAddresses, err := t.KeccakContractAddresses()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hashedAddresses).To(Equal([]common.Hash{
+				crypto.Keccak256Hash(addresses[0].Bytes()),
+				crypto.Keccak256Hash(addresses[1].Bytes()),
+			}))
+		})
+
+		It("returns error if loading addresses fails", func() {
+			t.Addresses = &mocks.MockAddressesLoader{LoadAddressesError: fakes.FakeError}
+
+			_, err := t.KeccakContractAddresses()
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
 	It("looks up metadata for storage key", func() {
 		t.Execute(utils.PersistedStorageDiff{})
 
@@ -105,6 +142,22 @@ var _ = Describe("Storage transformer", func() {
 		Expect(err).To(MatchError(fakes.FakeError))
 	})
 
+	It("passes the diff's contract address to repositories shared by several contracts", func() {
+		addressRepository := &mocks.MockAddressStorageRepository{}
+		t.Repository = addressRepository
+		storageKeysLookup.Metadata = utils.StorageValueMetadata{Type: utils.Uint256}
+		diff := utils.PersistedStorageDiff{
+			ID:               rand.Int63(),
+			StorageDiffInput: utils.StorageDiffInput{HashedAddress: test_data.FakeHash()},
+		}
+
+		err := t.Execute(diff)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addressRepository.PassedHashedAddress).To(Equal(diff.HashedAddress))
+		Expect(addressRepository.PassedDiffID).To(Equal(diff.ID))
+	})
+
 	Describe("when a storage row contains more than one item packed in storage", func() {
 		var (
 			rawValue        = common.HexToAddress("000000000000000000000000000000000000000000000002a300000000002a30")
@@ -157,4 +210,115 @@ var _ = Describe("Storage transformer", func() {
 			Expect(err).To(MatchError(fakes.FakeError))
 		})
 	})
+
+	Describe("when a storage row contains a long string", func() {
+		var (
+			metadata  = utils.GetStorageValueMetadataForDynamicValue("name", nil, utils.String, common.HexToHash(utils.IndexOne))
+			longValue = strings.Repeat("vulcanize", 4)
+			dataSlot  = crypto.Keccak256Hash(common.HexToHash(utils.IndexOne).Bytes())
+			valueDiff utils.PersistedStorageDiff
+		)
+
+		BeforeEach(func() {
+			t.LongValues = utils.NewLongValueAssembler()
+			storageKeysLookup.Metadata = metadata
+			valueDiff = utils.PersistedStorageDiff{
+				ID: rand.Int63(),
+				StorageDiffInput: utils.StorageDiffInput{
+					StorageKey:   common.HexToHash(utils.IndexOne),
+					StorageValue: common.BigToHash(big.NewInt(int64(len(longValue)*2 + 1))),
+				},
+			}
+		})
+
+		It("does not create a row until the value's data has been seen", func() {
+			err := t.Execute(valueDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repository.PassedValue).To(BeNil())
+		})
+
+		It("passes the assembled value to the repository", func() {
+			executeErr := t.Execute(valueDiff)
+			Expect(executeErr).NotTo(HaveOccurred())
+			dataDiffs := []utils.PersistedStorageDiff{
+				{ID: rand.Int63(), StorageDiffInput: utils.StorageDiffInput{
+					StorageKey:   dataSlot,
+					StorageValue: common.BytesToHash([]byte(longValue[:32])),
+				}},
+				{ID: rand.Int63(), StorageDiffInput: utils.StorageDiffInput{
+					StorageKey:   utils.GetIncrementedStorageKey(dataSlot, 1),
+					StorageValue: common.BytesToHash(common.RightPadBytes([]byte(longValue[32:]), 32)),
+				}},
+			}
+
+			for _, diff := range dataDiffs {
+				err := t.Execute(diff)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(repository.PassedDiffID).To(Equal(dataDiffs[1].ID))
+			Expect(repository.PassedMetadata).To(Equal(metadata))
+			Expect(repository.PassedValue).To(Equal(longValue))
+		})
+
+		It("rebuilds values seen before a restart from the loader", func() {
+			dataDiff := utils.PersistedStorageDiff{ID: rand.Int63(), StorageDiffInput: utils.StorageDiffInput{
+				StorageKey:   utils.GetIncrementedStorageKey(dataSlot, 1),
+				StorageValue: common.BytesToHash(common.RightPadBytes([]byte(longValue[32:]), 32)),
+			}}
+			loader := &mocks.MockLongValueLoader{Diffs: []utils.PersistedStorageDiff{
+				valueDiff,
+				{StorageDiffInput: utils.StorageDiffInput{StorageKey: dataSlot, StorageValue: common.BytesToHash([]byte(longValue[:32]))}},
+				dataDiff,
+			}}
+			t.LongValues.Loader = loader
+			storageKeysLookup.StorageKeys = map[common.Hash]utils.StorageValueMetadata{metadata.Slot: metadata}
+
+			err := t.Execute(dataDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storageKeysLookup.MappingsCalled).To(BeTrue())
+			Expect(repository.PassedDiffID).To(Equal(dataDiff.ID))
+			Expect(repository.PassedValue).To(Equal(longValue))
+		})
+
+		It("returns error if rebuilding values fails", func() {
+			t.LongValues.Loader = &mocks.MockLongValueLoader{}
+			storageKeysLookup.MappingsErr = fakes.FakeError
+
+			err := t.Execute(valueDiff)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
+	Describe("Revert", func() {
+		diffs := []utils.PersistedStorageDiff{{ID: rand.Int63()}, {ID: rand.Int63()}}
+
+		It("deletes the values created from the diffs", func() {
+			revertibleRepository := &mocks.MockRevertibleStorageRepository{}
+			t.Repository = revertibleRepository
+
+			err := t.Revert(diffs)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revertibleRepository.DeletedDiffIDs).To(Equal([]int64{diffs[0].ID, diffs[1].ID}))
+		})
+
+		It("returns error if deleting a value fails", func() {
+			t.Repository = &mocks.MockRevertibleStorageRepository{DeleteErr: fakes.FakeError}
+
+			err := t.Revert(diffs)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+
+		It("returns error if the repository cannot delete values", func() {
+			err := t.Revert(diffs)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cannot delete values"))
+		})
+	})
 })
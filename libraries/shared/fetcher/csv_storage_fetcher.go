@@ -0,0 +1,103 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
+)
+
+const DefaultCsvCheckpointInterval = 1000
+
+// CsvStorageFetcher reads storage diffs from a CSV file or directory of segments, resuming from the source's
+// last checkpoint. Rows that can't be parsed are appended to the rejects file, if there is one.
+type CsvStorageFetcher struct {
+	CheckpointInterval int // lines read between checkpoints
+	source             string
+	follower           fs.Follower
+	checkpoints        datastore.StorageDiffCheckpointRepository
+	rejects            fs.Appender
+}
+
+func NewCsvStorageFetcher(source string, follower fs.Follower, checkpoints datastore.StorageDiffCheckpointRepository, rejects fs.Appender) CsvStorageFetcher {
+	return CsvStorageFetcher{
+		CheckpointInterval: DefaultCsvCheckpointInterval,
+		source:             source,
+		follower:           follower,
+		checkpoints:        checkpoints,
+		rejects:            rejects,
+	}
+}
+
+func (storageFetcher CsvStorageFetcher) FetchStorageDiffs(out chan<- utils.StorageDiffInput, errs chan<- error) {
+	from, checkpointErr := storageFetcher.checkpoints.GetCheckpoint(storageFetcher.source)
+	if checkpointErr != nil {
+		errs <- checkpointErr
+		return
+	}
+	logrus.Infof("fetching storage diffs from %s at %s offset %d", storageFetcher.source, from.Segment, from.Offset)
+	lines := make(chan fs.Line)
+	followErrs := make(chan error, 1)
+	go func() {
+		followErrs <- storageFetcher.follower.Follow(from, lines)
+		close(lines)
+	}()
+
+	// a line is only checkpointed once the diffs buffered after it in out show it has been processed
+	var unprocessed []fs.Position
+	sinceCheckpoint := 0
+	for line := range lines {
+		storageFetcher.handleLine(line, out, errs)
+		unprocessed = append(unprocessed, line.Position)
+		if len(unprocessed) <= cap(out)+1 {
+			continue
+		}
+		processed := unprocessed[0]
+		unprocessed = unprocessed[1:]
+		sinceCheckpoint++
+		if sinceCheckpoint >= storageFetcher.CheckpointInterval {
+			sinceCheckpoint = 0
+			saveErr := storageFetcher.checkpoints.SaveCheckpoint(storageFetcher.source, processed)
+			if saveErr != nil {
+				errs <- saveErr
+			}
+		}
+	}
+	followErr := <-followErrs
+	if followErr != nil {
+		errs <- followErr
+	}
+}
+
+func (storageFetcher CsvStorageFetcher) handleLine(line fs.Line, out chan<- utils.StorageDiffInput, errs chan<- error) {
+	diff, parseErr := utils.FromParityCsvRow(strings.Split(line.Text, ","))
+	if parseErr == nil {
+		out <- diff
+		return
+	}
+	if storageFetcher.rejects != nil {
+		rejectErr := storageFetcher.rejects.Append(line.Text)
+		if rejectErr != nil {
+			errs <- rejectErr
+		}
+	}
+	errs <- parseErr
+}
@@ -0,0 +1,147 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
+)
+
+var _ = Describe("Csv storage fetcher", func() {
+	var (
+		source         = "/data/diffs"
+		checkpoints    *fakes.MockStorageDiffCheckpointRepository
+		follower       *fakes.MockFollower
+		rejects        *fakes.MockAppender
+		diffsChannel   chan utils.StorageDiffInput
+		errorsChannel  chan error
+		storageFetcher fetcher.CsvStorageFetcher
+	)
+
+	BeforeEach(func() {
+		checkpoints = &fakes.MockStorageDiffCheckpointRepository{}
+		follower = &fakes.MockFollower{}
+		rejects = &fakes.MockAppender{}
+		diffsChannel = make(chan utils.StorageDiffInput)
+		errorsChannel = make(chan error)
+		storageFetcher = fetcher.NewCsvStorageFetcher(source, follower, checkpoints, rejects)
+	})
+
+	It("adds error to errors channel if getting the checkpoint fails", func(done Done) {
+		checkpoints.GetCheckpointErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+		close(done)
+	})
+
+	It("follows the source from its checkpoint", func(done Done) {
+		checkpoints.Checkpoint = fs.Position{Segment: "diffs.2.csv", Offset: 123}
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Eventually(func() fs.Position {
+			return follower.PassedPosition
+		}).Should(Equal(checkpoints.Checkpoint))
+		close(done)
+	})
+
+	It("adds parsed csv row to diffs channel", func(done Done) {
+		line := fs.Line{Text: getFakeRow()}
+		follower.Lines = []fs.Line{line}
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		expectedDiff, err := utils.FromParityCsvRow(strings.Split(line.Text, ","))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(<-diffsChannel).To(Equal(expectedDiff))
+		close(done)
+	})
+
+	It("appends rows that can't be parsed to the rejects file", func(done Done) {
+		follower.Lines = []fs.Line{{Text: "invalid"}}
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(utils.ErrRowMalformed{Length: 1}))
+		Expect(rejects.AppendedLines).To(Equal([]string{"invalid"}))
+		close(done)
+	})
+
+	It("adds error to errors channel if appending a reject fails", func(done Done) {
+		follower.Lines = []fs.Line{{Text: "invalid"}}
+		rejects.AppendErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+		Expect(<-errorsChannel).To(MatchError(utils.ErrRowMalformed{Length: 1}))
+		close(done)
+	})
+
+	It("checkpoints lines once the diff after them has been received", func(done Done) {
+		text := getFakeRow()
+		follower.Lines = []fs.Line{
+			{Text: text, Position: fs.Position{Segment: "diffs.csv", Offset: 1}},
+			{Text: text, Position: fs.Position{Segment: "diffs.csv", Offset: 2}},
+			{Text: text, Position: fs.Position{Segment: "diffs.csv", Offset: 3}},
+		}
+		storageFetcher.CheckpointInterval = 1
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		for range follower.Lines {
+			<-diffsChannel
+		}
+		Eventually(func() []fs.Position {
+			return checkpoints.SavedCheckpoints
+		}).Should(Equal([]fs.Position{
+			{Segment: "diffs.csv", Offset: 1},
+			{Segment: "diffs.csv", Offset: 2},
+		}))
+		Expect(checkpoints.SavePassedSource).To(Equal(source))
+		close(done)
+	})
+
+	It("adds error to errors channel if following the source fails", func(done Done) {
+		follower.FollowErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+		close(done)
+	})
+})
+
+func getFakeRow() string {
+	address := common.HexToAddress("0x1234567890abcdef")
+	blockHash := []byte{4, 5, 6}
+	blockHeight := int64(789)
+	storageKey := []byte{9, 8, 7}
+	storageValue := []byte{6, 5, 4}
+	return fmt.Sprintf("%s,%s,%d,%s,%s", common.Bytes2Hex(address.Bytes()), common.Bytes2Hex(blockHash),
+		blockHeight, common.Bytes2Hex(storageKey), common.Bytes2Hex(storageValue))
+}
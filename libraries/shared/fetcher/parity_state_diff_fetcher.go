@@ -0,0 +1,291 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/eth/client"
+)
+
+const (
+	parityBlockByNumberMethod = "eth_getBlockByNumber"
+	parityBlockNumberMethod   = "eth_blockNumber"
+	parityReplayMethod        = "trace_replayBlockTransactions"
+)
+
+type ErrParityBlockNotFound struct {
+	BlockHeight uint64
+}
+
+func (e ErrParityBlockNotFound) Error() string {
+	return fmt.Sprintf("parity node returned no block at height %d", e.BlockHeight)
+}
+
+// ParityStateDiffFetcher fetches storage diffs from a Parity/OpenEthereum node with
+// trace_replayBlockTransactions(block, ["stateDiff"]), so no statediff-patched geth is required
+type ParityStateDiffFetcher struct {
+	client BatchClient
+}
+
+// NewParityStateDiffFetcher returns a ParityStateDiffFetcher; it satisfies StateDiffFetcher so it can drive the storage backfiller
+func NewParityStateDiffFetcher(bc BatchClient) *ParityStateDiffFetcher {
+	return &ParityStateDiffFetcher{client: bc}
+}
+
+// parityStorageChange is a single slot's entry in a Parity stateDiff: "=" when unchanged, otherwise
+// one of "+" (born), "-" (died) or "*" (changed from/to)
+type parityStorageChange struct {
+	Born    *common.Hash `json:"+"`
+	Died    *common.Hash `json:"-,"`
+	Changed *struct {
+		From common.Hash `json:"from"`
+		To   common.Hash `json:"to"`
+	} `json:"*"`
+}
+
+type parityAccountDiff struct {
+	Storage map[common.Hash]json.RawMessage `json:"storage"`
+}
+
+type parityTransactionTrace struct {
+	StateDiff map[common.Address]parityAccountDiff `json:"stateDiff"`
+}
+
+type parityBlockHeader struct {
+	Hash common.Hash `json:"hash"`
+}
+
+// parityBlockDiff holds the final value of every slot written in a block, keyed by address then slot
+type parityBlockDiff struct {
+	BlockHeight uint64
+	BlockHash   common.Hash
+	Storage     map[common.Address]map[common.Hash]common.Hash
+}
+
+// LatestBlock returns the node's current head block number
+func (fetcher *ParityStateDiffFetcher) LatestBlock() (uint64, error) {
+	var head hexutil.Uint64
+	batch := []client.BatchElem{{
+		Method: parityBlockNumberMethod,
+		Args:   []interface{}{},
+		Result: &head,
+	}}
+	batchErr := fetcher.client.BatchCall(batch)
+	if batchErr != nil {
+		return 0, fmt.Errorf("parityStateDiffFetcher err: %s", batchErr.Error())
+	}
+	if batch[0].Error != nil {
+		return 0, fmt.Errorf("parityStateDiffFetcher err: %s", batch[0].Error.Error())
+	}
+	return uint64(head), nil
+}
+
+// FetchStorageDiffsAt returns the storage diffs at the given block heights, collapsed to the final value per slot per block
+func (fetcher *ParityStateDiffFetcher) FetchStorageDiffsAt(blockHeights []uint64) ([]utils.StorageDiffInput, error) {
+	blockDiffs, fetchErr := fetcher.fetchBlockDiffs(blockHeights)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	var diffs []utils.StorageDiffInput
+	for _, blockDiff := range blockDiffs {
+		for _, address := range sortedAddresses(blockDiff.Storage) {
+			slots := blockDiff.Storage[address]
+			for _, slot := range sortedSlots(slots) {
+				diffs = append(diffs, utils.StorageDiffInput{
+					HashedAddress: crypto.Keccak256Hash(address.Bytes()),
+					BlockHash:     blockDiff.BlockHash,
+					BlockHeight:   int(blockDiff.BlockHeight),
+					StorageKey:    slot,
+					StorageValue:  slots[slot],
+				})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// FetchStateDiffsAt converts the Parity state diffs at the given block heights into statediff payloads
+func (fetcher *ParityStateDiffFetcher) FetchStateDiffsAt(blockHeights []uint64) ([]statediff.Payload, error) {
+	blockDiffs, fetchErr := fetcher.fetchBlockDiffs(blockHeights)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	payloads := make([]statediff.Payload, 0, len(blockDiffs))
+	for _, blockDiff := range blockDiffs {
+		stateDiff, convertErr := toStateDiff(blockDiff)
+		if convertErr != nil {
+			return nil, convertErr
+		}
+		stateDiffRlp, encodeErr := rlp.EncodeToBytes(stateDiff)
+		if encodeErr != nil {
+			return nil, encodeErr
+		}
+		payloads = append(payloads, statediff.Payload{StateDiffRlp: stateDiffRlp})
+	}
+	return payloads, nil
+}
+
+func (fetcher *ParityStateDiffFetcher) fetchBlockDiffs(blockHeights []uint64) ([]parityBlockDiff, error) {
+	batch := make([]client.BatchElem, 0, 2*len(blockHeights))
+	for _, height := range blockHeights {
+		hexHeight := hexutil.EncodeUint64(height)
+		batch = append(batch, client.BatchElem{
+			Method: parityBlockByNumberMethod,
+			Args:   []interface{}{hexHeight, false},
+			Result: new(parityBlockHeader),
+		}, client.BatchElem{
+			Method: parityReplayMethod,
+			Args:   []interface{}{hexHeight, []string{"stateDiff"}},
+			Result: new([]parityTransactionTrace),
+		})
+	}
+	batchErr := fetcher.client.BatchCall(batch)
+	if batchErr != nil {
+		return nil, fmt.Errorf("parityStateDiffFetcher err: %s", batchErr.Error())
+	}
+	blockDiffs := make([]parityBlockDiff, 0, len(blockHeights))
+	for i, height := range blockHeights {
+		headerElem, traceElem := batch[2*i], batch[2*i+1]
+		if headerElem.Error != nil {
+			return nil, fmt.Errorf("parityStateDiffFetcher err: %s", headerElem.Error.Error())
+		}
+		if traceElem.Error != nil {
+			return nil, fmt.Errorf("parityStateDiffFetcher err: %s", traceElem.Error.Error())
+		}
+		header := headerElem.Result.(*parityBlockHeader)
+		if header.Hash == (common.Hash{}) {
+			return nil, ErrParityBlockNotFound{BlockHeight: height}
+		}
+		blockDiff, collapseErr := collapseTraces(*traceElem.Result.(*[]parityTransactionTrace))
+		if collapseErr != nil {
+			return nil, collapseErr
+		}
+		blockDiff.BlockHeight = height
+		blockDiff.BlockHash = header.Hash
+		blockDiffs = append(blockDiffs, blockDiff)
+	}
+	return blockDiffs, nil
+}
+
+// collapseTraces folds the per-transaction storage changes of a block into the last value written to each slot
+func collapseTraces(traces []parityTransactionTrace) (parityBlockDiff, error) {
+	storage := make(map[common.Address]map[common.Hash]common.Hash)
+	for _, trace := range traces {
+		for address, accountDiff := range trace.StateDiff {
+			for slot, rawChange := range accountDiff.Storage {
+				value, changed, parseErr := parseStorageChange(rawChange)
+				if parseErr != nil {
+					return parityBlockDiff{}, fmt.Errorf("error parsing storage change for slot %s on %s: %s",
+						slot.Hex(), address.Hex(), parseErr.Error())
+				}
+				if !changed {
+					continue
+				}
+				if storage[address] == nil {
+					storage[address] = make(map[common.Hash]common.Hash)
+				}
+				storage[address][slot] = value
+			}
+		}
+	}
+	return parityBlockDiff{Storage: storage}, nil
+}
+
+func parseStorageChange(raw json.RawMessage) (common.Hash, bool, error) {
+	var unchanged string
+	if json.Unmarshal(raw, &unchanged) == nil {
+		return common.Hash{}, false, nil
+	}
+	var change parityStorageChange
+	unmarshalErr := json.Unmarshal(raw, &change)
+	if unmarshalErr != nil {
+		return common.Hash{}, false, unmarshalErr
+	}
+	switch {
+	case change.Born != nil:
+		return *change.Born, true, nil
+	case change.Changed != nil:
+		return change.Changed.To, true, nil
+	case change.Died != nil:
+		return common.Hash{}, true, nil
+	}
+	return common.Hash{}, false, fmt.Errorf("unrecognized storage change %s", string(raw))
+}
+
+// toStateDiff mirrors the statediff-patched geth payload so diffs decode with utils.FromGethStateDiff;
+// storage keys are the raw slots, as in the Parity CSV format
+func toStateDiff(blockDiff parityBlockDiff) (statediff.StateDiff, error) {
+	stateDiff := statediff.StateDiff{
+		BlockNumber: new(big.Int).SetUint64(blockDiff.BlockHeight),
+		BlockHash:   blockDiff.BlockHash,
+	}
+	for _, address := range sortedAddresses(blockDiff.Storage) {
+		slots := blockDiff.Storage[address]
+		account := statediff.AccountDiff{
+			Leaf: true,
+			Key:  crypto.Keccak256(address.Bytes()),
+		}
+		for _, slot := range sortedSlots(slots) {
+			value := slots[slot]
+			encodedValue, encodeErr := rlp.EncodeToBytes(bytes.TrimLeft(value.Bytes(), "\x00"))
+			if encodeErr != nil {
+				return statediff.StateDiff{}, encodeErr
+			}
+			account.Storage = append(account.Storage, statediff.StorageDiff{
+				Leaf:  true,
+				Key:   slot.Bytes(),
+				Value: encodedValue,
+			})
+		}
+		stateDiff.UpdatedAccounts = append(stateDiff.UpdatedAccounts, account)
+	}
+	return stateDiff, nil
+}
+
+func sortedAddresses(storage map[common.Address]map[common.Hash]common.Hash) []common.Address {
+	addresses := make([]common.Address, 0, len(storage))
+	for address := range storage {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+	return addresses
+}
+
+func sortedSlots(slots map[common.Hash]common.Hash) []common.Hash {
+	keys := make([]common.Hash, 0, len(slots))
+	for slot := range slots {
+		keys = append(keys, slot)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+	})
+	return keys
+}
@@ -0,0 +1,160 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Parity state diff fetcher", func() {
+	var (
+		mc            *mocks.ParityClient
+		parityFetcher *fetcher.ParityStateDiffFetcher
+		address       = common.HexToAddress("0x4a5f2a0a6e1d1e1c3a0b8ea6d8c4ae4b1e2e4d5f")
+		hashedAddress = crypto.Keccak256Hash(address.Bytes())
+		blockHash     = common.HexToHash("0xabc")
+		slotZero      = common.HexToHash("0x0")
+		slotOne       = common.HexToHash("0x1")
+		slotTwo       = common.HexToHash("0x2")
+		traces        = fmt.Sprintf(`[
+			{"stateDiff": {"%[1]s": {"storage": {
+				"%[2]s": {"+": "0x0000000000000000000000000000000000000000000000000000000000000001"},
+				"%[3]s": "=",
+				"%[4]s": {"-": "0x0000000000000000000000000000000000000000000000000000000000000007"}
+			}}}},
+			{"stateDiff": {"%[1]s": {"storage": {
+				"%[2]s": {"*": {"from": "0x0000000000000000000000000000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000000000000000000000000000123"}}
+			}}}}
+		]`, address.Hex(), slotZero.Hex(), slotOne.Hex(), slotTwo.Hex())
+	)
+
+	BeforeEach(func() {
+		mc = &mocks.ParityClient{}
+		mc.SetBlock(100, blockHash, traces)
+		parityFetcher = fetcher.NewParityStateDiffFetcher(mc)
+	})
+
+	Describe("FetchStorageDiffsAt", func() {
+		It("batch calls eth_getBlockByNumber and trace_replayBlockTransactions for each height", func() {
+			_, err := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mc.PassedBatches).To(HaveLen(1))
+			Expect(mc.PassedBatches[0]).To(HaveLen(2))
+			Expect(mc.PassedBatches[0][0].Method).To(Equal("eth_getBlockByNumber"))
+			Expect(mc.PassedBatches[0][0].Args).To(Equal([]interface{}{"0x64", false}))
+			Expect(mc.PassedBatches[0][1].Method).To(Equal("trace_replayBlockTransactions"))
+			Expect(mc.PassedBatches[0][1].Args).To(Equal([]interface{}{"0x64", []string{"stateDiff"}}))
+		})
+
+		It("collapses changes to the final value per slot, skipping unchanged slots", func() {
+			diffs, err := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal([]utils.StorageDiffInput{{
+				HashedAddress: hashedAddress,
+				BlockHash:     blockHash,
+				BlockHeight:   100,
+				StorageKey:    slotZero,
+				StorageValue:  common.HexToHash("0x123"),
+			}, {
+				HashedAddress: hashedAddress,
+				BlockHash:     blockHash,
+				BlockHeight:   100,
+				StorageKey:    slotTwo,
+				StorageValue:  common.Hash{},
+			}}))
+		})
+
+		It("returns an error if the block is not found", func() {
+			_, err := parityFetcher.FetchStorageDiffsAt([]uint64{101})
+
+			Expect(err).To(MatchError(fetcher.ErrParityBlockNotFound{BlockHeight: 101}))
+		})
+
+		It("returns an error if the batch call fails", func() {
+			mc.BatchCallErr = fakes.FakeError
+
+			_, err := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+
+			Expect(err).To(MatchError(ContainSubstring(fakes.FakeError.Error())))
+		})
+
+		It("returns an error if replaying the block fails", func() {
+			mc.TraceErr = fakes.FakeError
+
+			_, err := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+
+			Expect(err).To(MatchError(ContainSubstring(fakes.FakeError.Error())))
+		})
+
+		It("returns an error if a storage change is not recognized", func() {
+			mc.SetBlock(100, blockHash, fmt.Sprintf(`[{"stateDiff": {"%s": {"storage": {"%s": {"?": "0x1"}}}}}]`,
+				address.Hex(), slotZero.Hex()))
+
+			_, err := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+
+			Expect(err).To(MatchError(ContainSubstring("unrecognized storage change")))
+		})
+	})
+
+	Describe("FetchStateDiffsAt", func() {
+		It("returns payloads that decode into the same storage diffs", func() {
+			expectedDiffs, fetchDiffsErr := parityFetcher.FetchStorageDiffsAt([]uint64{100})
+			Expect(fetchDiffsErr).NotTo(HaveOccurred())
+
+			payloads, err := parityFetcher.FetchStateDiffsAt([]uint64{100})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payloads).To(HaveLen(1))
+			stateDiff := new(statediff.StateDiff)
+			Expect(rlp.DecodeBytes(payloads[0].StateDiffRlp, stateDiff)).To(Succeed())
+			var diffs []utils.StorageDiffInput
+			for _, account := range utils.GetAccountsFromDiff(*stateDiff) {
+				for _, storage := range account.Storage {
+					diff, formatErr := utils.FromGethStateDiff(account, stateDiff, storage)
+					Expect(formatErr).NotTo(HaveOccurred())
+					diffs = append(diffs, diff)
+				}
+			}
+			Expect(diffs).To(Equal(expectedDiffs))
+		})
+	})
+
+	Describe("LatestBlock", func() {
+		It("returns the node's head block number", func() {
+			mc.Head = 123
+
+			head, err := parityFetcher.LatestBlock()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(head).To(Equal(uint64(123)))
+		})
+	})
+})
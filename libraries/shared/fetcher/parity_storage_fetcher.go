@@ -0,0 +1,91 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+)
+
+const (
+	DefaultParityPollingInterval = 7 * time.Second
+	DefaultParityBatchSize       = 100
+)
+
+// ParityStorageFetcher polls a Parity/OpenEthereum node for new blocks and replays each one
+// with trace_replayBlockTransactions to produce its storage diffs
+type ParityStorageFetcher struct {
+	PollingInterval time.Duration
+	BatchSize       uint64
+	fetcher         *ParityStateDiffFetcher
+	nextBlock       uint64
+}
+
+// NewParityStorageFetcher returns a fetcher that starts at the node's head; earlier blocks are covered by back-fill
+func NewParityStorageFetcher(bc BatchClient) *ParityStorageFetcher {
+	return &ParityStorageFetcher{
+		PollingInterval: DefaultParityPollingInterval,
+		BatchSize:       DefaultParityBatchSize,
+		fetcher:         NewParityStateDiffFetcher(bc),
+	}
+}
+
+func (fetcher *ParityStorageFetcher) FetchStorageDiffs(out chan<- utils.StorageDiffInput, errs chan<- error) {
+	ticker := time.NewTicker(fetcher.PollingInterval)
+	defer ticker.Stop()
+	for {
+		pollErr := fetcher.poll(out)
+		if pollErr != nil {
+			logrus.Warnf("error fetching parity storage diffs: %s", pollErr.Error())
+			errs <- pollErr
+		}
+		<-ticker.C
+	}
+}
+
+// poll sends the diffs of every block between the last one fetched and the node's head
+func (fetcher *ParityStorageFetcher) poll(out chan<- utils.StorageDiffInput) error {
+	head, headErr := fetcher.fetcher.LatestBlock()
+	if headErr != nil {
+		return headErr
+	}
+	if fetcher.nextBlock == 0 {
+		fetcher.nextBlock = head
+	}
+	for fetcher.nextBlock <= head {
+		lastBlock := fetcher.nextBlock + fetcher.BatchSize - 1
+		if lastBlock > head {
+			lastBlock = head
+		}
+		heights := make([]uint64, 0, lastBlock-fetcher.nextBlock+1)
+		for height := fetcher.nextBlock; height <= lastBlock; height++ {
+			heights = append(heights, height)
+		}
+		diffs, fetchErr := fetcher.fetcher.FetchStorageDiffsAt(heights)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		for _, diff := range diffs {
+			out <- diff
+		}
+		fetcher.nextBlock = lastBlock + 1
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Parity storage fetcher", func() {
+	var (
+		mc             *mocks.ParityClient
+		storageFetcher *fetcher.ParityStorageFetcher
+		diffsChan      chan utils.StorageDiffInput
+		errsChan       chan error
+		traces         = `[{"stateDiff": {"0x0000000000000000000000000000000000000001": {"storage": {
+			"0x0000000000000000000000000000000000000000000000000000000000000000": {"+": "0x0000000000000000000000000000000000000000000000000000000000000001"}
+		}}}}]`
+	)
+
+	BeforeEach(func() {
+		mc = &mocks.ParityClient{Head: 100}
+		mc.SetBlock(100, common.HexToHash("0x64"), traces)
+		mc.SetBlock(101, common.HexToHash("0x65"), traces)
+		storageFetcher = fetcher.NewParityStorageFetcher(mc)
+		storageFetcher.PollingInterval = time.Millisecond
+		diffsChan = make(chan utils.StorageDiffInput)
+		errsChan = make(chan error)
+	})
+
+	It("starts at the node's head and sends diffs for each new block", func(done Done) {
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect((<-diffsChan).BlockHeight).To(Equal(100))
+		mc.Head = 101
+		Expect((<-diffsChan).BlockHeight).To(Equal(101))
+		close(done)
+	})
+
+	It("adds errors to the error channel if fetching fails", func(done Done) {
+		mc.BatchCallErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-errsChan).To(MatchError(ContainSubstring(fakes.FakeError.Error())))
+		close(done)
+	})
+})
@@ -0,0 +1,227 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+)
+
+var ErrMissingHeader = errors.New("super node payload does not include a header")
+
+const (
+	// DefaultSubscriptionRetryDelay is the wait before the first attempt to re-open a failed super node subscription
+	DefaultSubscriptionRetryDelay = time.Second
+	// DefaultMaxSubscriptionRetryDelay caps the wait between attempts, which doubles with each consecutive failure
+	DefaultMaxSubscriptionRetryDelay = time.Minute
+)
+
+// SuperNodeStorageFetcher streams storage diffs from a super node subscription,
+// allowing many storage watchers to share one statediffing geth node
+type SuperNodeStorageFetcher struct {
+	// WatchedAddresses returns the keccak hashed addresses of the contracts whose storage is streamed, all contracts if
+	// it is nil or returns none; the subscription is re-opened when they change
+	WatchedAddresses func() []common.Hash
+	PayloadChan      chan streamer.SuperNodePayload
+	RetryDelay       time.Duration
+	MaxRetryDelay    time.Duration
+	streamer         streamer.ISuperNodeStreamer
+	lastBlock        *big.Int
+	subscribed       map[common.Hash]bool
+}
+
+func NewSuperNodeStorageFetcher(superNodeStreamer streamer.ISuperNodeStreamer) *SuperNodeStorageFetcher {
+	return &SuperNodeStorageFetcher{
+		PayloadChan:   make(chan streamer.SuperNodePayload, PayloadChanBufferSize),
+		RetryDelay:    DefaultSubscriptionRetryDelay,
+		MaxRetryDelay: DefaultMaxSubscriptionRetryDelay,
+		streamer:      superNodeStreamer,
+	}
+}
+
+// FetchStorageDiffs sends the storage leaf nodes streamed by the super node to out as diffs
+// If the subscription can't be opened or fails it is re-opened after a backoff, back filling from the block after the
+// last one received
+func (fetcher *SuperNodeStorageFetcher) FetchStorageDiffs(out chan<- utils.StorageDiffInput, errs chan<- error) {
+	delay := fetcher.RetryDelay
+	for {
+		subscription, subscribeErr := fetcher.streamer.Stream(fetcher.PayloadChan, fetcher.subscriptionFilters())
+		if subscribeErr != nil {
+			logrus.Errorf("error creating a super node subscription, retrying in %s: %s", delay, subscribeErr.Error())
+			errs <- subscribeErr
+			delay = fetcher.backOff(delay)
+			continue
+		}
+		logrus.Info("Successfully created a super node subscription: ", subscription)
+		received, subscriptionErr := fetcher.streamDiffs(subscription, out, errs)
+		if subscription != nil {
+			subscription.Unsubscribe()
+		}
+		if received {
+			delay = fetcher.RetryDelay
+		}
+		if subscriptionErr == nil {
+			logrus.Info("watched contracts changed, re-opening the super node subscription")
+			continue
+		}
+		logrus.Warnf("super node subscription error, retrying in %s: %s", delay, subscriptionErr.Error())
+		errs <- subscriptionErr
+		delay = fetcher.backOff(delay)
+	}
+}
+
+// backOff waits for delay, returning the delay before the next attempt
+func (fetcher *SuperNodeStorageFetcher) backOff(delay time.Duration) time.Duration {
+	time.Sleep(delay)
+	delay *= 2
+	if delay > fetcher.MaxRetryDelay {
+		return fetcher.MaxRetryDelay
+	}
+	return delay
+}
+
+func (fetcher *SuperNodeStorageFetcher) subscriptionFilters() config.Subscription {
+	startingBlock := big.NewInt(0)
+	if fetcher.lastBlock != nil {
+		startingBlock.Add(fetcher.lastBlock, big.NewInt(1))
+	}
+	hashedAddresses := fetcher.watchedAddresses()
+	fetcher.subscribed = make(map[common.Hash]bool, len(hashedAddresses))
+	stateKeys := make([]string, 0, len(hashedAddresses))
+	for _, hashedAddress := range hashedAddresses {
+		fetcher.subscribed[hashedAddress] = true
+		stateKeys = append(stateKeys, hashedAddress.Hex())
+	}
+	return config.Subscription{
+		BackFill:      fetcher.lastBlock != nil,
+		StartingBlock: startingBlock,
+		EndingBlock:   big.NewInt(0),
+		HeaderFilter:  config.HeaderFilter{},
+		TrxFilter:     config.TrxFilter{Off: true},
+		ReceiptFilter: config.ReceiptFilter{Off: true},
+		StateFilter:   config.StateFilter{Off: true},
+		StorageFilter: config.StorageFilter{StateKeys: stateKeys},
+	}
+}
+
+func (fetcher *SuperNodeStorageFetcher) watchedAddresses() []common.Hash {
+	if fetcher.WatchedAddresses == nil {
+		return nil
+	}
+	return fetcher.WatchedAddresses()
+}
+
+// watchedAddressesChanged is whether contracts have been watched or unwatched since subscribing
+func (fetcher *SuperNodeStorageFetcher) watchedAddressesChanged() bool {
+	hashedAddresses := fetcher.watchedAddresses()
+	if len(hashedAddresses) != len(fetcher.subscribed) {
+		return true
+	}
+	for _, hashedAddress := range hashedAddresses {
+		if !fetcher.subscribed[hashedAddress] {
+			return true
+		}
+	}
+	return false
+}
+
+// Streams diffs until the subscription fails, returning its error, or until the watched contracts change, returning nil.
+// Reports whether any payloads were received
+func (fetcher *SuperNodeStorageFetcher) streamDiffs(subscription *rpc.ClientSubscription, out chan<- utils.StorageDiffInput, errs chan<- error) (bool, error) {
+	var subscriptionErrs <-chan error
+	if subscription != nil {
+		subscriptionErrs = subscription.Err()
+	}
+	received := false
+	for {
+		select {
+		case payload := <-fetcher.PayloadChan:
+			logrus.Trace("received a super node payload")
+			received = true
+			diffs, decodeErr := decodeStorageDiffs(payload)
+			if decodeErr != nil {
+				logrus.Warn("error decoding storage diffs from super node payload: ", decodeErr)
+				errs <- decodeErr
+				continue
+			}
+			for _, diff := range diffs {
+				out <- diff
+			}
+			if payload.BlockNumber != nil {
+				fetcher.lastBlock = payload.BlockNumber
+			}
+			if fetcher.watchedAddressesChanged() {
+				return received, nil
+			}
+		case subscriptionErr := <-subscriptionErrs:
+			return received, subscriptionErr
+		}
+	}
+}
+
+// Storage leaf nodes hold the RLP encoded value of a slot; intermediate nodes are RLP lists and are skipped
+func decodeStorageDiffs(payload streamer.SuperNodePayload) ([]utils.StorageDiffInput, error) {
+	if payload.ErrMsg != "" {
+		return nil, errors.New(payload.ErrMsg)
+	}
+	if len(payload.HeadersRlp) < 1 {
+		return nil, fmt.Errorf("%s: block %s", ErrMissingHeader.Error(), payload.BlockNumber.String())
+	}
+	var header types.Header
+	headerErr := rlp.DecodeBytes(payload.HeadersRlp[0], &header)
+	if headerErr != nil {
+		return nil, headerErr
+	}
+
+	var diffs []utils.StorageDiffInput
+	for hashedAddress, storageNodes := range payload.StorageNodesRlp {
+		for storageKey, node := range storageNodes {
+			kind, _, _, splitErr := rlp.Split(node)
+			if splitErr != nil {
+				return nil, splitErr
+			}
+			if kind == rlp.List {
+				continue
+			}
+			var value []byte
+			decodeErr := rlp.DecodeBytes(node, &value)
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			diffs = append(diffs, utils.StorageDiffInput{
+				HashedAddress: hashedAddress,
+				BlockHash:     header.Hash(),
+				BlockHeight:   int(header.Number.Int64()),
+				StorageKey:    storageKey,
+				StorageValue:  common.BytesToHash(value),
+			})
+		}
+	}
+	return diffs, nil
+}
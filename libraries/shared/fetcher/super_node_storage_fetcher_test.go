@@ -0,0 +1,211 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/test_data"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Super node storage fetcher", func() {
+	var (
+		superNodeStreamer *mocks.SuperNodeStreamer
+		storageFetcher    *fetcher.SuperNodeStorageFetcher
+		diffsChan         chan utils.StorageDiffInput
+		errsChan          chan error
+		header            = types.Header{Number: big.NewInt(100)}
+		hashedAddress     = test_data.FakeHash()
+		storageKey        = test_data.FakeHash()
+		storageValue      = common.HexToHash("0x0123")
+		payload           streamer.SuperNodePayload
+	)
+
+	BeforeEach(func() {
+		superNodeStreamer = &mocks.SuperNodeStreamer{}
+		storageFetcher = fetcher.NewSuperNodeStorageFetcher(superNodeStreamer)
+		storageFetcher.WatchedAddresses = func() []common.Hash { return []common.Hash{hashedAddress} }
+		storageFetcher.RetryDelay = time.Millisecond
+		storageFetcher.MaxRetryDelay = time.Millisecond
+		diffsChan = make(chan utils.StorageDiffInput)
+		errsChan = make(chan error)
+		headerRlp, headerErr := rlp.EncodeToBytes(&header)
+		Expect(headerErr).NotTo(HaveOccurred())
+		leafRlp, leafErr := rlp.EncodeToBytes([]byte{0x01, 0x23})
+		Expect(leafErr).NotTo(HaveOccurred())
+		payload = streamer.SuperNodePayload{
+			BlockNumber: header.Number,
+			HeadersRlp:  [][]byte{headerRlp},
+			StorageNodesRlp: map[common.Hash]map[common.Hash][]byte{
+				hashedAddress: {storageKey: leafRlp},
+			},
+		}
+	})
+
+	It("subscribes to the watched contracts' storage", func(done Done) {
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Eventually(func() []config.Subscription {
+			return superNodeStreamer.PassedFilters
+		}).Should(HaveLen(1))
+		filters := superNodeStreamer.PassedFilters[0]
+		Expect(filters.StorageFilter).To(Equal(config.StorageFilter{StateKeys: []string{hashedAddress.Hex()}}))
+		Expect(filters.BackFill).To(BeFalse())
+		Expect(filters.HeaderFilter.Off).To(BeFalse())
+		Expect(filters.TrxFilter.Off).To(BeTrue())
+		Expect(filters.ReceiptFilter.Off).To(BeTrue())
+		Expect(filters.StateFilter.Off).To(BeTrue())
+		close(done)
+	})
+
+	It("subscribes to every contract's storage if none are watched", func(done Done) {
+		storageFetcher.WatchedAddresses = nil
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Eventually(func() []config.Subscription {
+			return superNodeStreamer.PassedFilters
+		}).Should(HaveLen(1))
+		Expect(superNodeStreamer.PassedFilters[0].StorageFilter.StateKeys).To(BeEmpty())
+		close(done)
+	})
+
+	It("adds errors to error channel if the subscription fails", func(done Done) {
+		superNodeStreamer.ReturnErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-errsChan).To(MatchError(fakes.FakeError))
+		close(done)
+	})
+
+	It("keeps retrying to subscribe if the subscription can't be opened", func(done Done) {
+		superNodeStreamer.ReturnErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-errsChan).To(MatchError(fakes.FakeError))
+		Expect(<-errsChan).To(MatchError(fakes.FakeError))
+		Expect(len(superNodeStreamer.PassedFilters)).To(BeNumerically(">=", 2))
+		close(done)
+	})
+
+	It("re-subscribes from the block after the last one received when the watched contracts change", func(done Done) {
+		newHashedAddress := test_data.FakeHash()
+		calls := 0
+		storageFetcher.WatchedAddresses = func() []common.Hash {
+			calls++
+			if calls == 1 {
+				return []common.Hash{hashedAddress}
+			}
+			return []common.Hash{hashedAddress, newHashedAddress}
+		}
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		<-diffsChan
+		<-diffsChan
+		Expect(len(superNodeStreamer.PassedFilters)).To(BeNumerically(">=", 2))
+		filters := superNodeStreamer.PassedFilters[1]
+		Expect(filters.BackFill).To(BeTrue())
+		Expect(filters.StartingBlock).To(Equal(big.NewInt(101)))
+		Expect(filters.StorageFilter.StateKeys).To(ConsistOf(hashedAddress.Hex(), newHashedAddress.Hex()))
+		close(done)
+	})
+
+	It("sends decoded storage leaf nodes to the diffs channel", func(done Done) {
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-diffsChan).To(Equal(utils.StorageDiffInput{
+			HashedAddress: hashedAddress,
+			BlockHash:     header.Hash(),
+			BlockHeight:   int(header.Number.Int64()),
+			StorageKey:    storageKey,
+			StorageValue:  storageValue,
+		}))
+		close(done)
+	})
+
+	It("sends storage leaf nodes holding a single byte value to the diffs channel", func(done Done) {
+		leafRlp, encodeErr := rlp.EncodeToBytes([]byte{0x01})
+		Expect(encodeErr).NotTo(HaveOccurred())
+		Expect(leafRlp).To(Equal([]byte{0x01}))
+		payload.StorageNodesRlp[hashedAddress] = map[common.Hash][]byte{storageKey: leafRlp}
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		diff := <-diffsChan
+		Expect(diff.StorageKey).To(Equal(storageKey))
+		Expect(diff.StorageValue).To(Equal(common.HexToHash("0x01")))
+		close(done)
+	})
+
+	It("skips intermediate storage nodes", func(done Done) {
+		intermediateNode, encodeErr := rlp.EncodeToBytes([]interface{}{[]byte{1}, []byte{2}})
+		Expect(encodeErr).NotTo(HaveOccurred())
+		payload.StorageNodesRlp[hashedAddress] = map[common.Hash][]byte{storageKey: intermediateNode}
+		secondPayload := payload
+		secondPayload.StorageNodesRlp = map[common.Hash]map[common.Hash][]byte{
+			hashedAddress: {test_data.FakeHash(): {0x80}},
+		}
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload, secondPayload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		diff := <-diffsChan
+		Expect(diff.StorageKey).NotTo(Equal(storageKey))
+		Expect(diff.StorageValue).To(Equal(common.Hash{}))
+		close(done)
+	})
+
+	It("adds errors to error channel if the payload does not include a header", func(done Done) {
+		payload.HeadersRlp = nil
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-errsChan).To(MatchError(ContainSubstring(fetcher.ErrMissingHeader.Error())))
+		close(done)
+	})
+
+	It("adds errors to error channel if the payload has an error message", func(done Done) {
+		payload.ErrMsg = fakes.FakeError.Error()
+		superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{payload}
+
+		go storageFetcher.FetchStorageDiffs(diffsChan, errsChan)
+
+		Expect(<-errsChan).To(MatchError(fakes.FakeError.Error()))
+		close(done)
+	})
+})
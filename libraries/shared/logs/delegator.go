@@ -34,12 +34,16 @@ var (
 type ILogDelegator interface {
 	AddTransformer(t transformer.EventTransformer)
 	DelegateLogs() error
+	RemoveTransformer(transformerName string)
 }
 
 type LogDelegator struct {
-	Chunker       chunker.Chunker
-	LogRepository datastore.HeaderSyncLogRepository
-	Transformers  []transformer.EventTransformer
+	BlockChain               core.BlockChain
+	Chunker                  chunker.Chunker
+	ConfirmationDepth        int64 // Default for transformers that don't configure their own depth
+	LogRepository            datastore.HeaderSyncLogRepository
+	ProvisionalLogRepository datastore.ProvisionalLogRepository
+	Transformers             []transformer.EventTransformer
 }
 
 func (delegator *LogDelegator) AddTransformer(t transformer.EventTransformer) {
@@ -47,11 +51,35 @@ func (delegator *LogDelegator) AddTransformer(t transformer.EventTransformer) {
 	delegator.Chunker.AddConfig(t.GetConfig())
 }
 
+// Stops delegating logs to the named transformer
+func (delegator *LogDelegator) RemoveTransformer(transformerName string) {
+	var remaining []transformer.EventTransformer
+	for _, t := range delegator.Transformers {
+		if t.GetConfig().TransformerName != transformerName {
+			remaining = append(remaining, t)
+		}
+	}
+	delegator.Transformers = remaining
+	delegator.Chunker.RemoveConfig(transformerName)
+}
+
 func (delegator *LogDelegator) DelegateLogs() error {
 	if len(delegator.Transformers) < 1 {
 		return ErrNoTransformers
 	}
 
+	chainHead, headErr := delegator.getChainHead()
+	if headErr != nil {
+		logrus.Errorf("error getting chain head for confirmation depth: %s", headErr.Error())
+		return headErr
+	}
+
+	resolveErr := delegator.resolveProvisionalLogs(chainHead)
+	if resolveErr != nil {
+		logrus.Errorf("error resolving provisional logs: %s", resolveErr.Error())
+		return resolveErr
+	}
+
 	persistedLogs, fetchErr := delegator.LogRepository.GetUntransformedHeaderSyncLogs()
 	if fetchErr != nil {
 		logrus.Errorf("error loading logs from db: %s", fetchErr.Error())
@@ -62,7 +90,7 @@ func (delegator *LogDelegator) DelegateLogs() error {
 		return ErrNoLogs
 	}
 
-	transformErr := delegator.delegateLogs(persistedLogs)
+	transformErr := delegator.delegateLogs(persistedLogs, chainHead)
 	if transformErr != nil {
 		logrus.Errorf("error transforming logs: %s", transformErr)
 		return transformErr
@@ -71,16 +99,150 @@ func (delegator *LogDelegator) DelegateLogs() error {
 	return nil
 }
 
-func (delegator *LogDelegator) delegateLogs(logs []core.HeaderSyncLog) error {
+// delegateLogs passes each transformer its confirmed logs, along with flagged unconfirmed logs if it accepts them.
+// Returns ErrNoLogs if every log was withheld so that the watcher waits for new blocks instead of spinning.
+func (delegator *LogDelegator) delegateLogs(logs []core.HeaderSyncLog, chainHead int64) error {
 	chunkedLogs := delegator.Chunker.ChunkLogs(logs)
+	var delegatedCount, withheldCount int
 	for _, t := range delegator.Transformers {
 		transformerName := t.GetConfig().TransformerName
 		logChunk := chunkedLogs[transformerName]
-		err := t.Execute(logChunk)
+		confirmed, unconfirmed := delegator.splitByConfirmation(t, logChunk, chainHead)
+
+		_, isProvisional := t.(transformer.ProvisionalEventTransformer)
+		var provisional []core.HeaderSyncLog
+		if isProvisional {
+			provisional = flagProvisional(unconfirmed)
+		} else {
+			withheldCount += len(unconfirmed)
+		}
+
+		// Provisional logs are recorded before they are transformed, so that none persisted by Execute go unresolved
+		if len(provisional) > 0 {
+			createErr := delegator.ProvisionalLogRepository.CreateProvisionalLogs(transformerName, provisional)
+			if createErr != nil {
+				logrus.Errorf("error recording provisional logs for %v: %v", transformerName, createErr)
+				return createErr
+			}
+		}
+
+		err := t.Execute(append(confirmed, provisional...))
 		if err != nil {
 			logrus.Errorf("%v transformer failed to execute in watcher: %v", transformerName, err)
+			if len(provisional) > 0 {
+				deleteErr := delegator.ProvisionalLogRepository.DeleteProvisionalLogs(transformerName, getLogIDs(provisional))
+				if deleteErr != nil {
+					logrus.Errorf("error removing provisional logs for %v: %v", transformerName, deleteErr)
+				}
+			}
 			return err
 		}
+		delegatedCount += len(confirmed) + len(provisional)
+	}
+	if withheldCount > 0 && delegatedCount == 0 {
+		return ErrNoLogs
 	}
 	return nil
 }
+
+// resolveProvisionalLogs finalizes provisional logs that have reached their confirmation depth and reverts those
+// whose header has been removed by a reorg
+func (delegator *LogDelegator) resolveProvisionalLogs(chainHead int64) error {
+	for _, t := range delegator.Transformers {
+		provisionalTransformer, ok := t.(transformer.ProvisionalEventTransformer)
+		if !ok {
+			continue
+		}
+		transformerName := t.GetConfig().TransformerName
+		confirmedBlock := chainHead - delegator.getConfirmationDepth(t)
+		canonical, removed, getErr := delegator.ProvisionalLogRepository.GetResolvableProvisionalLogs(transformerName, confirmedBlock)
+		if getErr != nil {
+			return getErr
+		}
+		if len(removed) > 0 {
+			revertErr := provisionalTransformer.Revert(removed)
+			if revertErr != nil {
+				logrus.Errorf("%v transformer failed to revert provisional logs: %v", transformerName, revertErr)
+				return revertErr
+			}
+		}
+		if len(canonical) > 0 {
+			finalizeErr := provisionalTransformer.Finalize(canonical)
+			if finalizeErr != nil {
+				logrus.Errorf("%v transformer failed to finalize provisional logs: %v", transformerName, finalizeErr)
+				return finalizeErr
+			}
+		}
+		resolved := append(removed, canonical...)
+		if len(resolved) > 0 {
+			deleteErr := delegator.ProvisionalLogRepository.DeleteProvisionalLogs(transformerName, getLogIDs(resolved))
+			if deleteErr != nil {
+				return deleteErr
+			}
+		}
+	}
+	return nil
+}
+
+// getChainHead only queries the chain when some transformer is subject to confirmation gating
+func (delegator *LogDelegator) getChainHead() (int64, error) {
+	if !delegator.confirmationGatingEnabled() {
+		return 0, nil
+	}
+	lastBlock, err := delegator.BlockChain.LastBlock()
+	if err != nil {
+		return 0, err
+	}
+	return lastBlock.Int64(), nil
+}
+
+func (delegator *LogDelegator) confirmationGatingEnabled() bool {
+	for _, t := range delegator.Transformers {
+		_, isProvisional := t.(transformer.ProvisionalEventTransformer)
+		if isProvisional || delegator.getConfirmationDepth(t) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (delegator *LogDelegator) getConfirmationDepth(t transformer.EventTransformer) int64 {
+	if depth := t.GetConfig().ConfirmationDepth; depth != nil {
+		return *depth
+	}
+	return delegator.ConfirmationDepth
+}
+
+func (delegator *LogDelegator) splitByConfirmation(t transformer.EventTransformer, logs []core.HeaderSyncLog, chainHead int64) ([]core.HeaderSyncLog, []core.HeaderSyncLog) {
+	depth := delegator.getConfirmationDepth(t)
+	if depth < 1 {
+		return logs, nil
+	}
+	confirmedBlock := chainHead - depth
+	var confirmed, unconfirmed []core.HeaderSyncLog
+	for _, log := range logs {
+		if int64(log.Log.BlockNumber) <= confirmedBlock {
+			confirmed = append(confirmed, log)
+		} else {
+			unconfirmed = append(unconfirmed, log)
+		}
+	}
+	return confirmed, unconfirmed
+}
+
+func flagProvisional(logs []core.HeaderSyncLog) []core.HeaderSyncLog {
+	var provisional []core.HeaderSyncLog
+	for _, log := range logs {
+		log.Provisional = true
+		provisional = append(provisional, log)
+	}
+	return provisional
+}
+
+func getLogIDs(logs []core.HeaderSyncLog) []int64 {
+	var ids []int64
+	for _, log := range logs {
+		ids = append(ids, log.ID)
+	}
+	return ids
+}
@@ -17,6 +17,7 @@
 package logs_test
 
 import (
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -59,6 +60,32 @@ var _ = Describe("Log delegator", func() {
 		})
 	})
 
+	Describe("RemoveTransformer", func() {
+		It("stops delegating logs to the removed transformer", func() {
+			keptTransformer := &mocks.MockEventTransformer{}
+			keptTransformer.SetTransformerConfig(transformer.EventTransformerConfig{
+				TransformerName:   "kept",
+				ContractAddresses: []string{"0xA"},
+				Topic:             "0x1",
+			})
+			removedTransformer := &mocks.MockEventTransformer{}
+			removedTransformer.SetTransformerConfig(transformer.EventTransformerConfig{
+				TransformerName:   "removed",
+				ContractAddresses: []string{"0xB"},
+				Topic:             "0x2",
+			})
+			logChunker := chunker.NewLogChunker()
+			delegator := logs.LogDelegator{Chunker: logChunker}
+			delegator.AddTransformer(keptTransformer)
+			delegator.AddTransformer(removedTransformer)
+
+			delegator.RemoveTransformer("removed")
+
+			Expect(delegator.Transformers).To(Equal([]transformer.EventTransformer{keptTransformer}))
+			Expect(logChunker.NameToTopic0).To(Equal(map[string]common.Hash{"kept": common.HexToHash("0x1")}))
+		})
+	})
+
 	Describe("DelegateLogs", func() {
 		It("returns error if no transformers configured", func() {
 			delegator := newDelegator(&fakes.MockHeaderSyncLogRepository{})
@@ -137,6 +164,159 @@ var _ = Describe("Log delegator", func() {
 			Expect(err).To(MatchError(fakes.FakeError))
 		})
 
+		Describe("confirmation depth", func() {
+			var (
+				blockChain        *fakes.MockBlockChain
+				config            transformer.EventTransformerConfig
+				confirmedLog      core.HeaderSyncLog
+				unconfirmedLog    core.HeaderSyncLog
+				mockLogRepository *fakes.MockHeaderSyncLogRepository
+				provisionalRepo   *fakes.MockProvisionalLogRepository
+				delegator         *logs.LogDelegator
+			)
+
+			BeforeEach(func() {
+				config = mocks.FakeTransformerConfig
+				blockChain = fakes.NewMockBlockChain()
+				blockChain.SetLastBlock(big.NewInt(100))
+				confirmedLog = core.HeaderSyncLog{ID: 1, Log: types.Log{
+					Address:     common.HexToAddress(config.ContractAddresses[0]),
+					Topics:      []common.Hash{common.HexToHash(config.Topic)},
+					BlockNumber: 90,
+				}}
+				unconfirmedLog = core.HeaderSyncLog{ID: 2, Log: types.Log{
+					Address:     common.HexToAddress(config.ContractAddresses[0]),
+					Topics:      []common.Hash{common.HexToHash(config.Topic)},
+					BlockNumber: 95,
+				}}
+				mockLogRepository = &fakes.MockHeaderSyncLogRepository{}
+				mockLogRepository.ReturnLogs = []core.HeaderSyncLog{confirmedLog, unconfirmedLog}
+				provisionalRepo = &fakes.MockProvisionalLogRepository{}
+				delegator = newDelegator(mockLogRepository)
+				delegator.BlockChain = blockChain
+				delegator.ProvisionalLogRepository = provisionalRepo
+				delegator.ConfirmationDepth = 10
+			})
+
+			It("only delegates logs whose header is confirmation depth blocks behind head", func() {
+				fakeTransformer := &mocks.MockEventTransformer{}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeTransformer.PassedLogs).To(Equal([]core.HeaderSyncLog{confirmedLog}))
+			})
+
+			It("prefers a transformer's own confirmation depth", func() {
+				fakeTransformer := &mocks.MockEventTransformer{}
+				depth := int64(5)
+				config.ConfirmationDepth = &depth
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeTransformer.PassedLogs).To(Equal([]core.HeaderSyncLog{confirmedLog, unconfirmedLog}))
+			})
+
+			It("lets a transformer's confirmation depth of zero override the default", func() {
+				fakeTransformer := &mocks.MockEventTransformer{}
+				depth := int64(0)
+				config.ConfirmationDepth = &depth
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeTransformer.PassedLogs).To(Equal([]core.HeaderSyncLog{confirmedLog, unconfirmedLog}))
+			})
+
+			It("returns error that no logs were found if every log is unconfirmed", func() {
+				mockLogRepository.ReturnLogs = []core.HeaderSyncLog{unconfirmedLog}
+				fakeTransformer := &mocks.MockEventTransformer{}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).To(MatchError(logs.ErrNoLogs))
+			})
+
+			It("delegates unconfirmed logs flagged as provisional to provisional transformers", func() {
+				fakeTransformer := &mocks.MockProvisionalEventTransformer{}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).NotTo(HaveOccurred())
+				provisionalLog := unconfirmedLog
+				provisionalLog.Provisional = true
+				Expect(fakeTransformer.PassedLogs).To(Equal([]core.HeaderSyncLog{confirmedLog, provisionalLog}))
+				Expect(provisionalRepo.CreatePassedLogs[config.TransformerName]).To(Equal([]core.HeaderSyncLog{provisionalLog}))
+			})
+
+			It("does not transform provisional logs if recording them fails", func() {
+				provisionalRepo.CreateError = fakes.FakeError
+				fakeTransformer := &mocks.MockProvisionalEventTransformer{}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(fakeTransformer.ExecuteWasCalled).To(BeFalse())
+			})
+
+			It("removes recorded provisional logs if transforming them fails", func() {
+				fakeTransformer := &mocks.MockProvisionalEventTransformer{}
+				fakeTransformer.ExecuteError = fakes.FakeError
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(provisionalRepo.CreatePassedLogs[config.TransformerName]).To(HaveLen(1))
+				Expect(provisionalRepo.DeletePassedLogIDs[config.TransformerName]).To(Equal([]int64{unconfirmedLog.ID}))
+			})
+
+			It("finalizes confirmed provisional logs and reverts removed ones", func() {
+				mockLogRepository.ReturnLogs = nil
+				removedLog := unconfirmedLog
+				removedLog.Log.Removed = true
+				provisionalRepo.ReturnCanonicalLogs = []core.HeaderSyncLog{confirmedLog}
+				provisionalRepo.ReturnRemovedLogs = []core.HeaderSyncLog{removedLog}
+				fakeTransformer := &mocks.MockProvisionalEventTransformer{}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).To(MatchError(logs.ErrNoLogs))
+				Expect(provisionalRepo.GetPassedBlock).To(Equal(int64(90)))
+				Expect(fakeTransformer.FinalizedLogs).To(Equal([]core.HeaderSyncLog{confirmedLog}))
+				Expect(fakeTransformer.RevertedLogs).To(Equal([]core.HeaderSyncLog{removedLog}))
+				Expect(provisionalRepo.DeletePassedLogIDs[config.TransformerName]).To(ConsistOf(confirmedLog.ID, removedLog.ID))
+			})
+
+			It("returns error if finalizing provisional logs fails", func() {
+				provisionalRepo.ReturnCanonicalLogs = []core.HeaderSyncLog{confirmedLog}
+				fakeTransformer := &mocks.MockProvisionalEventTransformer{FinalizeError: fakes.FakeError}
+				fakeTransformer.SetTransformerConfig(config)
+				delegator.AddTransformer(fakeTransformer)
+
+				err := delegator.DelegateLogs()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(provisionalRepo.DeletePassedLogIDs).To(BeEmpty())
+			})
+		})
+
 		It("returns nil for error when logs returned and delegated", func() {
 			fakeTransformer := &mocks.MockEventTransformer{}
 			config := mocks.FakeTransformerConfig
@@ -37,6 +37,7 @@ var (
 type ILogExtractor interface {
 	AddTransformerConfig(config transformer.EventTransformerConfig) error
 	ExtractLogs(recheckHeaders constants.TransformerExecution) error
+	RemoveTransformerConfig(transformerName string)
 }
 
 type LogExtractor struct {
@@ -48,11 +49,12 @@ type LogExtractor struct {
 	StartingBlock            *int64
 	Syncer                   transactions.ITransactionsSyncer
 	Topics                   []common.Hash
+	configs                  []transformer.EventTransformerConfig
 }
 
 // Add additional logs to extract
 func (extractor *LogExtractor) AddTransformerConfig(config transformer.EventTransformerConfig) error {
-	checkedHeadersErr := extractor.updateCheckedHeaders(config)
+	checkedHeadersErr := updateCheckedHeaders(extractor.CheckedHeadersRepository, extractor.CheckedLogsRepository, config)
 	if checkedHeadersErr != nil {
 		return checkedHeadersErr
 	}
@@ -66,9 +68,16 @@ func (extractor *LogExtractor) AddTransformerConfig(config transformer.EventTran
 	addresses := transformer.HexStringsToAddresses(config.ContractAddresses)
 	extractor.Addresses = append(extractor.Addresses, addresses...)
 	extractor.Topics = append(extractor.Topics, common.HexToHash(config.Topic))
+	extractor.configs = append(extractor.configs, config)
 	return nil
 }
 
+// Stop extracting logs for the named transformer
+func (extractor *LogExtractor) RemoveTransformerConfig(transformerName string) {
+	extractor.configs = removeConfig(extractor.configs, transformerName)
+	extractor.Addresses, extractor.Topics, extractor.StartingBlock = watchedLogs(extractor.configs)
+}
+
 // Fetch and persist watched logs
 func (extractor LogExtractor) ExtractLogs(recheckHeaders constants.TransformerExecution) error {
 	if len(extractor.Addresses) < 1 {
@@ -116,6 +125,33 @@ func (extractor LogExtractor) ExtractLogs(recheckHeaders constants.TransformerEx
 	return nil
 }
 
+func removeConfig(configs []transformer.EventTransformerConfig, transformerName string) []transformer.EventTransformerConfig {
+	var remaining []transformer.EventTransformerConfig
+	for _, config := range configs {
+		if config.TransformerName != transformerName {
+			remaining = append(remaining, config)
+		}
+	}
+	return remaining
+}
+
+// Addresses, topics and earliest starting block watched for the given transformer configs
+func watchedLogs(configs []transformer.EventTransformerConfig) ([]common.Address, []common.Hash, *int64) {
+	var (
+		addresses     []common.Address
+		topics        []common.Hash
+		startingBlock *int64
+	)
+	for i := range configs {
+		if startingBlock == nil || earlierStartingBlockNumber(configs[i].StartingBlockNumber, *startingBlock) {
+			startingBlock = &configs[i].StartingBlockNumber
+		}
+		addresses = append(addresses, transformer.HexStringsToAddresses(configs[i].ContractAddresses)...)
+		topics = append(topics, common.HexToHash(configs[i].Topic))
+	}
+	return addresses, topics, startingBlock
+}
+
 func earlierStartingBlockNumber(transformerBlock, watcherBlock int64) bool {
 	return transformerBlock < watcherBlock
 }
@@ -135,17 +171,17 @@ func getCheckCount(recheckHeaders constants.TransformerExecution) int64 {
 	return constants.RecheckHeaderCap
 }
 
-func (extractor *LogExtractor) updateCheckedHeaders(config transformer.EventTransformerConfig) error {
-	alreadyWatchingLog, watchingLogErr := extractor.CheckedLogsRepository.AlreadyWatchingLog(config.ContractAddresses, config.Topic)
+func updateCheckedHeaders(checkedHeadersRepository datastore.CheckedHeadersRepository, checkedLogsRepository datastore.CheckedLogsRepository, config transformer.EventTransformerConfig) error {
+	alreadyWatchingLog, watchingLogErr := checkedLogsRepository.AlreadyWatchingLog(config.ContractAddresses, config.Topic)
 	if watchingLogErr != nil {
 		return watchingLogErr
 	}
 	if !alreadyWatchingLog {
-		uncheckHeadersErr := extractor.CheckedHeadersRepository.MarkHeadersUnchecked(config.StartingBlockNumber)
+		uncheckHeadersErr := checkedHeadersRepository.MarkHeadersUnchecked(config.StartingBlockNumber)
 		if uncheckHeadersErr != nil {
 			return uncheckHeadersErr
 		}
-		markLogWatchedErr := extractor.CheckedLogsRepository.MarkLogWatched(config.ContractAddresses, config.Topic)
+		markLogWatchedErr := checkedLogsRepository.MarkLogWatched(config.ContractAddresses, config.Topic)
 		if markLogWatchedErr != nil {
 			return markLogWatchedErr
 		}
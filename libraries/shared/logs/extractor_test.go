@@ -156,6 +156,42 @@ var _ = Describe("Log extractor", func() {
 		})
 	})
 
+	Describe("RemoveTransformerConfig", func() {
+		It("stops watching the removed transformer's addresses and topic", func() {
+			keptConfig := transformer.EventTransformerConfig{
+				TransformerName:     "kept",
+				ContractAddresses:   []string{"0xA"},
+				Topic:               "0x1",
+				StartingBlockNumber: 200,
+			}
+			removedConfig := transformer.EventTransformerConfig{
+				TransformerName:     "removed",
+				ContractAddresses:   []string{"0xB"},
+				Topic:               "0x2",
+				StartingBlockNumber: 100,
+			}
+			Expect(extractor.AddTransformerConfig(keptConfig)).To(Succeed())
+			Expect(extractor.AddTransformerConfig(removedConfig)).To(Succeed())
+
+			extractor.RemoveTransformerConfig("removed")
+
+			Expect(extractor.Addresses).To(Equal(transformer.HexStringsToAddresses([]string{"0xA"})))
+			Expect(extractor.Topics).To(Equal([]common.Hash{common.HexToHash("0x1")}))
+			Expect(*extractor.StartingBlock).To(Equal(int64(200)))
+		})
+
+		It("leaves no watched addresses when the last transformer is removed", func() {
+			config := getTransformerConfig(rand.Int63())
+			config.TransformerName = "removed"
+			Expect(extractor.AddTransformerConfig(config)).To(Succeed())
+
+			extractor.RemoveTransformerConfig("removed")
+
+			err := extractor.ExtractLogs(constants.HeaderUnchecked)
+			Expect(err).To(MatchError(logs.ErrNoWatchedAddresses))
+		})
+	})
+
 	Describe("ExtractLogs", func() {
 		It("returns error if no watched addresses configured", func() {
 			err := extractor.ExtractLogs(constants.HeaderUnchecked)
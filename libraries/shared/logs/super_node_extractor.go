@@ -0,0 +1,301 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/constants"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transactions"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore"
+	vulcCommon "github.com/vulcanize/vulcanizedb/pkg/eth/converters/common"
+)
+
+var (
+	ErrMissingHeader      = errors.New("super node payload does not include a header")
+	ErrMissingLogMetadata = errors.New("super node payload does not include the metadata of watched logs")
+)
+
+const superNodePayloadBufferSize = 800
+
+// SuperNodeLogExtractor persists watched logs streamed from a super node subscription,
+// in place of fetching them from the node with eth_getLogs.
+// Fetcher is only used for blocks the super node indexed before it recorded the positions of their logs
+type SuperNodeLogExtractor struct {
+	Addresses                []common.Address
+	CheckedHeadersRepository datastore.CheckedHeadersRepository
+	CheckedLogsRepository    datastore.CheckedLogsRepository
+	Fetcher                  fetcher.ILogFetcher
+	HeaderConverter          vulcCommon.HeaderConverter
+	HeaderRepository         datastore.HeaderRepository
+	LogRepository            datastore.HeaderSyncLogRepository
+	StartingBlock            *int64
+	Streamer                 streamer.ISuperNodeStreamer
+	Syncer                   transactions.ITransactionsSyncer
+	Topics                   []common.Hash
+	configs                  []transformer.EventTransformerConfig
+	payloadChan              chan streamer.SuperNodePayload
+	subscription             *rpc.ClientSubscription
+	subscriptionStale        bool
+}
+
+// Add additional logs to extract, resubscribing to the super node on the next extraction
+func (extractor *SuperNodeLogExtractor) AddTransformerConfig(config transformer.EventTransformerConfig) error {
+	checkedHeadersErr := updateCheckedHeaders(extractor.CheckedHeadersRepository, extractor.CheckedLogsRepository, config)
+	if checkedHeadersErr != nil {
+		return checkedHeadersErr
+	}
+
+	if extractor.StartingBlock == nil {
+		extractor.StartingBlock = &config.StartingBlockNumber
+	} else if earlierStartingBlockNumber(config.StartingBlockNumber, *extractor.StartingBlock) {
+		extractor.StartingBlock = &config.StartingBlockNumber
+	}
+
+	addresses := transformer.HexStringsToAddresses(config.ContractAddresses)
+	extractor.Addresses = append(extractor.Addresses, addresses...)
+	extractor.Topics = append(extractor.Topics, common.HexToHash(config.Topic))
+	extractor.configs = append(extractor.configs, config)
+	extractor.subscriptionStale = true
+	return nil
+}
+
+// Stop extracting logs for the named transformer, resubscribing to the super node on the next extraction
+func (extractor *SuperNodeLogExtractor) RemoveTransformerConfig(transformerName string) {
+	extractor.configs = removeConfig(extractor.configs, transformerName)
+	extractor.Addresses, extractor.Topics, extractor.StartingBlock = watchedLogs(extractor.configs)
+	extractor.subscriptionStale = true
+}
+
+// Persist watched logs from the next block streamed by the super node
+// Headers are only received as they are synced by the super node, so recheckHeaders has no effect
+func (extractor *SuperNodeLogExtractor) ExtractLogs(recheckHeaders constants.TransformerExecution) error {
+	if len(extractor.Addresses) < 1 {
+		logrus.Errorf("error extracting logs: %s", ErrNoWatchedAddresses.Error())
+		return ErrNoWatchedAddresses
+	}
+
+	if extractor.payloadChan == nil || extractor.subscriptionStale {
+		subscribeErr := extractor.subscribe()
+		if subscribeErr != nil {
+			logrus.Errorf("error subscribing to super node: %s", subscribeErr.Error())
+			return subscribeErr
+		}
+	}
+
+	select {
+	case payload := <-extractor.payloadChan:
+		return extractor.persistLogs(payload)
+	case subErr := <-extractor.subscriptionErr():
+		logrus.Errorf("super node subscription error: %s", subErr.Error())
+		extractor.subscriptionStale = true
+		return subErr
+	default:
+		return ErrNoUncheckedHeaders
+	}
+}
+
+func (extractor *SuperNodeLogExtractor) subscribe() error {
+	if extractor.subscription != nil {
+		extractor.subscription.Unsubscribe()
+	}
+	filters, filtersErr := extractor.subscriptionFilters()
+	if filtersErr != nil {
+		extractor.payloadChan = nil
+		return filtersErr
+	}
+	extractor.payloadChan = make(chan streamer.SuperNodePayload, superNodePayloadBufferSize)
+	subscription, streamErr := extractor.Streamer.Stream(extractor.payloadChan, filters)
+	if streamErr != nil {
+		extractor.payloadChan = nil
+		return streamErr
+	}
+	extractor.subscription = subscription
+	extractor.subscriptionStale = false
+	return nil
+}
+
+// Receipts are filtered by topic alone, since the super node matches contracts against a transaction's
+// recipient rather than the address emitting the log; watched addresses are applied to each log instead.
+// Blocks after the last checked header are back-filled, so that none are missed while unsubscribed
+func (extractor *SuperNodeLogExtractor) subscriptionFilters() (config.Subscription, error) {
+	lastCheckedBlock, lastCheckedErr := extractor.CheckedHeadersRepository.LastCheckedBlock(*extractor.StartingBlock)
+	if lastCheckedErr != nil {
+		return config.Subscription{}, lastCheckedErr
+	}
+	startingBlock := *extractor.StartingBlock
+	if lastCheckedBlock >= startingBlock {
+		startingBlock = lastCheckedBlock + 1
+	}
+	topics := make([]string, 0, len(extractor.Topics))
+	for _, topic := range extractor.Topics {
+		topics = append(topics, topic.Hex())
+	}
+	return config.Subscription{
+		BackFill:      true,
+		StartingBlock: big.NewInt(startingBlock),
+		EndingBlock:   big.NewInt(0),
+		HeaderFilter:  config.HeaderFilter{},
+		TrxFilter:     config.TrxFilter{Off: true},
+		ReceiptFilter: config.ReceiptFilter{Topic0s: topics},
+		StateFilter:   config.StateFilter{Off: true},
+		StorageFilter: config.StorageFilter{Off: true},
+	}, nil
+}
+
+func (extractor *SuperNodeLogExtractor) subscriptionErr() <-chan error {
+	if extractor.subscription == nil {
+		return nil
+	}
+	return extractor.subscription.Err()
+}
+
+func (extractor *SuperNodeLogExtractor) persistLogs(payload streamer.SuperNodePayload) error {
+	if payload.ErrMsg != "" {
+		logrus.Errorf("super node payload error: %s", payload.ErrMsg)
+		return errors.New(payload.ErrMsg)
+	}
+
+	header, headerErr := extractor.persistHeader(payload)
+	if headerErr != nil {
+		logrus.Errorf("error persisting super node header: %s", headerErr.Error())
+		return headerErr
+	}
+
+	logs, logsErr := extractor.decodeWatchedLogs(payload, header)
+	if logsErr != nil {
+		logError("error decoding logs for header: %s", logsErr, header)
+		return logsErr
+	}
+
+	if len(logs) > 0 {
+		transactionsSyncErr := extractor.Syncer.SyncTransactions(header.ID, logs)
+		if transactionsSyncErr != nil {
+			logError("error syncing transactions: %s", transactionsSyncErr, header)
+			return transactionsSyncErr
+		}
+
+		createLogsErr := extractor.LogRepository.CreateHeaderSyncLogs(header.ID, logs)
+		if createLogsErr != nil {
+			logError("error persisting logs: %s", createLogsErr, header)
+			return createLogsErr
+		}
+	}
+
+	markHeaderCheckedErr := extractor.CheckedHeadersRepository.MarkHeaderChecked(header.ID)
+	if markHeaderCheckedErr != nil {
+		logError("error marking header checked: %s", markHeaderCheckedErr, header)
+		return markHeaderCheckedErr
+	}
+	return nil
+}
+
+func (extractor *SuperNodeLogExtractor) persistHeader(payload streamer.SuperNodePayload) (core.Header, error) {
+	if len(payload.HeadersRlp) < 1 {
+		return core.Header{}, fmt.Errorf("%s: block %s", ErrMissingHeader.Error(), payload.BlockNumber.String())
+	}
+	var gethHeader types.Header
+	decodeErr := rlp.DecodeBytes(payload.HeadersRlp[0], &gethHeader)
+	if decodeErr != nil {
+		return core.Header{}, decodeErr
+	}
+	header := extractor.HeaderConverter.Convert(&gethHeader, gethHeader.Hash().Hex())
+	headerID, createErr := extractor.HeaderRepository.CreateOrUpdateHeader(header)
+	if createErr != nil {
+		return core.Header{}, createErr
+	}
+	header.ID = headerID
+	return header, nil
+}
+
+// decodeWatchedLogs decodes the watched logs of a payload's receipts with their block and transaction metadata.
+// Receipts the super node indexed without the positions of their logs are streamed without it, so if any of those
+// include a watched log, the header's watched logs are fetched from the node instead
+func (extractor *SuperNodeLogExtractor) decodeWatchedLogs(payload streamer.SuperNodePayload, header core.Header) ([]types.Log, error) {
+	var watchedLogs []types.Log
+	for i, receiptRlp := range payload.ReceiptsRlp {
+		if i < len(payload.ReceiptLogsRlp) && len(payload.ReceiptLogsRlp[i]) > 0 {
+			logs, decodeErr := streamer.DecodeReceiptLogs(payload.ReceiptLogsRlp[i])
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			for _, log := range logs {
+				if extractor.watching(log) {
+					watchedLogs = append(watchedLogs, log)
+				}
+			}
+			continue
+		}
+		var receipt types.ReceiptForStorage
+		decodeErr := rlp.DecodeBytes(receiptRlp, &receipt)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		for _, log := range receipt.Logs {
+			if extractor.watching(*log) {
+				return extractor.fetchWatchedLogs(header)
+			}
+		}
+	}
+	return watchedLogs, nil
+}
+
+func (extractor *SuperNodeLogExtractor) fetchWatchedLogs(header core.Header) ([]types.Log, error) {
+	if extractor.Fetcher == nil {
+		return nil, fmt.Errorf("%s: block %d", ErrMissingLogMetadata.Error(), header.BlockNumber)
+	}
+	logrus.Infof("fetching logs for block %d from the node, since the super node streamed them without their metadata",
+		header.BlockNumber)
+	return extractor.Fetcher.FetchLogs(extractor.Addresses, extractor.Topics, header)
+}
+
+func (extractor *SuperNodeLogExtractor) watching(log types.Log) bool {
+	if len(log.Topics) < 1 {
+		return false
+	}
+	return containsAddress(extractor.Addresses, log.Address) && containsTopic(extractor.Topics, log.Topics[0])
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTopic(topics []common.Hash, topic common.Hash) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
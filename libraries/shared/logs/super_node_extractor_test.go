@@ -0,0 +1,371 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package logs_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/constants"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/logs"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Super node log extractor", func() {
+	var (
+		checkedHeadersRepository *fakes.MockCheckedHeadersRepository
+		headerRepository         *fakes.MockHeaderRepository
+		logRepository            *fakes.MockHeaderSyncLogRepository
+		superNodeStreamer        *mocks.SuperNodeStreamer
+		syncer                   *fakes.MockTransactionSyncer
+		extractor                *logs.SuperNodeLogExtractor
+		logFetcher               *mocks.MockLogFetcher
+		watchedLog               types.Log
+	)
+
+	BeforeEach(func() {
+		checkedHeadersRepository = &fakes.MockCheckedHeadersRepository{}
+		headerRepository = fakes.NewMockHeaderRepository()
+		logRepository = &fakes.MockHeaderSyncLogRepository{}
+		superNodeStreamer = &mocks.SuperNodeStreamer{}
+		syncer = &fakes.MockTransactionSyncer{}
+		logFetcher = &mocks.MockLogFetcher{}
+		extractor = &logs.SuperNodeLogExtractor{
+			CheckedHeadersRepository: checkedHeadersRepository,
+			CheckedLogsRepository:    &fakes.MockCheckedLogsRepository{},
+			Fetcher:                  logFetcher,
+			HeaderRepository:         headerRepository,
+			LogRepository:            logRepository,
+			Streamer:                 superNodeStreamer,
+			Syncer:                   syncer,
+		}
+		watchedLog = types.Log{
+			Address:     fakes.FakeAddress,
+			Topics:      []common.Hash{fakes.FakeHash},
+			Data:        []byte{1, 2, 3},
+			BlockNumber: 100,
+			TxHash:      common.HexToHash("0x123"),
+			TxIndex:     1,
+			BlockHash:   common.HexToHash("0x456"),
+			Index:       2,
+		}
+	})
+
+	Describe("AddTransformerConfig", func() {
+		It("adds transformer's addresses and topic to the extractor", func() {
+			err := extractor.AddTransformerConfig(getTransformerConfig(100))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(extractor.Addresses).To(Equal([]common.Address{fakes.FakeAddress}))
+			Expect(extractor.Topics).To(Equal([]common.Hash{fakes.FakeHash}))
+			Expect(*extractor.StartingBlock).To(Equal(int64(100)))
+		})
+
+		It("marks headers since transformer's starting block number as unchecked", func() {
+			err := extractor.AddTransformerConfig(getTransformerConfig(100))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checkedHeadersRepository.MarkHeadersUncheckedCalled).To(BeTrue())
+			Expect(checkedHeadersRepository.MarkHeadersUncheckedStartingBlockNumber).To(Equal(int64(100)))
+		})
+	})
+
+	Describe("ExtractLogs", func() {
+		It("returns error if no watched addresses configured", func() {
+			err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(MatchError(logs.ErrNoWatchedAddresses))
+		})
+
+		Describe("when subscribing", func() {
+			BeforeEach(func() {
+				addErr := extractor.AddTransformerConfig(getTransformerConfig(100))
+				Expect(addErr).NotTo(HaveOccurred())
+			})
+
+			It("subscribes to receipts with the watched topics from the earliest starting block", func() {
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(MatchError(logs.ErrNoUncheckedHeaders))
+				Expect(len(superNodeStreamer.PassedFilters)).To(Equal(1))
+				filters := superNodeStreamer.PassedFilters[0]
+				Expect(filters.StartingBlock.Int64()).To(Equal(int64(100)))
+				Expect(filters.HeaderFilter.Off).To(BeFalse())
+				Expect(filters.ReceiptFilter.Off).To(BeFalse())
+				Expect(filters.ReceiptFilter.Topic0s).To(ConsistOf(fakes.FakeHash.Hex()))
+				Expect(filters.TrxFilter.Off).To(BeTrue())
+				Expect(filters.StateFilter.Off).To(BeTrue())
+				Expect(filters.StorageFilter.Off).To(BeTrue())
+				Expect(filters.BackFill).To(BeTrue())
+			})
+
+			It("back-fills from the block after the last checked header", func() {
+				checkedHeadersRepository.LastCheckedBlockNumber = 150
+
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(checkedHeadersRepository.LastCheckedBlockStartingBlockNumber).To(Equal(int64(100)))
+				Expect(superNodeStreamer.PassedFilters[0].BackFill).To(BeTrue())
+				Expect(superNodeStreamer.PassedFilters[0].StartingBlock.Int64()).To(Equal(int64(151)))
+			})
+
+			It("back-fills the gap when resubscribing after a failed subscription", func() {
+				superNodeStreamer.ReturnErr = fakes.FakeError
+				checkedHeadersRepository.LastCheckedBlockNumber = 120
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+				Expect(err).To(MatchError(fakes.FakeError))
+
+				superNodeStreamer.ReturnErr = nil
+				checkedHeadersRepository.LastCheckedBlockNumber = 150
+				err = extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(MatchError(logs.ErrNoUncheckedHeaders))
+				Expect(len(superNodeStreamer.PassedFilters)).To(Equal(2))
+				Expect(superNodeStreamer.PassedFilters[1].BackFill).To(BeTrue())
+				Expect(superNodeStreamer.PassedFilters[1].StartingBlock.Int64()).To(Equal(int64(151)))
+			})
+
+			It("returns error if getting the last checked header fails", func() {
+				checkedHeadersRepository.LastCheckedBlockReturnError = fakes.FakeError
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(superNodeStreamer.PassedFilters).To(BeEmpty())
+			})
+
+			It("only subscribes once while transformer configs are unchanged", func() {
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(len(superNodeStreamer.PassedFilters)).To(Equal(1))
+			})
+
+			It("resubscribes when a transformer config is added", func() {
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+				addErr := extractor.AddTransformerConfig(getTransformerConfig(50))
+				Expect(addErr).NotTo(HaveOccurred())
+
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(len(superNodeStreamer.PassedFilters)).To(Equal(2))
+				Expect(superNodeStreamer.PassedFilters[1].StartingBlock.Int64()).To(Equal(int64(50)))
+			})
+
+			It("resubscribes without a removed transformer's topic", func() {
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+				config := getTransformerConfig(50)
+				config.TransformerName = "removed"
+				config.Topic = "0x1"
+				addErr := extractor.AddTransformerConfig(config)
+				Expect(addErr).NotTo(HaveOccurred())
+
+				extractor.RemoveTransformerConfig("removed")
+				extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(len(superNodeStreamer.PassedFilters)).To(Equal(2))
+				Expect(superNodeStreamer.PassedFilters[1].StartingBlock.Int64()).To(Equal(int64(100)))
+				Expect(superNodeStreamer.PassedFilters[1].ReceiptFilter.Topic0s).To(ConsistOf(fakes.FakeHash.Hex()))
+			})
+
+			It("returns error if subscribing fails", func() {
+				superNodeStreamer.ReturnErr = fakes.FakeError
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+			})
+		})
+
+		Describe("when the super node streams a payload", func() {
+			BeforeEach(func() {
+				addErr := extractor.AddTransformerConfig(getTransformerConfig(100))
+				Expect(addErr).NotTo(HaveOccurred())
+				headerRepository.SetCreateOrUpdateHeaderReturnID(123)
+			})
+
+			It("persists the streamed header", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				headerRepository.AssertCreateOrUpdateHeaderCallCountAndPassedBlockNumbers(1, []int64{100})
+			})
+
+			It("syncs transactions and persists watched logs", func() {
+				unwatchedLog := watchedLog
+				unwatchedLog.Address = common.HexToAddress("0x789")
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100, watchedLog, unwatchedLog)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(syncer.SyncTransactionsCalled).To(BeTrue())
+				Expect(logRepository.PassedHeaderID).To(Equal(int64(123)))
+				Expect(logRepository.PassedLogs).To(ConsistOf(watchedLog))
+			})
+
+			It("does not sync transactions if there are no watched logs", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(syncer.SyncTransactionsCalled).To(BeFalse())
+			})
+
+			It("marks header checked", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100, watchedLog)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(checkedHeadersRepository.MarkHeaderCheckedHeaderID).To(Equal(int64(123)))
+			})
+
+			It("returns error if the payload contains an error message", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{{ErrMsg: fakes.FakeError.Error()}}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal(fakes.FakeError.Error()))
+			})
+
+			It("returns error if the payload does not include a header", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{{BlockNumber: big.NewInt(100)}}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(logs.ErrMissingHeader.Error()))
+			})
+
+			It("returns error if persisting the header fails", func() {
+				headerRepository.SetCreateOrUpdateHeaderReturnErr(fakes.FakeError)
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+			})
+
+			It("fetches watched logs from the node if receipts do not include their metadata", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getPayloadWithoutLogMetadata(100, watchedLog)}
+				logFetcher.ReturnLogs = []types.Log{watchedLog}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logFetcher.FetchCalled).To(BeTrue())
+				Expect(logFetcher.ContractAddresses).To(Equal(extractor.Addresses))
+				Expect(logFetcher.Topics).To(Equal(extractor.Topics))
+				Expect(logFetcher.MissingHeader.BlockNumber).To(Equal(int64(100)))
+				Expect(logRepository.PassedLogs).To(Equal([]types.Log{watchedLog}))
+			})
+
+			It("does not fetch logs if receipts without metadata have no watched logs", func() {
+				unwatchedLog := watchedLog
+				unwatchedLog.Address = common.HexToAddress("0xabc")
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getPayloadWithoutLogMetadata(100, unwatchedLog)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logFetcher.FetchCalled).To(BeFalse())
+				Expect(checkedHeadersRepository.MarkHeaderCheckedHeaderID).To(Equal(int64(123)))
+			})
+
+			It("returns error if fetching logs without metadata fails", func() {
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getPayloadWithoutLogMetadata(100, watchedLog)}
+				logFetcher.ReturnError = fakes.FakeError
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(logRepository.PassedLogs).To(BeNil())
+			})
+
+			It("returns error if receipts do not include log metadata and there is no fetcher", func() {
+				extractor.Fetcher = nil
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getPayloadWithoutLogMetadata(100, watchedLog)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(logs.ErrMissingLogMetadata.Error()))
+				Expect(logRepository.PassedLogs).To(BeNil())
+			})
+
+			It("returns error if persisting logs fails", func() {
+				logRepository.CreateError = fakes.FakeError
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100, watchedLog)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+			})
+
+			It("returns error if marking header checked fails", func() {
+				checkedHeadersRepository.MarkHeaderCheckedReturnError = fakes.FakeError
+				superNodeStreamer.StreamPayloads = []streamer.SuperNodePayload{getSuperNodePayload(100)}
+
+				err := extractor.ExtractLogs(constants.HeaderUnchecked)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+			})
+		})
+	})
+})
+
+func getSuperNodePayload(blockNumber int64, receiptLogs ...types.Log) streamer.SuperNodePayload {
+	headerRlp, headerErr := rlp.EncodeToBytes(&types.Header{Number: big.NewInt(blockNumber), Difficulty: big.NewInt(1)})
+	Expect(headerErr).NotTo(HaveOccurred())
+	payload := streamer.SuperNodePayload{
+		BlockNumber: big.NewInt(blockNumber),
+		HeadersRlp:  [][]byte{headerRlp},
+	}
+	for i := range receiptLogs {
+		receiptRlp, receiptErr := rlp.EncodeToBytes(&types.ReceiptForStorage{Logs: []*types.Log{&receiptLogs[i]}})
+		Expect(receiptErr).NotTo(HaveOccurred())
+		logsRlp, logsErr := streamer.EncodeReceiptLogs([]*types.Log{&receiptLogs[i]})
+		Expect(logsErr).NotTo(HaveOccurred())
+		payload.ReceiptsRlp = append(payload.ReceiptsRlp, receiptRlp)
+		payload.ReceiptLogsRlp = append(payload.ReceiptLogsRlp, logsRlp)
+	}
+	return payload
+}
+
+// getPayloadWithoutLogMetadata streams receipts like those the super node indexed before recording the positions of logs
+func getPayloadWithoutLogMetadata(blockNumber int64, receiptLogs ...types.Log) streamer.SuperNodePayload {
+	payload := getSuperNodePayload(blockNumber, receiptLogs...)
+	payload.ReceiptLogsRlp = make([][]byte, len(receiptLogs))
+	return payload
+}
@@ -0,0 +1,52 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MockBackFillProgress mock for tests
+type MockBackFillProgress struct {
+	IncompleteRanges    [][2]uint64
+	GetIncompleteErr    error
+	GetPassedAddresses  []common.Hash
+	MarkBinCompleteErr  error
+	MarkedBins          [][2]uint64
+	MarkedBinsAddresses [][]common.Hash
+	incompleteRangesSet bool
+}
+
+// SetIncompleteRanges sets the ranges returned by GetIncompleteRanges; by default the whole range is returned
+func (progress *MockBackFillProgress) SetIncompleteRanges(ranges [][2]uint64) {
+	progress.IncompleteRanges = ranges
+	progress.incompleteRangesSet = true
+}
+
+func (progress *MockBackFillProgress) GetIncompleteRanges(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) ([][2]uint64, error) {
+	progress.GetPassedAddresses = hashedAddresses
+	if !progress.incompleteRangesSet {
+		return [][2]uint64{{startingBlock, endingBlock}}, progress.GetIncompleteErr
+	}
+	return progress.IncompleteRanges, progress.GetIncompleteErr
+}
+
+func (progress *MockBackFillProgress) MarkBinComplete(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) error {
+	progress.MarkedBinsAddresses = append(progress.MarkedBinsAddresses, hashedAddresses)
+	progress.MarkedBins = append(progress.MarkedBins, [2]uint64{startingBlock, endingBlock})
+	return progress.MarkBinCompleteErr
+}
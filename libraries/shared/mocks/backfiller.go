@@ -26,6 +26,7 @@ import (
 type BackFiller struct {
 	StorageDiffsToReturn []utils.StorageDiffInput
 	BackFillErrs         []error
+	PassedStartingBlocks []uint64
 	PassedEndingBlock    uint64
 }
 
@@ -35,24 +36,30 @@ func (backFiller *BackFiller) SetStorageDiffsToReturn(diffs []utils.StorageDiffI
 }
 
 // BackFill mock method
-func (backFiller *BackFiller) BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, done chan bool) error {
+func (backFiller *BackFiller) BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, binDone chan [2]uint64, done chan bool) error {
 	if endingBlock < startingBlock {
 		return errors.New("backfill: ending block number needs to be greater than starting block number")
 	}
+	backFiller.PassedStartingBlocks = append(backFiller.PassedStartingBlocks, startingBlock)
 	backFiller.PassedEndingBlock = endingBlock
-	go func(backFill chan utils.StorageDiffInput, errChan chan error, done chan bool) {
+	go func(backFill chan utils.StorageDiffInput, errChan chan error, binDone chan [2]uint64, done chan bool) {
 		errLen := len(backFiller.BackFillErrs)
+		complete := true
 		for i, diff := range backFiller.StorageDiffsToReturn {
 			if i < errLen {
 				err := backFiller.BackFillErrs[i]
 				if err != nil {
+					complete = false
 					errChan <- err
 					continue
 				}
 			}
 			backFill <- diff
 		}
+		if complete && binDone != nil {
+			binDone <- [2]uint64{startingBlock, endingBlock}
+		}
 		done <- true
-	}(backFill, errChan, done)
+	}(backFill, errChan, binDone, done)
 	return nil
 }
@@ -0,0 +1,57 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// MockContractTransformer for tests
+type MockContractTransformer struct {
+	Config           config.ContractConfig
+	InitErr          error
+	InitCalled       bool
+	ExecuteErr       error
+	ExecuteCalls     int
+	PassedBlockChain core.BlockChain
+}
+
+// Init mock method
+func (transformer *MockContractTransformer) Init() error {
+	transformer.InitCalled = true
+	return transformer.InitErr
+}
+
+// Execute mock method
+func (transformer *MockContractTransformer) Execute() error {
+	transformer.ExecuteCalls++
+	return transformer.ExecuteErr
+}
+
+// GetConfig mock method
+func (transformer *MockContractTransformer) GetConfig() config.ContractConfig {
+	return transformer.Config
+}
+
+// FakeTransformerInitializer mock method
+func (transformer *MockContractTransformer) FakeTransformerInitializer(db *postgres.DB, bc core.BlockChain) transformer.ContractTransformer {
+	transformer.PassedBlockChain = bc
+	return transformer
+}
@@ -56,3 +56,21 @@ var FakeTransformerConfig = transformer.EventTransformerConfig{
 	ContractAddresses: []string{fakes.FakeAddress.Hex()},
 	Topic:             fakes.FakeHash.Hex(),
 }
+
+type MockProvisionalEventTransformer struct {
+	MockEventTransformer
+	FinalizeError error
+	FinalizedLogs []core.HeaderSyncLog
+	RevertError   error
+	RevertedLogs  []core.HeaderSyncLog
+}
+
+func (t *MockProvisionalEventTransformer) Finalize(logs []core.HeaderSyncLog) error {
+	t.FinalizedLogs = append(t.FinalizedLogs, logs...)
+	return t.FinalizeError
+}
+
+func (t *MockProvisionalEventTransformer) Revert(logs []core.HeaderSyncLog) error {
+	t.RevertedLogs = append(t.RevertedLogs, logs...)
+	return t.RevertError
+}
@@ -24,6 +24,7 @@ type MockLogDelegator struct {
 	AddedTransformers []transformer.EventTransformer
 	DelegateCallCount int
 	DelegateErrors    []error
+	RemovedNames      []string
 }
 
 func (delegator *MockLogDelegator) AddTransformer(t transformer.EventTransformer) {
@@ -43,3 +44,7 @@ func (delegator *MockLogDelegator) DelegateLogs() error {
 	}
 	return nil
 }
+
+func (delegator *MockLogDelegator) RemoveTransformer(transformerName string) {
+	delegator.RemovedNames = append(delegator.RemovedNames, transformerName)
+}
@@ -26,6 +26,7 @@ type MockLogExtractor struct {
 	AddTransformerConfigError error
 	ExtractLogsCount          int
 	ExtractLogsErrors         []error
+	RemovedNames              []string
 }
 
 func (extractor *MockLogExtractor) AddTransformerConfig(config transformer.EventTransformerConfig) error {
@@ -46,3 +47,7 @@ func (extractor *MockLogExtractor) ExtractLogs(recheckHeaders constants.Transfor
 	}
 	return nil
 }
+
+func (extractor *MockLogExtractor) RemoveTransformerConfig(transformerName string) {
+	extractor.RemovedNames = append(extractor.RemovedNames, transformerName)
+}
@@ -0,0 +1,49 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+)
+
+// MockLongValueLoader returns the latest of its Diffs for each requested key, like the storage diff repository
+type MockLongValueLoader struct {
+	Diffs      []utils.PersistedStorageDiff
+	LoadErr    error
+	LoadCalled bool
+}
+
+func (loader *MockLongValueLoader) GetLatestDiffs(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int) ([]utils.PersistedStorageDiff, error) {
+	loader.LoadCalled = true
+	latest := make(map[common.Hash]utils.PersistedStorageDiff)
+	for _, diff := range loader.Diffs {
+		if diff.HashedAddress != hashedAddress || diff.BlockHeight > blockHeight {
+			continue
+		}
+		for _, key := range storageKeys {
+			if diff.StorageKey == key && diff.BlockHeight >= latest[key].BlockHeight {
+				latest[key] = diff
+			}
+		}
+	}
+	var result []utils.PersistedStorageDiff
+	for _, diff := range latest {
+		result = append(result, diff)
+	}
+	return result, loader.LoadErr
+}
@@ -0,0 +1,105 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/vulcanize/vulcanizedb/pkg/eth/client"
+)
+
+// ParityClient is a mock client for use in Parity state diff fetcher tests
+type ParityClient struct {
+	BatchCallErr  error
+	BlockHashes   map[uint64]common.Hash
+	Head          uint64
+	PassedBatches [][]client.BatchElem
+	Traces        map[uint64]string
+	TraceErr      error
+}
+
+// SetBlock sets the hash and raw trace_replayBlockTransactions JSON the mock client returns at a height
+func (mc *ParityClient) SetBlock(height uint64, hash common.Hash, traces string) {
+	if mc.BlockHashes == nil {
+		mc.BlockHashes = make(map[uint64]common.Hash)
+		mc.Traces = make(map[uint64]string)
+	}
+	mc.BlockHashes[height] = hash
+	mc.Traces[height] = traces
+}
+
+// BatchCall mockClient method to simulate batch call to parity
+func (mc *ParityClient) BatchCall(batch []client.BatchElem) error {
+	mc.PassedBatches = append(mc.PassedBatches, batch)
+	if mc.BatchCallErr != nil {
+		return mc.BatchCallErr
+	}
+	for i, batchElem := range batch {
+		var result string
+		switch batchElem.Method {
+		case "eth_blockNumber":
+			result = fmt.Sprintf("%q", hexutil.EncodeUint64(mc.Head))
+		case "eth_getBlockByNumber":
+			height, err := heightArg(batchElem)
+			if err != nil {
+				return err
+			}
+			hash, ok := mc.BlockHashes[height]
+			if !ok {
+				result = "null"
+				break
+			}
+			result = fmt.Sprintf(`{"hash":%q}`, hash.Hex())
+		case "trace_replayBlockTransactions":
+			if mc.TraceErr != nil {
+				batch[i].Error = mc.TraceErr
+				continue
+			}
+			height, err := heightArg(batchElem)
+			if err != nil {
+				return err
+			}
+			result = mc.Traces[height]
+			if result == "" {
+				result = "[]"
+			}
+		default:
+			return fmt.Errorf("unexpected method %s", batchElem.Method)
+		}
+		err := json.Unmarshal([]byte(result), batchElem.Result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func heightArg(batchElem client.BatchElem) (uint64, error) {
+	if len(batchElem.Args) == 0 {
+		return 0, errors.New("expected batch elem to have a block height argument")
+	}
+	hexHeight, ok := batchElem.Args[0].(string)
+	if !ok {
+		return 0, errors.New("expected batch elem block height to be a hex string")
+	}
+	return hexutil.DecodeUint64(hexHeight)
+}
@@ -24,6 +24,8 @@ import (
 type StorageFetcher struct {
 	DiffsToReturn []utils.StorageDiffInput
 	ErrsToReturn  []error
+	// Stops makes FetchStorageDiffs return after sending its diffs and errors, instead of running until the process ends
+	Stops bool
 }
 
 // NewStorageFetcher returns a new StorageFetcher
@@ -39,4 +41,7 @@ func (fetcher *StorageFetcher) FetchStorageDiffs(out chan<- utils.StorageDiffInp
 	for _, diff := range fetcher.DiffsToReturn {
 		out <- diff
 	}
+	if !fetcher.Stops {
+		select {}
+	}
 }
@@ -23,9 +23,12 @@ import (
 )
 
 type MockStorageKeysLookup struct {
-	Metadata     utils.StorageValueMetadata
-	LookupCalled bool
-	LookupErr    error
+	Metadata       utils.StorageValueMetadata
+	LookupCalled   bool
+	LookupErr      error
+	StorageKeys    map[common.Hash]utils.StorageValueMetadata
+	MappingsCalled bool
+	MappingsErr    error
 }
 
 func (mappings *MockStorageKeysLookup) Lookup(key common.Hash) (utils.StorageValueMetadata, error) {
@@ -33,6 +36,11 @@ func (mappings *MockStorageKeysLookup) Lookup(key common.Hash) (utils.StorageVal
 	return mappings.Metadata, mappings.LookupErr
 }
 
+func (mappings *MockStorageKeysLookup) Mappings() (map[common.Hash]utils.StorageValueMetadata, error) {
+	mappings.MappingsCalled = true
+	return mappings.StorageKeys, mappings.MappingsErr
+}
+
 func (*MockStorageKeysLookup) SetDB(db *postgres.DB) {
 	panic("implement me")
 }
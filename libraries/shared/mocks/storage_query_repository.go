@@ -0,0 +1,46 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+)
+
+type MockStorageQueryRepository struct {
+	DiffAt              utils.PersistedStorageDiff
+	DiffAtFound         bool
+	Diffs               []utils.PersistedStorageDiff
+	Err                 error
+	PassedHashedAddress common.Hash
+	PassedStorageKeys   []common.Hash
+	PassedBlockHeight   int64
+}
+
+func (repository *MockStorageQueryRepository) GetDiffAt(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int64) (utils.PersistedStorageDiff, bool, error) {
+	repository.PassedHashedAddress = hashedAddress
+	repository.PassedStorageKeys = storageKeys
+	repository.PassedBlockHeight = blockHeight
+	return repository.DiffAt, repository.DiffAtFound, repository.Err
+}
+
+func (repository *MockStorageQueryRepository) GetDiffs(hashedAddress common.Hash, storageKeys []common.Hash) ([]utils.PersistedStorageDiff, error) {
+	repository.PassedHashedAddress = hashedAddress
+	repository.PassedStorageKeys = storageKeys
+	return repository.Diffs, repository.Err
+}
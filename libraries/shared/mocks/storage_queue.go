@@ -17,32 +17,50 @@
 package mocks
 
 import (
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 )
 
 // MockStorageQueue for tests
 type MockStorageQueue struct {
-	AddCalled       bool
-	AddError        error
-	AddPassedDiffs  []utils.PersistedStorageDiff
-	DeleteErr       error
-	DeletePassedIds []int64
-	GetAllErr       error
-	DiffsToReturn   []utils.PersistedStorageDiff
-	GetAllCalled    bool
+	AddCalled               bool
+	AddError                error
+	AddPassedDiffs          []utils.PersistedStorageDiff
+	AddPassedTransformers   []string
+	AddPassedFailures       []error
+	DeleteErr               error
+	DeletePassedIds         []int64
+	DeleteForTransformerErr error
+	DeleteForTransformerIds []int64
+	DeleteForTransformers   []string
+	GetDueErr               error
+	GetDueCalled            bool
+	GetDuePassedLimits      []int
+	DiffsToReturn           []storage.QueuedStorageDiff
+	RecordFailurePassedIds  []int64
+	RecordFailureNames      []string
+	RecordFailurePoisoned   bool
+	RecordFailureErr        error
+	PoisonedDiffsToReturn   []storage.PoisonedStorageDiff
+	GetPoisonedErr          error
+	GetPoisonedPassedOffset int
+	ReplayPassedIds         []int64
+	ReplayErr               error
 }
 
 // Add mock method
-func (queue *MockStorageQueue) Add(diff utils.PersistedStorageDiff) error {
+func (queue *MockStorageQueue) Add(diff utils.PersistedStorageDiff, transformerName string, failure error) error {
 	queue.AddCalled = true
 	queue.AddPassedDiffs = append(queue.AddPassedDiffs, diff)
+	queue.AddPassedTransformers = append(queue.AddPassedTransformers, transformerName)
+	queue.AddPassedFailures = append(queue.AddPassedFailures, failure)
 	return queue.AddError
 }
 
 // Delete mock method
 func (queue *MockStorageQueue) Delete(id int64) error {
 	queue.DeletePassedIds = append(queue.DeletePassedIds, id)
-	var diffs []utils.PersistedStorageDiff
+	var diffs []storage.QueuedStorageDiff
 	for _, diff := range queue.DiffsToReturn {
 		if diff.ID != id {
 			diffs = append(diffs, diff)
@@ -52,8 +70,57 @@ func (queue *MockStorageQueue) Delete(id int64) error {
 	return queue.DeleteErr
 }
 
-// GetAll mock method
-func (queue *MockStorageQueue) GetAll() ([]utils.PersistedStorageDiff, error) {
-	queue.GetAllCalled = true
-	return queue.DiffsToReturn, queue.GetAllErr
+// DeleteForTransformer mock method
+func (queue *MockStorageQueue) DeleteForTransformer(diffID int64, transformerName string) error {
+	queue.DeleteForTransformerIds = append(queue.DeleteForTransformerIds, diffID)
+	queue.DeleteForTransformers = append(queue.DeleteForTransformers, transformerName)
+	var diffs []storage.QueuedStorageDiff
+	for _, diff := range queue.DiffsToReturn {
+		if diff.ID != diffID || diff.Transformer != transformerName {
+			diffs = append(diffs, diff)
+		}
+	}
+	queue.DiffsToReturn = diffs
+	return queue.DeleteForTransformerErr
+}
+
+// GetDue mock method
+func (queue *MockStorageQueue) GetDue(afterBlockHeight int, afterDiffID int64, afterTransformer string, limit int) ([]storage.QueuedStorageDiff, error) {
+	queue.GetDueCalled = true
+	queue.GetDuePassedLimits = append(queue.GetDuePassedLimits, limit)
+	var diffs []storage.QueuedStorageDiff
+	for _, diff := range queue.DiffsToReturn {
+		after := diff.BlockHeight > afterBlockHeight || (diff.BlockHeight == afterBlockHeight &&
+			(diff.ID > afterDiffID || (diff.ID == afterDiffID && diff.Transformer > afterTransformer)))
+		if after && len(diffs) < limit {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, queue.GetDueErr
+}
+
+// RecordFailure mock method
+func (queue *MockStorageQueue) RecordFailure(diffID int64, transformerName string, failure error) (bool, error) {
+	queue.RecordFailurePassedIds = append(queue.RecordFailurePassedIds, diffID)
+	queue.RecordFailureNames = append(queue.RecordFailureNames, transformerName)
+	return queue.RecordFailurePoisoned, queue.RecordFailureErr
+}
+
+// GetPoisoned mock method
+func (queue *MockStorageQueue) GetPoisoned(limit, offset int) ([]storage.PoisonedStorageDiff, error) {
+	queue.GetPoisonedPassedOffset = offset
+	if offset >= len(queue.PoisonedDiffsToReturn) {
+		return nil, queue.GetPoisonedErr
+	}
+	end := offset + limit
+	if end > len(queue.PoisonedDiffsToReturn) {
+		end = len(queue.PoisonedDiffsToReturn)
+	}
+	return queue.PoisonedDiffsToReturn[offset:end], queue.GetPoisonedErr
+}
+
+// Replay mock method
+func (queue *MockStorageQueue) Replay(diffID int64) error {
+	queue.ReplayPassedIds = append(queue.ReplayPassedIds, diffID)
+	return queue.ReplayErr
 }
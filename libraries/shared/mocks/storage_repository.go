@@ -17,6 +17,7 @@
 package mocks
 
 import (
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 )
@@ -38,3 +39,24 @@ func (repository *MockStorageRepository) Create(diffID int64, metadata utils.Sto
 func (*MockStorageRepository) SetDB(db *postgres.DB) {
 	panic("implement me")
 }
+
+type MockRevertibleStorageRepository struct {
+	MockStorageRepository
+	DeleteErr      error
+	DeletedDiffIDs []int64
+}
+
+func (repository *MockRevertibleStorageRepository) Delete(diffID int64) error {
+	repository.DeletedDiffIDs = append(repository.DeletedDiffIDs, diffID)
+	return repository.DeleteErr
+}
+
+type MockAddressStorageRepository struct {
+	MockStorageRepository
+	PassedHashedAddress common.Hash
+}
+
+func (repository *MockAddressStorageRepository) CreateForAddress(diffID int64, hashedAddress common.Hash, metadata utils.StorageValueMetadata, value interface{}) error {
+	repository.PassedHashedAddress = hashedAddress
+	return repository.Create(diffID, metadata, value)
+}
@@ -46,3 +46,40 @@ func (transformer *MockStorageTransformer) KeccakContractAddress() common.Hash {
 func (transformer *MockStorageTransformer) FakeTransformerInitializer(db *postgres.DB) transformer.StorageTransformer {
 	return transformer
 }
+
+// MockRevertibleStorageTransformer for tests
+type MockRevertibleStorageTransformer struct {
+	MockStorageTransformer
+	RevertErr     error
+	RevertedDiffs []utils.PersistedStorageDiff
+}
+
+// Revert mock method
+func (transformer *MockRevertibleStorageTransformer) Revert(diffs []utils.PersistedStorageDiff) error {
+	transformer.RevertedDiffs = append(transformer.RevertedDiffs, diffs...)
+	return transformer.RevertErr
+}
+
+// FakeTransformerInitializer mock method
+func (transformer *MockRevertibleStorageTransformer) FakeTransformerInitializer(db *postgres.DB) transformer.StorageTransformer {
+	return transformer
+}
+
+// MockMultiAddressStorageTransformer for tests
+type MockMultiAddressStorageTransformer struct {
+	MockStorageTransformer
+	KeccaksOfAddresses []common.Hash
+	LoadAddressesErr   error
+	LoadAddressesCalls int
+}
+
+// KeccakContractAddresses mock method
+func (transformer *MockMultiAddressStorageTransformer) KeccakContractAddresses() ([]common.Hash, error) {
+	transformer.LoadAddressesCalls++
+	return transformer.KeccaksOfAddresses, transformer.LoadAddressesErr
+}
+
+// FakeTransformerInitializer mock method
+func (transformer *MockMultiAddressStorageTransformer) FakeTransformerInitializer(db *postgres.DB) transformer.StorageTransformer {
+	return transformer
+}
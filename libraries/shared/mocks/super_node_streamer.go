@@ -0,0 +1,44 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+)
+
+// SuperNodeStreamer is a mock of the ISuperNodeStreamer interface
+type SuperNodeStreamer struct {
+	PassedPayloadChan chan streamer.SuperNodePayload
+	PassedFilters     []config.Subscription
+	ReturnSub         *rpc.ClientSubscription
+	ReturnErr         error
+	StreamPayloads    []streamer.SuperNodePayload
+}
+
+// Stream records the subscription filters and sends the mock payloads to the passed channel
+func (sns *SuperNodeStreamer) Stream(payloadChan chan streamer.SuperNodePayload, streamFilters config.Subscription) (*rpc.ClientSubscription, error) {
+	sns.PassedPayloadChan = payloadChan
+	sns.PassedFilters = append(sns.PassedFilters, streamFilters)
+
+	for _, payload := range sns.StreamPayloads {
+		sns.PassedPayloadChan <- payload
+	}
+
+	return sns.ReturnSub, sns.ReturnErr
+}
@@ -0,0 +1,86 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// MockSuperNodeTransformer for tests, safe to call from a watcher's goroutines
+type MockSuperNodeTransformer struct {
+	InitErrs           []error // returned by successive calls to Init, which then succeeds
+	ExecuteErrs        []error // returned by successive calls to Execute, which then succeeds
+	PassedSubscription config.Subscription
+	PassedClient       core.RPCClient
+	initCalls          int
+	executeCalls       int
+	lock               sync.Mutex
+}
+
+// Init mock method
+func (transformer *MockSuperNodeTransformer) Init() error {
+	transformer.lock.Lock()
+	defer transformer.lock.Unlock()
+	transformer.initCalls++
+	return nthErr(transformer.InitErrs, transformer.initCalls)
+}
+
+// Execute mock method
+func (transformer *MockSuperNodeTransformer) Execute() error {
+	transformer.lock.Lock()
+	defer transformer.lock.Unlock()
+	transformer.executeCalls++
+	return nthErr(transformer.ExecuteErrs, transformer.executeCalls)
+}
+
+// GetConfig mock method
+func (transformer *MockSuperNodeTransformer) GetConfig() config.Subscription {
+	return transformer.PassedSubscription
+}
+
+// InitCalls is the number of times Init was called
+func (transformer *MockSuperNodeTransformer) InitCalls() int {
+	transformer.lock.Lock()
+	defer transformer.lock.Unlock()
+	return transformer.initCalls
+}
+
+// ExecuteCalls is the number of times Execute was called
+func (transformer *MockSuperNodeTransformer) ExecuteCalls() int {
+	transformer.lock.Lock()
+	defer transformer.lock.Unlock()
+	return transformer.executeCalls
+}
+
+// FakeTransformerInitializer mock method
+func (transformer *MockSuperNodeTransformer) FakeTransformerInitializer(db *postgres.DB, subCon config.Subscription, client core.RPCClient) transformer.SuperNodeTransformer {
+	transformer.PassedSubscription = subCon
+	transformer.PassedClient = client
+	return transformer
+}
+
+func nthErr(errs []error, n int) error {
+	if n > len(errs) {
+		return nil
+	}
+	return errs[n-1]
+}
@@ -0,0 +1,115 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+)
+
+// APIName is the namespace used for the transformer API
+const APIName = "transformer"
+
+// APIVersion is the version of the transformer API
+const APIVersion = "0.0.1"
+
+// TransformerAPI is the api vulcanizedb calls to run transformers in another process.
+// A nil error acknowledges that a batch was transformed.
+type TransformerAPI struct {
+	server *Server
+}
+
+// NewTransformerAPI creates a new TransformerAPI with the provided Server
+func NewTransformerAPI(server *Server) *TransformerAPI {
+	return &TransformerAPI{server: server}
+}
+
+// Names is the method to get the names of the served transformers
+func (api *TransformerAPI) Names() Names {
+	return api.server.Names()
+}
+
+// Initialize is the method to initialize the served transformers and describe them
+func (api *TransformerAPI) Initialize(settings Settings) (Description, error) {
+	return api.server.Initialize(settings)
+}
+
+// ExecuteEvents is the method to pass a batch of logs to an event transformer
+func (api *TransformerAPI) ExecuteEvents(name string, logs []core.HeaderSyncLog) error {
+	return api.server.ExecuteEvents(name, logs)
+}
+
+// FinalizeEvents is the method to pass confirmed provisional logs to an event transformer
+func (api *TransformerAPI) FinalizeEvents(name string, logs []core.HeaderSyncLog) error {
+	return api.server.FinalizeEvents(name, logs)
+}
+
+// RevertEvents is the method to pass reorged provisional logs to an event transformer
+func (api *TransformerAPI) RevertEvents(name string, logs []core.HeaderSyncLog) error {
+	return api.server.RevertEvents(name, logs)
+}
+
+// ExecuteStorage is the method to pass a storage diff to a storage transformer
+func (api *TransformerAPI) ExecuteStorage(name string, diff utils.PersistedStorageDiff) error {
+	return api.server.ExecuteStorage(name, diff)
+}
+
+// RevertStorage is the method to pass a batch of reorged storage diffs to a storage transformer
+func (api *TransformerAPI) RevertStorage(name string, diffs []utils.PersistedStorageDiff) error {
+	return api.server.RevertStorage(name, diffs)
+}
+
+// StorageAddresses is the method to get the keccak hashes of the addresses a storage transformer transforms
+func (api *TransformerAPI) StorageAddresses(name string) ([]common.Hash, error) {
+	return api.server.StorageAddresses(name)
+}
+
+// InitContract is the method to initialize a contract transformer
+func (api *TransformerAPI) InitContract(name string) error {
+	return api.server.InitContract(name)
+}
+
+// ExecuteContract is the method to run a contract transformer for one poll tick
+func (api *TransformerAPI) ExecuteContract(name string) error {
+	return api.server.ExecuteContract(name)
+}
+
+// InitSuperNode is the method to construct a super node transformer with its subscription and initialize it
+func (api *TransformerAPI) InitSuperNode(name string, subscription config.Subscription) error {
+	return api.server.InitSuperNode(name, subscription)
+}
+
+// ExecuteSuperNode is the method to run a super node transformer, which returns when it finishes or fails
+func (api *TransformerAPI) ExecuteSuperNode(name string) error {
+	return api.server.ExecuteSuperNode(name)
+}
+
+// APIs returns the RPC descriptors of the transformer API
+func APIs(server *Server) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: APIName,
+			Version:   APIVersion,
+			Service:   NewTransformerAPI(server),
+			Public:    true,
+		},
+	}
+}
@@ -0,0 +1,325 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// Exporter exports transformers that run in another process, so they can be added to watchers like a plugin's.
+// The process initializes its transformers when the first one is, with the node of the database it is passed.
+type Exporter struct {
+	client      *rpc.Client
+	settings    Settings
+	names       Names
+	once        sync.Once
+	description Description
+	err         error
+}
+
+// NewExporter gets the names of the transformers served over the client
+func NewExporter(client *rpc.Client, settings Settings) (*Exporter, error) {
+	exporter := &Exporter{client: client, settings: settings}
+	err := client.Call(&exporter.names, APIName+"_names")
+	if err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
+
+// Start runs the executable of a plugin composed in RPC mode and calls it over its stdin and stdout.
+// The process exits when vulcanizedb does.
+func Start(path string, settings Settings) (*Exporter, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	stdin, stdinErr := cmd.StdinPipe()
+	if stdinErr != nil {
+		return nil, stdinErr
+	}
+	stdout, stdoutErr := cmd.StdoutPipe()
+	if stdoutErr != nil {
+		return nil, stdoutErr
+	}
+	startErr := cmd.Start()
+	if startErr != nil {
+		return nil, startErr
+	}
+	go func() {
+		waitErr := cmd.Wait()
+		logrus.Errorf("transformer process %s exited: %v", path, waitErr)
+	}()
+	client, dialErr := rpc.DialIO(context.Background(), stdout, stdin)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	return NewExporter(client, settings)
+}
+
+// Dial calls a transformer process that is already serving on a Unix socket
+func Dial(socketPath string, settings Settings) (*Exporter, error) {
+	client, err := rpc.DialIPC(context.Background(), socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewExporter(client, settings)
+}
+
+func (exporter *Exporter) Export() ([]transformer.EventTransformerInitializer, []transformer.StorageTransformerInitializer, []transformer.ContractTransformerInitializer, []transformer.SuperNodeTransformerInitializer) {
+	var eventInitializers []transformer.EventTransformerInitializer
+	for _, name := range exporter.names.Events {
+		eventName := name
+		eventInitializers = append(eventInitializers, func(db *postgres.DB) transformer.EventTransformer {
+			return exporter.eventTransformer(db, eventName)
+		})
+	}
+	var storageInitializers []transformer.StorageTransformerInitializer
+	for _, name := range exporter.names.Storage {
+		storageName := name
+		storageInitializers = append(storageInitializers, func(db *postgres.DB) transformer.StorageTransformer {
+			return exporter.storageTransformer(db, storageName)
+		})
+	}
+	var contractInitializers []transformer.ContractTransformerInitializer
+	for _, name := range exporter.names.Contracts {
+		contractName := name
+		contractInitializers = append(contractInitializers, func(db *postgres.DB, bc core.BlockChain) transformer.ContractTransformer {
+			return exporter.contractTransformer(db, contractName)
+		})
+	}
+	// The transformer process subscribes to the super node in its settings rather than with the client passed here
+	var superNodeInitializers []transformer.SuperNodeTransformerInitializer
+	for _, name := range exporter.names.SuperNode {
+		superNodeName := name
+		superNodeInitializers = append(superNodeInitializers, func(db *postgres.DB, subCon config.Subscription, client core.RPCClient) transformer.SuperNodeTransformer {
+			return exporter.superNodeTransformer(db, superNodeName, subCon)
+		})
+	}
+	return eventInitializers, storageInitializers, contractInitializers, superNodeInitializers
+}
+
+func (exporter *Exporter) ExportNames() ([]string, []string, []string, []string) {
+	return exporter.names.Events, exporter.names.Storage, exporter.names.Contracts, exporter.names.SuperNode
+}
+
+// initialize initializes the served transformers once; if that fails, every remote transformer returns the error
+func (exporter *Exporter) initialize(db *postgres.DB) (Description, error) {
+	exporter.once.Do(func() {
+		settings := exporter.settings
+		settings.Node = db.Node
+		exporter.err = exporter.client.Call(&exporter.description, APIName+"_initialize", settings)
+		if exporter.err != nil {
+			logrus.Errorf("failed to initialize remote transformers: %s", exporter.err.Error())
+		}
+	})
+	return exporter.description, exporter.err
+}
+
+func (exporter *Exporter) eventTransformer(db *postgres.DB, name string) transformer.EventTransformer {
+	description, err := exporter.initialize(db)
+	eventTransformer := remoteEventTransformer{remoteTransformer: exporter.remoteTransformer(name, err)}
+	for _, event := range description.Events {
+		if event.Name == name {
+			eventTransformer.config = event.Config
+			if event.Provisional {
+				return remoteProvisionalEventTransformer{eventTransformer}
+			}
+		}
+	}
+	return eventTransformer
+}
+
+func (exporter *Exporter) storageTransformer(db *postgres.DB, name string) transformer.StorageTransformer {
+	description, err := exporter.initialize(db)
+	storageTransformer := remoteStorageTransformer{remoteTransformer: exporter.remoteTransformer(name, err)}
+	for _, storage := range description.Storage {
+		if storage.Name != name {
+			continue
+		}
+		storageTransformer.hashedAddress = storage.KeccakContractAddress
+		switch {
+		case storage.Revertible && storage.MultiAddress:
+			return remoteRevertibleMultiAddressStorageTransformer{storageTransformer}
+		case storage.Revertible:
+			return remoteRevertibleStorageTransformer{storageTransformer}
+		case storage.MultiAddress:
+			return remoteMultiAddressStorageTransformer{storageTransformer}
+		}
+	}
+	return storageTransformer
+}
+
+func (exporter *Exporter) contractTransformer(db *postgres.DB, name string) transformer.ContractTransformer {
+	description, err := exporter.initialize(db)
+	contractTransformer := remoteContractTransformer{remoteTransformer: exporter.remoteTransformer(name, err)}
+	for _, contract := range description.Contracts {
+		if contract.Name == name {
+			contractTransformer.config = contract.Config
+		}
+	}
+	return contractTransformer
+}
+
+func (exporter *Exporter) superNodeTransformer(db *postgres.DB, name string, subscription config.Subscription) transformer.SuperNodeTransformer {
+	_, err := exporter.initialize(db)
+	return remoteSuperNodeTransformer{remoteTransformer: exporter.remoteTransformer(name, err), subscription: subscription}
+}
+
+func (exporter *Exporter) remoteTransformer(name string, err error) remoteTransformer {
+	return remoteTransformer{client: exporter.client, name: name, err: err}
+}
+
+// remoteTransformer calls a transformer by name, or returns the error initializing it
+type remoteTransformer struct {
+	client *rpc.Client
+	name   string
+	err    error
+}
+
+func (remote remoteTransformer) call(result interface{}, method string, args ...interface{}) error {
+	if remote.err != nil {
+		return remote.err
+	}
+	err := remote.client.Call(result, APIName+"_"+method, append([]interface{}{remote.name}, args...)...)
+	if err != nil {
+		return fmt.Errorf("remote transformer %s: %s", remote.name, err.Error())
+	}
+	return nil
+}
+
+type remoteEventTransformer struct {
+	remoteTransformer
+	config transformer.EventTransformerConfig
+}
+
+func (remote remoteEventTransformer) Execute(logs []core.HeaderSyncLog) error {
+	return remote.call(nil, "executeEvents", logs)
+}
+
+func (remote remoteEventTransformer) GetConfig() transformer.EventTransformerConfig {
+	return remote.config
+}
+
+type remoteProvisionalEventTransformer struct {
+	remoteEventTransformer
+}
+
+func (remote remoteProvisionalEventTransformer) Finalize(logs []core.HeaderSyncLog) error {
+	return remote.call(nil, "finalizeEvents", logs)
+}
+
+func (remote remoteProvisionalEventTransformer) Revert(logs []core.HeaderSyncLog) error {
+	return remote.call(nil, "revertEvents", logs)
+}
+
+type remoteStorageTransformer struct {
+	remoteTransformer
+	hashedAddress common.Hash
+}
+
+func (remote remoteStorageTransformer) Execute(diff utils.PersistedStorageDiff) error {
+	return remote.call(nil, "executeStorage", diff)
+}
+
+func (remote remoteStorageTransformer) KeccakContractAddress() common.Hash {
+	return remote.hashedAddress
+}
+
+func (remote remoteStorageTransformer) revert(diffs []utils.PersistedStorageDiff) error {
+	return remote.call(nil, "revertStorage", diffs)
+}
+
+func (remote remoteStorageTransformer) keccakContractAddresses() ([]common.Hash, error) {
+	var hashedAddresses []common.Hash
+	err := remote.call(&hashedAddresses, "storageAddresses")
+	return hashedAddresses, err
+}
+
+type remoteRevertibleStorageTransformer struct {
+	remoteStorageTransformer
+}
+
+func (remote remoteRevertibleStorageTransformer) Revert(diffs []utils.PersistedStorageDiff) error {
+	return remote.revert(diffs)
+}
+
+type remoteMultiAddressStorageTransformer struct {
+	remoteStorageTransformer
+}
+
+func (remote remoteMultiAddressStorageTransformer) KeccakContractAddresses() ([]common.Hash, error) {
+	return remote.keccakContractAddresses()
+}
+
+type remoteRevertibleMultiAddressStorageTransformer struct {
+	remoteStorageTransformer
+}
+
+func (remote remoteRevertibleMultiAddressStorageTransformer) Revert(diffs []utils.PersistedStorageDiff) error {
+	return remote.revert(diffs)
+}
+
+func (remote remoteRevertibleMultiAddressStorageTransformer) KeccakContractAddresses() ([]common.Hash, error) {
+	return remote.keccakContractAddresses()
+}
+
+type remoteContractTransformer struct {
+	remoteTransformer
+	config config.ContractConfig
+}
+
+func (remote remoteContractTransformer) Init() error {
+	return remote.call(nil, "initContract")
+}
+
+func (remote remoteContractTransformer) Execute() error {
+	return remote.call(nil, "executeContract")
+}
+
+func (remote remoteContractTransformer) GetConfig() config.ContractConfig {
+	return remote.config
+}
+
+type remoteSuperNodeTransformer struct {
+	remoteTransformer
+	subscription config.Subscription
+}
+
+func (remote remoteSuperNodeTransformer) Init() error {
+	return remote.call(nil, "initSuperNode", remote.subscription)
+}
+
+func (remote remoteSuperNodeTransformer) Execute() error {
+	return remote.call(nil, "executeSuperNode")
+}
+
+func (remote remoteSuperNodeTransformer) GetConfig() config.Subscription {
+	return remote.subscription
+}
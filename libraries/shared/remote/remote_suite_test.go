@@ -14,30 +14,22 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package fakes
+package remote_test
 
 import (
-	"github.com/hpcloud/tail"
-	"gopkg.in/tomb.v1"
-)
+	"io/ioutil"
+	"testing"
 
-type MockTailer struct {
-	Lines   chan *tail.Line
-	TailErr error
-}
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
 
-func NewMockTailer() *MockTailer {
-	return &MockTailer{
-		Lines: make(chan *tail.Line, 1),
-	}
+func TestRemote(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Remote Transformers Suite")
 }
 
-func (mock *MockTailer) Tail() (*tail.Tail, error) {
-	fakeTail := &tail.Tail{
-		Filename: "",
-		Lines:    mock.Lines,
-		Config:   tail.Config{},
-		Tomb:     tomb.Tomb{},
-	}
-	return fakeTail, mock.TailErr
-}
+var _ = BeforeSuite(func() {
+	log.SetOutput(ioutil.Discard)
+})
@@ -0,0 +1,334 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote_test
+
+import (
+	"context"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/remote"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+type fakeExporter struct {
+	eventInitializers     []transformer.EventTransformerInitializer
+	storageInitializers   []transformer.StorageTransformerInitializer
+	contractInitializers  []transformer.ContractTransformerInitializer
+	superNodeInitializers []transformer.SuperNodeTransformerInitializer
+	eventNames            []string
+	storageNames          []string
+	contractNames         []string
+	superNodeNames        []string
+}
+
+func (exporter fakeExporter) Export() ([]transformer.EventTransformerInitializer, []transformer.StorageTransformerInitializer, []transformer.ContractTransformerInitializer, []transformer.SuperNodeTransformerInitializer) {
+	return exporter.eventInitializers, exporter.storageInitializers, exporter.contractInitializers, exporter.superNodeInitializers
+}
+
+func (exporter fakeExporter) ExportNames() ([]string, []string, []string, []string) {
+	return exporter.eventNames, exporter.storageNames, exporter.contractNames, exporter.superNodeNames
+}
+
+type fakeConnections struct {
+	db             *postgres.DB
+	dbErr          error
+	blockChain     core.BlockChain
+	superNode      core.RPCClient
+	superNodeDials int
+	passedSetting  remote.Settings
+}
+
+func (connections *fakeConnections) DB(settings remote.Settings) (*postgres.DB, error) {
+	connections.passedSetting = settings
+	return connections.db, connections.dbErr
+}
+
+func (connections *fakeConnections) BlockChain(settings remote.Settings) (core.BlockChain, error) {
+	return connections.blockChain, nil
+}
+
+func (connections *fakeConnections) SuperNode(settings remote.Settings) (core.RPCClient, error) {
+	connections.superNodeDials++
+	return connections.superNode, nil
+}
+
+var _ = Describe("Remote transformers", func() {
+	var (
+		eventTransformer          *mocks.MockEventTransformer
+		provisionalTransformer    *mocks.MockProvisionalEventTransformer
+		storageTransformer        *mocks.MockStorageTransformer
+		revertibleTransformer     *mocks.MockRevertibleStorageTransformer
+		multiAddressesTransformer *mocks.MockMultiAddressStorageTransformer
+		contractTransformer       *mocks.MockContractTransformer
+		superNodeTransformer      *mocks.MockSuperNodeTransformer
+		connections               *fakeConnections
+		server                    *remote.Server
+		settings                  remote.Settings
+		hostDB                    *postgres.DB
+		logs                      []core.HeaderSyncLog
+		diff                      utils.PersistedStorageDiff
+	)
+
+	BeforeEach(func() {
+		eventTransformer = &mocks.MockEventTransformer{}
+		eventTransformer.SetTransformerConfig(mocks.FakeTransformerConfig)
+		provisionalTransformer = &mocks.MockProvisionalEventTransformer{}
+		storageTransformer = &mocks.MockStorageTransformer{KeccakOfAddress: common.HexToHash("0x1")}
+		revertibleTransformer = &mocks.MockRevertibleStorageTransformer{
+			MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: common.HexToHash("0x2")},
+		}
+		multiAddressesTransformer = &mocks.MockMultiAddressStorageTransformer{
+			MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: common.HexToHash("0x3")},
+			KeccaksOfAddresses:     []common.Hash{common.HexToHash("0x3"), common.HexToHash("0x4")},
+		}
+		contractTransformer = &mocks.MockContractTransformer{Config: config.ContractConfig{Name: "contract"}}
+		superNodeTransformer = &mocks.MockSuperNodeTransformer{}
+		exporter := fakeExporter{
+			eventInitializers: []transformer.EventTransformerInitializer{
+				eventTransformer.FakeTransformerInitializer,
+				func(db *postgres.DB) transformer.EventTransformer { return provisionalTransformer },
+			},
+			storageInitializers: []transformer.StorageTransformerInitializer{
+				storageTransformer.FakeTransformerInitializer,
+				revertibleTransformer.FakeTransformerInitializer,
+				multiAddressesTransformer.FakeTransformerInitializer,
+			},
+			contractInitializers:  []transformer.ContractTransformerInitializer{contractTransformer.FakeTransformerInitializer},
+			superNodeInitializers: []transformer.SuperNodeTransformerInitializer{superNodeTransformer.FakeTransformerInitializer},
+			eventNames:            []string{"event", "provisional"},
+			storageNames:          []string{"storage", "revertible", "multiAddress"},
+			contractNames:         []string{"contract"},
+			superNodeNames:        []string{"superNode"},
+		}
+		connections = &fakeConnections{db: &postgres.DB{}, blockChain: fakes.NewMockBlockChain(), superNode: fakes.NewMockRPCClient()}
+		server = remote.NewServer(exporter, connections)
+		settings = remote.Settings{Database: config.Database{Name: "vulcanize_test"}, ClientIPCPath: "/tmp/geth.ipc"}
+		hostDB = &postgres.DB{Node: core.Node{ID: "node"}}
+		logs = []core.HeaderSyncLog{{
+			ID:       1,
+			HeaderID: 2,
+			Log: types.Log{
+				Address: fakes.FakeAddress,
+				Topics:  []common.Hash{fakes.FakeHash},
+				Data:    []byte{1, 2, 3},
+				TxHash:  fakes.FakeHash,
+			},
+		}}
+		diff = utils.PersistedStorageDiff{ID: 1, StorageDiffInput: utils.StorageDiffInput{
+			HashedAddress: common.HexToHash("0x1"),
+			BlockHeight:   100,
+			StorageKey:    fakes.FakeHash,
+			StorageValue:  common.HexToHash("0x5"),
+		}}
+	})
+
+	newInProcExporter := func() *remote.Exporter {
+		rpcServer := rpc.NewServer()
+		for _, api := range remote.APIs(server) {
+			Expect(rpcServer.RegisterName(api.Namespace, api.Service)).To(Succeed())
+		}
+		exporter, err := remote.NewExporter(rpc.DialInProc(rpcServer), settings)
+		Expect(err).NotTo(HaveOccurred())
+		return exporter
+	}
+
+	It("exports the names of the served transformers", func() {
+		exporter := newInProcExporter()
+
+		eventNames, storageNames, contractNames, superNodeNames := exporter.ExportNames()
+		eventInitializers, storageInitializers, contractInitializers, superNodeInitializers := exporter.Export()
+
+		Expect(eventNames).To(Equal([]string{"event", "provisional"}))
+		Expect(storageNames).To(Equal([]string{"storage", "revertible", "multiAddress"}))
+		Expect(contractNames).To(Equal([]string{"contract"}))
+		Expect(superNodeNames).To(Equal([]string{"superNode"}))
+		Expect(eventInitializers).To(HaveLen(2))
+		Expect(storageInitializers).To(HaveLen(3))
+		Expect(contractInitializers).To(HaveLen(1))
+		Expect(superNodeInitializers).To(HaveLen(1))
+	})
+
+	It("initializes the served transformers once with the settings and the node of the host's database", func() {
+		exporter := newInProcExporter()
+		eventInitializers, storageInitializers, _, _ := exporter.Export()
+
+		eventInitializers[0](hostDB)
+		storageInitializers[0](hostDB)
+
+		expectedSettings := settings
+		expectedSettings.Node = hostDB.Node
+		Expect(connections.passedSetting).To(Equal(expectedSettings))
+	})
+
+	It("passes batches of logs to event transformers", func() {
+		eventInitializers, _, _, _ := newInProcExporter().Export()
+
+		remoteTransformer := eventInitializers[0](hostDB)
+		err := remoteTransformer.Execute(logs)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remoteTransformer.GetConfig()).To(Equal(mocks.FakeTransformerConfig))
+		Expect(eventTransformer.PassedLogs).To(Equal(logs))
+		_, provisional := remoteTransformer.(transformer.ProvisionalEventTransformer)
+		Expect(provisional).To(BeFalse())
+	})
+
+	It("passes provisional logs to event transformers that handle them", func() {
+		eventInitializers, _, _, _ := newInProcExporter().Export()
+
+		remoteTransformer, ok := eventInitializers[1](hostDB).(transformer.ProvisionalEventTransformer)
+		Expect(ok).To(BeTrue())
+		Expect(remoteTransformer.Finalize(logs)).To(Succeed())
+		Expect(remoteTransformer.Revert(logs)).To(Succeed())
+
+		Expect(provisionalTransformer.FinalizedLogs).To(Equal(logs))
+		Expect(provisionalTransformer.RevertedLogs).To(Equal(logs))
+	})
+
+	It("returns errors from event transformers", func() {
+		eventTransformer.ExecuteError = fakes.FakeError
+		eventInitializers, _, _, _ := newInProcExporter().Export()
+
+		err := eventInitializers[0](hostDB).Execute(logs)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+	})
+
+	It("passes storage diffs to storage transformers", func() {
+		_, storageInitializers, _, _ := newInProcExporter().Export()
+
+		remoteTransformer := storageInitializers[0](hostDB)
+		err := remoteTransformer.Execute(diff)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remoteTransformer.KeccakContractAddress()).To(Equal(common.HexToHash("0x1")))
+		Expect(storageTransformer.PassedDiffs).To(Equal([]utils.PersistedStorageDiff{diff}))
+		_, revertible := remoteTransformer.(transformer.RevertibleStorageTransformer)
+		Expect(revertible).To(BeFalse())
+		_, multiAddress := remoteTransformer.(transformer.MultiAddressStorageTransformer)
+		Expect(multiAddress).To(BeFalse())
+	})
+
+	It("reverts diffs with storage transformers that can revert them", func() {
+		_, storageInitializers, _, _ := newInProcExporter().Export()
+
+		remoteTransformer, ok := storageInitializers[1](hostDB).(transformer.RevertibleStorageTransformer)
+		Expect(ok).To(BeTrue())
+		err := remoteTransformer.Revert([]utils.PersistedStorageDiff{diff})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revertibleTransformer.RevertedDiffs).To(Equal([]utils.PersistedStorageDiff{diff}))
+	})
+
+	It("loads the addresses of multi-address storage transformers", func() {
+		_, storageInitializers, _, _ := newInProcExporter().Export()
+
+		remoteTransformer, ok := storageInitializers[2](hostDB).(transformer.MultiAddressStorageTransformer)
+		Expect(ok).To(BeTrue())
+		hashedAddresses, err := remoteTransformer.KeccakContractAddresses()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hashedAddresses).To(Equal(multiAddressesTransformer.KeccaksOfAddresses))
+	})
+
+	It("initializes and executes contract transformers with the process's blockchain", func() {
+		_, _, contractInitializers, _ := newInProcExporter().Export()
+
+		remoteTransformer := contractInitializers[0](hostDB, nil)
+		Expect(remoteTransformer.Init()).To(Succeed())
+		Expect(remoteTransformer.Execute()).To(Succeed())
+
+		Expect(remoteTransformer.GetConfig()).To(Equal(contractTransformer.Config))
+		Expect(contractTransformer.InitCalled).To(BeTrue())
+		Expect(contractTransformer.ExecuteCalls).To(Equal(1))
+		Expect(contractTransformer.PassedBlockChain).To(Equal(connections.blockChain))
+	})
+
+	It("initializes and executes super node transformers with their subscription and the process's super node", func() {
+		settings.SuperNodePath = "ws://127.0.0.1:8080"
+		subscription := config.Subscription{StartingBlock: big.NewInt(10), EndingBlock: big.NewInt(20)}
+		_, _, _, superNodeInitializers := newInProcExporter().Export()
+
+		remoteTransformer := superNodeInitializers[0](hostDB, subscription, nil)
+		Expect(remoteTransformer.Init()).To(Succeed())
+		Expect(remoteTransformer.Init()).To(Succeed())
+		Expect(remoteTransformer.Execute()).To(Succeed())
+
+		Expect(remoteTransformer.GetConfig()).To(Equal(subscription))
+		Expect(superNodeTransformer.PassedSubscription).To(Equal(subscription))
+		Expect(superNodeTransformer.PassedClient).To(Equal(connections.superNode))
+		Expect(superNodeTransformer.InitCalls()).To(Equal(2))
+		Expect(superNodeTransformer.ExecuteCalls()).To(Equal(1))
+		Expect(connections.superNodeDials).To(Equal(1))
+		Expect(connections.passedSetting.SuperNodePath).To(Equal(settings.SuperNodePath))
+	})
+
+	It("returns an error executing a super node transformer that isn't initialized", func() {
+		_, _, _, superNodeInitializers := newInProcExporter().Export()
+
+		err := superNodeInitializers[0](hostDB, config.Subscription{}, nil).Execute()
+
+		Expect(err).To(MatchError(ContainSubstring("super node transformer superNode is not initialized")))
+	})
+
+	It("returns the initialization error from every transformer", func() {
+		connections.dbErr = fakes.FakeError
+		eventInitializers, storageInitializers, _, _ := newInProcExporter().Export()
+
+		eventErr := eventInitializers[0](hostDB).Execute(logs)
+		storageErr := storageInitializers[0](hostDB).Execute(diff)
+
+		Expect(eventErr).To(HaveOccurred())
+		Expect(eventErr.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+		Expect(storageErr).To(Equal(eventErr))
+		Expect(eventTransformer.ExecuteWasCalled).To(BeFalse())
+	})
+
+	It("serves transformers over a reader and writer, like a process's stdin and stdout", func() {
+		requests, requestWriter := io.Pipe()
+		responseReader, responses := io.Pipe()
+		go func() {
+			defer GinkgoRecover()
+			Expect(server.ServeIO(requests, responses)).To(Succeed())
+		}()
+		client, dialErr := rpc.DialIO(context.Background(), responseReader, requestWriter)
+		Expect(dialErr).NotTo(HaveOccurred())
+		exporter, err := remote.NewExporter(client, settings)
+		Expect(err).NotTo(HaveOccurred())
+		eventInitializers, _, _, _ := exporter.Export()
+
+		executeErr := eventInitializers[0](hostDB).Execute(logs)
+
+		Expect(executeErr).NotTo(HaveOccurred())
+		Expect(eventTransformer.PassedLogs).To(Equal(logs))
+		Expect(requestWriter.Close()).To(Succeed())
+	})
+})
@@ -0,0 +1,459 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+	"github.com/vulcanize/vulcanizedb/pkg/eth/client"
+	vRpc "github.com/vulcanize/vulcanizedb/pkg/eth/converters/rpc"
+	"github.com/vulcanize/vulcanizedb/pkg/eth/node"
+)
+
+// NamedExporter exports transformer initializers and their names, index-aligned, as composed plugins do
+type NamedExporter interface {
+	Export() ([]transformer.EventTransformerInitializer, []transformer.StorageTransformerInitializer, []transformer.ContractTransformerInitializer, []transformer.SuperNodeTransformerInitializer)
+	ExportNames() ([]string, []string, []string, []string)
+}
+
+// Names are the names of the transformers a process serves, by type
+type Names struct {
+	Events    []string
+	Storage   []string
+	Contracts []string
+	SuperNode []string
+}
+
+// Settings are sent by vulcanizedb so that the transformer process uses the same database and node
+type Settings struct {
+	Database      config.Database
+	Node          core.Node
+	ClientIPCPath string
+	SuperNodePath string // super node that super node transformers subscribe to
+}
+
+// Description describes the transformers a process serves once they are initialized
+type Description struct {
+	Events    []EventDescription
+	Storage   []StorageDescription
+	Contracts []ContractDescription
+}
+
+type EventDescription struct {
+	Name        string
+	Config      transformer.EventTransformerConfig
+	Provisional bool
+}
+
+type StorageDescription struct {
+	Name                  string
+	KeccakContractAddress common.Hash
+	Revertible            bool
+	MultiAddress          bool
+}
+
+type ContractDescription struct {
+	Name   string
+	Config config.ContractConfig
+}
+
+// Connections opens the database and blockchain the served transformers are initialized with
+type Connections interface {
+	DB(settings Settings) (*postgres.DB, error)
+	BlockChain(settings Settings) (core.BlockChain, error)
+	SuperNode(settings Settings) (core.RPCClient, error)
+}
+
+// DialConnections connects to the database and the Ethereum node given in the settings
+type DialConnections struct{}
+
+func (DialConnections) DB(settings Settings) (*postgres.DB, error) {
+	return postgres.NewDB(settings.Database, settings.Node)
+}
+
+func (DialConnections) BlockChain(settings Settings) (core.BlockChain, error) {
+	rawRPCClient, dialErr := rpc.Dial(settings.ClientIPCPath)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	rpcClient := client.NewRPCClient(rawRPCClient, settings.ClientIPCPath)
+	ethClient := ethclient.NewClient(rawRPCClient)
+	transactionConverter := vRpc.NewRPCTransactionConverter(ethClient)
+	return eth.NewBlockChain(client.NewEthClient(ethClient), rpcClient, node.MakeNode(rpcClient), transactionConverter), nil
+}
+
+func (DialConnections) SuperNode(settings Settings) (core.RPCClient, error) {
+	rawRPCClient, err := rpc.Dial(settings.SuperNodePath)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewRPCClient(rawRPCClient, settings.SuperNodePath), nil
+}
+
+// Server runs an exporter's transformers for a vulcanizedb process that calls it over RPC
+type Server struct {
+	exporter    NamedExporter
+	connections Connections
+	events      map[string]transformer.EventTransformer
+	storage     map[string]transformer.StorageTransformer
+	contracts   map[string]transformer.ContractTransformer
+	// super node transformers are constructed when they are first initialized, with the subscription they are passed
+	db                    *postgres.DB
+	settings              Settings
+	superNodeClient       core.RPCClient
+	superNodeInitializers map[string]transformer.SuperNodeTransformerInitializer
+	superNode             map[string]transformer.SuperNodeTransformer
+	lock                  sync.RWMutex
+}
+
+func NewServer(exporter NamedExporter, connections Connections) *Server {
+	return &Server{
+		exporter:    exporter,
+		connections: connections,
+	}
+}
+
+// Serve serves the exporter's transformers on the Unix socket given by the -socket flag, or on stdin and stdout.
+// It is called by the main function of a plugin composed in RPC mode.
+func Serve(exporter NamedExporter) {
+	socket := flag.String("socket", "", "Unix socket to serve transformers on, instead of stdin and stdout")
+	flag.Parse()
+	server := NewServer(exporter, DialConnections{})
+	var err error
+	if *socket != "" {
+		err = server.ServeSocket(*socket)
+	} else {
+		err = server.ServeIO(os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// ServeIO serves JSON-RPC requests read from in until it is closed, writing responses to out
+func (server *Server) ServeIO(in io.Reader, out io.Writer) error {
+	rpcServer, err := server.rpcServer()
+	if err != nil {
+		return err
+	}
+	rpcServer.ServeCodec(rpc.NewJSONCodec(ioConn{in: in, out: out}), rpc.OptionMethodInvocation)
+	return nil
+}
+
+// ServeSocket serves JSON-RPC requests on a Unix socket, replacing any stale socket file at the path
+func (server *Server) ServeSocket(path string) error {
+	rpcServer, err := server.rpcServer()
+	if err != nil {
+		return err
+	}
+	removeErr := os.Remove(path)
+	if removeErr != nil && !os.IsNotExist(removeErr) {
+		return removeErr
+	}
+	listener, listenErr := net.Listen("unix", path)
+	if listenErr != nil {
+		return listenErr
+	}
+	logrus.Infof("serving transformers on %s", path)
+	return rpcServer.ServeListener(listener)
+}
+
+func (server *Server) rpcServer() (*rpc.Server, error) {
+	rpcServer := rpc.NewServer()
+	for _, api := range APIs(server) {
+		err := rpcServer.RegisterName(api.Namespace, api.Service)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rpcServer, nil
+}
+
+// Names returns the names of the exporter's transformers, which doesn't require initializing them
+func (server *Server) Names() Names {
+	events, storage, contracts, superNode := server.exporter.ExportNames()
+	return Names{Events: events, Storage: storage, Contracts: contracts, SuperNode: superNode}
+}
+
+// Initialize connects to the database, and the blockchain if there are contract transformers,
+// initializes the exporter's transformers with them, and describes them
+func (server *Server) Initialize(settings Settings) (Description, error) {
+	eventNames, storageNames, contractNames, superNodeNames := server.exporter.ExportNames()
+	eventInitializers, storageInitializers, contractInitializers, superNodeInitializers := server.exporter.Export()
+	if len(eventNames) != len(eventInitializers) || len(storageNames) != len(storageInitializers) ||
+		len(contractNames) != len(contractInitializers) || len(superNodeNames) != len(superNodeInitializers) {
+		return Description{}, fmt.Errorf("exporter names do not match its initializers")
+	}
+	db, dbErr := server.connections.DB(settings)
+	if dbErr != nil {
+		return Description{}, dbErr
+	}
+	var bc core.BlockChain
+	if len(contractInitializers) > 0 {
+		var bcErr error
+		bc, bcErr = server.connections.BlockChain(settings)
+		if bcErr != nil {
+			return Description{}, bcErr
+		}
+	}
+
+	var description Description
+	events := make(map[string]transformer.EventTransformer)
+	for i, initializer := range eventInitializers {
+		eventTransformer := initializer(db)
+		_, provisional := eventTransformer.(transformer.ProvisionalEventTransformer)
+		events[eventNames[i]] = eventTransformer
+		description.Events = append(description.Events, EventDescription{
+			Name:        eventNames[i],
+			Config:      eventTransformer.GetConfig(),
+			Provisional: provisional,
+		})
+	}
+	storage := make(map[string]transformer.StorageTransformer)
+	for i, initializer := range storageInitializers {
+		storageTransformer := initializer(db)
+		_, revertible := storageTransformer.(transformer.RevertibleStorageTransformer)
+		_, multiAddress := storageTransformer.(transformer.MultiAddressStorageTransformer)
+		storage[storageNames[i]] = storageTransformer
+		description.Storage = append(description.Storage, StorageDescription{
+			Name:                  storageNames[i],
+			KeccakContractAddress: storageTransformer.KeccakContractAddress(),
+			Revertible:            revertible,
+			MultiAddress:          multiAddress,
+		})
+	}
+	contracts := make(map[string]transformer.ContractTransformer)
+	for i, initializer := range contractInitializers {
+		contractTransformer := initializer(db, bc)
+		contracts[contractNames[i]] = contractTransformer
+		description.Contracts = append(description.Contracts, ContractDescription{
+			Name:   contractNames[i],
+			Config: contractTransformer.GetConfig(),
+		})
+	}
+	superNode := make(map[string]transformer.SuperNodeTransformerInitializer)
+	for i, initializer := range superNodeInitializers {
+		superNode[superNodeNames[i]] = initializer
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.events, server.storage, server.contracts = events, storage, contracts
+	server.db, server.settings = db, settings
+	server.superNodeInitializers, server.superNode = superNode, make(map[string]transformer.SuperNodeTransformer)
+	return description, nil
+}
+
+func (server *Server) ExecuteEvents(name string, logs []core.HeaderSyncLog) error {
+	eventTransformer, err := server.eventTransformer(name)
+	if err != nil {
+		return err
+	}
+	return eventTransformer.Execute(logs)
+}
+
+func (server *Server) FinalizeEvents(name string, logs []core.HeaderSyncLog) error {
+	provisionalTransformer, err := server.provisionalEventTransformer(name)
+	if err != nil {
+		return err
+	}
+	return provisionalTransformer.Finalize(logs)
+}
+
+func (server *Server) RevertEvents(name string, logs []core.HeaderSyncLog) error {
+	provisionalTransformer, err := server.provisionalEventTransformer(name)
+	if err != nil {
+		return err
+	}
+	return provisionalTransformer.Revert(logs)
+}
+
+func (server *Server) ExecuteStorage(name string, diff utils.PersistedStorageDiff) error {
+	storageTransformer, err := server.storageTransformer(name)
+	if err != nil {
+		return err
+	}
+	return storageTransformer.Execute(diff)
+}
+
+func (server *Server) RevertStorage(name string, diffs []utils.PersistedStorageDiff) error {
+	storageTransformer, err := server.storageTransformer(name)
+	if err != nil {
+		return err
+	}
+	revertibleTransformer, ok := storageTransformer.(transformer.RevertibleStorageTransformer)
+	if !ok {
+		return fmt.Errorf("storage transformer %s can't revert diffs", name)
+	}
+	return revertibleTransformer.Revert(diffs)
+}
+
+func (server *Server) StorageAddresses(name string) ([]common.Hash, error) {
+	storageTransformer, err := server.storageTransformer(name)
+	if err != nil {
+		return nil, err
+	}
+	multiAddressTransformer, ok := storageTransformer.(transformer.MultiAddressStorageTransformer)
+	if !ok {
+		return []common.Hash{storageTransformer.KeccakContractAddress()}, nil
+	}
+	return multiAddressTransformer.KeccakContractAddresses()
+}
+
+func (server *Server) InitContract(name string) error {
+	contractTransformer, err := server.contractTransformer(name)
+	if err != nil {
+		return err
+	}
+	return contractTransformer.Init()
+}
+
+func (server *Server) ExecuteContract(name string) error {
+	contractTransformer, err := server.contractTransformer(name)
+	if err != nil {
+		return err
+	}
+	return contractTransformer.Execute()
+}
+
+// InitSuperNode constructs a super node transformer with the subscription, and the super node in the settings,
+// the first time it is initialized, and initializes it
+func (server *Server) InitSuperNode(name string, subscription config.Subscription) error {
+	superNodeTransformer, err := server.constructSuperNodeTransformer(name, subscription)
+	if err != nil {
+		return err
+	}
+	return superNodeTransformer.Init()
+}
+
+// ExecuteSuperNode runs a super node transformer until it finishes or fails
+func (server *Server) ExecuteSuperNode(name string) error {
+	server.lock.RLock()
+	superNodeTransformer, ok := server.superNode[name]
+	server.lock.RUnlock()
+	if !ok {
+		return errNotInitialized("super node", name)
+	}
+	return superNodeTransformer.Execute()
+}
+
+func (server *Server) constructSuperNodeTransformer(name string, subscription config.Subscription) (transformer.SuperNodeTransformer, error) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if superNodeTransformer, ok := server.superNode[name]; ok {
+		return superNodeTransformer, nil
+	}
+	initializer, ok := server.superNodeInitializers[name]
+	if !ok {
+		return nil, errNotInitialized("super node", name)
+	}
+	if server.superNodeClient == nil {
+		superNodeClient, err := server.connections.SuperNode(server.settings)
+		if err != nil {
+			return nil, err
+		}
+		server.superNodeClient = superNodeClient
+	}
+	superNodeTransformer := initializer(server.db, subscription, server.superNodeClient)
+	server.superNode[name] = superNodeTransformer
+	return superNodeTransformer, nil
+}
+
+func (server *Server) eventTransformer(name string) (transformer.EventTransformer, error) {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	eventTransformer, ok := server.events[name]
+	if !ok {
+		return nil, errNotInitialized("event", name)
+	}
+	return eventTransformer, nil
+}
+
+func (server *Server) provisionalEventTransformer(name string) (transformer.ProvisionalEventTransformer, error) {
+	eventTransformer, err := server.eventTransformer(name)
+	if err != nil {
+		return nil, err
+	}
+	provisionalTransformer, ok := eventTransformer.(transformer.ProvisionalEventTransformer)
+	if !ok {
+		return nil, fmt.Errorf("event transformer %s does not handle provisional logs", name)
+	}
+	return provisionalTransformer, nil
+}
+
+func (server *Server) storageTransformer(name string) (transformer.StorageTransformer, error) {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	storageTransformer, ok := server.storage[name]
+	if !ok {
+		return nil, errNotInitialized("storage", name)
+	}
+	return storageTransformer, nil
+}
+
+func (server *Server) contractTransformer(name string) (transformer.ContractTransformer, error) {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	contractTransformer, ok := server.contracts[name]
+	if !ok {
+		return nil, errNotInitialized("contract", name)
+	}
+	return contractTransformer, nil
+}
+
+func errNotInitialized(transformerType, name string) error {
+	return fmt.Errorf("%s transformer %s is not initialized", transformerType, name)
+}
+
+// ioConn is a connection over a reader and writer, such as a process's stdin and stdout
+type ioConn struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (conn ioConn) Read(b []byte) (int, error) {
+	return conn.in.Read(b)
+}
+
+func (conn ioConn) Write(b []byte) (int, error) {
+	return conn.out.Write(b)
+}
+
+func (conn ioConn) Close() error {
+	return nil
+}
+
+func (conn ioConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
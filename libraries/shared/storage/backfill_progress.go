@@ -0,0 +1,105 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// IBackFillProgress tracks the bins of blocks whose storage diffs have been back-filled for each contract
+type IBackFillProgress interface {
+	GetIncompleteRanges(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) ([][2]uint64, error)
+	MarkBinComplete(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) error
+}
+
+type BackFillProgress struct {
+	db *postgres.DB
+}
+
+func NewBackFillProgress(db *postgres.DB) BackFillProgress {
+	return BackFillProgress{db: db}
+}
+
+// GetIncompleteRanges returns the ranges within the given blocks that have not been back-filled for at least one of the contracts
+func (progress BackFillProgress) GetIncompleteRanges(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) ([][2]uint64, error) {
+	if len(hashedAddresses) == 0 {
+		return [][2]uint64{{startingBlock, endingBlock}}, nil
+	}
+	var incomplete [][2]uint64
+	for _, hashedAddress := range hashedAddresses {
+		var completed []struct {
+			StartingBlock uint64 `db:"starting_block"`
+			EndingBlock   uint64 `db:"ending_block"`
+		}
+		err := progress.db.Select(&completed, `SELECT starting_block, ending_block FROM public.storage_backfill_progress
+			WHERE hashed_address = $1 AND ending_block >= $2 AND starting_block <= $3
+			ORDER BY starting_block`, hashedAddress.Bytes(), startingBlock, endingBlock)
+		if err != nil {
+			return nil, err
+		}
+		next := startingBlock
+		for _, bin := range completed {
+			if bin.StartingBlock > next {
+				incomplete = append(incomplete, [2]uint64{next, bin.StartingBlock - 1})
+			}
+			if bin.EndingBlock >= next {
+				next = bin.EndingBlock + 1
+			}
+		}
+		if next <= endingBlock {
+			incomplete = append(incomplete, [2]uint64{next, endingBlock})
+		}
+	}
+	return mergeRanges(incomplete), nil
+}
+
+// MarkBinComplete records that the bin's storage diffs have been back-filled for each of the contracts
+func (progress BackFillProgress) MarkBinComplete(hashedAddresses []common.Hash, startingBlock, endingBlock uint64) error {
+	tx, txErr := progress.db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	for _, hashedAddress := range hashedAddresses {
+		_, insertErr := tx.Exec(`INSERT INTO public.storage_backfill_progress (hashed_address, starting_block, ending_block)
+			VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, hashedAddress.Bytes(), startingBlock, endingBlock)
+		if insertErr != nil {
+			return rollback(tx, insertErr)
+		}
+	}
+	return tx.Commit()
+}
+
+// mergeRanges sorts block ranges and joins those that overlap or are adjacent
+func mergeRanges(ranges [][2]uint64) [][2]uint64 {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var merged [][2]uint64
+	for _, blockRange := range ranges {
+		last := len(merged) - 1
+		if last >= 0 && blockRange[0] <= merged[last][1]+1 {
+			if blockRange[1] > merged[last][1] {
+				merged[last][1] = blockRange[1]
+			}
+			continue
+		}
+		merged = append(merged, blockRange)
+	}
+	return merged
+}
@@ -0,0 +1,88 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/test_config"
+)
+
+var _ = Describe("Back-fill progress", func() {
+	var (
+		db            *postgres.DB
+		progress      storage.BackFillProgress
+		addressOne    = common.HexToHash("0x01")
+		addressTwo    = common.HexToHash("0x02")
+		bothContracts = []common.Hash{addressOne, addressTwo}
+	)
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		progress = storage.NewBackFillProgress(db)
+	})
+
+	It("returns the whole range when nothing has been back-filled", func() {
+		ranges, err := progress.GetIncompleteRanges(bothContracts, 0, 99)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ranges).To(Equal([][2]uint64{{0, 99}}))
+	})
+
+	It("excludes bins completed for every contract", func() {
+		Expect(progress.MarkBinComplete(bothContracts, 0, 9)).To(Succeed())
+		Expect(progress.MarkBinComplete(bothContracts, 20, 29)).To(Succeed())
+
+		ranges, err := progress.GetIncompleteRanges(bothContracts, 0, 99)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ranges).To(Equal([][2]uint64{{10, 19}, {30, 99}}))
+	})
+
+	It("includes bins completed for only some of the contracts", func() {
+		Expect(progress.MarkBinComplete([]common.Hash{addressOne}, 0, 49)).To(Succeed())
+		Expect(progress.MarkBinComplete([]common.Hash{addressTwo}, 0, 9)).To(Succeed())
+
+		ranges, err := progress.GetIncompleteRanges(bothContracts, 0, 99)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ranges).To(Equal([][2]uint64{{10, 99}}))
+	})
+
+	It("returns nothing when the range has been back-filled", func() {
+		Expect(progress.MarkBinComplete(bothContracts, 0, 49)).To(Succeed())
+		Expect(progress.MarkBinComplete(bothContracts, 50, 99)).To(Succeed())
+
+		ranges, err := progress.GetIncompleteRanges(bothContracts, 10, 89)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ranges).To(BeEmpty())
+	})
+
+	It("ignores bins that were already recorded", func() {
+		Expect(progress.MarkBinComplete(bothContracts, 0, 9)).To(Succeed())
+		Expect(progress.MarkBinComplete(bothContracts, 0, 9)).To(Succeed())
+
+		var count int
+		Expect(db.Get(&count, `SELECT COUNT(*) FROM public.storage_backfill_progress`)).To(Succeed())
+		Expect(count).To(Equal(2))
+	})
+})
@@ -35,8 +35,9 @@ const (
 )
 
 // BackFiller is the backfilling interface
+// If binDone is not nil, each bin's block range is sent on it once all of the bin's diffs have been sent without error
 type BackFiller interface {
-	BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, done chan bool) error
+	BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, binDone chan [2]uint64, done chan bool) error
 }
 
 // backFiller is the backfilling struct
@@ -59,7 +60,7 @@ func NewStorageBackFiller(fetcher fetcher.StateDiffFetcher, batchSize uint64) Ba
 
 // BackFill fetches, processes, and returns utils.StorageDiffs over a range of blocks
 // It splits a large range up into smaller chunks, batch fetching and processing those chunks concurrently
-func (bf *backFiller) BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, done chan bool) error {
+func (bf *backFiller) BackFill(startingBlock, endingBlock uint64, backFill chan utils.StorageDiffInput, errChan chan error, binDone chan [2]uint64, done chan bool) error {
 	logrus.Infof("going to fill in gap from %d to %d", startingBlock, endingBlock)
 
 	// break the range up into bins of smaller ranges
@@ -82,7 +83,7 @@ func (bf *backFiller) BackFill(startingBlock, endingBlock uint64, backFill chan
 				// this blocks until a process signals it has finished
 				<-forwardDone
 			}
-			go bf.backFillRange(blockHeights, backFill, errChan, processingDone)
+			go bf.backFillRange(blockHeights, backFill, errChan, binDone, processingDone)
 		}
 	}()
 
@@ -113,15 +114,19 @@ func (bf *backFiller) BackFill(startingBlock, endingBlock uint64, backFill chan
 	return nil
 }
 
-func (bf *backFiller) backFillRange(blockHeights []uint64, diffChan chan utils.StorageDiffInput, errChan chan error, doneChan chan [2]uint64) {
+func (bf *backFiller) backFillRange(blockHeights []uint64, diffChan chan utils.StorageDiffInput, errChan chan error, binDone, doneChan chan [2]uint64) {
+	bin := [2]uint64{blockHeights[0], blockHeights[len(blockHeights)-1]}
+	complete := true
 	payloads, fetchErr := bf.fetcher.FetchStateDiffsAt(blockHeights)
 	if fetchErr != nil {
+		complete = false
 		errChan <- fetchErr
 	}
 	for _, payload := range payloads {
 		stateDiff := new(statediff.StateDiff)
 		stateDiffDecodeErr := rlp.DecodeBytes(payload.StateDiffRlp, stateDiff)
 		if stateDiffDecodeErr != nil {
+			complete = false
 			errChan <- stateDiffDecodeErr
 			continue
 		}
@@ -132,6 +137,7 @@ func (bf *backFiller) backFillRange(blockHeights []uint64, diffChan chan utils.S
 				diff, formatErr := utils.FromGethStateDiff(account, stateDiff, storage)
 				if formatErr != nil {
 					logrus.Error("failed to format utils.StorageDiff from storage with key: ", common.BytesToHash(storage.Key), "from account with key: ", common.BytesToHash(account.Key))
+					complete = false
 					errChan <- formatErr
 					continue
 				}
@@ -144,6 +150,9 @@ func (bf *backFiller) backFillRange(blockHeights []uint64, diffChan chan utils.S
 			}
 		}
 	}
+	if complete && binDone != nil {
+		binDone <- bin
+	}
 	// when this is done, send out a signal
-	doneChan <- [2]uint64{blockHeights[0], blockHeights[len(blockHeights)-1]}
+	doneChan <- bin
 }
@@ -53,6 +53,7 @@ var _ = Describe("BackFiller", func() {
 				test_data.BlockNumber2.Uint64(),
 				backFill,
 				errChan,
+				nil,
 				done)
 			Expect(backFillInitErr).ToNot(HaveOccurred())
 			var diffs []utils.StorageDiffInput
@@ -87,6 +88,7 @@ var _ = Describe("BackFiller", func() {
 				test_data.BlockNumber2.Uint64(),
 				backFill,
 				errChan,
+				nil,
 				done)
 			Expect(backFillInitErr).ToNot(HaveOccurred())
 			var diffs []utils.StorageDiffInput
@@ -127,6 +129,7 @@ var _ = Describe("BackFiller", func() {
 				test_data.BlockNumber.Uint64()+1000,
 				backFill,
 				errChan,
+				nil,
 				done)
 			Expect(backFillInitErr).ToNot(HaveOccurred())
 			var diffs []utils.StorageDiffInput
@@ -163,6 +166,7 @@ var _ = Describe("BackFiller", func() {
 				test_data.BlockNumber2.Uint64(),
 				backFill,
 				errChan,
+				nil,
 				done)
 			Expect(backFillInitErr).ToNot(HaveOccurred())
 			var numOfErrs int
@@ -201,6 +205,7 @@ var _ = Describe("BackFiller", func() {
 				test_data.BlockNumber2.Uint64(),
 				backFill,
 				errChan,
+				nil,
 				done)
 			Expect(backFillInitErr).ToNot(HaveOccurred())
 			numOfErrs = 0
@@ -224,6 +229,41 @@ var _ = Describe("BackFiller", func() {
 			Expect(numOfErrs).To(Equal(2))
 			Expect(len(diffs)).To(Equal(0))
 		})
+
+		It("signals each bin completed without errors", func() {
+			mockFetcher.FetchErrs = map[uint64]error{
+				test_data.BlockNumber.Uint64(): errors.New("mock fetcher error"),
+			}
+			backFiller = storage.NewStorageBackFiller(mockFetcher, 1)
+			backFill := make(chan utils.StorageDiffInput)
+			binDone := make(chan [2]uint64)
+			done := make(chan bool)
+			errChan := make(chan error)
+			backFillInitErr := backFiller.BackFill(
+				test_data.BlockNumber.Uint64(),
+				test_data.BlockNumber2.Uint64(),
+				backFill,
+				errChan,
+				binDone,
+				done)
+			Expect(backFillInitErr).ToNot(HaveOccurred())
+			var completedBins [][2]uint64
+			for {
+				select {
+				case <-backFill:
+					continue
+				case <-errChan:
+					continue
+				case bin := <-binDone:
+					completedBins = append(completedBins, bin)
+					continue
+				case <-done:
+					break
+				}
+				break
+			}
+			Expect(completedBins).To(Equal([][2]uint64{{test_data.BlockNumber2.Uint64(), test_data.BlockNumber2.Uint64()}}))
+		})
 	})
 })
 
@@ -0,0 +1,70 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// APIName is the namespace used for the storage query API
+const APIName = "storage"
+
+// APIVersion is the version of the storage query API
+const APIVersion = "0.0.1"
+
+// PublicStorageAPI is the public api for querying historical storage values
+type PublicStorageAPI struct {
+	querier *Querier
+}
+
+// NewPublicStorageAPI creates a new PublicStorageAPI with the provided Querier
+func NewPublicStorageAPI(querier *Querier) *PublicStorageAPI {
+	return &PublicStorageAPI{querier: querier}
+}
+
+// ValueAt is the public method to get the value of a contract's storage key as of a block
+func (api *PublicStorageAPI) ValueAt(contract common.Address, key common.Hash, blockNumber int64) (StorageValue, error) {
+	return api.querier.ValueAt(contract, key, blockNumber)
+}
+
+// History is the public method to get every change to a contract's storage key
+func (api *PublicStorageAPI) History(contract common.Address, key common.Hash) ([]StorageValue, error) {
+	return api.querier.History(contract, key)
+}
+
+// NamedValueAt is the public method to get the value of a contract's named storage value, e.g. balances[0xabc...], as of a block
+func (api *PublicStorageAPI) NamedValueAt(contract common.Address, name string, blockNumber int64) (StorageValue, error) {
+	return api.querier.NamedValueAt(contract, name, blockNumber)
+}
+
+// NamedHistory is the public method to get every change to a contract's named storage value
+func (api *PublicStorageAPI) NamedHistory(contract common.Address, name string) ([]StorageValue, error) {
+	return api.querier.NamedHistory(contract, name)
+}
+
+// APIs returns the RPC descriptors of the storage query API
+func APIs(querier *Querier) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: APIName,
+			Version:   APIVersion,
+			Service:   NewPublicStorageAPI(querier),
+			Public:    true,
+		},
+	}
+}
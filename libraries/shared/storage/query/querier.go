@@ -0,0 +1,214 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+var (
+	ErrNoValue     = errors.New("no storage diff for key at or before block")
+	ErrNoLookup    = errors.New("contract has no storage keys lookup to resolve names with")
+	ErrUnknownName = errors.New("no storage key with that name in the contract's storage keys lookup")
+)
+
+// StorageValue is a storage key's value as of a diff, named and decoded if the contract's keys lookup knows the key
+type StorageValue struct {
+	BlockHeight int         `json:"blockHeight"`
+	BlockHash   common.Hash `json:"blockHash"`
+	StorageKey  common.Hash `json:"storageKey"`
+	RawValue    common.Hash `json:"rawValue"`
+	Name        string      `json:"name,omitempty"`
+	Value       interface{} `json:"value,omitempty"`
+}
+
+// Querier answers point-in-time values and change histories of contracts' storage keys
+type Querier struct {
+	Repository Repository
+	lookups    map[common.Hash]storage.KeysLookup // keccak hash of a contract address => the contract's keys lookup
+}
+
+// NewQuerier creates a Querier that decodes the values of the contracts with a keys lookup; other contracts' values are raw
+func NewQuerier(db *postgres.DB, lookups map[common.Hash]storage.KeysLookup) *Querier {
+	return &Querier{Repository: NewRepository(db), lookups: lookups}
+}
+
+// ValueAt returns the value of the contract's storage key as of the block
+func (querier *Querier) ValueAt(contract common.Address, key common.Hash, blockHeight int64) (StorageValue, error) {
+	hashedAddress := crypto.Keccak256Hash(contract.Bytes())
+	diff, found, err := querier.Repository.GetDiffAt(hashedAddress, storageKeys(key), blockHeight)
+	if err != nil {
+		return StorageValue{}, err
+	}
+	if !found {
+		return StorageValue{}, ErrNoValue
+	}
+	return querier.toStorageValue(diff), nil
+}
+
+// History returns every change to the contract's storage key, in block order
+func (querier *Querier) History(contract common.Address, key common.Hash) ([]StorageValue, error) {
+	hashedAddress := crypto.Keccak256Hash(contract.Bytes())
+	diffs, err := querier.Repository.GetDiffs(hashedAddress, storageKeys(key))
+	if err != nil {
+		return nil, err
+	}
+	values := make([]StorageValue, 0, len(diffs))
+	for _, diff := range diffs {
+		values = append(values, querier.toStorageValue(diff))
+	}
+	return values, nil
+}
+
+// NamedValueAt returns the value the contract's keys lookup names, e.g. balances[0xabc...], as of the block
+func (querier *Querier) NamedValueAt(contract common.Address, name string, blockHeight int64) (StorageValue, error) {
+	hashedAddress := crypto.Keccak256Hash(contract.Bytes())
+	keys, err := querier.namedKeys(hashedAddress, name)
+	if err != nil {
+		return StorageValue{}, err
+	}
+	diff, found, err := querier.Repository.GetDiffAt(hashedAddress, keys, blockHeight)
+	if err != nil {
+		return StorageValue{}, err
+	}
+	if !found {
+		return StorageValue{}, ErrNoValue
+	}
+	return querier.toStorageValue(diff), nil
+}
+
+// NamedHistory returns every change to the value the contract's keys lookup names, in block order
+func (querier *Querier) NamedHistory(contract common.Address, name string) ([]StorageValue, error) {
+	hashedAddress := crypto.Keccak256Hash(contract.Bytes())
+	keys, err := querier.namedKeys(hashedAddress, name)
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := querier.Repository.GetDiffs(hashedAddress, keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]StorageValue, 0, len(diffs))
+	for _, diff := range diffs {
+		values = append(values, querier.toStorageValue(diff))
+	}
+	return values, nil
+}
+
+// namedKeys returns the keys the contract's keys lookup gives the name, which it holds in both plain and hashed form
+// Names are matched as formatted in StorageValue.Name, ignoring case so addresses needn't be checksummed
+func (querier *Querier) namedKeys(hashedAddress common.Hash, name string) ([]common.Hash, error) {
+	lookup, ok := querier.lookups[hashedAddress]
+	if !ok {
+		return nil, ErrNoLookup
+	}
+	mappings, err := lookup.Mappings()
+	if err != nil {
+		return nil, err
+	}
+	var keys []common.Hash
+	for key, metadata := range mappings {
+		if strings.EqualFold(valueName(metadata), name) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrUnknownName
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Hex() < keys[j].Hex() })
+	return keys, nil
+}
+
+func (querier *Querier) toStorageValue(diff utils.PersistedStorageDiff) StorageValue {
+	value := StorageValue{
+		BlockHeight: diff.BlockHeight,
+		BlockHash:   diff.BlockHash,
+		StorageKey:  diff.StorageKey,
+		RawValue:    diff.StorageValue,
+	}
+	lookup, ok := querier.lookups[diff.HashedAddress]
+	if !ok {
+		return value
+	}
+	metadata, lookupErr := lookup.Lookup(diff.StorageKey)
+	if lookupErr != nil {
+		return value
+	}
+	value.Name = valueName(metadata)
+	decoded, decodeErr := utils.Decode(diff, metadata)
+	if decodeErr != nil {
+		// long bytes and strings span several slots and can't be decoded from one diff
+		return value
+	}
+	value.Value = decoded
+	if metadata.Type == utils.PackedSlot {
+		value.Value = packedValues(decoded, metadata.PackedNames)
+	}
+	return value
+}
+
+// Both forms of the key, since diffs may be keyed by the slot or its keccak hash
+func storageKeys(key common.Hash) []common.Hash {
+	return []common.Hash{key, crypto.Keccak256Hash(key.Bytes())}
+}
+
+// valueName formats a value's name with its keys, e.g. balances[0xabc...], or allowance[owner=0xabc...][spender=0xdef...]
+func valueName(metadata utils.StorageValueMetadata) string {
+	if len(metadata.Keys) == 0 {
+		return metadata.Name
+	}
+	var name strings.Builder
+	name.WriteString(metadata.Name)
+	if len(metadata.Keys) == 1 {
+		for _, value := range metadata.Keys {
+			name.WriteString(fmt.Sprintf("[%s]", value))
+		}
+		return name.String()
+	}
+	keys := make([]string, 0, len(metadata.Keys))
+	for key := range metadata.Keys {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name.WriteString(fmt.Sprintf("[%s=%s]", key, metadata.Keys[utils.Key(key)]))
+	}
+	return name.String()
+}
+
+// packedValues names the items of a packed slot
+func packedValues(decoded interface{}, packedNames map[int]string) interface{} {
+	items, ok := decoded.(map[int]string)
+	if !ok {
+		return decoded
+	}
+	named := make(map[string]string, len(items))
+	for position, item := range items {
+		named[packedNames[position]] = item
+	}
+	return named
+}
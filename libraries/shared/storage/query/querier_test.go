@@ -0,0 +1,229 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package query_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/query"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Storage querier", func() {
+	var (
+		contract      = common.HexToAddress("0x0123456789abcdef0123456789abcdef01234567")
+		hashedAddress = crypto.Keccak256Hash(contract.Bytes())
+		key           = common.HexToHash("0x02")
+		repository    *mocks.MockStorageQueryRepository
+		keysLookup    *mocks.MockStorageKeysLookup
+		querier       *query.Querier
+		diff          utils.PersistedStorageDiff
+	)
+
+	BeforeEach(func() {
+		diff = utils.PersistedStorageDiff{
+			ID: 1,
+			StorageDiffInput: utils.StorageDiffInput{
+				HashedAddress: hashedAddress,
+				BlockHash:     common.HexToHash("0xabc"),
+				BlockHeight:   100,
+				StorageKey:    key,
+				StorageValue:  common.HexToHash("0x7b"),
+			},
+		}
+		repository = &mocks.MockStorageQueryRepository{DiffAt: diff, DiffAtFound: true}
+		keysLookup = &mocks.MockStorageKeysLookup{
+			Metadata: utils.GetStorageValueMetadata("balances", map[utils.Key]string{"owner": "0xabc"}, utils.Uint256),
+		}
+		querier = query.NewQuerier(nil, map[common.Hash]storage.KeysLookup{hashedAddress: keysLookup})
+		querier.Repository = repository
+	})
+
+	Describe("ValueAt", func() {
+		It("queries the hashed contract address with both forms of the key", func() {
+			_, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repository.PassedHashedAddress).To(Equal(hashedAddress))
+			Expect(repository.PassedStorageKeys).To(Equal([]common.Hash{key, crypto.Keccak256Hash(key.Bytes())}))
+			Expect(repository.PassedBlockHeight).To(Equal(int64(150)))
+		})
+
+		It("returns the named and decoded value", func() {
+			value, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(query.StorageValue{
+				BlockHeight: 100,
+				BlockHash:   diff.BlockHash,
+				StorageKey:  key,
+				RawValue:    diff.StorageValue,
+				Name:        "balances[0xabc]",
+				Value:       "123",
+			}))
+		})
+
+		It("names values with several keys by key name", func() {
+			keysLookup.Metadata = utils.GetStorageValueMetadata("allowance",
+				map[utils.Key]string{"spender": "0xdef", "owner": "0xabc"}, utils.Uint256)
+
+			value, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.Name).To(Equal("allowance[owner=0xabc][spender=0xdef]"))
+		})
+
+		It("names the items of a packed slot", func() {
+			keysLookup.Metadata = utils.GetStorageValueMetadataForPackedSlot("packed", nil, utils.PackedSlot,
+				map[int]string{0: "first", 1: "second"}, map[int]utils.ValueType{0: utils.Uint48, 1: utils.Uint48})
+			repository.DiffAt.StorageValue = common.HexToHash("0x000000000002000000000001")
+
+			value, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.Value).To(Equal(map[string]string{"first": "1", "second": "2"}))
+		})
+
+		It("returns the raw value if the key is unknown", func() {
+			keysLookup.LookupErr = utils.ErrStorageKeyNotFound{Key: key.Hex()}
+
+			value, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.RawValue).To(Equal(diff.StorageValue))
+			Expect(value.Name).To(BeEmpty())
+			Expect(value.Value).To(BeNil())
+		})
+
+		It("returns the raw value if the contract has no keys lookup", func() {
+			querier = query.NewQuerier(nil, nil)
+			querier.Repository = repository
+
+			value, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.Name).To(BeEmpty())
+			Expect(keysLookup.LookupCalled).To(BeFalse())
+		})
+
+		It("returns an error if there is no diff at or before the block", func() {
+			repository.DiffAtFound = false
+
+			_, err := querier.ValueAt(contract, key, 50)
+
+			Expect(err).To(MatchError(query.ErrNoValue))
+		})
+
+		It("returns an error if the query fails", func() {
+			repository.Err = fakes.FakeError
+
+			_, err := querier.ValueAt(contract, key, 150)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
+	Describe("History", func() {
+		It("returns each change to the key", func() {
+			secondDiff := diff
+			secondDiff.BlockHeight = 101
+			secondDiff.StorageValue = common.HexToHash("0x0")
+			repository.Diffs = []utils.PersistedStorageDiff{diff, secondDiff}
+
+			values, err := querier.History(contract, key)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(HaveLen(2))
+			Expect(values[0].Value).To(Equal("123"))
+			Expect(values[1].BlockHeight).To(Equal(101))
+			Expect(values[1].Value).To(Equal("0"))
+			Expect(values[1].Name).To(Equal("balances[0xabc]"))
+		})
+
+		It("returns an error if the query fails", func() {
+			repository.Err = fakes.FakeError
+
+			_, err := querier.History(contract, key)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
+	Describe("named queries", func() {
+		var (
+			otherKey = common.HexToHash("0x03")
+			metadata = utils.GetStorageValueMetadata("balances", map[utils.Key]string{"owner": "0xAbC"}, utils.Uint256)
+		)
+
+		BeforeEach(func() {
+			keysLookup.StorageKeys = map[common.Hash]utils.StorageValueMetadata{
+				key:                               metadata,
+				crypto.Keccak256Hash(key.Bytes()): metadata,
+				otherKey:                          utils.GetStorageValueMetadata("supply", nil, utils.Uint256),
+			}
+		})
+
+		It("queries every key the lookup gives the name, ignoring case", func() {
+			_, err := querier.NamedValueAt(contract, "balances[0xabc]", 150)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keysLookup.MappingsCalled).To(BeTrue())
+			Expect(repository.PassedHashedAddress).To(Equal(hashedAddress))
+			Expect(repository.PassedStorageKeys).To(ConsistOf(key, crypto.Keccak256Hash(key.Bytes())))
+			Expect(repository.PassedBlockHeight).To(Equal(int64(150)))
+		})
+
+		It("returns each change to the named value", func() {
+			repository.Diffs = []utils.PersistedStorageDiff{diff}
+
+			values, err := querier.NamedHistory(contract, "supply")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repository.PassedStorageKeys).To(Equal([]common.Hash{otherKey}))
+			Expect(values).To(HaveLen(1))
+		})
+
+		It("returns an error if the lookup has no value with the name", func() {
+			_, err := querier.NamedValueAt(contract, "allowance[0xabc]", 150)
+
+			Expect(err).To(MatchError(query.ErrUnknownName))
+		})
+
+		It("returns an error if the contract has no keys lookup", func() {
+			querier = query.NewQuerier(nil, nil)
+			querier.Repository = repository
+
+			_, err := querier.NamedHistory(contract, "supply")
+
+			Expect(err).To(MatchError(query.ErrNoLookup))
+		})
+
+		It("returns an error if loading the lookup's keys fails", func() {
+			keysLookup.MappingsErr = fakes.FakeError
+
+			_, err := querier.NamedValueAt(contract, "supply", 150)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+})
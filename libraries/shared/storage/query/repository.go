@@ -0,0 +1,75 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"database/sql"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/lib/pq"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// Repository reads the canonical diffs of a contract's storage key from storage_diff.
+// Diffs from geth are keyed by the keccak hash of the slot and diffs from parity by the slot itself,
+// so callers pass both forms of a key
+type Repository interface {
+	GetDiffAt(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int64) (utils.PersistedStorageDiff, bool, error)
+	GetDiffs(hashedAddress common.Hash, storageKeys []common.Hash) ([]utils.PersistedStorageDiff, error)
+}
+
+type storageDiffRepository struct {
+	db *postgres.DB
+}
+
+func NewRepository(db *postgres.DB) Repository {
+	return storageDiffRepository{db: db}
+}
+
+// GetDiffAt returns the latest diff to the key at or before the block height, and whether there was one
+func (repository storageDiffRepository) GetDiffAt(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int64) (utils.PersistedStorageDiff, bool, error) {
+	var diff utils.PersistedStorageDiff
+	err := repository.db.Get(&diff, `SELECT id, hashed_address, block_height, block_hash, storage_key, storage_value
+		FROM public.storage_diff
+		WHERE hashed_address = $1 AND storage_key = ANY($2) AND block_height <= $3 AND NOT non_canonical
+		ORDER BY block_height DESC, id DESC
+		LIMIT 1`, hashedAddress.Bytes(), keysArray(storageKeys), blockHeight)
+	if err == sql.ErrNoRows {
+		return diff, false, nil
+	}
+	return diff, err == nil, err
+}
+
+// GetDiffs returns every diff to the key, in block order
+func (repository storageDiffRepository) GetDiffs(hashedAddress common.Hash, storageKeys []common.Hash) ([]utils.PersistedStorageDiff, error) {
+	var diffs []utils.PersistedStorageDiff
+	err := repository.db.Select(&diffs, `SELECT id, hashed_address, block_height, block_hash, storage_key, storage_value
+		FROM public.storage_diff
+		WHERE hashed_address = $1 AND storage_key = ANY($2) AND NOT non_canonical
+		ORDER BY block_height, id`, hashedAddress.Bytes(), keysArray(storageKeys))
+	return diffs, err
+}
+
+func keysArray(storageKeys []common.Hash) pq.ByteaArray {
+	keys := make(pq.ByteaArray, 0, len(storageKeys))
+	for _, key := range storageKeys {
+		keys = append(keys, key.Bytes())
+	}
+	return keys
+}
@@ -0,0 +1,109 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package query_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/query"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/vulcanize/vulcanizedb/test_config"
+)
+
+var _ = Describe("Storage query repository", func() {
+	var (
+		db             *postgres.DB
+		diffRepository repositories.StorageDiffRepository
+		repository     query.Repository
+		hashedAddress  = utils.HexToKeccak256Hash("0x123456")
+		slot           = common.HexToHash("0x01")
+		hashedSlot     = crypto.Keccak256Hash(slot.Bytes())
+		keys           = []common.Hash{slot, hashedSlot}
+	)
+
+	createDiff := func(blockHeight int, storageKey common.Hash, value string) utils.PersistedStorageDiff {
+		input := utils.StorageDiffInput{
+			HashedAddress: hashedAddress,
+			BlockHash:     common.BigToHash(common.Big1),
+			BlockHeight:   blockHeight,
+			StorageKey:    storageKey,
+			StorageValue:  common.HexToHash(value),
+		}
+		id, err := diffRepository.CreateStorageDiff(input)
+		Expect(err).NotTo(HaveOccurred())
+		return utils.ToPersistedDiff(input, id)
+	}
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		diffRepository = repositories.NewStorageDiffRepository(db)
+		repository = query.NewRepository(db)
+	})
+
+	Describe("GetDiffAt", func() {
+		It("returns the latest diff at or before the block", func() {
+			createDiff(100, slot, "0x01")
+			expected := createDiff(110, hashedSlot, "0x02")
+			createDiff(120, slot, "0x03")
+
+			diff, found, err := repository.GetDiffAt(hashedAddress, keys, 115)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(diff).To(Equal(expected))
+		})
+
+		It("ignores non-canonical diffs", func() {
+			expected := createDiff(100, slot, "0x01")
+			orphaned := createDiff(110, slot, "0x02")
+			Expect(diffRepository.MarkNonCanonical(orphaned.ID)).To(Succeed())
+
+			diff, _, err := repository.GetDiffAt(hashedAddress, keys, 115)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal(expected))
+		})
+
+		It("reports when there is no diff at or before the block", func() {
+			createDiff(100, slot, "0x01")
+
+			_, found, err := repository.GetDiffAt(hashedAddress, keys, 99)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("GetDiffs", func() {
+		It("returns every diff to the key in block order", func() {
+			second := createDiff(110, hashedSlot, "0x02")
+			first := createDiff(100, slot, "0x01")
+			createDiff(105, common.HexToHash("0x02"), "0x09")
+
+			diffs, err := repository.GetDiffs(hashedAddress, keys)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal([]utils.PersistedStorageDiff{first, second}))
+		})
+	})
+})
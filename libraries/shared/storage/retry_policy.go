@@ -0,0 +1,53 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import "time"
+
+// DefaultRetryPolicy retries a queued diff for roughly four days before poisoning it
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  100,
+	InitialDelay: time.Minute,
+	MaxDelay:     time.Hour,
+}
+
+// RetryPolicy determines when a queued storage diff is next attempted, and when it is given up on
+type RetryPolicy struct {
+	// MaxAttempts is the number of failed attempts after which a diff is poisoned; zero retries indefinitely
+	MaxAttempts int
+	// InitialDelay is the wait after the first failed attempt, doubling with each subsequent failure
+	InitialDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// Delay is how long to wait before attempting a diff again after the given number of failed attempts
+func (policy RetryPolicy) Delay(attempts int) time.Duration {
+	delay := policy.InitialDelay
+	for i := 1; i < attempts && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return delay
+}
+
+// Exhausted is whether a diff with the given number of failed attempts should be poisoned
+func (policy RetryPolicy) Exhausted(attempts int) bool {
+	return policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+}
@@ -0,0 +1,63 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
+)
+
+var _ = Describe("Retry policy", func() {
+	policy := storage.RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+	}
+
+	Describe("Delay", func() {
+		It("waits the initial delay after the first failure", func() {
+			Expect(policy.Delay(1)).To(Equal(time.Second))
+		})
+
+		It("doubles the delay with each failure", func() {
+			Expect(policy.Delay(2)).To(Equal(2 * time.Second))
+			Expect(policy.Delay(4)).To(Equal(8 * time.Second))
+		})
+
+		It("does not exceed the max delay", func() {
+			Expect(policy.Delay(5)).To(Equal(10 * time.Second))
+			Expect(policy.Delay(1000)).To(Equal(10 * time.Second))
+		})
+	})
+
+	Describe("Exhausted", func() {
+		It("is exhausted once max attempts have failed", func() {
+			Expect(policy.Exhausted(2)).To(BeFalse())
+			Expect(policy.Exhausted(3)).To(BeTrue())
+		})
+
+		It("is never exhausted without max attempts", func() {
+			unlimited := storage.RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Second}
+
+			Expect(unlimited.Exhausted(1000)).To(BeFalse())
+		})
+	})
+})
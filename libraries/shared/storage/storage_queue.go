@@ -17,39 +17,176 @@
 package storage
 
 import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 )
 
+// DefaultQueuePageSize is the number of queued diffs loaded at a time
+const DefaultQueuePageSize = 1000
+
 type IStorageQueue interface {
-	Add(diff utils.PersistedStorageDiff) error
+	Add(diff utils.PersistedStorageDiff, transformerName string, failure error) error
 	Delete(id int64) error
-	GetAll() ([]utils.PersistedStorageDiff, error)
+	DeleteForTransformer(diffID int64, transformerName string) error
+	GetDue(afterBlockHeight int, afterDiffID int64, afterTransformer string, limit int) ([]QueuedStorageDiff, error)
+	RecordFailure(diffID int64, transformerName string, failure error) (bool, error)
+	GetPoisoned(limit, offset int) ([]PoisonedStorageDiff, error)
+	Replay(diffID int64) error
+}
+
+// QueuedStorageDiff is a diff queued after failing to transform, with the name of the transformer that failed it.
+// An empty name retries every transformer watching the diff's address
+type QueuedStorageDiff struct {
+	utils.PersistedStorageDiff
+	Transformer string
+}
+
+// PoisonedStorageDiff is a diff that was given up on after exhausting its retry policy
+type PoisonedStorageDiff struct {
+	QueuedStorageDiff
+	Attempts   int
+	LastError  string    `db:"last_error"`
+	PoisonedAt time.Time `db:"poisoned_at"`
 }
 
 type StorageQueue struct {
-	db *postgres.DB
+	db     *postgres.DB
+	Policy RetryPolicy
 }
 
 func NewStorageQueue(db *postgres.DB) StorageQueue {
-	return StorageQueue{db: db}
+	return StorageQueue{db: db, Policy: DefaultRetryPolicy}
 }
 
-func (queue StorageQueue) Add(diff utils.PersistedStorageDiff) error {
-	_, err := queue.db.Exec(`INSERT INTO public.queued_storage (diff_id) VALUES
-		($1) ON CONFLICT DO NOTHING`, diff.ID)
+// Add queues a diff after its first failed attempt by the named transformer
+func (queue StorageQueue) Add(diff utils.PersistedStorageDiff, transformerName string, failure error) error {
+	_, err := queue.db.Exec(`INSERT INTO public.queued_storage (diff_id, transformer, attempts, last_error, next_attempt_at) VALUES
+		($1, $2, 1, $3, NOW() + $4::FLOAT * INTERVAL '1 millisecond') ON CONFLICT DO NOTHING`,
+		diff.ID, transformerName, errorMessage(failure), queue.delayMilliseconds(1))
 	return err
 }
 
+// Delete dequeues a diff for every transformer that failed it
 func (queue StorageQueue) Delete(diffID int64) error {
 	_, err := queue.db.Exec(`DELETE FROM public.queued_storage WHERE diff_id = $1`, diffID)
 	return err
 }
 
-func (queue StorageQueue) GetAll() ([]utils.PersistedStorageDiff, error) {
-	var result []utils.PersistedStorageDiff
-	err := queue.db.Select(&result, `SELECT storage_diff.id, hashed_address, block_height, block_hash, storage_key, storage_value
+// DeleteForTransformer dequeues a diff for the named transformer only
+func (queue StorageQueue) DeleteForTransformer(diffID int64, transformerName string) error {
+	_, err := queue.db.Exec(`DELETE FROM public.queued_storage WHERE diff_id = $1 AND transformer = $2`, diffID, transformerName)
+	return err
+}
+
+// GetDue returns a page of diffs whose next attempt is due, in block order,
+// following the given block height, diff ID and transformer name
+func (queue StorageQueue) GetDue(afterBlockHeight int, afterDiffID int64, afterTransformer string, limit int) ([]QueuedStorageDiff, error) {
+	var result []QueuedStorageDiff
+	err := queue.db.Select(&result, `SELECT storage_diff.id, hashed_address, block_height, block_hash, storage_key, storage_value,
+			queued_storage.transformer
 		FROM public.queued_storage
-			LEFT JOIN public.storage_diff ON queued_storage.diff_id = storage_diff.id`)
+			JOIN public.storage_diff ON queued_storage.diff_id = storage_diff.id
+		WHERE queued_storage.next_attempt_at <= NOW()
+			AND (storage_diff.block_height, storage_diff.id, queued_storage.transformer) > ($1, $2, $3)
+		ORDER BY storage_diff.block_height, storage_diff.id, queued_storage.transformer
+		LIMIT $4`, afterBlockHeight, afterDiffID, afterTransformer, limit)
+	return result, err
+}
+
+// RecordFailure counts a failed attempt at a diff queued for the named transformer and schedules its next attempt,
+// or moves it to the poisoned diffs if the retry policy is exhausted
+func (queue StorageQueue) RecordFailure(diffID int64, transformerName string, failure error) (bool, error) {
+	tx, txErr := queue.db.Beginx()
+	if txErr != nil {
+		return false, txErr
+	}
+	var attempts int
+	updateErr := tx.Get(&attempts, `UPDATE public.queued_storage SET attempts = attempts + 1, last_error = $3
+		WHERE diff_id = $1 AND transformer = $2 RETURNING attempts`, diffID, transformerName, errorMessage(failure))
+	if updateErr != nil {
+		return false, rollback(tx, updateErr)
+	}
+	if !queue.Policy.Exhausted(attempts) {
+		_, scheduleErr := tx.Exec(`UPDATE public.queued_storage SET next_attempt_at = NOW() + $3::FLOAT * INTERVAL '1 millisecond'
+			WHERE diff_id = $1 AND transformer = $2`, diffID, transformerName, queue.delayMilliseconds(attempts))
+		if scheduleErr != nil {
+			return false, rollback(tx, scheduleErr)
+		}
+		return false, tx.Commit()
+	}
+	_, poisonErr := tx.Exec(`INSERT INTO public.poisoned_storage (diff_id, transformer, attempts, last_error)
+		SELECT diff_id, transformer, attempts, last_error FROM public.queued_storage WHERE diff_id = $1 AND transformer = $2
+		ON CONFLICT (diff_id, transformer) DO UPDATE SET attempts = excluded.attempts, last_error = excluded.last_error, poisoned_at = NOW()`,
+		diffID, transformerName)
+	if poisonErr != nil {
+		return false, rollback(tx, poisonErr)
+	}
+	_, deleteErr := tx.Exec(`DELETE FROM public.queued_storage WHERE diff_id = $1 AND transformer = $2`, diffID, transformerName)
+	if deleteErr != nil {
+		return false, rollback(tx, deleteErr)
+	}
+	return true, tx.Commit()
+}
+
+// GetPoisoned returns a page of poisoned diffs, in block order
+func (queue StorageQueue) GetPoisoned(limit, offset int) ([]PoisonedStorageDiff, error) {
+	var result []PoisonedStorageDiff
+	err := queue.db.Select(&result, `SELECT storage_diff.id, hashed_address, block_height, block_hash, storage_key, storage_value,
+			transformer, attempts, COALESCE(last_error, '') AS last_error, poisoned_at
+		FROM public.poisoned_storage
+			JOIN public.storage_diff ON poisoned_storage.diff_id = storage_diff.id
+		ORDER BY storage_diff.block_height, storage_diff.id, poisoned_storage.transformer
+		LIMIT $1 OFFSET $2`, limit, offset)
 	return result, err
 }
+
+// Replay moves a poisoned diff back into the queue with its attempts reset, to be retried on the next recheck
+// by each transformer it was poisoned for
+func (queue StorageQueue) Replay(diffID int64) error {
+	tx, txErr := queue.db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	var transformerNames []string
+	deleteErr := tx.Select(&transformerNames, `DELETE FROM public.poisoned_storage WHERE diff_id = $1 RETURNING transformer`, diffID)
+	if deleteErr != nil {
+		return rollback(tx, deleteErr)
+	}
+	if len(transformerNames) == 0 {
+		return rollback(tx, sql.ErrNoRows)
+	}
+	for _, transformerName := range transformerNames {
+		_, insertErr := tx.Exec(`INSERT INTO public.queued_storage (diff_id, transformer) VALUES ($1, $2)
+			ON CONFLICT (diff_id, transformer) DO UPDATE SET attempts = 0, last_error = NULL, next_attempt_at = NOW()`,
+			diffID, transformerName)
+		if insertErr != nil {
+			return rollback(tx, insertErr)
+		}
+	}
+	return tx.Commit()
+}
+
+func (queue StorageQueue) delayMilliseconds(attempts int) int64 {
+	return int64(queue.Policy.Delay(attempts) / time.Millisecond)
+}
+
+func errorMessage(err error) sql.NullString {
+	if err == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: err.Error(), Valid: true}
+}
+
+func rollback(tx *sqlx.Tx, err error) error {
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		logrus.Errorf("error rolling back storage transaction: %s", rollbackErr.Error())
+	}
+	return err
+}
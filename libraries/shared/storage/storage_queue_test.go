@@ -17,6 +17,9 @@
 package storage_test
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -24,15 +27,18 @@ import (
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
 	"github.com/vulcanize/vulcanizedb/test_config"
 )
 
+const transformerName = "transformer"
+
 var _ = Describe("Storage queue", func() {
 	var (
 		db             *postgres.DB
 		diff           utils.PersistedStorageDiff
 		diffRepository repositories.StorageDiffRepository
-		queue          storage.IStorageQueue
+		queue          storage.StorageQueue
 	)
 
 	BeforeEach(func() {
@@ -51,7 +57,8 @@ var _ = Describe("Storage queue", func() {
 		Expect(insertDiffErr).NotTo(HaveOccurred())
 		diff = utils.ToPersistedDiff(rawDiff, diffID)
 		queue = storage.NewStorageQueue(db)
-		addErr := queue.Add(diff)
+		queue.Policy = storage.RetryPolicy{MaxAttempts: 3}
+		addErr := queue.Add(diff, transformerName, fakes.FakeError)
 		Expect(addErr).NotTo(HaveOccurred())
 	})
 
@@ -65,60 +72,217 @@ var _ = Describe("Storage queue", func() {
 			Expect(result).To(Equal(diff))
 		})
 
+		It("records the first failed attempt", func() {
+			var attempts int
+			var lastError string
+			getErr := db.QueryRow(`SELECT attempts, last_error FROM public.queued_storage`).Scan(&attempts, &lastError)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(1))
+			Expect(lastError).To(Equal(fakes.FakeError.Error()))
+		})
+
 		It("does not duplicate storage diffs", func() {
-			addErr := queue.Add(diff)
+			addErr := queue.Add(diff, transformerName, fakes.FakeError)
 			Expect(addErr).NotTo(HaveOccurred())
 			var count int
 			getErr := db.Get(&count, `SELECT count(*) FROM public.queued_storage`)
 			Expect(getErr).NotTo(HaveOccurred())
 			Expect(count).To(Equal(1))
 		})
+
+		It("queues a diff for each transformer that fails it", func() {
+			addErr := queue.Add(diff, "other transformer", fakes.FakeError)
+			Expect(addErr).NotTo(HaveOccurred())
+
+			diffs, getErr := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
+
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal([]storage.QueuedStorageDiff{
+				{PersistedStorageDiff: diff, Transformer: "other transformer"},
+				{PersistedStorageDiff: diff, Transformer: transformerName},
+			}))
+		})
 	})
 
 	It("deletes storage diff from db", func() {
-		diffs, getErr := queue.GetAll()
+		diffs, getErr := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
 		Expect(getErr).NotTo(HaveOccurred())
 		Expect(len(diffs)).To(Equal(1))
 
 		err := queue.Delete(diffs[0].ID)
 
 		Expect(err).NotTo(HaveOccurred())
-		remainingRows, secondGetErr := queue.GetAll()
+		remainingRows, secondGetErr := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
 		Expect(secondGetErr).NotTo(HaveOccurred())
 		Expect(len(remainingRows)).To(BeZero())
 	})
 
-	It("gets all storage diffs from db", func() {
-		fakeAddr := "0x234567"
-		diffTwo := utils.StorageDiffInput{
-			HashedAddress: utils.HexToKeccak256Hash(fakeAddr),
-			BlockHash:     common.HexToHash("0x678902"),
-			BlockHeight:   988,
-			StorageKey:    common.HexToHash("0x654322"),
-			StorageValue:  common.HexToHash("0x198766"),
-		}
-		persistedDiffTwoID, insertDiffErr := diffRepository.CreateStorageDiff(diffTwo)
-		Expect(insertDiffErr).NotTo(HaveOccurred())
-		persistedDiffTwo := utils.ToPersistedDiff(diffTwo, persistedDiffTwoID)
-		addErr := queue.Add(persistedDiffTwo)
+	It("deletes storage diff queued for a transformer from db", func() {
+		addErr := queue.Add(diff, "other transformer", fakes.FakeError)
 		Expect(addErr).NotTo(HaveOccurred())
 
-		diffs, err := queue.GetAll()
+		err := queue.DeleteForTransformer(diff.ID, transformerName)
 
 		Expect(err).NotTo(HaveOccurred())
-		Expect(len(diffs)).To(Equal(2))
-		Expect(diffs[0]).NotTo(Equal(diffs[1]))
-		Expect(diffs[0].ID).NotTo(BeZero())
-		Expect(diffs[0].HashedAddress).To(Or(Equal(diff.HashedAddress), Equal(diffTwo.HashedAddress)))
-		Expect(diffs[0].BlockHash).To(Or(Equal(diff.BlockHash), Equal(diffTwo.BlockHash)))
-		Expect(diffs[0].BlockHeight).To(Or(Equal(diff.BlockHeight), Equal(diffTwo.BlockHeight)))
-		Expect(diffs[0].StorageKey).To(Or(Equal(diff.StorageKey), Equal(diffTwo.StorageKey)))
-		Expect(diffs[0].StorageValue).To(Or(Equal(diff.StorageValue), Equal(diffTwo.StorageValue)))
-		Expect(diffs[1].ID).NotTo(BeZero())
-		Expect(diffs[1].HashedAddress).To(Or(Equal(diff.HashedAddress), Equal(diffTwo.HashedAddress)))
-		Expect(diffs[1].BlockHash).To(Or(Equal(diff.BlockHash), Equal(diffTwo.BlockHash)))
-		Expect(diffs[1].BlockHeight).To(Or(Equal(diff.BlockHeight), Equal(diffTwo.BlockHeight)))
-		Expect(diffs[1].StorageKey).To(Or(Equal(diff.StorageKey), Equal(diffTwo.StorageKey)))
-		Expect(diffs[1].StorageValue).To(Or(Equal(diff.StorageValue), Equal(diffTwo.StorageValue)))
+		remainingRows, getErr := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
+		Expect(getErr).NotTo(HaveOccurred())
+		Expect(remainingRows).To(Equal([]storage.QueuedStorageDiff{{PersistedStorageDiff: diff, Transformer: "other transformer"}}))
+	})
+
+	Describe("GetDue", func() {
+		var diffTwo, diffThree utils.PersistedStorageDiff
+
+		BeforeEach(func() {
+			diffTwo = addDiff(diffRepository, queue, "0x234567", 986)
+			diffThree = addDiff(diffRepository, queue, "0x345678", 988)
+		})
+
+		It("gets due storage diffs in block order", func() {
+			diffs, err := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal(queued(diffTwo, diff, diffThree)))
+		})
+
+		It("gets a page of diffs following the given diff", func() {
+			diffs, err := queue.GetDue(diffTwo.BlockHeight, diffTwo.ID, transformerName, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal(queued(diff)))
+		})
+
+		It("does not get diffs whose next attempt is not due", func() {
+			_, updateErr := db.Exec(`UPDATE public.queued_storage SET next_attempt_at = NOW() + INTERVAL '1 hour' WHERE diff_id = $1`, diff.ID)
+			Expect(updateErr).NotTo(HaveOccurred())
+
+			diffs, err := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal(queued(diffTwo, diffThree)))
+		})
+	})
+
+	Describe("RecordFailure", func() {
+		It("counts the attempt and records its error", func() {
+			poisoned, err := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(poisoned).To(BeFalse())
+			var attempts int
+			var lastError string
+			getErr := db.QueryRow(`SELECT attempts, last_error FROM public.queued_storage WHERE diff_id = $1`, diff.ID).Scan(&attempts, &lastError)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(2))
+			Expect(lastError).To(Equal(fakes.FakeError.Error()))
+		})
+
+		It("schedules the next attempt with the policy's delay", func() {
+			queue.Policy = storage.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Hour, MaxDelay: 2 * time.Hour}
+
+			_, err := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+
+			Expect(err).NotTo(HaveOccurred())
+			var delay float64
+			getErr := db.Get(&delay, `SELECT EXTRACT(EPOCH FROM next_attempt_at - NOW()) FROM public.queued_storage WHERE diff_id = $1`, diff.ID)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(delay).To(BeNumerically("~", (2 * time.Hour).Seconds(), 60))
+		})
+
+		It("moves the diff to poisoned storage once the policy is exhausted", func() {
+			_, firstErr := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+			Expect(firstErr).NotTo(HaveOccurred())
+
+			poisoned, err := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(poisoned).To(BeTrue())
+			var queuedCount int
+			countErr := db.Get(&queuedCount, `SELECT count(*) FROM public.queued_storage`)
+			Expect(countErr).NotTo(HaveOccurred())
+			Expect(queuedCount).To(BeZero())
+			poisonedDiffs, getErr := queue.GetPoisoned(10, 0)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(len(poisonedDiffs)).To(Equal(1))
+			Expect(poisonedDiffs[0].PersistedStorageDiff).To(Equal(diff))
+			Expect(poisonedDiffs[0].Transformer).To(Equal(transformerName))
+			Expect(poisonedDiffs[0].Attempts).To(Equal(3))
+			Expect(poisonedDiffs[0].LastError).To(Equal(fakes.FakeError.Error()))
+		})
+
+		It("only counts the attempt of the given transformer", func() {
+			addErr := queue.Add(diff, "other transformer", fakes.FakeError)
+			Expect(addErr).NotTo(HaveOccurred())
+
+			_, err := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+
+			Expect(err).NotTo(HaveOccurred())
+			var attempts int
+			getErr := db.Get(&attempts, `SELECT attempts FROM public.queued_storage WHERE diff_id = $1 AND transformer = $2`,
+				diff.ID, "other transformer")
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(1))
+		})
+
+		It("returns error if the diff is not queued", func() {
+			_, err := queue.RecordFailure(diff.ID+1, transformerName, fakes.FakeError)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+	})
+
+	Describe("Replay", func() {
+		BeforeEach(func() {
+			for i := 0; i < 2; i++ {
+				_, err := queue.RecordFailure(diff.ID, transformerName, fakes.FakeError)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("moves a poisoned diff back into the queue with its attempts reset", func() {
+			err := queue.Replay(diff.ID)
+
+			Expect(err).NotTo(HaveOccurred())
+			poisonedDiffs, getPoisonedErr := queue.GetPoisoned(10, 0)
+			Expect(getPoisonedErr).NotTo(HaveOccurred())
+			Expect(poisonedDiffs).To(BeEmpty())
+			diffs, getErr := queue.GetDue(-1, 0, "", storage.DefaultQueuePageSize)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal(queued(diff)))
+			var attempts int
+			attemptsErr := db.Get(&attempts, `SELECT attempts FROM public.queued_storage WHERE diff_id = $1`, diff.ID)
+			Expect(attemptsErr).NotTo(HaveOccurred())
+			Expect(attempts).To(BeZero())
+		})
+
+		It("returns error if the diff is not poisoned", func() {
+			err := queue.Replay(diff.ID + 1)
+
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
 	})
 })
+
+func addDiff(diffRepository repositories.StorageDiffRepository, queue storage.IStorageQueue, address string, blockHeight int) utils.PersistedStorageDiff {
+	rawDiff := utils.StorageDiffInput{
+		HashedAddress: utils.HexToKeccak256Hash(address),
+		BlockHash:     common.HexToHash("0x678902"),
+		BlockHeight:   blockHeight,
+		StorageKey:    common.HexToHash("0x654322"),
+		StorageValue:  common.HexToHash("0x198766"),
+	}
+	diffID, insertDiffErr := diffRepository.CreateStorageDiff(rawDiff)
+	Expect(insertDiffErr).NotTo(HaveOccurred())
+	persistedDiff := utils.ToPersistedDiff(rawDiff, diffID)
+	addErr := queue.Add(persistedDiff, transformerName, fakes.FakeError)
+	Expect(addErr).NotTo(HaveOccurred())
+	return persistedDiff
+}
+
+func queued(diffs ...utils.PersistedStorageDiff) []storage.QueuedStorageDiff {
+	var result []storage.QueuedStorageDiff
+	for _, diff := range diffs {
+		result = append(result, storage.QueuedStorageDiff{PersistedStorageDiff: diff, Transformer: transformerName})
+	}
+	return result
+}
@@ -17,33 +17,39 @@
 package utils
 
 import (
-	"fmt"
+	"errors"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 const (
 	bitsPerByte = 8
 )
 
+var ErrLongValue = errors.New("long bytes and string values are stored across several slots and must be assembled")
+
 func Decode(diff PersistedStorageDiff, metadata StorageValueMetadata) (interface{}, error) {
 	switch metadata.Type {
-	case Uint256:
-		return decodeInteger(diff.StorageValue.Bytes()), nil
-	case Uint48:
-		return decodeInteger(diff.StorageValue.Bytes()), nil
-	case Uint128:
-		return decodeInteger(diff.StorageValue.Bytes()), nil
-	case Address:
-		return decodeAddress(diff.StorageValue.Bytes()), nil
 	case Bytes32:
 		return diff.StorageValue.Hex(), nil
 	case PackedSlot:
-		return decodePackedSlot(diff.StorageValue.Bytes(), metadata.PackedTypes), nil
+		return decodePackedSlot(diff.StorageValue.Bytes(), metadata.PackedTypes)
+	case Bytes, String:
+		return decodeShortValue(diff.StorageValue, metadata.Type)
 	default:
-		panic(fmt.Sprintf("can't decode unknown type: %d", metadata.Type))
+		return decodeValue(diff.StorageValue.Bytes(), metadata.Type)
+	}
+}
+
+// Decodes a value stored in the lowest order bytes of the slot
+func decodeValue(raw []byte, valueType ValueType) (string, error) {
+	size, sizeErr := getNumberOfBytes(valueType)
+	if sizeErr != nil {
+		return "", sizeErr
 	}
+	return decodeIndividualItem(raw[len(raw)-size:], valueType)
 }
 
 func decodeInteger(raw []byte) string {
@@ -51,11 +57,58 @@ func decodeInteger(raw []byte) string {
 	return n.String()
 }
 
+func decodeSignedInteger(raw []byte) string {
+	n := big.NewInt(0).SetBytes(raw)
+	if len(raw) > 0 && raw[0]&0x80 != 0 {
+		n.Sub(n, big.NewInt(0).Lsh(big.NewInt(1), uint(len(raw)*bitsPerByte)))
+	}
+	return n.String()
+}
+
 func decodeAddress(raw []byte) string {
 	return common.BytesToAddress(raw).Hex()
 }
 
-func decodePackedSlot(raw []byte, packedTypes map[int]ValueType) map[int]string {
+func decodeBool(raw []byte) string {
+	if big.NewInt(0).SetBytes(raw).Sign() == 0 {
+		return "false"
+	}
+	return "true"
+}
+
+// Short bytes and strings are stored in the highest order bytes of their slot, with their length * 2 in the lowest;
+// long values store length * 2 + 1, with the value in the slots following the keccak hash of the slot
+func decodeShortValue(raw common.Hash, valueType ValueType) (interface{}, error) {
+	length, long := DynamicValueLength(raw)
+	if long {
+		return nil, ErrLongValue
+	}
+	return DynamicValue(raw.Bytes()[:length], valueType), nil
+}
+
+// DynamicValueLength is the length of a bytes or string value given its slot, and whether the value is long
+func DynamicValueLength(raw common.Hash) (int, bool) {
+	lowestByte := raw[common.HashLength-1]
+	if lowestByte&1 == 0 {
+		return int(lowestByte / 2), false
+	}
+	length := big.NewInt(0).SetBytes(raw.Bytes())
+	length.Rsh(length, 1)
+	if !length.IsInt64() {
+		return -1, true
+	}
+	return int(length.Int64()), true
+}
+
+// DynamicValue is the decoded value of a bytes or string's data
+func DynamicValue(data []byte, valueType ValueType) interface{} {
+	if valueType == String {
+		return string(data)
+	}
+	return hexutil.Encode(data)
+}
+
+func decodePackedSlot(raw []byte, packedTypes map[int]ValueType) (map[int]string, error) {
 	storageSlotData := raw
 	decodedStorageSlotItems := map[int]string{}
 	numberOfTypes := len(packedTypes)
@@ -66,41 +119,71 @@ func decodePackedSlot(raw []byte, packedTypes map[int]ValueType) map[int]string
 
 		//get item details (type, length, starting index, value bytes)
 		itemType := packedTypes[position]
-		lengthOfItem := getNumberOfBytes(itemType)
+		lengthOfItem, lengthErr := getNumberOfBytes(itemType)
+		if lengthErr != nil {
+			return nil, lengthErr
+		}
+		if lengthOfItem > lengthOfStorageData {
+			return nil, ErrPackedSlotOverflow{Position: position}
+		}
 		itemStartingIndex := lengthOfStorageData - lengthOfItem
 		itemValueBytes := storageSlotData[itemStartingIndex:]
 
 		//decode item's bytes and set in results map
-		decodedValue := decodeIndividualItem(itemValueBytes, itemType)
+		decodedValue, decodeErr := decodeIndividualItem(itemValueBytes, itemType)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
 		decodedStorageSlotItems[position] = decodedValue
 
 		//pop last item off raw slot data before moving on
 		storageSlotData = storageSlotData[0:itemStartingIndex]
 	}
 
-	return decodedStorageSlotItems
+	return decodedStorageSlotItems, nil
 }
 
-func decodeIndividualItem(itemBytes []byte, valueType ValueType) string {
-	switch valueType {
-	case Uint48, Uint128:
-		return decodeInteger(itemBytes)
-	case Address:
-		return decodeAddress(itemBytes)
+func decodeIndividualItem(itemBytes []byte, valueType ValueType) (string, error) {
+	switch {
+	case valueType == Uint256, valueType == Uint48, valueType == Uint128, isSized(valueType, uintTypes):
+		return decodeInteger(itemBytes), nil
+	case isSized(valueType, intTypes):
+		return decodeSignedInteger(itemBytes), nil
+	case valueType == Bytes32, isSized(valueType, fixedBytesTypes):
+		return hexutil.Encode(itemBytes), nil
+	case valueType == Address:
+		return decodeAddress(itemBytes), nil
+	case valueType == Bool:
+		return decodeBool(itemBytes), nil
 	default:
-		panic(fmt.Sprintf("can't decode unknown type: %d", valueType))
+		return "", ErrUnknownValueType{Type: valueType}
 	}
 }
 
-func getNumberOfBytes(valueType ValueType) int {
-	switch valueType {
-	case Uint48:
-		return 48 / bitsPerByte
-	case Uint128:
-		return 128 / bitsPerByte
-	case Address:
-		return 20
+func getNumberOfBytes(valueType ValueType) (int, error) {
+	switch {
+	case valueType == Uint256, valueType == Bytes32:
+		return 32, nil
+	case valueType == Uint48:
+		return 48 / bitsPerByte, nil
+	case valueType == Uint128:
+		return 128 / bitsPerByte, nil
+	case valueType == Address:
+		return 20, nil
+	case valueType == Bool:
+		return 1, nil
+	case isSized(valueType, uintTypes):
+		return int(valueType - uintTypes), nil
+	case isSized(valueType, intTypes):
+		return int(valueType - intTypes), nil
+	case isSized(valueType, fixedBytesTypes):
+		return int(valueType - fixedBytesTypes), nil
 	default:
-		panic(fmt.Sprintf("ValueType %d not recognized", valueType))
+		return 0, ErrUnknownValueType{Type: valueType}
 	}
 }
+
+// Whether the type is an integer or fixed bytes of a valid size numbered from base
+func isSized(valueType, base ValueType) bool {
+	return valueType > base && valueType <= base+32
+}
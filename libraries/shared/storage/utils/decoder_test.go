@@ -71,6 +71,111 @@ var _ = Describe("Storage decoder", func() {
 		Expect(result).To(Equal(fakeAddress.Hex()))
 	})
 
+	It("decodes uintN", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0xff01")}}
+		metadata := utils.StorageValueMetadata{Type: utils.Uint(8)}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("1"))
+	})
+
+	It("decodes negative intN", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0xfffe")}}
+		metadata := utils.StorageValueMetadata{Type: utils.Int(16)}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("-2"))
+	})
+
+	It("decodes positive intN", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x7ffe")}}
+		metadata := utils.StorageValueMetadata{Type: utils.Int(16)}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("32766"))
+	})
+
+	It("decodes negative int256", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{
+			StorageValue: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		}}
+		metadata := utils.StorageValueMetadata{Type: utils.Int(256)}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("-1"))
+	})
+
+	It("decodes bool", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x1")}}
+		metadata := utils.StorageValueMetadata{Type: utils.Bool}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("true"))
+	})
+
+	It("decodes bytesN", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0xdeadbeef")}}
+		metadata := utils.StorageValueMetadata{Type: utils.FixedBytes(4)}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("0xdeadbeef"))
+	})
+
+	It("decodes short strings", func() {
+		// "vulcanize" left aligned, with its length * 2 in the lowest order byte
+		value := common.HexToHash("0x76756c63616e697a650000000000000000000000000000000000000000000012")
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: value}}
+		metadata := utils.StorageValueMetadata{Type: utils.String}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("vulcanize"))
+	})
+
+	It("decodes short bytes", func() {
+		value := common.HexToHash("0x0102030000000000000000000000000000000000000000000000000000000006")
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: value}}
+		metadata := utils.StorageValueMetadata{Type: utils.Bytes}
+
+		result, err := utils.Decode(diff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal("0x010203"))
+	})
+
+	It("returns error for long strings", func() {
+		// length of 40 bytes, stored as length * 2 + 1
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x51")}}
+		metadata := utils.StorageValueMetadata{Type: utils.String}
+
+		_, err := utils.Decode(diff, metadata)
+
+		Expect(err).To(MatchError(utils.ErrLongValue))
+	})
+
+	It("returns error for unknown types", func() {
+		diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x1")}}
+		metadata := utils.StorageValueMetadata{Type: utils.Uint(7)}
+
+		_, err := utils.Decode(diff, metadata)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(utils.ErrUnknownValueType{}))
+	})
+
 	Describe("when there are multiple items packed in the storage slot", func() {
 		It("decodes uint48 items", func() {
 			//this is a real storage data example
@@ -170,5 +275,55 @@ var _ = Describe("Storage decoder", func() {
 			Expect(decodedValues[1]).To(Equal(big.NewInt(0).SetBytes(common.HexToHash("2a30").Bytes()).String()))
 			Expect(decodedValues[2]).To(Equal(big.NewInt(0).SetBytes(common.HexToHash("2a300").Bytes()).String()))
 		})
+
+		It("decodes items of mixed types", func() {
+			// bytes2 0xabcd, int16 -2, bool true, address 0x12345
+			addressHex := "0000000000000000000000000000000000012345"
+			packedStorage := common.HexToHash("abcd" + "fffe" + "01" + addressHex)
+			diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: packedStorage}}
+			metadata := utils.StorageValueMetadata{
+				Type: utils.PackedSlot,
+				PackedTypes: map[int]utils.ValueType{
+					0: utils.Address,
+					1: utils.Bool,
+					2: utils.Int(16),
+					3: utils.FixedBytes(2),
+				},
+			}
+
+			result, err := utils.Decode(diff, metadata)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(map[int]string{
+				0: "0x" + addressHex,
+				1: "true",
+				2: "-2",
+				3: "0xabcd",
+			}))
+		})
+
+		It("returns error if the items don't fit in the slot", func() {
+			diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x1")}}
+			metadata := utils.StorageValueMetadata{
+				Type:        utils.PackedSlot,
+				PackedTypes: map[int]utils.ValueType{0: utils.Uint128, 1: utils.Uint128, 2: utils.Uint48},
+			}
+
+			_, err := utils.Decode(diff, metadata)
+
+			Expect(err).To(MatchError(utils.ErrPackedSlotOverflow{Position: 2}))
+		})
+
+		It("returns error for unknown types", func() {
+			diff := utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{StorageValue: common.HexToHash("0x1")}}
+			metadata := utils.StorageValueMetadata{
+				Type:        utils.PackedSlot,
+				PackedTypes: map[int]utils.ValueType{0: utils.String},
+			}
+
+			_, err := utils.Decode(diff, metadata)
+
+			Expect(err).To(MatchError(utils.ErrUnknownValueType{Type: utils.String}))
+		})
 	})
 })
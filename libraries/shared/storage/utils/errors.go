@@ -51,3 +51,19 @@ type ErrStorageKeyNotFound struct {
 func (e ErrStorageKeyNotFound) Error() string {
 	return fmt.Sprintf("unknown storage key: %s", e.Key)
 }
+
+type ErrUnknownValueType struct {
+	Type ValueType
+}
+
+func (e ErrUnknownValueType) Error() string {
+	return fmt.Sprintf("can't decode unknown type: %d", e.Type)
+}
+
+type ErrPackedSlotOverflow struct {
+	Position int
+}
+
+func (e ErrPackedSlotOverflow) Error() string {
+	return fmt.Sprintf("packed slot item %d does not fit in the slot", e.Position)
+}
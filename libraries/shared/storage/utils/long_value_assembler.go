@@ -0,0 +1,255 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Longer values are assumed to be malformed, rather than watching the slots for them
+const maxLongValueLength = 1 << 20
+
+// LongValueLoader loads the latest canonical diff of each of a contract's storage keys at or before a block.
+// Keys are passed in both raw and hashed form, and diffs are returned under whichever form they were stored with
+type LongValueLoader interface {
+	GetLatestDiffs(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int) ([]PersistedStorageDiff, error)
+}
+
+// LongValueAssembler assembles long bytes and string values from the diffs of the value's slot, which holds the length,
+// and of its data slots, which follow the keccak hash of the value's slot. The latest data of each slot is kept,
+// since slots that are unchanged when a value is updated don't produce diffs.
+// With a Loader, data is only held while a value is assembled and is otherwise loaded from storage_diff,
+// as are the values seen before a restart once Rebuild is called. Only the routes of data slots to their values
+// are kept, much like the keys lookup keeps the keys of each value.
+type LongValueAssembler struct {
+	Loader    LongValueLoader
+	values    map[common.Hash]*longValue // value's slot => value
+	dataSlots map[common.Hash]dataSlot   // raw and hashed keys of data slots => data slot
+	rebuilt   bool
+	lock      sync.Mutex
+}
+
+type longValue struct {
+	metadata    StorageValueMetadata
+	long        bool
+	length      int
+	blockHeight int
+	registered  int              // number of data slots routed to the value
+	data        map[int]dataPart // position of the data slot => data
+}
+
+type dataSlot struct {
+	valueSlot common.Hash
+	position  int
+}
+
+type dataPart struct {
+	blockHeight int
+	value       common.Hash
+}
+
+func NewLongValueAssembler() *LongValueAssembler {
+	return &LongValueAssembler{
+		values:    make(map[common.Hash]*longValue),
+		dataSlots: make(map[common.Hash]dataSlot),
+	}
+}
+
+// AddValue records a diff of a Bytes or String value's slot, returning the value if all of its data is known
+func (assembler *LongValueAssembler) AddValue(diff PersistedStorageDiff, metadata StorageValueMetadata) (interface{}, bool, error) {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	value, addErr := assembler.addValue(diff, metadata)
+	if addErr != nil || value == nil {
+		return nil, false, addErr
+	}
+	if !value.long {
+		return DynamicValue(diff.StorageValue.Bytes()[:value.length], metadata.Type), true, nil
+	}
+	return assembler.assemble(diff, value)
+}
+
+// AddData records a diff of a long value's data slot, returning the value's metadata and the value if all of its data
+// is known. isData is false if the diff isn't of a data slot of a long value seen by the assembler.
+func (assembler *LongValueAssembler) AddData(diff PersistedStorageDiff) (metadata StorageValueMetadata, value interface{}, complete, isData bool, err error) {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	slot, ok := assembler.dataSlots[diff.StorageKey]
+	if !ok {
+		return StorageValueMetadata{}, nil, false, false, nil
+	}
+	longValue := assembler.values[slot.valueSlot]
+	part, seen := longValue.data[slot.position]
+	if !seen || diff.BlockHeight >= part.blockHeight {
+		longValue.data[slot.position] = dataPart{blockHeight: diff.BlockHeight, value: diff.StorageValue}
+	}
+	if !longValue.long || slot.position >= dataSlotCount(longValue.length) {
+		// data slots beyond the current length are cleared when a value shrinks
+		delete(longValue.data, slot.position)
+		return longValue.metadata, nil, false, true, nil
+	}
+	value, complete, err = assembler.assemble(diff, longValue)
+	return longValue.metadata, value, complete, true, err
+}
+
+// Rebuilt returns whether Rebuild has been called
+func (assembler *LongValueAssembler) Rebuilt() bool {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	return assembler.rebuilt
+}
+
+// Rebuild loads the latest diffs of the given Bytes and String values of the diff's contract at or before its block,
+// so that diffs of their data slots are recognized after a restart. It does nothing without a Loader
+func (assembler *LongValueAssembler) Rebuild(diff PersistedStorageDiff, metadata []StorageValueMetadata) error {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	if assembler.Loader == nil || len(metadata) == 0 {
+		assembler.rebuilt = true
+		return nil
+	}
+	valueSlots := make(map[common.Hash]StorageValueMetadata)
+	var keys []common.Hash
+	for _, valueMetadata := range metadata {
+		hashedSlot := crypto.Keccak256Hash(valueMetadata.Slot.Bytes())
+		valueSlots[valueMetadata.Slot] = valueMetadata
+		valueSlots[hashedSlot] = valueMetadata
+		keys = append(keys, valueMetadata.Slot, hashedSlot)
+	}
+	valueDiffs, loadErr := assembler.Loader.GetLatestDiffs(diff.HashedAddress, keys, diff.BlockHeight)
+	if loadErr != nil {
+		return loadErr
+	}
+	// a value slot's hashed key is also the plain key of its first data slot, and like Execute, data takes precedence
+	for _, plain := range []bool{true, false} {
+		for _, valueDiff := range valueDiffs {
+			valueMetadata := valueSlots[valueDiff.StorageKey]
+			if (valueDiff.StorageKey == valueMetadata.Slot) != plain {
+				continue
+			}
+			if _, isData := assembler.dataSlots[valueDiff.StorageKey]; isData {
+				continue
+			}
+			_, addErr := assembler.addValue(valueDiff, valueMetadata)
+			if addErr != nil {
+				return addErr
+			}
+		}
+	}
+	assembler.rebuilt = true
+	return nil
+}
+
+// addValue records the length of a value, registering its data slots; it returns nil if the diff is older than the value
+func (assembler *LongValueAssembler) addValue(diff PersistedStorageDiff, metadata StorageValueMetadata) (*longValue, error) {
+	length, long := DynamicValueLength(diff.StorageValue)
+	if long && (length < 0 || length > maxLongValueLength) {
+		return nil, fmt.Errorf("length of %s exceeds %d bytes", metadata.Name, maxLongValueLength)
+	}
+	value, ok := assembler.values[metadata.Slot]
+	if !ok {
+		value = &longValue{data: make(map[int]dataPart)}
+		assembler.values[metadata.Slot] = value
+	} else if diff.BlockHeight < value.blockHeight {
+		// a queued diff of an earlier block doesn't change the current value
+		return nil, nil
+	}
+	value.metadata = metadata
+	value.long = long
+	value.length = length
+	value.blockHeight = diff.BlockHeight
+	if !long {
+		return value, nil
+	}
+	for position := range value.data {
+		if position >= dataSlotCount(length) {
+			delete(value.data, position)
+		}
+	}
+	// data slots stay routed once the value shrinks, since diffs clearing them follow
+	firstDataSlot := crypto.Keccak256Hash(metadata.Slot.Bytes()).Big()
+	for position := value.registered; position < dataSlotCount(length); position++ {
+		key := common.BigToHash(big.NewInt(0).Add(firstDataSlot, big.NewInt(int64(position))))
+		assembler.dataSlots[key] = dataSlot{valueSlot: metadata.Slot, position: position}
+		assembler.dataSlots[crypto.Keccak256Hash(key.Bytes())] = dataSlot{valueSlot: metadata.Slot, position: position}
+		value.registered = position + 1
+	}
+	return value, nil
+}
+
+// assemble returns the value if all of its data is known, loading data not held in memory if there is a Loader.
+// Data that can be loaded again is then dropped
+func (assembler *LongValueAssembler) assemble(diff PersistedStorageDiff, value *longValue) (interface{}, bool, error) {
+	if assembler.Loader != nil {
+		defer func() { value.data = make(map[int]dataPart) }()
+		loadErr := assembler.loadData(diff, value)
+		if loadErr != nil {
+			return nil, false, loadErr
+		}
+	}
+	data := make([]byte, 0, dataSlotCount(value.length)*common.HashLength)
+	for position := 0; position < dataSlotCount(value.length); position++ {
+		part, ok := value.data[position]
+		if !ok {
+			return nil, false, nil
+		}
+		data = append(data, part.value.Bytes()...)
+	}
+	return DynamicValue(data[:value.length], value.metadata.Type), true, nil
+}
+
+// loadData loads the latest diffs of the value's data slots as of the later of the diff's block and the value's,
+// keeping data in memory from later blocks
+func (assembler *LongValueAssembler) loadData(diff PersistedStorageDiff, value *longValue) error {
+	blockHeight := diff.BlockHeight
+	if value.blockHeight > blockHeight {
+		blockHeight = value.blockHeight
+	}
+	firstDataSlot := crypto.Keccak256Hash(value.metadata.Slot.Bytes()).Big()
+	var keys []common.Hash
+	for position := 0; position < dataSlotCount(value.length); position++ {
+		key := common.BigToHash(big.NewInt(0).Add(firstDataSlot, big.NewInt(int64(position))))
+		keys = append(keys, key, crypto.Keccak256Hash(key.Bytes()))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	dataDiffs, loadErr := assembler.Loader.GetLatestDiffs(diff.HashedAddress, keys, blockHeight)
+	if loadErr != nil {
+		return loadErr
+	}
+	for _, dataDiff := range dataDiffs {
+		slot, ok := assembler.dataSlots[dataDiff.StorageKey]
+		if !ok {
+			continue
+		}
+		part, seen := value.data[slot.position]
+		if !seen || dataDiff.BlockHeight >= part.blockHeight {
+			value.data[slot.position] = dataPart{blockHeight: dataDiff.BlockHeight, value: dataDiff.StorageValue}
+		}
+	}
+	return nil
+}
+
+func dataSlotCount(length int) int {
+	return (length + common.HashLength - 1) / common.HashLength
+}
@@ -0,0 +1,227 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package utils_test
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Long value assembler", func() {
+	var (
+		assembler     *utils.LongValueAssembler
+		metadata      utils.StorageValueMetadata
+		longValue     = strings.Repeat("vulcanize", 5) // 45 bytes, stored in two data slots
+		valueDiff     utils.PersistedStorageDiff
+		dataDiffs     []utils.PersistedStorageDiff
+		firstDataSlot = crypto.Keccak256Hash(common.HexToHash(utils.IndexTwo).Bytes())
+	)
+
+	BeforeEach(func() {
+		assembler = utils.NewLongValueAssembler()
+		metadata = utils.GetStorageValueMetadataForDynamicValue("name", nil, utils.String, common.HexToHash(utils.IndexTwo))
+		valueDiff = utils.PersistedStorageDiff{StorageDiffInput: utils.StorageDiffInput{
+			StorageKey:   common.HexToHash(utils.IndexTwo),
+			StorageValue: common.BigToHash(big.NewInt(int64(len(longValue)*2 + 1))),
+			BlockHeight:  10,
+		}}
+		dataDiffs = []utils.PersistedStorageDiff{
+			{StorageDiffInput: utils.StorageDiffInput{
+				StorageKey:   firstDataSlot,
+				StorageValue: common.BytesToHash([]byte(longValue[:32])),
+				BlockHeight:  10,
+			}},
+			{StorageDiffInput: utils.StorageDiffInput{
+				StorageKey:   utils.GetIncrementedStorageKey(firstDataSlot, 1),
+				StorageValue: common.BytesToHash(common.RightPadBytes([]byte(longValue[32:]), 32)),
+				BlockHeight:  10,
+			}},
+		}
+	})
+
+	It("returns short values from the value's slot", func() {
+		valueDiff.StorageValue = common.HexToHash("0x76756c63616e697a650000000000000000000000000000000000000000000012")
+
+		value, complete, err := assembler.AddValue(valueDiff, metadata)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(complete).To(BeTrue())
+		Expect(value).To(Equal("vulcanize"))
+	})
+
+	It("does not return a long value until all of its data is known", func() {
+		_, complete, err := assembler.AddValue(valueDiff, metadata)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(complete).To(BeFalse())
+
+		returnedMetadata, _, complete, isData, err := assembler.AddData(dataDiffs[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isData).To(BeTrue())
+		Expect(complete).To(BeFalse())
+		Expect(returnedMetadata).To(Equal(metadata))
+
+		_, value, complete, isData, err := assembler.AddData(dataDiffs[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isData).To(BeTrue())
+		Expect(complete).To(BeTrue())
+		Expect(value).To(Equal(longValue))
+	})
+
+	It("recognizes hashed data slot keys", func() {
+		_, _, err := assembler.AddValue(valueDiff, metadata)
+		Expect(err).NotTo(HaveOccurred())
+		dataDiffs[0].StorageKey = crypto.Keccak256Hash(dataDiffs[0].StorageKey.Bytes())
+
+		_, _, _, isData, err := assembler.AddData(dataDiffs[0])
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isData).To(BeTrue())
+	})
+
+	It("does not recognize data slots before the value's slot has been seen", func() {
+		_, _, _, isData, err := assembler.AddData(dataDiffs[0])
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isData).To(BeFalse())
+	})
+
+	It("keeps data from earlier diffs of unchanged slots", func() {
+		_, _, err := assembler.AddValue(valueDiff, metadata)
+		Expect(err).NotTo(HaveOccurred())
+		assembler.AddData(dataDiffs[0])
+		assembler.AddData(dataDiffs[1])
+		updatedValue := longValue[:32] + "VULCANIZE" + longValue[41:]
+		updatedDiff := dataDiffs[1]
+		updatedDiff.StorageValue = common.BytesToHash(common.RightPadBytes([]byte(updatedValue[32:]), 32))
+		updatedDiff.BlockHeight = 11
+
+		_, value, complete, _, err := assembler.AddData(updatedDiff)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(complete).To(BeTrue())
+		Expect(value).To(Equal(updatedValue))
+	})
+
+	It("ignores data diffs from earlier blocks", func() {
+		_, _, err := assembler.AddValue(valueDiff, metadata)
+		Expect(err).NotTo(HaveOccurred())
+		assembler.AddData(dataDiffs[0])
+		assembler.AddData(dataDiffs[1])
+		staleDiff := dataDiffs[1]
+		staleDiff.StorageValue = common.HexToHash("0x0")
+		staleDiff.BlockHeight = 9
+
+		_, value, _, _, err := assembler.AddData(staleDiff)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(longValue))
+	})
+
+	It("returns error if the length is implausibly long", func() {
+		valueDiff.StorageValue = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+		_, _, err := assembler.AddValue(valueDiff, metadata)
+
+		Expect(err).To(HaveOccurred())
+	})
+	Describe("with a loader", func() {
+		var loader *mocks.MockLongValueLoader
+
+		BeforeEach(func() {
+			loader = &mocks.MockLongValueLoader{}
+			assembler.Loader = loader
+		})
+
+		It("loads data of unchanged slots instead of keeping it", func() {
+			loader.Diffs = append(loader.Diffs, valueDiff, dataDiffs[0])
+			_, _, err := assembler.AddValue(valueDiff, metadata)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, _, _, err = assembler.AddData(dataDiffs[0])
+			Expect(err).NotTo(HaveOccurred())
+			loader.Diffs = append(loader.Diffs, dataDiffs[1])
+
+			_, value, complete, isData, err := assembler.AddData(dataDiffs[1])
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isData).To(BeTrue())
+			Expect(complete).To(BeTrue())
+			Expect(value).To(Equal(longValue))
+		})
+
+		It("does not load data from later blocks than the value", func() {
+			laterDiff := dataDiffs[0]
+			laterDiff.StorageValue = common.HexToHash("0x0")
+			laterDiff.BlockHeight = 11
+			loader.Diffs = append(loader.Diffs, valueDiff, dataDiffs[0], laterDiff, dataDiffs[1])
+			_, _, err := assembler.AddValue(valueDiff, metadata)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, value, _, _, err := assembler.AddData(dataDiffs[1])
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(longValue))
+		})
+
+		It("recognizes data slots of values seen before a rebuild", func() {
+			loader.Diffs = append(loader.Diffs, valueDiff, dataDiffs[0], dataDiffs[1])
+			Expect(assembler.Rebuilt()).To(BeFalse())
+
+			err := assembler.Rebuild(dataDiffs[1], []utils.StorageValueMetadata{metadata})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembler.Rebuilt()).To(BeTrue())
+
+			returnedMetadata, value, complete, isData, err := assembler.AddData(dataDiffs[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isData).To(BeTrue())
+			Expect(complete).To(BeTrue())
+			Expect(returnedMetadata).To(Equal(metadata))
+			Expect(value).To(Equal(longValue))
+		})
+
+		It("rebuilds values from diffs with hashed keys", func() {
+			valueDiff.StorageKey = crypto.Keccak256Hash(valueDiff.StorageKey.Bytes())
+			for i := range dataDiffs {
+				dataDiffs[i].StorageKey = crypto.Keccak256Hash(dataDiffs[i].StorageKey.Bytes())
+			}
+			loader.Diffs = append(loader.Diffs, valueDiff, dataDiffs[0], dataDiffs[1])
+
+			err := assembler.Rebuild(dataDiffs[1], []utils.StorageValueMetadata{metadata})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, value, complete, _, err := assembler.AddData(dataDiffs[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(complete).To(BeTrue())
+			Expect(value).To(Equal(longValue))
+		})
+
+		It("returns error if loading fails", func() {
+			loader.LoadErr = fakes.FakeError
+			_, _, err := assembler.AddValue(valueDiff, metadata)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+})
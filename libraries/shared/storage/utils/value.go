@@ -16,7 +16,11 @@
 
 package utils
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
 
 type ValueType int
 
@@ -27,8 +31,53 @@ const (
 	Bytes32
 	Address
 	PackedSlot
+	Bool
+	Bytes  // dynamic bytes
+	String // dynamic string
+)
+
+// Other sizes of integers and fixed bytes are numbered by their size in bytes from these bases
+const (
+	uintTypes       ValueType = 100
+	intTypes        ValueType = 200
+	fixedBytesTypes ValueType = 300
 )
 
+// Uint is the type of a uintN value; enums are stored as the smallest uint that fits their members, usually uint8
+func Uint(bits int) ValueType {
+	switch bits {
+	case 256:
+		return Uint256
+	case 128:
+		return Uint128
+	case 48:
+		return Uint48
+	default:
+		return uintTypes + sizedType(bits)
+	}
+}
+
+// Int is the type of a two's complement intN value
+func Int(bits int) ValueType {
+	return intTypes + sizedType(bits)
+}
+
+// FixedBytes is the type of a bytesN value
+func FixedBytes(size int) ValueType {
+	if size == 32 {
+		return Bytes32
+	}
+	return fixedBytesTypes + sizedType(size*8)
+}
+
+// Size in bytes of types with a bit size, or zero for invalid sizes, which can't be decoded
+func sizedType(bits int) ValueType {
+	if bits < 8 || bits > 256 || bits%8 != 0 {
+		return 0
+	}
+	return ValueType(bits / 8)
+}
+
 type Key string
 
 type StorageValueMetadata struct {
@@ -37,6 +86,7 @@ type StorageValueMetadata struct {
 	Type        ValueType
 	PackedNames map[int]string    //zero indexed position in map => name of packed item
 	PackedTypes map[int]ValueType //zero indexed position in map => type of packed item
+	Slot        common.Hash       //slot of a Bytes or String value, long values are stored in the slots following its keccak hash
 }
 
 func GetStorageValueMetadata(name string, keys map[Key]string, valueType ValueType) StorageValueMetadata {
@@ -47,6 +97,13 @@ func GetStorageValueMetadataForPackedSlot(name string, keys map[Key]string, valu
 	return getMetadata(name, keys, valueType, packedNames, packedTypes)
 }
 
+// GetStorageValueMetadataForDynamicValue describes a Bytes or String value, which needs its slot to find where long values are stored
+func GetStorageValueMetadataForDynamicValue(name string, keys map[Key]string, valueType ValueType, slot common.Hash) StorageValueMetadata {
+	metadata := getMetadata(name, keys, valueType, nil, nil)
+	metadata.Slot = slot
+	return metadata
+}
+
 func getMetadata(name string, keys map[Key]string, valueType ValueType, packedNames map[int]string, packedTypes map[int]ValueType) StorageValueMetadata {
 	assertPackedSlotArgs(valueType, packedNames, packedTypes)
 
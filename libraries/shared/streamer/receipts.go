@@ -0,0 +1,76 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package streamer
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// storedLogRLP is a log with the block and transaction metadata that receipts for storage leave out
+type storedLogRLP struct {
+	Address     common.Address
+	Topics      []common.Hash
+	Data        []byte
+	BlockNumber uint64
+	TxHash      common.Hash
+	TxIndex     uint
+	BlockHash   common.Hash
+	Index       uint
+}
+
+// EncodeReceiptLogs encodes a receipt's logs for a super node payload, preserving their metadata
+func EncodeReceiptLogs(logs []*types.Log) ([]byte, error) {
+	enc := make([]storedLogRLP, len(logs))
+	for i, log := range logs {
+		enc[i] = storedLogRLP{
+			Address:     log.Address,
+			Topics:      log.Topics,
+			Data:        log.Data,
+			BlockNumber: log.BlockNumber,
+			TxHash:      log.TxHash,
+			TxIndex:     log.TxIndex,
+			BlockHash:   log.BlockHash,
+			Index:       log.Index,
+		}
+	}
+	return rlp.EncodeToBytes(enc)
+}
+
+// DecodeReceiptLogs decodes logs, along with their metadata, encoded with EncodeReceiptLogs
+func DecodeReceiptLogs(logsRLP []byte) ([]types.Log, error) {
+	var stored []storedLogRLP
+	decodeErr := rlp.DecodeBytes(logsRLP, &stored)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	logs := make([]types.Log, 0, len(stored))
+	for _, log := range stored {
+		logs = append(logs, types.Log{
+			Address:     log.Address,
+			Topics:      log.Topics,
+			Data:        log.Data,
+			BlockNumber: log.BlockNumber,
+			TxHash:      log.TxHash,
+			TxIndex:     log.TxIndex,
+			BlockHash:   log.BlockHash,
+			Index:       log.Index,
+		})
+	}
+	return logs, nil
+}
@@ -0,0 +1,78 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package streamer_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+)
+
+var _ = Describe("Super node receipts", func() {
+	var (
+		log = types.Log{
+			Address:     common.HexToAddress("0x1234"),
+			Topics:      []common.Hash{common.HexToHash("0xabcd")},
+			Data:        []byte{1, 2, 3},
+			BlockNumber: 100,
+			TxHash:      common.HexToHash("0x5678"),
+			TxIndex:     2,
+			BlockHash:   common.HexToHash("0x9abc"),
+			Index:       7,
+		}
+		receipt = &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			TxHash:            common.HexToHash("0x5678"),
+			Logs:              []*types.Log{&log},
+			GasUsed:           21000,
+		}
+	)
+
+	It("retains log metadata when decoding encoded logs", func() {
+		logsRlp, encodeErr := streamer.EncodeReceiptLogs(receipt.Logs)
+		Expect(encodeErr).NotTo(HaveOccurred())
+
+		logs, decodeErr := streamer.DecodeReceiptLogs(logsRlp)
+
+		Expect(decodeErr).NotTo(HaveOccurred())
+		Expect(logs).To(ConsistOf(log))
+	})
+
+	It("encodes a receipt without logs", func() {
+		logsRlp, encodeErr := streamer.EncodeReceiptLogs(nil)
+		Expect(encodeErr).NotTo(HaveOccurred())
+		Expect(logsRlp).NotTo(BeEmpty())
+
+		logs, decodeErr := streamer.DecodeReceiptLogs(logsRlp)
+
+		Expect(decodeErr).NotTo(HaveOccurred())
+		Expect(logs).To(BeEmpty())
+	})
+
+	It("returns an error if the rlp is not encoded logs", func() {
+		receiptRlp, encodeErr := rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+		Expect(encodeErr).NotTo(HaveOccurred())
+
+		_, decodeErr := streamer.DecodeReceiptLogs(receiptRlp)
+
+		Expect(decodeErr).To(HaveOccurred())
+	})
+})
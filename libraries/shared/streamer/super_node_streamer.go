@@ -57,6 +57,7 @@ type SuperNodePayload struct {
 	UnclesRlp       [][]byte                               `json:"unclesRlp"`
 	TransactionsRlp [][]byte                               `json:"transactionsRlp"`
 	ReceiptsRlp     [][]byte                               `json:"receiptsRlp"`
+	ReceiptLogsRlp  [][]byte                               `json:"receiptLogsRlp"` // logs of each receipt encoded with EncodeReceiptLogs, empty if not indexed
 	StateNodesRlp   map[common.Hash][]byte                 `json:"stateNodesRlp"`
 	StorageNodesRlp map[common.Hash]map[common.Hash][]byte `json:"storageNodesRlp"`
 	ErrMsg          string                                 `json:"errMsg"`
@@ -27,6 +27,15 @@ type EventTransformer interface {
 	GetConfig() EventTransformerConfig
 }
 
+// ProvisionalEventTransformer is an EventTransformer that opts in to receiving logs before they reach their
+// confirmation depth. Those logs are passed to Execute with Provisional set, and once their block is confirmed they
+// are passed to Finalize if their header is still canonical or to Revert if it was removed by a reorg.
+type ProvisionalEventTransformer interface {
+	EventTransformer
+	Finalize(logs []core.HeaderSyncLog) error
+	Revert(logs []core.HeaderSyncLog) error
+}
+
 type EventTransformerInitializer func(db *postgres.DB) EventTransformer
 
 type EventTransformerConfig struct {
@@ -35,7 +44,8 @@ type EventTransformerConfig struct {
 	ContractAbi         string
 	Topic               string
 	StartingBlockNumber int64
-	EndingBlockNumber   int64 // Set -1 for indefinite transformer
+	EndingBlockNumber   int64  // Set -1 for indefinite transformer
+	ConfirmationDepth   *int64 // Blocks behind head a log's header must be before delegation; nil uses the watcher's default
 }
 
 func HexToInt64(byteString string) int64 {
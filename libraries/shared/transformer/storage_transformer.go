@@ -27,4 +27,19 @@ type StorageTransformer interface {
 	KeccakContractAddress() common.Hash
 }
 
+// RevertibleStorageTransformer is a StorageTransformer that can remove the rows it derived from diffs whose block
+// was removed by a reorg. Canonical diffs at the same heights are passed to Execute again after a Revert.
+type RevertibleStorageTransformer interface {
+	StorageTransformer
+	Revert(diffs []utils.PersistedStorageDiff) error
+}
+
+// MultiAddressStorageTransformer is a StorageTransformer for several contracts with the same storage layout,
+// such as the contracts created by a factory. KeccakContractAddress identifies the transformer, and the addresses
+// it transforms are reloaded each time the watcher rechecks its queue, so the set can grow.
+type MultiAddressStorageTransformer interface {
+	StorageTransformer
+	KeccakContractAddresses() ([]common.Hash, error)
+}
+
 type StorageTransformerInitializer func(db *postgres.DB) StorageTransformer
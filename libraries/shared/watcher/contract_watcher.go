@@ -21,7 +21,9 @@
 package watcher
 
 import (
+	"context"
 	"fmt"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
@@ -63,8 +65,12 @@ func (watcher *ContractWatcher) AddTransformers(inits interface{}) error {
 	return nil
 }
 
-func (watcher *ContractWatcher) Execute() error {
+// Execute runs each transformer once, skipping the remaining transformers if the context is cancelled
+func (watcher *ContractWatcher) Execute(ctx context.Context) error {
 	for _, contractTransformer := range watcher.Transformers {
+		if ctx.Err() != nil {
+			return nil
+		}
 		err := contractTransformer.Execute()
 		if err != nil {
 			logrus.Error("Unable to execute transformer:", contractTransformer.GetConfig().Name, err)
@@ -17,6 +17,8 @@
 package watcher
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -25,6 +27,7 @@ import (
 	"github.com/vulcanize/vulcanizedb/libraries/shared/constants"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/fetcher"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/logs"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transactions"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
 	"github.com/vulcanize/vulcanizedb/pkg/core"
@@ -39,9 +42,14 @@ type EventWatcher struct {
 	db           *postgres.DB
 	LogDelegator logs.ILogDelegator
 	LogExtractor logs.ILogExtractor
+	// Guards the delegator and extractor so transformers can be added or removed while executing
+	transformersLock    sync.RWMutex
+	removedTransformers bool
 }
 
-func NewEventWatcher(db *postgres.DB, bc core.BlockChain) EventWatcher {
+// NewEventWatcher creates an event watcher whose logs are only delegated once their header is confirmationDepth
+// blocks behind the chain head, unless a transformer configures its own depth
+func NewEventWatcher(db *postgres.DB, bc core.BlockChain, confirmationDepth int64) *EventWatcher {
 	extractor := &logs.LogExtractor{
 		CheckedHeadersRepository: repositories.NewCheckedHeadersRepository(db),
 		CheckedLogsRepository:    repositories.NewCheckedLogsRepository(db),
@@ -49,11 +57,33 @@ func NewEventWatcher(db *postgres.DB, bc core.BlockChain) EventWatcher {
 		LogRepository:            repositories.NewHeaderSyncLogRepository(db),
 		Syncer:                   transactions.NewTransactionsSyncer(db, bc),
 	}
+	return newEventWatcher(db, bc, extractor, confirmationDepth)
+}
+
+// NewSuperNodeEventWatcher creates an event watcher that extracts logs from a super node subscription
+// rather than fetching them from the node with eth_getLogs
+func NewSuperNodeEventWatcher(db *postgres.DB, bc core.BlockChain, superNodeStreamer streamer.ISuperNodeStreamer, confirmationDepth int64) *EventWatcher {
+	extractor := &logs.SuperNodeLogExtractor{
+		CheckedHeadersRepository: repositories.NewCheckedHeadersRepository(db),
+		CheckedLogsRepository:    repositories.NewCheckedLogsRepository(db),
+		Fetcher:                  fetcher.NewLogFetcher(bc),
+		HeaderRepository:         repositories.NewHeaderRepository(db),
+		LogRepository:            repositories.NewHeaderSyncLogRepository(db),
+		Streamer:                 superNodeStreamer,
+		Syncer:                   transactions.NewTransactionsSyncer(db, bc),
+	}
+	return newEventWatcher(db, bc, extractor, confirmationDepth)
+}
+
+func newEventWatcher(db *postgres.DB, bc core.BlockChain, extractor logs.ILogExtractor, confirmationDepth int64) *EventWatcher {
 	logTransformer := &logs.LogDelegator{
-		Chunker:       chunker.NewLogChunker(),
-		LogRepository: repositories.NewHeaderSyncLogRepository(db),
+		BlockChain:               bc,
+		Chunker:                  chunker.NewLogChunker(),
+		ConfirmationDepth:        confirmationDepth,
+		LogRepository:            repositories.NewHeaderSyncLogRepository(db),
+		ProvisionalLogRepository: repositories.NewProvisionalLogRepository(db),
 	}
-	return EventWatcher{
+	return &EventWatcher{
 		blockChain:   bc,
 		db:           db,
 		LogExtractor: extractor,
@@ -63,6 +93,8 @@ func NewEventWatcher(db *postgres.DB, bc core.BlockChain) EventWatcher {
 
 // Adds transformers to the watcher so that their logs will be extracted and delegated.
 func (watcher *EventWatcher) AddTransformers(initializers []transformer.EventTransformerInitializer) error {
+	watcher.transformersLock.Lock()
+	defer watcher.transformersLock.Unlock()
 	for _, initializer := range initializers {
 		t := initializer(watcher.db)
 
@@ -75,54 +107,102 @@ func (watcher *EventWatcher) AddTransformers(initializers []transformer.EventTra
 	return nil
 }
 
-// Extracts and delegates watched log events.
-func (watcher *EventWatcher) Execute(recheckHeaders constants.TransformerExecution) error {
-	delegateErrsChan := make(chan error)
-	extractErrsChan := make(chan error)
-	defer close(delegateErrsChan)
-	defer close(extractErrsChan)
+// Removes transformers from the watcher so that their logs are no longer extracted or delegated.
+func (watcher *EventWatcher) RemoveTransformers(transformerNames []string) {
+	watcher.transformersLock.Lock()
+	defer watcher.transformersLock.Unlock()
+	for _, name := range transformerNames {
+		watcher.LogDelegator.RemoveTransformer(name)
+		watcher.LogExtractor.RemoveTransformerConfig(name)
+	}
+	watcher.removedTransformers = true
+}
+
+// Extracts and delegates watched log events until the context is cancelled or either process fails.
+func (watcher *EventWatcher) Execute(ctx context.Context, recheckHeaders constants.TransformerExecution) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	delegateErrsChan := make(chan error, 1)
+	extractErrsChan := make(chan error, 1)
 
-	go watcher.extractLogs(recheckHeaders, extractErrsChan)
-	go watcher.delegateLogs(delegateErrsChan)
+	go func() { extractErrsChan <- watcher.extractLogs(ctx, recheckHeaders) }()
+	go func() { delegateErrsChan <- watcher.delegateLogs(ctx) }()
 
-	for {
+	// wait for both processes to stop, cancelling the other as soon as one fails
+	var executeErr error
+	for i := 0; i < 2; i++ {
 		select {
 		case delegateErr := <-delegateErrsChan:
-			logrus.Errorf("error delegating logs in event watcher: %s", delegateErr.Error())
-			return delegateErr
+			if delegateErr != nil {
+				logrus.Errorf("error delegating logs in event watcher: %s", delegateErr.Error())
+				executeErr = firstErr(executeErr, delegateErr)
+				cancel()
+			}
 		case extractErr := <-extractErrsChan:
-			logrus.Errorf("error extracting logs in event watcher: %s", extractErr.Error())
-			return extractErr
+			if extractErr != nil {
+				logrus.Errorf("error extracting logs in event watcher: %s", extractErr.Error())
+				executeErr = firstErr(executeErr, extractErr)
+				cancel()
+			}
 		}
 	}
+	return executeErr
 }
 
-func (watcher *EventWatcher) extractLogs(recheckHeaders constants.TransformerExecution, errs chan error) {
-	err := watcher.LogExtractor.ExtractLogs(recheckHeaders)
-	if err != nil && err != logs.ErrNoUncheckedHeaders {
-		errs <- err
-		return
+func (watcher *EventWatcher) extractLogs(ctx context.Context, recheckHeaders constants.TransformerExecution) error {
+	for ctx.Err() == nil {
+		watcher.transformersLock.RLock()
+		err := watcher.LogExtractor.ExtractLogs(recheckHeaders)
+		emptied := watcher.emptied(err)
+		watcher.transformersLock.RUnlock()
+		if err != nil && err != logs.ErrNoUncheckedHeaders && !emptied {
+			return err
+		}
+
+		if err == logs.ErrNoUncheckedHeaders || emptied {
+			pause(ctx, NoNewDataPause)
+		}
 	}
+	return nil
+}
+
+func (watcher *EventWatcher) delegateLogs(ctx context.Context) error {
+	for ctx.Err() == nil {
+		watcher.transformersLock.RLock()
+		err := watcher.LogDelegator.DelegateLogs()
+		emptied := watcher.emptied(err)
+		watcher.transformersLock.RUnlock()
+		if err != nil && err != logs.ErrNoLogs && !emptied {
+			return err
+		}
 
-	if err == logs.ErrNoUncheckedHeaders {
-		time.Sleep(NoNewDataPause)
-		watcher.extractLogs(recheckHeaders, errs)
-	} else {
-		watcher.extractLogs(recheckHeaders, errs)
+		if err == logs.ErrNoLogs || emptied {
+			pause(ctx, NoNewDataPause)
+		}
 	}
+	return nil
 }
 
-func (watcher *EventWatcher) delegateLogs(errs chan error) {
-	err := watcher.LogDelegator.DelegateLogs()
-	if err != nil && err != logs.ErrNoLogs {
-		errs <- err
-		return
+// emptied reports whether err is due to every transformer having been removed at runtime,
+// in which case the watcher waits for transformers to be added back rather than failing
+func (watcher *EventWatcher) emptied(err error) bool {
+	return watcher.removedTransformers && (err == logs.ErrNoWatchedAddresses || err == logs.ErrNoTransformers)
+}
+
+// pause waits for the given duration, returning early if the context is cancelled
+func pause(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
 	}
+}
 
-	if err == logs.ErrNoLogs {
-		time.Sleep(NoNewDataPause)
-		watcher.delegateLogs(errs)
-	} else {
-		watcher.delegateLogs(errs)
+func firstErr(existing, err error) error {
+	if existing != nil {
+		return existing
 	}
+	return err
 }
@@ -17,6 +17,7 @@
 package watcher_test
 
 import (
+	"context"
 	"errors"
 
 	. "github.com/onsi/ginkgo"
@@ -83,13 +84,22 @@ var _ = Describe("Event Watcher", func() {
 		})
 	})
 
+	Describe("RemoveTransformers", func() {
+		It("removes transformers from the log delegator and extractor", func() {
+			eventWatcher.RemoveTransformers([]string{"one", "two"})
+
+			Expect(delegator.RemovedNames).To(Equal([]string{"one", "two"}))
+			Expect(extractor.RemovedNames).To(Equal([]string{"one", "two"}))
+		})
+	})
+
 	Describe("Execute", func() {
 
 		It("extracts watched logs", func(done Done) {
 			delegator.DelegateErrors = []error{logs.ErrNoLogs}
 			extractor.ExtractLogsErrors = []error{nil, errExecuteClosed}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(errExecuteClosed))
 			Eventually(func() bool {
@@ -102,7 +112,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{logs.ErrNoLogs}
 			extractor.ExtractLogsErrors = []error{fakes.FakeError}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(fakes.FakeError))
 			close(done)
@@ -112,7 +122,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{logs.ErrNoLogs}
 			extractor.ExtractLogsErrors = []error{nil, errExecuteClosed}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(errExecuteClosed))
 			Eventually(func() bool {
@@ -125,7 +135,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{logs.ErrNoLogs}
 			extractor.ExtractLogsErrors = []error{nil, fakes.FakeError}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(fakes.FakeError))
 			close(done)
@@ -135,7 +145,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{nil, errExecuteClosed}
 			extractor.ExtractLogsErrors = []error{logs.ErrNoUncheckedHeaders}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(errExecuteClosed))
 			Eventually(func() bool {
@@ -147,7 +157,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{fakes.FakeError}
 			extractor.ExtractLogsErrors = []error{logs.ErrNoUncheckedHeaders}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(fakes.FakeError))
 			close(done)
@@ -157,7 +167,7 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{nil, nil, nil, errExecuteClosed}
 			extractor.ExtractLogsErrors = []error{logs.ErrNoUncheckedHeaders}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(errExecuteClosed))
 			Eventually(func() bool {
@@ -170,10 +180,52 @@ var _ = Describe("Event Watcher", func() {
 			delegator.DelegateErrors = []error{nil, fakes.FakeError}
 			extractor.ExtractLogsErrors = []error{logs.ErrNoUncheckedHeaders}
 
-			err := eventWatcher.Execute(constants.HeaderUnchecked)
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
 
 			Expect(err).To(MatchError(fakes.FakeError))
 			close(done)
 		})
+
+		It("returns error if no transformers are configured", func(done Done) {
+			delegator.DelegateErrors = []error{logs.ErrNoLogs}
+			extractor.ExtractLogsErrors = []error{logs.ErrNoWatchedAddresses}
+
+			err := eventWatcher.Execute(context.Background(), constants.HeaderUnchecked)
+
+			Expect(err).To(MatchError(logs.ErrNoWatchedAddresses))
+			close(done)
+		})
+
+		It("waits for transformers to be added back after all are removed", func(done Done) {
+			eventWatcher.RemoveTransformers([]string{mocks.FakeTransformerConfig.TransformerName})
+			delegator.DelegateErrors = []error{logs.ErrNoTransformers}
+			extractor.ExtractLogsErrors = []error{logs.ErrNoWatchedAddresses}
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error)
+
+			go func() {
+				errs <- eventWatcher.Execute(ctx, constants.HeaderUnchecked)
+			}()
+			Consistently(errs).ShouldNot(Receive())
+			cancel()
+
+			Eventually(errs).Should(Receive(BeNil()))
+			close(done)
+		})
+
+		It("returns without error when the context is cancelled", func(done Done) {
+			delegator.DelegateErrors = []error{logs.ErrNoLogs}
+			extractor.ExtractLogsErrors = []error{logs.ErrNoUncheckedHeaders}
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error)
+
+			go func() {
+				errs <- eventWatcher.Execute(ctx, constants.HeaderUnchecked)
+			}()
+			cancel()
+
+			Eventually(errs).Should(Receive(BeNil()))
+			close(done)
+		})
 	})
 })
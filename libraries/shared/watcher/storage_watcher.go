@@ -17,7 +17,10 @@
 package watcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -32,28 +35,53 @@ import (
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
 )
 
+// DefaultReorgCheckDepth matches the window in which header sync replaces headers removed by a reorg
+const DefaultReorgCheckDepth = 15
+
+// ErrStorageFetcherStopped is returned by Execute when the storage fetcher gives up fetching diffs
+var ErrStorageFetcherStopped = errors.New("storage fetcher stopped")
+
 type IStorageWatcher interface {
 	AddTransformers(initializers []transformer.StorageTransformerInitializer)
-	Execute(queueRecheckInterval time.Duration, backFillOn bool)
+	AddNamedTransformers(names []string, initializers []transformer.StorageTransformerInitializer)
+	RemoveTransformers(names []string)
+	Execute(ctx context.Context, queueRecheckInterval time.Duration, backFillOn bool) error
 	BackFill(startingBlock uint64, backFiller storage.BackFiller)
+	BackFillRange(startingBlock, endingBlock uint64, backFiller storage.BackFiller, hashedAddresses []common.Hash) error
 }
 
 type StorageWatcher struct {
 	db                        *postgres.DB
 	StorageFetcher            fetcher.IStorageFetcher
 	Queue                     storage.IStorageQueue
+	QueuePageSize             int
+	BackFillProgress          storage.IBackFillProgress
+	ReorgCheckDepth           int64
 	StorageDiffRepository     datastore.StorageDiffRepository
-	KeccakAddressTransformers map[common.Hash]transformer.StorageTransformer // keccak hash of an address => transformer
+	KeccakAddressTransformers map[common.Hash][]transformer.StorageTransformer // keccak hash of an address => transformers watching it
 	DiffsChan                 chan utils.StorageDiffInput
 	ErrsChan                  chan error
 	BackFillDoneChan          chan bool
 	StartingSyncBlockChan     chan uint64
+	transformers              []watchedTransformer
+	routes                    map[common.Hash][]watchedTransformer // keccak hash of an address => named transformers watching it
+	transformersLock          sync.RWMutex
+	// transformLock serializes transforming diffs between Execute and back-fills, since transformers
+	// and their keys lookups and long value assemblers aren't safe to run concurrently
+	transformLock sync.Mutex
+}
+
+// watchedTransformer is a transformer with the keccak hashed addresses it was last routed diffs from
+type watchedTransformer struct {
+	name            string
+	transformer     transformer.StorageTransformer
+	hashedAddresses []common.Hash
 }
 
 func NewStorageWatcher(f fetcher.IStorageFetcher, db *postgres.DB) *StorageWatcher {
 	queue := storage.NewStorageQueue(db)
 	storageDiffRepository := repositories.NewStorageDiffRepository(db)
-	transformers := make(map[common.Hash]transformer.StorageTransformer)
+	transformers := make(map[common.Hash][]transformer.StorageTransformer)
 	return &StorageWatcher{
 		db:                        db,
 		StorageFetcher:            f,
@@ -62,107 +90,454 @@ func NewStorageWatcher(f fetcher.IStorageFetcher, db *postgres.DB) *StorageWatch
 		StartingSyncBlockChan:     make(chan uint64),
 		BackFillDoneChan:          make(chan bool),
 		Queue:                     queue,
+		QueuePageSize:             storage.DefaultQueuePageSize,
+		BackFillProgress:          storage.NewBackFillProgress(db),
+		ReorgCheckDepth:           DefaultReorgCheckDepth,
 		StorageDiffRepository:     storageDiffRepository,
 		KeccakAddressTransformers: transformers,
 	}
 }
 
+// AddTransformers routes diffs to the initialized transformers from each of their addresses,
+// alongside any other transformers watching the same addresses.
+// Each transformer is named by the hex of its keccak hashed address, which transformers sharing an address share;
+// use AddNamedTransformers to remove them individually
 func (storageWatcher *StorageWatcher) AddTransformers(initializers []transformer.StorageTransformerInitializer) {
-	for _, initializer := range initializers {
+	storageWatcher.addTransformers(nil, initializers)
+}
+
+// AddNamedTransformers adds transformers like AddTransformers, identified by the given names, e.g. from a plugin's
+// exported names, so that RemoveTransformers only removes the named transformer
+func (storageWatcher *StorageWatcher) AddNamedTransformers(names []string, initializers []transformer.StorageTransformerInitializer) {
+	storageWatcher.addTransformers(names, initializers)
+}
+
+func (storageWatcher *StorageWatcher) addTransformers(names []string, initializers []transformer.StorageTransformerInitializer) {
+	storageWatcher.transformersLock.Lock()
+	defer storageWatcher.transformersLock.Unlock()
+	for i, initializer := range initializers {
 		storageTransformer := initializer(storageWatcher.db)
-		storageWatcher.KeccakAddressTransformers[storageTransformer.KeccakContractAddress()] = storageTransformer
+		name := storageTransformer.KeccakContractAddress().Hex()
+		if i < len(names) {
+			name = names[i]
+		}
+		hashedAddresses := getHashedAddresses(storageTransformer, nil)
+		storageWatcher.transformers = append(storageWatcher.transformers, watchedTransformer{
+			name:            name,
+			transformer:     storageTransformer,
+			hashedAddresses: hashedAddresses,
+		})
+	}
+	storageWatcher.routeTransformers()
+}
+
+// RemoveTransformers stops transforming diffs with the transformers of the given names
+func (storageWatcher *StorageWatcher) RemoveTransformers(names []string) {
+	storageWatcher.transformersLock.Lock()
+	defer storageWatcher.transformersLock.Unlock()
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+	}
+	remaining := storageWatcher.transformers[:0]
+	for _, watched := range storageWatcher.transformers {
+		if !removed[watched.name] {
+			remaining = append(remaining, watched)
+		}
+	}
+	storageWatcher.transformers = remaining
+	storageWatcher.routeTransformers()
+}
+
+// reloadAddresses picks up addresses added to multi-address transformers since they were last loaded
+func (storageWatcher *StorageWatcher) reloadAddresses() {
+	storageWatcher.transformersLock.Lock()
+	defer storageWatcher.transformersLock.Unlock()
+	for i, watched := range storageWatcher.transformers {
+		storageWatcher.transformers[i].hashedAddresses = getHashedAddresses(watched.transformer, watched.hashedAddresses)
+	}
+	storageWatcher.routeTransformers()
+}
+
+// routeTransformers rebuilds the maps of addresses to transformers; callers hold the transformers lock
+func (storageWatcher *StorageWatcher) routeTransformers() {
+	transformers := make(map[common.Hash][]transformer.StorageTransformer)
+	routes := make(map[common.Hash][]watchedTransformer)
+	for _, watched := range storageWatcher.transformers {
+		for _, hashedAddress := range watched.hashedAddresses {
+			transformers[hashedAddress] = append(transformers[hashedAddress], watched.transformer)
+			routes[hashedAddress] = append(routes[hashedAddress], watched)
+		}
+	}
+	storageWatcher.KeccakAddressTransformers = transformers
+	storageWatcher.routes = routes
+}
+
+// getHashedAddresses returns the addresses a transformer watches, keeping the previous addresses if they can't be loaded
+func getHashedAddresses(storageTransformer transformer.StorageTransformer, previous []common.Hash) []common.Hash {
+	multiAddressTransformer, ok := storageTransformer.(transformer.MultiAddressStorageTransformer)
+	if !ok {
+		return []common.Hash{storageTransformer.KeccakContractAddress()}
+	}
+	hashedAddresses, loadErr := multiAddressTransformer.KeccakContractAddresses()
+	if loadErr != nil {
+		logrus.Warnf("error loading addresses of storage transformer %s: %s",
+			storageTransformer.KeccakContractAddress().Hex(), loadErr.Error())
+		if previous == nil {
+			return []common.Hash{storageTransformer.KeccakContractAddress()}
+		}
+		return previous
 	}
+	return hashedAddresses
 }
 
-// BackFill uses a backFiller to backfill missing storage diffs for the storageWatcher
+// BackFill uses a backFiller to backfill missing storage diffs for the storageWatcher's contracts
 func (storageWatcher *StorageWatcher) BackFill(startingBlock uint64, backFiller storage.BackFiller) {
 	// this blocks until the Execute process sends us the first block number it sees
 	endBackFillBlock := <-storageWatcher.StartingSyncBlockChan
-	backFillInitErr := backFiller.BackFill(startingBlock, endBackFillBlock,
-		storageWatcher.DiffsChan, storageWatcher.ErrsChan, storageWatcher.BackFillDoneChan)
-	if backFillInitErr != nil {
-		logrus.Warn(backFillInitErr)
+	backFillErr := storageWatcher.BackFillRange(startingBlock, endBackFillBlock, backFiller, storageWatcher.WatchedAddresses())
+	if backFillErr != nil {
+		logrus.Warn(backFillErr)
+		return
+	}
+	storageWatcher.BackFillDoneChan <- true
+}
+
+// BackFillRange back-fills the blocks in the range not yet completed for the given keccak hashed contract addresses,
+// recording progress as each bin completes so an interrupted back-fill resumes where it stopped.
+// Diffs are transformed one at a time with those of Execute, so it's safe to call while the watcher runs
+func (storageWatcher *StorageWatcher) BackFillRange(startingBlock, endingBlock uint64, backFiller storage.BackFiller, hashedAddresses []common.Hash) error {
+	ranges, rangesErr := storageWatcher.BackFillProgress.GetIncompleteRanges(hashedAddresses, startingBlock, endingBlock)
+	if rangesErr != nil {
+		return fmt.Errorf("error getting storage back-fill progress: %s", rangesErr.Error())
+	}
+	watched := make(map[common.Hash]bool, len(hashedAddresses))
+	for _, hashedAddress := range hashedAddresses {
+		watched[hashedAddress] = true
+	}
+	// diffs are received unbuffered and processed before the next receive, so a bin is only
+	// signalled complete after all of its diffs have been persisted
+	diffs := make(chan utils.StorageDiffInput)
+	errs := make(chan error)
+	binDone := make(chan [2]uint64)
+	done := make(chan bool)
+	for _, blockRange := range ranges {
+		backFillErr := backFiller.BackFill(blockRange[0], blockRange[1], diffs, errs, binDone, done)
+		if backFillErr != nil {
+			return backFillErr
+		}
+		for finished := false; !finished; {
+			select {
+			case diff := <-diffs:
+				if len(watched) == 0 || watched[diff.HashedAddress] {
+					processErr := storageWatcher.transformDiff(diff)
+					if processErr != nil {
+						return processErr
+					}
+				}
+			case err := <-errs:
+				logrus.Warnf("error back-filling storage diffs: %s", err.Error())
+			case bin := <-binDone:
+				markErr := storageWatcher.BackFillProgress.MarkBinComplete(hashedAddresses, bin[0], bin[1])
+				if markErr != nil {
+					logrus.Warnf("error recording storage back-fill progress: %s", markErr.Error())
+				}
+			case <-done:
+				finished = true
+			}
+		}
 	}
+	return nil
 }
 
-// Execute runs the StorageWatcher processes
-func (storageWatcher *StorageWatcher) Execute(queueRecheckInterval time.Duration, backFillOn bool) {
+// Execute runs the StorageWatcher processes until the context is cancelled.
+// It returns an error if the fetcher stops fetching diffs, or if a diff that failed to transform can't be queued
+func (storageWatcher *StorageWatcher) Execute(ctx context.Context, queueRecheckInterval time.Duration, backFillOn bool) error {
 	ticker := time.NewTicker(queueRecheckInterval)
-	go storageWatcher.StorageFetcher.FetchStorageDiffs(storageWatcher.DiffsChan, storageWatcher.ErrsChan)
+	defer ticker.Stop()
+	fetcherDone := make(chan struct{})
+	go func() {
+		storageWatcher.StorageFetcher.FetchStorageDiffs(storageWatcher.DiffsChan, storageWatcher.ErrsChan)
+		close(fetcherDone)
+	}()
+	var fetchErr error
 	start := true
 	for {
 		select {
+		case <-ctx.Done():
+			logrus.Info("storage watcher shutting down")
+			return nil
 		case err := <-storageWatcher.ErrsChan:
 			logrus.Warn(fmt.Sprintf("error fetching storage diffs: %s", err.Error()))
+			fetchErr = err
 		case diff := <-storageWatcher.DiffsChan:
 			if start && backFillOn {
 				storageWatcher.StartingSyncBlockChan <- uint64(diff.BlockHeight - 1)
 				start = false
 			}
-			storageWatcher.processRow(diff)
+			processErr := storageWatcher.transformDiff(diff)
+			if processErr != nil {
+				return processErr
+			}
 		case <-ticker.C:
+			storageWatcher.reloadAddresses()
+			storageWatcher.transformLock.Lock()
 			storageWatcher.processQueue()
+			reorgErr := storageWatcher.checkReorgs()
+			storageWatcher.transformLock.Unlock()
+			if reorgErr != nil {
+				return reorgErr
+			}
+		case <-fetcherDone:
+			return storageWatcher.fetcherStopped(fetchErr)
 		case <-storageWatcher.BackFillDoneChan:
 			logrus.Info("storage watcher backfill process has finished")
 		}
 	}
 }
 
-func (storageWatcher *StorageWatcher) getTransformer(diff utils.PersistedStorageDiff) (transformer.StorageTransformer, bool) {
-	storageTransformer, ok := storageWatcher.KeccakAddressTransformers[diff.HashedAddress]
-	return storageTransformer, ok
+// fetcherStopped transforms the diffs the fetcher sent before stopping, returning why it stopped
+func (storageWatcher *StorageWatcher) fetcherStopped(fetchErr error) error {
+	for {
+		select {
+		case err := <-storageWatcher.ErrsChan:
+			logrus.Warn(fmt.Sprintf("error fetching storage diffs: %s", err.Error()))
+			fetchErr = err
+		case diff := <-storageWatcher.DiffsChan:
+			processErr := storageWatcher.transformDiff(diff)
+			if processErr != nil {
+				return processErr
+			}
+		default:
+			if fetchErr == nil {
+				return ErrStorageFetcherStopped
+			}
+			return fmt.Errorf("%s: %s", ErrStorageFetcherStopped.Error(), fetchErr.Error())
+		}
+	}
+}
+
+// transformDiff persists and transforms a diff, one at a time with other diffs
+func (storageWatcher *StorageWatcher) transformDiff(diff utils.StorageDiffInput) error {
+	storageWatcher.transformLock.Lock()
+	defer storageWatcher.transformLock.Unlock()
+	return storageWatcher.processRow(diff)
+}
+
+// WatchedAddresses returns the keccak hashed addresses of the contracts with a storage transformer
+func (storageWatcher *StorageWatcher) WatchedAddresses() []common.Hash {
+	storageWatcher.transformersLock.RLock()
+	defer storageWatcher.transformersLock.RUnlock()
+	hashedAddresses := make([]common.Hash, 0, len(storageWatcher.KeccakAddressTransformers))
+	for hashedAddress := range storageWatcher.KeccakAddressTransformers {
+		hashedAddresses = append(hashedAddresses, hashedAddress)
+	}
+	return hashedAddresses
+}
+
+func (storageWatcher *StorageWatcher) getTransformers(hashedAddress common.Hash) []watchedTransformer {
+	storageWatcher.transformersLock.RLock()
+	defer storageWatcher.transformersLock.RUnlock()
+	return storageWatcher.routes[hashedAddress]
 }
 
-func (storageWatcher StorageWatcher) processRow(diffInput utils.StorageDiffInput) {
+// processRow persists a diff and transforms it with each transformer watching its address, returning an error if it
+// fails to transform and can't be queued
+func (storageWatcher *StorageWatcher) processRow(diffInput utils.StorageDiffInput) error {
 	diffID, err := storageWatcher.StorageDiffRepository.CreateStorageDiff(diffInput)
 	if err != nil {
 		if err == repositories.ErrDuplicateDiff {
 			logrus.Warn("ignoring duplicate diff")
-			return
+			return nil
 		}
 		logrus.Warnf("failed to persist storage diff: %s", err.Error())
 		// TODO: bail? Should we continue attempting to transform a diff we didn't persist
 	}
 	persistedDiff := utils.ToPersistedDiff(diffInput, diffID)
-	storageTransformer, ok := storageWatcher.getTransformer(persistedDiff)
-	if !ok {
+	storageTransformers := storageWatcher.getTransformers(persistedDiff.HashedAddress)
+	if len(storageTransformers) == 0 {
 		logrus.Debug("ignoring diff from unwatched contract")
-		return
+		return nil
 	}
-	executeErr := storageTransformer.Execute(persistedDiff)
+	for _, watched := range storageTransformers {
+		transformErr := storageWatcher.transformRow(watched, persistedDiff)
+		if transformErr != nil {
+			return transformErr
+		}
+	}
+	return nil
+}
+
+// transformRow executes the transformer on a diff, queueing the diff for the transformer if that fails.
+// It returns an error if the diff can't be queued, since it would otherwise be lost
+func (storageWatcher *StorageWatcher) transformRow(watched watchedTransformer, diff utils.PersistedStorageDiff) error {
+	executeErr := watched.transformer.Execute(diff)
 	if executeErr != nil {
-		logrus.Warn(fmt.Sprintf("error executing storage transformer: %s", executeErr))
-		queueErr := storageWatcher.Queue.Add(persistedDiff)
+		logrus.Warn(fmt.Sprintf("error executing storage transformer %s: %s", watched.name, executeErr))
+		queueErr := storageWatcher.Queue.Add(diff, watched.name, executeErr)
 		if queueErr != nil {
-			logrus.Warn(fmt.Sprintf("error queueing storage diff: %s", queueErr))
+			return fmt.Errorf("error queueing storage diff %d for transformer %s: %s", diff.ID, watched.name, queueErr.Error())
+		}
+		return nil
+	}
+	logrus.Debugf("Storage diff persisted at block height: %d", diff.BlockHeight)
+	return nil
+}
+
+// processQueue retries due diffs a page at a time, in block order
+func (storageWatcher *StorageWatcher) processQueue() {
+	afterBlockHeight, afterDiffID, afterTransformer := -1, int64(0), ""
+	for {
+		diffs, fetchErr := storageWatcher.Queue.GetDue(afterBlockHeight, afterDiffID, afterTransformer, storageWatcher.QueuePageSize)
+		if fetchErr != nil {
+			logrus.Warn(fmt.Sprintf("error getting queued storage: %s", fetchErr))
+			return
+		}
+		for _, diff := range diffs {
+			storageWatcher.processQueuedRow(diff)
 		}
+		if len(diffs) < storageWatcher.QueuePageSize {
+			return
+		}
+		lastDiff := diffs[len(diffs)-1]
+		afterBlockHeight, afterDiffID, afterTransformer = lastDiff.BlockHeight, lastDiff.ID, lastDiff.Transformer
+	}
+}
+
+// processQueuedRow retries a diff with the transformer that failed it, or with every transformer
+// watching its address if the queue doesn't name one.
+// Diffs for transformers that aren't watching, e.g. while a plugin is recomposed or a transformer is dropped from the
+// config, stay queued until the transformer is added back or their retries are exhausted and they can be replayed
+func (storageWatcher *StorageWatcher) processQueuedRow(diff storage.QueuedStorageDiff) {
+	var storageTransformers []watchedTransformer
+	for _, watched := range storageWatcher.getTransformers(diff.HashedAddress) {
+		if diff.Transformer == "" || watched.name == diff.Transformer {
+			storageTransformers = append(storageTransformers, watched)
+		}
+	}
+	if len(storageTransformers) == 0 {
+		storageWatcher.recordQueuedFailure(diff, fmt.Errorf("no storage transformer %s is watching %s",
+			diff.Transformer, diff.HashedAddress.Hex()))
+		return
+	}
+	var executeErr error
+	for _, watched := range storageTransformers {
+		transformerErr := watched.transformer.Execute(diff.PersistedStorageDiff)
+		if transformerErr != nil && executeErr == nil {
+			executeErr = transformerErr
+		}
+	}
+	if executeErr == nil {
+		storageWatcher.deleteQueuedRow(diff)
+		return
+	}
+	storageWatcher.recordQueuedFailure(diff, executeErr)
+}
+
+// recordQueuedFailure schedules the next attempt at a queued diff, or poisons it if its retries are exhausted
+func (storageWatcher *StorageWatcher) recordQueuedFailure(diff storage.QueuedStorageDiff, executeErr error) {
+	poisoned, recordErr := storageWatcher.Queue.RecordFailure(diff.ID, diff.Transformer, executeErr)
+	if recordErr != nil {
+		logrus.Warn(fmt.Sprintf("error recording failed attempt at queued diff: %s", recordErr))
 		return
 	}
-	logrus.Debugf("Storage diff persisted at block height: %d", diffInput.BlockHeight)
+	if poisoned {
+		logrus.Warnf("moved storage diff %d to poisoned storage after exhausting retries: %s", diff.ID, executeErr)
+	}
 }
 
-func (storageWatcher StorageWatcher) processQueue() {
-	diffs, fetchErr := storageWatcher.Queue.GetAll()
+// checkReorgs flags diffs whose block hash no longer matches the header at their height, reverting the values
+// transformed from them and re-executing the canonical diffs at those heights
+func (storageWatcher *StorageWatcher) checkReorgs() error {
+	diffs, fetchErr := storageWatcher.StorageDiffRepository.GetNonCanonicalDiffs(storageWatcher.ReorgCheckDepth, storageWatcher.QueuePageSize)
 	if fetchErr != nil {
-		logrus.Warn(fmt.Sprintf("error getting queued storage: %s", fetchErr))
+		logrus.Warn(fmt.Sprintf("error getting non-canonical storage diffs: %s", fetchErr))
+		return nil
 	}
+	var hashedAddresses []common.Hash
+	diffsByAddress := make(map[common.Hash][]utils.PersistedStorageDiff)
 	for _, diff := range diffs {
-		storageTransformer, ok := storageWatcher.getTransformer(diff)
+		if _, ok := diffsByAddress[diff.HashedAddress]; !ok {
+			hashedAddresses = append(hashedAddresses, diff.HashedAddress)
+		}
+		diffsByAddress[diff.HashedAddress] = append(diffsByAddress[diff.HashedAddress], diff)
+	}
+	for _, hashedAddress := range hashedAddresses {
+		revertErr := storageWatcher.revertRows(hashedAddress, diffsByAddress[hashedAddress])
+		if revertErr != nil {
+			return revertErr
+		}
+	}
+	return nil
+}
+
+// revertRows reverts a contract's non-canonical diffs with each transformer watching it, then flags them and
+// re-executes the canonical diffs at their heights. Diffs are only flagged once every transformer has reverted them,
+// so those a transformer can't revert are retried on each check until they leave the reorg check depth
+func (storageWatcher *StorageWatcher) revertRows(hashedAddress common.Hash, diffs []utils.PersistedStorageDiff) error {
+	storageTransformers := storageWatcher.getTransformers(hashedAddress)
+	reverted := true
+	for _, watched := range storageTransformers {
+		revertibleTransformer, ok := watched.transformer.(transformer.RevertibleStorageTransformer)
 		if !ok {
-			// delete diff from queue if address no longer watched
-			storageWatcher.deleteRow(diff.ID)
+			logrus.Warnf("storage transformer %s cannot revert values from %d non-canonical diffs", watched.name, len(diffs))
+			reverted = false
 			continue
 		}
-		executeErr := storageTransformer.Execute(diff)
-		if executeErr == nil {
-			storageWatcher.deleteRow(diff.ID)
+		revertErr := revertibleTransformer.Revert(diffs)
+		if revertErr != nil {
+			logrus.Warn(fmt.Sprintf("error reverting non-canonical storage diffs with transformer %s: %s", watched.name, revertErr))
+			reverted = false
 		}
 	}
+	if !reverted {
+		return nil
+	}
+
+	var blockHeights []int
+	for _, diff := range diffs {
+		storageWatcher.deleteRow(diff.ID)
+		markErr := storageWatcher.StorageDiffRepository.MarkNonCanonical(diff.ID)
+		if markErr != nil {
+			logrus.Warn(fmt.Sprintf("error flagging non-canonical storage diff: %s", markErr))
+			return nil
+		}
+		if len(blockHeights) == 0 || blockHeights[len(blockHeights)-1] != diff.BlockHeight {
+			blockHeights = append(blockHeights, diff.BlockHeight)
+		}
+	}
+	if len(storageTransformers) == 0 {
+		return nil
+	}
+
+	for _, blockHeight := range blockHeights {
+		canonicalDiffs, fetchErr := storageWatcher.StorageDiffRepository.GetCanonicalDiffs(blockHeight, hashedAddress)
+		if fetchErr != nil {
+			logrus.Warn(fmt.Sprintf("error getting canonical storage diffs: %s", fetchErr))
+			continue
+		}
+		for _, diff := range canonicalDiffs {
+			for _, watched := range storageTransformers {
+				transformErr := storageWatcher.transformRow(watched, diff)
+				if transformErr != nil {
+					return transformErr
+				}
+			}
+		}
+	}
+	return nil
 }
 
-func (storageWatcher StorageWatcher) deleteRow(diffID int64) {
+func (storageWatcher *StorageWatcher) deleteRow(diffID int64) {
 	deleteErr := storageWatcher.Queue.Delete(diffID)
 	if deleteErr != nil {
 		logrus.Warn(fmt.Sprintf("error deleting persisted diff from queue: %s", deleteErr))
 	}
 }
+
+func (storageWatcher *StorageWatcher) deleteQueuedRow(diff storage.QueuedStorageDiff) {
+	deleteErr := storageWatcher.Queue.DeleteForTransformer(diff.ID, diff.Transformer)
+	if deleteErr != nil {
+		logrus.Warn(fmt.Sprintf("error deleting persisted diff from queue: %s", deleteErr))
+	}
+}
@@ -17,6 +17,7 @@
 package watcher_test
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"math/rand"
@@ -29,6 +30,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/storage"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/test_data"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
@@ -57,7 +59,84 @@ var _ = Describe("Storage Watcher", func() {
 
 			w.AddTransformers([]transformer.StorageTransformerInitializer{fakeTransformer.FakeTransformerInitializer})
 
-			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal(fakeTransformer))
+			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal([]transformer.StorageTransformer{fakeTransformer}))
+		})
+
+		It("routes an address to every transformer watching it", func() {
+			fakeHashedAddress := utils.HexToKeccak256Hash("0x12345")
+			fakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			otherFakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			w := watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+
+			w.AddTransformers([]transformer.StorageTransformerInitializer{
+				fakeTransformer.FakeTransformerInitializer,
+				otherFakeTransformer.FakeTransformerInitializer,
+			})
+
+			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal([]transformer.StorageTransformer{
+				fakeTransformer,
+				otherFakeTransformer,
+			}))
+		})
+
+		It("routes every address of a multi-address transformer to it", func() {
+			fakeHashedAddress := utils.HexToKeccak256Hash("0x12345")
+			otherFakeHashedAddress := utils.HexToKeccak256Hash("0x67890")
+			fakeTransformer := &mocks.MockMultiAddressStorageTransformer{
+				MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress},
+				KeccaksOfAddresses:     []common.Hash{fakeHashedAddress, otherFakeHashedAddress},
+			}
+			w := watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+
+			w.AddTransformers([]transformer.StorageTransformerInitializer{fakeTransformer.FakeTransformerInitializer})
+
+			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal([]transformer.StorageTransformer{fakeTransformer}))
+			Expect(w.KeccakAddressTransformers[otherFakeHashedAddress]).To(Equal([]transformer.StorageTransformer{fakeTransformer}))
+		})
+	})
+	Describe("RemoveTransformers", func() {
+		It("removes transformers", func() {
+			fakeHashedAddress := utils.HexToKeccak256Hash("0x12345")
+			fakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			w := watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+			w.AddTransformers([]transformer.StorageTransformerInitializer{fakeTransformer.FakeTransformerInitializer})
+
+			w.RemoveTransformers([]string{fakeHashedAddress.Hex()})
+
+			Expect(w.KeccakAddressTransformers).To(BeEmpty())
+		})
+
+		It("only removes the named transformer of those sharing an address", func() {
+			fakeHashedAddress := utils.HexToKeccak256Hash("0x12345")
+			fakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			otherFakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			w := watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+			w.AddNamedTransformers([]string{"one", "two"}, []transformer.StorageTransformerInitializer{
+				fakeTransformer.FakeTransformerInitializer,
+				otherFakeTransformer.FakeTransformerInitializer,
+			})
+
+			w.RemoveTransformers([]string{"one"})
+
+			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal([]transformer.StorageTransformer{otherFakeTransformer}))
+		})
+
+		It("keeps other transformers watching the same address", func() {
+			fakeHashedAddress := utils.HexToKeccak256Hash("0x12345")
+			fakeTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: fakeHashedAddress}
+			multiAddressTransformer := &mocks.MockMultiAddressStorageTransformer{
+				MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: utils.HexToKeccak256Hash("0x67890")},
+				KeccaksOfAddresses:     []common.Hash{fakeHashedAddress},
+			}
+			w := watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+			w.AddTransformers([]transformer.StorageTransformerInitializer{
+				fakeTransformer.FakeTransformerInitializer,
+				multiAddressTransformer.FakeTransformerInitializer,
+			})
+
+			w.RemoveTransformers([]string{fakeHashedAddress.Hex()})
+
+			Expect(w.KeccakAddressTransformers[fakeHashedAddress]).To(Equal([]transformer.StorageTransformer{multiAddressTransformer}))
 		})
 	})
 	Describe("Execute", func() {
@@ -86,7 +165,7 @@ var _ = Describe("Storage Watcher", func() {
 			defer os.Remove(tempFile.Name())
 			logrus.SetOutput(tempFile)
 
-			go storageWatcher.Execute(time.Hour, false)
+			go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 			Eventually(func() (string, error) {
 				logContent, err := ioutil.ReadFile(tempFile.Name())
@@ -95,6 +174,52 @@ var _ = Describe("Storage Watcher", func() {
 			close(done)
 		})
 
+		It("returns when the context is cancelled", func(done Done) {
+			storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
+			storageWatcher.Queue = mockQueue
+			storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+			ctx, cancel := context.WithCancel(context.Background())
+			finished := make(chan error)
+
+			go func() {
+				finished <- storageWatcher.Execute(ctx, time.Hour, false)
+			}()
+			cancel()
+
+			Eventually(finished).Should(Receive(BeNil()))
+			close(done)
+		})
+
+		It("returns an error if the fetcher stops", func(done Done) {
+			mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+			mockFetcher.Stops = true
+			storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
+			storageWatcher.Queue = mockQueue
+			storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+
+			err := storageWatcher.Execute(context.Background(), time.Hour, false)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(watcher.ErrStorageFetcherStopped.Error()))
+			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+			close(done)
+		})
+
+		It("transforms diffs sent before the fetcher stops", func(done Done) {
+			mockFetcher.DiffsToReturn = []utils.StorageDiffInput{csvDiff}
+			mockFetcher.Stops = true
+			storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
+			storageWatcher.Queue = mockQueue
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{mockTransformer.FakeTransformerInitializer})
+			storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+
+			err := storageWatcher.Execute(context.Background(), time.Hour, false)
+
+			Expect(err).To(MatchError(watcher.ErrStorageFetcherStopped))
+			Expect(mockTransformer.PassedDiffs).To(HaveLen(1))
+			close(done)
+		})
+
 		Describe("transforming new storage diffs from csv", func() {
 			var fakePersistedDiff utils.PersistedStorageDiff
 			BeforeEach(func() {
@@ -117,7 +242,7 @@ var _ = Describe("Storage Watcher", func() {
 			})
 
 			It("writes raw diff before processing", func(done Done) {
-				go storageWatcher.Execute(time.Hour, false)
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 				Eventually(func() []utils.StorageDiffInput {
 					return mockStorageDiffRepository.CreatePassedInputs
@@ -128,7 +253,7 @@ var _ = Describe("Storage Watcher", func() {
 			It("discards raw diff if it's already been persisted", func(done Done) {
 				mockStorageDiffRepository.CreateReturnError = repositories.ErrDuplicateDiff
 
-				go storageWatcher.Execute(time.Hour, false)
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 				Consistently(func() []utils.PersistedStorageDiff {
 					return mockTransformer.PassedDiffs
@@ -143,7 +268,7 @@ var _ = Describe("Storage Watcher", func() {
 				defer os.Remove(tempFile.Name())
 				logrus.SetOutput(tempFile)
 
-				go storageWatcher.Execute(time.Hour, false)
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 				Eventually(func() (string, error) {
 					logContent, err := ioutil.ReadFile(tempFile.Name())
@@ -153,7 +278,7 @@ var _ = Describe("Storage Watcher", func() {
 			})
 
 			It("executes transformer for recognized storage diff", func(done Done) {
-				go storageWatcher.Execute(time.Hour, false)
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 				Eventually(func() []utils.PersistedStorageDiff {
 					return mockTransformer.PassedDiffs
@@ -163,10 +288,25 @@ var _ = Describe("Storage Watcher", func() {
 				close(done)
 			})
 
+			It("executes every transformer watching the diff's address", func(done Done) {
+				otherMockTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: hashedAddress}
+				storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{otherMockTransformer.FakeTransformerInitializer})
+
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
+
+				Eventually(func() []utils.PersistedStorageDiff {
+					return otherMockTransformer.PassedDiffs
+				}).Should(Equal([]utils.PersistedStorageDiff{fakePersistedDiff}))
+				Eventually(func() []utils.PersistedStorageDiff {
+					return mockTransformer.PassedDiffs
+				}).Should(Equal([]utils.PersistedStorageDiff{fakePersistedDiff}))
+				close(done)
+			})
+
 			It("queues diff for later processing if transformer execution fails", func(done Done) {
 				mockTransformer.ExecuteErr = fakes.FakeError
 
-				go storageWatcher.Execute(time.Hour, false)
+				go storageWatcher.Execute(context.Background(), time.Hour, false)
 
 				Eventually(func() bool {
 					return mockQueue.AddCalled
@@ -180,78 +320,75 @@ var _ = Describe("Storage Watcher", func() {
 				close(done)
 			})
 
-			It("logs error if queueing diff fails", func(done Done) {
+			It("returns an error if queueing diff fails", func(done Done) {
 				mockTransformer.ExecuteErr = utils.ErrStorageKeyNotFound{}
 				mockQueue.AddError = fakes.FakeError
-				tempFile, fileErr := ioutil.TempFile("", "log")
-				Expect(fileErr).NotTo(HaveOccurred())
-				defer os.Remove(tempFile.Name())
-				logrus.SetOutput(tempFile)
 
-				go storageWatcher.Execute(time.Hour, false)
+				err := storageWatcher.Execute(context.Background(), time.Hour, false)
 
-				Eventually(func() bool {
-					return mockQueue.AddCalled
-				}).Should(BeTrue())
-				Eventually(func() (string, error) {
-					logContent, err := ioutil.ReadFile(tempFile.Name())
-					return string(logContent), err
-				}).Should(ContainSubstring(fakes.FakeError.Error()))
+				Expect(mockQueue.AddCalled).To(BeTrue())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
 				close(done)
 			})
 		})
 
 		Describe("transforming queued storage diffs", func() {
-			var queuedDiff utils.PersistedStorageDiff
+			var queuedDiff storage.QueuedStorageDiff
 			BeforeEach(func() {
-				queuedDiff = utils.PersistedStorageDiff{
-					ID: 1337,
-					StorageDiffInput: utils.StorageDiffInput{
-						HashedAddress: hashedAddress,
-						BlockHash:     test_data.FakeHash(),
-						BlockHeight:   rand.Int(),
-						StorageKey:    test_data.FakeHash(),
-						StorageValue:  test_data.FakeHash(),
+				queuedDiff = storage.QueuedStorageDiff{
+					PersistedStorageDiff: utils.PersistedStorageDiff{
+						ID: 1337,
+						StorageDiffInput: utils.StorageDiffInput{
+							HashedAddress: hashedAddress,
+							BlockHash:     test_data.FakeHash(),
+							BlockHeight:   rand.Int(),
+							StorageKey:    test_data.FakeHash(),
+							StorageValue:  test_data.FakeHash(),
+						},
 					},
+					Transformer: hashedAddress.Hex(),
 				}
-				mockQueue.DiffsToReturn = []utils.PersistedStorageDiff{queuedDiff}
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
 				storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
 				storageWatcher.Queue = mockQueue
+				storageWatcher.StorageDiffRepository = mockStorageDiffRepository
 				storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{mockTransformer.FakeTransformerInitializer})
 			})
 
 			It("executes transformer for storage diff", func(done Done) {
-				go storageWatcher.Execute(time.Nanosecond, false)
+				go storageWatcher.Execute(context.Background(), time.Nanosecond, false)
 
 				Eventually(func() utils.PersistedStorageDiff {
 					if len(mockTransformer.PassedDiffs) > 0 {
 						return mockTransformer.PassedDiffs[0]
 					}
 					return utils.PersistedStorageDiff{}
-				}).Should(Equal(queuedDiff))
+				}).Should(Equal(queuedDiff.PersistedStorageDiff))
 				close(done)
 			})
 
-			It("deletes diff from queue if transformer execution successful", func(done Done) {
-				go storageWatcher.Execute(time.Nanosecond, false)
+			It("deletes diff from queue for the transformer if transformer execution successful", func(done Done) {
+				go storageWatcher.Execute(context.Background(), time.Nanosecond, false)
 
 				Eventually(func() int64 {
-					if len(mockQueue.DeletePassedIds) > 0 {
-						return mockQueue.DeletePassedIds[0]
+					if len(mockQueue.DeleteForTransformerIds) > 0 {
+						return mockQueue.DeleteForTransformerIds[0]
 					}
 					return 0
 				}).Should(Equal(queuedDiff.ID))
+				Expect(mockQueue.DeleteForTransformers[0]).To(Equal(queuedDiff.Transformer))
 				close(done)
 			})
 
 			It("logs error if deleting persisted diff fails", func(done Done) {
-				mockQueue.DeleteErr = fakes.FakeError
+				mockQueue.DeleteForTransformerErr = fakes.FakeError
 				tempFile, fileErr := ioutil.TempFile("", "log")
 				Expect(fileErr).NotTo(HaveOccurred())
 				defer os.Remove(tempFile.Name())
 				logrus.SetOutput(tempFile)
 
-				go storageWatcher.Execute(time.Nanosecond, false)
+				go storageWatcher.Execute(context.Background(), time.Nanosecond, false)
 
 				Eventually(func() (string, error) {
 					logContent, err := ioutil.ReadFile(tempFile.Name())
@@ -260,37 +397,204 @@ var _ = Describe("Storage Watcher", func() {
 				close(done)
 			})
 
-			It("deletes obsolete diff from queue if contract not recognized", func(done Done) {
-				obsoleteDiff := utils.PersistedStorageDiff{
-					ID:               queuedDiff.ID + 1,
-					StorageDiffInput: utils.StorageDiffInput{HashedAddress: test_data.FakeHash()},
-				}
-				mockQueue.DiffsToReturn = []utils.PersistedStorageDiff{obsoleteDiff}
+			It("keeps diff queued if contract not recognized", func(done Done) {
+				obsoleteDiff := storage.QueuedStorageDiff{}
+				obsoleteDiff.ID = queuedDiff.ID + 1
+				obsoleteDiff.HashedAddress = test_data.FakeHash()
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{obsoleteDiff}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []int64 {
+					return mockQueue.RecordFailurePassedIds
+				}).Should(ContainElement(obsoleteDiff.ID))
+				Expect(mockQueue.DeleteForTransformerIds).To(BeEmpty())
+				close(done)
+			})
+
+			It("keeps diff queued for a transformer that isn't watching, to retry once it's added back", func(done Done) {
+				queuedDiff.Transformer = "removed"
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []string {
+					return mockQueue.RecordFailureNames
+				}).Should(ContainElement("removed"))
+				Expect(mockQueue.DeleteForTransformerIds).To(BeEmpty())
+				Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+				close(done)
+			})
+
+			It("records a failed attempt if transformer execution fails", func(done Done) {
+				mockTransformer.ExecuteErr = fakes.FakeError
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() int64 {
+					if len(mockQueue.RecordFailurePassedIds) > 0 {
+						return mockQueue.RecordFailurePassedIds[0]
+					}
+					return 0
+				}).Should(Equal(queuedDiff.ID))
+				Expect(mockQueue.RecordFailureNames[0]).To(Equal(queuedDiff.Transformer))
+				Expect(mockQueue.DeleteForTransformerIds).To(BeEmpty())
+				close(done)
+			})
+
+			It("only retries the transformer that failed the diff", func(done Done) {
+				failingTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: hashedAddress, ExecuteErr: fakes.FakeError}
+				storageWatcher.AddNamedTransformers([]string{"failing"},
+					[]transformer.StorageTransformerInitializer{failingTransformer.FakeTransformerInitializer})
+				queuedDiff.Transformer = "failing"
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []string {
+					return mockQueue.RecordFailureNames
+				}).Should(ContainElement("failing"))
+				Expect(failingTransformer.PassedDiffs).To(ContainElement(queuedDiff.PersistedStorageDiff))
+				Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+				close(done)
+			})
+
+			It("retries every transformer watching the address if the queue doesn't name one", func(done Done) {
+				failingTransformer := &mocks.MockStorageTransformer{KeccakOfAddress: hashedAddress, ExecuteErr: fakes.FakeError}
+				storageWatcher.AddNamedTransformers([]string{"failing"},
+					[]transformer.StorageTransformerInitializer{failingTransformer.FakeTransformerInitializer})
+				queuedDiff.Transformer = ""
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
 
-				go storageWatcher.Execute(time.Nanosecond, false)
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
 
 				Eventually(func() int64 {
-					if len(mockQueue.DeletePassedIds) > 0 {
-						return mockQueue.DeletePassedIds[0]
+					if len(mockQueue.RecordFailurePassedIds) > 0 {
+						return mockQueue.RecordFailurePassedIds[0]
 					}
 					return 0
-				}).Should(Equal(obsoleteDiff.ID))
+				}).Should(Equal(queuedDiff.ID))
+				Expect(mockTransformer.PassedDiffs).To(ContainElement(queuedDiff.PersistedStorageDiff))
+				Expect(mockQueue.DeleteForTransformerIds).To(BeEmpty())
 				close(done)
 			})
 
-			It("logs error if deleting obsolete diff fails", func(done Done) {
-				obsoleteDiff := utils.PersistedStorageDiff{
-					ID:               queuedDiff.ID + 1,
-					StorageDiffInput: utils.StorageDiffInput{HashedAddress: test_data.FakeHash()},
+			It("transforms diffs from addresses loaded after the transformer was added", func(done Done) {
+				multiAddressTransformer := &mocks.MockMultiAddressStorageTransformer{
+					MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: test_data.FakeHash()},
 				}
-				mockQueue.DiffsToReturn = []utils.PersistedStorageDiff{obsoleteDiff}
-				mockQueue.DeleteErr = fakes.FakeError
+				storageWatcher.RemoveTransformers([]string{hashedAddress.Hex()})
+				storageWatcher.AddNamedTransformers([]string{"multi"},
+					[]transformer.StorageTransformerInitializer{multiAddressTransformer.FakeTransformerInitializer})
+				multiAddressTransformer.KeccaksOfAddresses = []common.Hash{hashedAddress}
+				queuedDiff.Transformer = "multi"
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []int64 {
+					return mockQueue.DeleteForTransformerIds
+				}).Should(ContainElement(queuedDiff.ID))
+				Expect(multiAddressTransformer.PassedDiffs).To(ContainElement(queuedDiff.PersistedStorageDiff))
+				close(done)
+			})
+
+			It("keeps transforming diffs from known addresses if reloading addresses fails", func(done Done) {
+				multiAddressTransformer := &mocks.MockMultiAddressStorageTransformer{
+					MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: test_data.FakeHash()},
+					KeccaksOfAddresses:     []common.Hash{hashedAddress},
+				}
+				storageWatcher.RemoveTransformers([]string{hashedAddress.Hex()})
+				storageWatcher.AddNamedTransformers([]string{"multi"},
+					[]transformer.StorageTransformerInitializer{multiAddressTransformer.FakeTransformerInitializer})
+				multiAddressTransformer.KeccaksOfAddresses = nil
+				queuedDiff.Transformer = "multi"
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff}
+				multiAddressTransformer.LoadAddressesErr = fakes.FakeError
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []int64 {
+					return mockQueue.DeleteForTransformerIds
+				}).Should(ContainElement(queuedDiff.ID))
+				Expect(multiAddressTransformer.PassedDiffs).To(ContainElement(queuedDiff.PersistedStorageDiff))
+				close(done)
+			})
+
+			It("logs when a diff is poisoned", func(done Done) {
+				mockTransformer.ExecuteErr = fakes.FakeError
+				mockQueue.RecordFailurePoisoned = true
+				tempFile, fileErr := ioutil.TempFile("", "log")
+				Expect(fileErr).NotTo(HaveOccurred())
+				defer os.Remove(tempFile.Name())
+				logrus.SetOutput(tempFile)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() (string, error) {
+					logContent, err := ioutil.ReadFile(tempFile.Name())
+					return string(logContent), err
+				}).Should(ContainSubstring("moved storage diff 1337 to poisoned storage"))
+				close(done)
+			})
+
+			It("loads queued diffs a page at a time", func(done Done) {
+				secondDiff := queuedDiff
+				secondDiff.ID = queuedDiff.ID + 1
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{queuedDiff, secondDiff}
+				storageWatcher.QueuePageSize = 1
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+				Eventually(func() []int64 {
+					if len(mockQueue.DeleteForTransformerIds) > 1 {
+						return mockQueue.DeleteForTransformerIds
+					}
+					return []int64{}
+				}).Should(Equal([]int64{queuedDiff.ID, secondDiff.ID}))
+				Expect(mockQueue.GetDuePassedLimits).To(ContainElement(1))
+				close(done)
+			})
+
+			It("logs error if recording an attempt at a diff for an unwatched contract fails", func(done Done) {
+				obsoleteDiff := storage.QueuedStorageDiff{}
+				obsoleteDiff.ID = queuedDiff.ID + 1
+				obsoleteDiff.HashedAddress = test_data.FakeHash()
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{obsoleteDiff}
+				mockQueue.RecordFailureErr = fakes.FakeError
 				tempFile, fileErr := ioutil.TempFile("", "log")
 				Expect(fileErr).NotTo(HaveOccurred())
 				defer os.Remove(tempFile.Name())
 				logrus.SetOutput(tempFile)
 
-				go storageWatcher.Execute(time.Nanosecond, false)
+				go storageWatcher.Execute(context.Background(), time.Nanosecond, false)
 
 				Eventually(func() (string, error) {
 					logContent, err := ioutil.ReadFile(tempFile.Name())
@@ -301,9 +605,171 @@ var _ = Describe("Storage Watcher", func() {
 		})
 	})
 
+	Describe("checking for reorgs", func() {
+		var (
+			mockQueue                 *mocks.MockStorageQueue
+			mockStorageDiffRepository *fakes.MockStorageDiffRepository
+			mockTransformer           *mocks.MockRevertibleStorageTransformer
+			orphanedDiff              utils.PersistedStorageDiff
+			canonicalDiff             utils.PersistedStorageDiff
+		)
+
+		BeforeEach(func() {
+			mockQueue = &mocks.MockStorageQueue{}
+			mockStorageDiffRepository = &fakes.MockStorageDiffRepository{}
+			mockTransformer = &mocks.MockRevertibleStorageTransformer{
+				MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: hashedAddress},
+			}
+			orphanedDiff = utils.PersistedStorageDiff{
+				ID: rand.Int63(),
+				StorageDiffInput: utils.StorageDiffInput{
+					HashedAddress: hashedAddress,
+					BlockHash:     test_data.FakeHash(),
+					BlockHeight:   100,
+					StorageKey:    test_data.FakeHash(),
+					StorageValue:  test_data.FakeHash(),
+				},
+			}
+			canonicalDiff = orphanedDiff
+			canonicalDiff.ID = orphanedDiff.ID + 1
+			canonicalDiff.BlockHash = test_data.FakeHash()
+			mockStorageDiffRepository.NonCanonicalDiffs = []utils.PersistedStorageDiff{orphanedDiff}
+			mockStorageDiffRepository.CanonicalDiffs = []utils.PersistedStorageDiff{canonicalDiff}
+			storageWatcher = watcher.NewStorageWatcher(mocks.NewStorageFetcher(), test_config.NewTestDB(test_config.NewTestNode()))
+			storageWatcher.Queue = mockQueue
+			storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+		})
+
+		It("checks diffs within the reorg check depth", func(done Done) {
+			storageWatcher.ReorgCheckDepth = 42
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() int64 {
+				return mockStorageDiffRepository.GetNonCanonicalPassedDepth
+			}).Should(Equal(int64(42)))
+			close(done)
+		})
+
+		It("reverts non-canonical diffs and flags them", func(done Done) {
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{mockTransformer.FakeTransformerInitializer})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() []int64 {
+				return mockStorageDiffRepository.MarkedNonCanonicalIDs
+			}).Should(Equal([]int64{orphanedDiff.ID}))
+			Expect(mockTransformer.RevertedDiffs).To(Equal([]utils.PersistedStorageDiff{orphanedDiff}))
+			Expect(mockQueue.DeletePassedIds).To(ContainElement(orphanedDiff.ID))
+			close(done)
+		})
+
+		It("re-executes the canonical diffs at the reverted heights", func(done Done) {
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{mockTransformer.FakeTransformerInitializer})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() []utils.PersistedStorageDiff {
+				return mockTransformer.PassedDiffs
+			}).Should(Equal([]utils.PersistedStorageDiff{canonicalDiff}))
+			Expect(mockStorageDiffRepository.GetCanonicalPassedHeights).To(ContainElement(orphanedDiff.BlockHeight))
+			close(done)
+		})
+
+		It("does not flag diffs if reverting them fails", func(done Done) {
+			mockTransformer.RevertErr = fakes.FakeError
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{mockTransformer.FakeTransformerInitializer})
+			tempFile, fileErr := ioutil.TempFile("", "log")
+			Expect(fileErr).NotTo(HaveOccurred())
+			defer os.Remove(tempFile.Name())
+			logrus.SetOutput(tempFile)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() (string, error) {
+				logContent, err := ioutil.ReadFile(tempFile.Name())
+				return string(logContent), err
+			}).Should(ContainSubstring(fakes.FakeError.Error()))
+			Expect(mockStorageDiffRepository.MarkedNonCanonicalIDs).To(BeEmpty())
+			close(done)
+		})
+
+		It("does not flag diffs for transformers that cannot revert", func(done Done) {
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{
+				mockTransformer.MockStorageTransformer.FakeTransformerInitializer,
+			})
+			tempFile, fileErr := ioutil.TempFile("", "log")
+			Expect(fileErr).NotTo(HaveOccurred())
+			defer os.Remove(tempFile.Name())
+			logrus.SetOutput(tempFile)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() (string, error) {
+				logContent, err := ioutil.ReadFile(tempFile.Name())
+				return string(logContent), err
+			}).Should(ContainSubstring("cannot revert"))
+			Expect(mockStorageDiffRepository.MarkedNonCanonicalIDs).To(BeEmpty())
+			Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+			close(done)
+		})
+
+		It("does not flag diffs until every transformer watching the contract reverts them", func(done Done) {
+			failingTransformer := &mocks.MockRevertibleStorageTransformer{
+				MockStorageTransformer: mocks.MockStorageTransformer{KeccakOfAddress: hashedAddress},
+				RevertErr:              fakes.FakeError,
+			}
+			storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{
+				mockTransformer.FakeTransformerInitializer,
+				failingTransformer.FakeTransformerInitializer,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() []utils.PersistedStorageDiff {
+				return failingTransformer.RevertedDiffs
+			}).ShouldNot(BeEmpty())
+			Expect(mockStorageDiffRepository.MarkedNonCanonicalIDs).To(BeEmpty())
+			Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+			close(done)
+		})
+
+		It("flags diffs from unwatched contracts without re-executing them", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go storageWatcher.Execute(ctx, time.Nanosecond, false)
+
+			Eventually(func() []int64 {
+				return mockStorageDiffRepository.MarkedNonCanonicalIDs
+			}).Should(Equal([]int64{orphanedDiff.ID}))
+			Expect(mockStorageDiffRepository.GetCanonicalPassedHeights).To(BeEmpty())
+			close(done)
+		})
+	})
+
 	Describe("BackFill", func() {
 		var (
 			mockBackFiller       *mocks.BackFiller
+			mockBackFillProgress *mocks.MockBackFillProgress
 			mockTransformer2     *mocks.MockStorageTransformer
 			mockTransformer3     *mocks.MockStorageTransformer
 			createdPersistedDiff = utils.PersistedStorageDiff{
@@ -337,6 +803,7 @@ var _ = Describe("Storage Watcher", func() {
 
 		BeforeEach(func() {
 			mockBackFiller = new(mocks.BackFiller)
+			mockBackFillProgress = &mocks.MockBackFillProgress{}
 			hashedAddress = utils.HexToKeccak256Hash("0x0123456789abcdef")
 			mockFetcher = mocks.NewStorageFetcher()
 			mockQueue = &mocks.MockStorageQueue{}
@@ -355,7 +822,7 @@ var _ = Describe("Storage Watcher", func() {
 					test_data.DeletedExpectedStorageDiff,
 					test_data.UpdatedExpectedStorageDiff2,
 				})
-				mockQueue.DiffsToReturn = []utils.PersistedStorageDiff{}
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{}
 				storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
 				storageWatcher.Queue = mockQueue
 				storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{
@@ -366,11 +833,12 @@ var _ = Describe("Storage Watcher", func() {
 				mockStorageDiffRepository.CreateReturnID = fakeDiffId
 
 				storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+				storageWatcher.BackFillProgress = mockBackFillProgress
 			})
 
 			It("executes transformer for storage diffs received from fetcher and backfiller", func(done Done) {
 				go storageWatcher.BackFill(test_data.BlockNumber.Uint64(), mockBackFiller)
-				go storageWatcher.Execute(time.Hour, true)
+				go storageWatcher.Execute(context.Background(), time.Hour, true)
 
 				Eventually(func() int {
 					return len(mockTransformer.PassedDiffs)
@@ -392,7 +860,7 @@ var _ = Describe("Storage Watcher", func() {
 			It("adds diffs to the queue if transformation fails", func(done Done) {
 				mockTransformer3.ExecuteErr = fakes.FakeError
 				go storageWatcher.BackFill(test_data.BlockNumber.Uint64(), mockBackFiller)
-				go storageWatcher.Execute(time.Hour, true)
+				go storageWatcher.Execute(context.Background(), time.Hour, true)
 
 				Eventually(func() int {
 					return len(mockTransformer.PassedDiffs)
@@ -435,7 +903,7 @@ var _ = Describe("Storage Watcher", func() {
 				}
 
 				go storageWatcher.BackFill(test_data.BlockNumber.Uint64(), mockBackFiller)
-				go storageWatcher.Execute(time.Hour, true)
+				go storageWatcher.Execute(context.Background(), time.Hour, true)
 
 				Eventually(func() int {
 					return len(mockTransformer.PassedDiffs)
@@ -460,7 +928,7 @@ var _ = Describe("Storage Watcher", func() {
 				logrus.SetOutput(tempFile)
 
 				go storageWatcher.BackFill(test_data.BlockNumber.Uint64(), mockBackFiller)
-				go storageWatcher.Execute(time.Hour, true)
+				go storageWatcher.Execute(context.Background(), time.Hour, true)
 
 				Eventually(func() (string, error) {
 					logContent, err := ioutil.ReadFile(tempFile.Name())
@@ -470,17 +938,111 @@ var _ = Describe("Storage Watcher", func() {
 			})
 		})
 
+		Describe("tracking back-fill progress", func() {
+			BeforeEach(func() {
+				mockBackFiller.SetStorageDiffsToReturn([]utils.StorageDiffInput{
+					test_data.CreatedExpectedStorageDiff,
+					test_data.UpdatedExpectedStorageDiff,
+				})
+				storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
+				storageWatcher.Queue = mockQueue
+				storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+				storageWatcher.BackFillProgress = mockBackFillProgress
+				storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{
+					mockTransformer2.FakeTransformerInitializer,
+					mockTransformer3.FakeTransformerInitializer,
+				})
+			})
+
+			It("only back-fills ranges not yet completed for the contracts", func() {
+				mockBackFillProgress.SetIncompleteRanges([][2]uint64{{10, 19}, {30, 39}})
+				hashedAddresses := []common.Hash{mockTransformer2.KeccakOfAddress}
+
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, hashedAddresses)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockBackFillProgress.GetPassedAddresses).To(Equal(hashedAddresses))
+				Expect(mockBackFiller.PassedStartingBlocks).To(Equal([]uint64{10, 30}))
+				Expect(mockBackFiller.PassedEndingBlock).To(Equal(uint64(39)))
+			})
+
+			It("records progress for each completed bin", func() {
+				hashedAddresses := []common.Hash{mockTransformer2.KeccakOfAddress}
+
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, hashedAddresses)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockBackFillProgress.MarkedBins).To(Equal([][2]uint64{{0, 50}}))
+				Expect(mockBackFillProgress.MarkedBinsAddresses).To(Equal([][]common.Hash{hashedAddresses}))
+			})
+
+			It("does not record progress for a bin with errors", func() {
+				mockBackFiller.BackFillErrs = []error{fakes.FakeError}
+
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, []common.Hash{mockTransformer2.KeccakOfAddress})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockBackFillProgress.MarkedBins).To(BeEmpty())
+			})
+
+			It("only transforms diffs for the given contracts", func() {
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, []common.Hash{mockTransformer2.KeccakOfAddress})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockTransformer2.PassedDiffs).To(HaveLen(1))
+				Expect(mockTransformer3.PassedDiffs).To(BeEmpty())
+			})
+
+			It("does not transform back-filled diffs concurrently with Execute", func() {
+				var fetchedDiffs, backFilledDiffs []utils.StorageDiffInput
+				for i := 0; i < 50; i++ {
+					fetchedDiff := test_data.CreatedExpectedStorageDiff
+					fetchedDiff.BlockHeight = i
+					fetchedDiffs = append(fetchedDiffs, fetchedDiff)
+					backFilledDiff := test_data.CreatedExpectedStorageDiff
+					backFilledDiff.BlockHeight = 100 + i
+					backFilledDiffs = append(backFilledDiffs, backFilledDiff)
+				}
+				mockFetcher.DiffsToReturn = fetchedDiffs
+				mockBackFiller.SetStorageDiffsToReturn(backFilledDiffs)
+				ctx, cancel := context.WithCancel(context.Background())
+				executed := make(chan bool)
+				go func() {
+					storageWatcher.Execute(ctx, time.Hour, false)
+					close(executed)
+				}()
+
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, []common.Hash{mockTransformer2.KeccakOfAddress})
+				cancel()
+				<-executed
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(len(mockTransformer2.PassedDiffs)).To(BeNumerically(">=", len(backFilledDiffs)))
+			})
+
+			It("returns an error if progress cannot be loaded", func() {
+				mockBackFillProgress.GetIncompleteErr = fakes.FakeError
+
+				err := storageWatcher.BackFillRange(0, 50, mockBackFiller, nil)
+
+				Expect(err).To(MatchError(ContainSubstring(fakes.FakeError.Error())))
+				Expect(mockBackFiller.PassedStartingBlocks).To(BeEmpty())
+			})
+		})
+
 		Describe("transforms queued storage diffs", func() {
 			BeforeEach(func() {
-				mockQueue.DiffsToReturn = []utils.PersistedStorageDiff{
-					csvPersistedDiff,
-					createdPersistedDiff,
-					updatedPersistedDiff1,
-					deletedPersistedDiff,
-					updatedPersistedDiff2,
+				mockQueue.DiffsToReturn = []storage.QueuedStorageDiff{
+					{PersistedStorageDiff: csvPersistedDiff},
+					{PersistedStorageDiff: createdPersistedDiff},
+					{PersistedStorageDiff: updatedPersistedDiff1},
+					{PersistedStorageDiff: deletedPersistedDiff},
+					{PersistedStorageDiff: updatedPersistedDiff2},
 				}
 				storageWatcher = watcher.NewStorageWatcher(mockFetcher, test_config.NewTestDB(test_config.NewTestNode()))
 				storageWatcher.Queue = mockQueue
+				storageWatcher.StorageDiffRepository = mockStorageDiffRepository
+				storageWatcher.BackFillProgress = mockBackFillProgress
 				storageWatcher.AddTransformers([]transformer.StorageTransformerInitializer{
 					mockTransformer.FakeTransformerInitializer,
 					mockTransformer2.FakeTransformerInitializer,
@@ -490,7 +1052,7 @@ var _ = Describe("Storage Watcher", func() {
 
 			It("executes transformers on queued storage diffs", func(done Done) {
 				go storageWatcher.BackFill(test_data.BlockNumber.Uint64(), mockBackFiller)
-				go storageWatcher.Execute(time.Nanosecond, true)
+				go storageWatcher.Execute(context.Background(), time.Nanosecond, true)
 
 				Eventually(func() int {
 					return len(mockTransformer.PassedDiffs)
@@ -502,7 +1064,7 @@ var _ = Describe("Storage Watcher", func() {
 					return len(mockTransformer3.PassedDiffs)
 				}).Should(Equal(3))
 				Eventually(func() bool {
-					return mockQueue.GetAllCalled
+					return mockQueue.GetDueCalled
 				}).Should(BeTrue())
 				expectedIDs := []int64{
 					fakeDiffId,
@@ -512,8 +1074,8 @@ var _ = Describe("Storage Watcher", func() {
 					fakeDiffId,
 				}
 				Eventually(func() []int64 {
-					if len(mockQueue.DeletePassedIds) > 4 {
-						return mockQueue.DeletePassedIds
+					if len(mockQueue.DeleteForTransformerIds) > 4 {
+						return mockQueue.DeleteForTransformerIds
 					}
 					return []int64{}
 				}).Should(Equal(expectedIDs))
@@ -0,0 +1,128 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/transformer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+// DefaultSuperNodeRestartPause is how long a failed super node transformer waits before it is restarted
+const DefaultSuperNodeRestartPause = time.Second * 10
+
+// DefaultSuperNodeMaxRestarts is how many times in a row a failing super node transformer is restarted before the
+// watcher gives up on it
+const DefaultSuperNodeMaxRestarts = 10
+
+// SuperNodeWatcher runs transformers that subscribe to a super node themselves, each with its own filters
+type SuperNodeWatcher struct {
+	DB           *postgres.DB
+	Client       core.RPCClient // super node the transformers subscribe to
+	Transformers map[string]transformer.SuperNodeTransformer
+	RestartPause time.Duration
+	MaxRestarts  int // consecutive restarts of a transformer that fails within the restart pause of starting
+}
+
+func NewSuperNodeWatcher(db *postgres.DB, client core.RPCClient) SuperNodeWatcher {
+	return SuperNodeWatcher{
+		DB:           db,
+		Client:       client,
+		Transformers: make(map[string]transformer.SuperNodeTransformer),
+		RestartPause: DefaultSuperNodeRestartPause,
+		MaxRestarts:  DefaultSuperNodeMaxRestarts,
+	}
+}
+
+// AddTransformer constructs a transformer that subscribes with the given filters
+func (watcher *SuperNodeWatcher) AddTransformer(name string, initializer transformer.SuperNodeTransformerInitializer, subscription config.Subscription) {
+	watcher.Transformers[name] = initializer(watcher.DB, subscription, watcher.Client)
+}
+
+// Execute runs each transformer concurrently until its Execute returns without error, such as at the ending block of
+// its subscription. A transformer whose Init or Execute fails is restarted from Init after the restart pause, unless it
+// has already failed MaxRestarts times in a row without running for longer than the pause, in which case Execute
+// returns its error. Otherwise Execute returns when every transformer is done or the context is cancelled; since
+// transformers can't be cancelled, one still executing then is left to stop with the process.
+func (watcher *SuperNodeWatcher) Execute(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(watcher.Transformers))
+	for name, superNodeTransformer := range watcher.Transformers {
+		wg.Add(1)
+		go func(name string, superNodeTransformer transformer.SuperNodeTransformer) {
+			defer wg.Done()
+			err := watcher.run(ctx, name, superNodeTransformer)
+			if err != nil {
+				errs <- err
+			}
+		}(name, superNodeTransformer)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	case <-done:
+		select {
+		case err := <-errs:
+			return err
+		default:
+			return nil
+		}
+	}
+}
+
+func (watcher *SuperNodeWatcher) run(ctx context.Context, name string, superNodeTransformer transformer.SuperNodeTransformer) error {
+	restarts := 0
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := superNodeTransformer.Init()
+		if err != nil {
+			err = fmt.Errorf("failed to initialize super node transformer %s: %s", name, err.Error())
+		} else {
+			err = superNodeTransformer.Execute()
+			if err == nil {
+				logrus.Infof("super node transformer %s finished", name)
+				return nil
+			}
+			err = fmt.Errorf("super node transformer %s failed: %s", name, err.Error())
+		}
+		if time.Since(started) > watcher.RestartPause {
+			restarts = 0
+		}
+		if restarts >= watcher.MaxRestarts {
+			return fmt.Errorf("%s, giving up after %d restarts", err.Error(), restarts)
+		}
+		restarts++
+		logrus.Errorf("%s, restarting in %s", err.Error(), watcher.RestartPause)
+		pause(ctx, watcher.RestartPause)
+	}
+	return nil
+}
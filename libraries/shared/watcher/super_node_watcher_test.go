@@ -0,0 +1,123 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package watcher_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/mocks"
+	"github.com/vulcanize/vulcanizedb/libraries/shared/watcher"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Super node watcher", func() {
+	var (
+		mockTransformer  *mocks.MockSuperNodeTransformer
+		client           *fakes.MockRPCClient
+		superNodeWatcher watcher.SuperNodeWatcher
+	)
+
+	BeforeEach(func() {
+		mockTransformer = &mocks.MockSuperNodeTransformer{}
+		client = fakes.NewMockRPCClient()
+		superNodeWatcher = watcher.NewSuperNodeWatcher(&postgres.DB{}, client)
+		superNodeWatcher.RestartPause = time.Millisecond
+	})
+
+	It("constructs transformers with their subscription and the super node client", func() {
+		subscription := config.Subscription{StartingBlock: big.NewInt(10), EndingBlock: big.NewInt(20)}
+
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, subscription)
+
+		Expect(superNodeWatcher.Transformers).To(HaveKey("transformer"))
+		Expect(mockTransformer.PassedSubscription).To(Equal(subscription))
+		Expect(mockTransformer.PassedClient).To(Equal(client))
+	})
+
+	It("initializes and executes each transformer until it finishes", func() {
+		otherTransformer := &mocks.MockSuperNodeTransformer{}
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, config.Subscription{})
+		superNodeWatcher.AddTransformer("other", otherTransformer.FakeTransformerInitializer, config.Subscription{})
+
+		err := superNodeWatcher.Execute(context.Background())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockTransformer.InitCalls()).To(Equal(1))
+		Expect(mockTransformer.ExecuteCalls()).To(Equal(1))
+		Expect(otherTransformer.InitCalls()).To(Equal(1))
+		Expect(otherTransformer.ExecuteCalls()).To(Equal(1))
+	})
+
+	It("restarts a transformer that fails to execute", func() {
+		mockTransformer.ExecuteErrs = []error{fakes.FakeError, fakes.FakeError}
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, config.Subscription{})
+
+		err := superNodeWatcher.Execute(context.Background())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockTransformer.InitCalls()).To(Equal(3))
+		Expect(mockTransformer.ExecuteCalls()).To(Equal(3))
+	})
+
+	It("returns an error when a transformer keeps failing", func() {
+		mockTransformer.ExecuteErrs = []error{fakes.FakeError, fakes.FakeError, fakes.FakeError}
+		superNodeWatcher.MaxRestarts = 2
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, config.Subscription{})
+
+		err := superNodeWatcher.Execute(context.Background())
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("transformer"))
+		Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+		Expect(mockTransformer.ExecuteCalls()).To(Equal(3))
+	})
+
+	It("retries initializing a transformer that fails to initialize", func() {
+		mockTransformer.InitErrs = []error{fakes.FakeError}
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, config.Subscription{})
+
+		superNodeWatcher.Execute(context.Background())
+
+		Expect(mockTransformer.InitCalls()).To(Equal(2))
+		Expect(mockTransformer.ExecuteCalls()).To(Equal(1))
+	})
+
+	It("stops restarting transformers when the context is cancelled", func() {
+		mockTransformer.ExecuteErrs = []error{fakes.FakeError, fakes.FakeError, fakes.FakeError}
+		superNodeWatcher.RestartPause = time.Hour
+		superNodeWatcher.AddTransformer("transformer", mockTransformer.FakeTransformerInitializer, config.Subscription{})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			superNodeWatcher.Execute(ctx)
+			close(done)
+		}()
+		Eventually(mockTransformer.ExecuteCalls).Should(Equal(1))
+		cancel()
+
+		Eventually(done).Should(BeClosed())
+		Consistently(mockTransformer.ExecuteCalls).Should(Equal(1))
+	})
+})
@@ -65,11 +65,23 @@ type ContractConfig struct {
 
 	// Map of contract address to whether or not to pipe method polling results forward into subsequent method calls
 	Piping map[string]bool
+
+	// Address of an on-chain Multicall aggregator contract to batch method polling calls through
+	// If none is provided method polling calls are batched into JSON-RPC batch requests
+	Multicall string
+
+	// Maximum number of method polling calls in a single batch, and of batches in flight at once
+	// Defaults are used if these are not set
+	PollingBatchSize   int
+	PollingConcurrency int
 }
 
 func (contractConfig *ContractConfig) PrepConfig() {
 	addrs := viper.GetStringSlice("contract.addresses")
 	contractConfig.Network = viper.GetString("contract.network")
+	contractConfig.Multicall = viper.GetString("contract.multicall")
+	contractConfig.PollingBatchSize = viper.GetInt("contract.pollingBatchSize")
+	contractConfig.PollingConcurrency = viper.GetInt("contract.pollingConcurrency")
 	contractConfig.Addresses = make(map[string]bool, len(addrs))
 	contractConfig.Abis = make(map[string]string, len(addrs))
 	contractConfig.Methods = make(map[string][]string, len(addrs))
@@ -19,8 +19,10 @@ package config
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 )
@@ -31,16 +33,24 @@ type Plugin struct {
 	FileName     string
 	Save         bool
 	Home         string
+	Mode         Mode
+	Race         bool // build an executable with the race detector
 }
 
 type Transformer struct {
-	Path           string
-	Type           TransformerType
-	MigrationPath  string
-	MigrationRank  uint64
-	RepositoryPath string
+	Path                string
+	Type                TransformerType
+	MigrationPath       string
+	MigrationRank       uint64
+	MigrationSchema     string // schema the transformer's migrations create unqualified objects in, public if empty
+	RepositoryPath      string
+	RepositoryVersion   string       // module version the repository is pinned at
+	RepositoryDirectory string       // local source of the repository, used if it isn't pinned at a version
+	Subscription        Subscription // super node data an eth_super_node transformer is constructed to subscribe to
 }
 
+// Returns the paths of the plugin's .go file and the file it is built into: a .so file, or an executable in RPC and
+// static modes
 func (pluginConfig *Plugin) GetPluginPaths() (string, string, error) {
 	path, err := helpers.CleanPath(pluginConfig.FilePath)
 	if err != nil {
@@ -50,6 +60,9 @@ func (pluginConfig *Plugin) GetPluginPaths() (string, string, error) {
 	name := strings.Split(pluginConfig.FileName, ".")[0]
 	goFile := filepath.Join(path, name+".go")
 	soFile := filepath.Join(path, name+".so")
+	if pluginConfig.Mode.BuildsExecutable() {
+		soFile = filepath.Join(path, name)
+	}
 
 	return goFile, soFile, nil
 }
@@ -59,13 +72,11 @@ func (pluginConfig *Plugin) GetMigrationsPaths() ([]string, error) {
 	paths := make(map[uint64]string)
 	highestRank := -1
 	for name, transformer := range pluginConfig.Transformers {
-		repo := transformer.RepositoryPath
-		mig := transformer.MigrationPath
-		path := filepath.Join("$GOPATH/src", pluginConfig.Home, "vendor", repo, mig)
-		cleanPath, err := helpers.CleanPath(path)
+		repoDir, err := transformer.RepositoryDir()
 		if err != nil {
 			return nil, err
 		}
+		cleanPath := filepath.Join(repoDir, transformer.MigrationPath)
 		// If there is a different path with the same rank then we have a conflict
 		_, ok := paths[transformer.MigrationRank]
 		if ok {
@@ -95,6 +106,68 @@ func (pluginConfig *Plugin) GetMigrationsPaths() ([]string, error) {
 	return sortedPaths, nil
 }
 
+// Returns the version each repo is pinned at, which must be the same for all of the repo's transformers, as must the
+// directory of a repo that isn't pinned
+func (pluginConfig *Plugin) GetRepoVersions() (map[string]string, error) {
+	versions := make(map[string]string)
+	directories := make(map[string]string)
+	for name, transformer := range pluginConfig.Transformers {
+		version, ok := versions[transformer.RepositoryPath]
+		if ok && version != transformer.RepositoryVersion {
+			return nil, fmt.Errorf("transformer %s pins %s at version %q, but another transformer pins it at %q",
+				name, transformer.RepositoryPath, transformer.RepositoryVersion, version)
+		}
+		directory, ok := directories[transformer.RepositoryPath]
+		if ok && directory != transformer.RepositoryDirectory {
+			return nil, fmt.Errorf("transformer %s reads %s from directory %q, but another transformer reads it from %q",
+				name, transformer.RepositoryPath, transformer.RepositoryDirectory, directory)
+		}
+		versions[transformer.RepositoryPath] = transformer.RepositoryVersion
+		directories[transformer.RepositoryPath] = transformer.RepositoryDirectory
+	}
+	return versions, nil
+}
+
+// ImportPath is the path of the package exporting the transformer's initializer
+func (transformer Transformer) ImportPath() string {
+	return transformer.RepositoryPath + "/" + transformer.Path
+}
+
+// RepositoryDir returns where the transformer's repository source is found: in the module cache if it is
+// pinned at a version, otherwise in its configured directory
+func (transformer Transformer) RepositoryDir() (string, error) {
+	if transformer.RepositoryVersion == "" {
+		if transformer.RepositoryDirectory == "" {
+			return "", fmt.Errorf("transformer repository %s needs a version to pin it at or a directory to read it from",
+				transformer.RepositoryPath)
+		}
+		return helpers.CleanPath(transformer.RepositoryDirectory)
+	}
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		var err error
+		modCache, err = helpers.CleanPath("$GOPATH/pkg/mod")
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(modCache, escapeModulePath(transformer.RepositoryPath)+"@"+escapeModulePath(transformer.RepositoryVersion)), nil
+}
+
+// escapeModulePath escapes upper case letters as the module cache does, e.g. github.com/!burnt!sushi
+func escapeModulePath(path string) string {
+	var escaped strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			escaped.WriteRune('!')
+			escaped.WriteRune(unicode.ToLower(r))
+		} else {
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
 // Removes duplicate repo paths before returning them
 func (pluginConfig *Plugin) GetRepoPaths() map[string]bool {
 	paths := make(map[string]bool)
@@ -112,6 +185,7 @@ const (
 	EthEvent
 	EthStorage
 	EthContract
+	EthSuperNode
 )
 
 func (transformerType TransformerType) String() string {
@@ -120,20 +194,38 @@ func (transformerType TransformerType) String() string {
 		"eth_event",
 		"eth_storage",
 		"eth_contract",
+		"eth_super_node",
 	}
 
-	if transformerType > EthContract || transformerType < EthEvent {
+	if transformerType > EthSuperNode || transformerType < EthEvent {
 		return "Unknown"
 	}
 
 	return names[transformerType]
 }
 
+// InitializerSymbol is the name of the variable a transformer package of this type exports its initializer as
+func (transformerType TransformerType) InitializerSymbol() string {
+	switch transformerType {
+	case EthEvent:
+		return "EventTransformerInitializer"
+	case EthStorage:
+		return "StorageTransformerInitializer"
+	case EthContract:
+		return "ContractTransformerInitializer"
+	case EthSuperNode:
+		return "SuperNodeTransformerInitializer"
+	default:
+		return ""
+	}
+}
+
 func GetTransformerType(str string) TransformerType {
 	types := [...]TransformerType{
 		EthEvent,
 		EthStorage,
 		EthContract,
+		EthSuperNode,
 	}
 
 	for _, ty := range types {
@@ -145,6 +237,49 @@ func GetTransformerType(str string) TransformerType {
 	return UnknownTransformerType
 }
 
+// Mode is how a plugin's transformers are run: linked into vulcanizedb as a Go plugin, in a separate process that
+// vulcanizedb calls over RPC, or compiled into a static vulcanizedb binary
+type Mode int
+
+const (
+	GoPluginMode Mode = iota
+	RPCMode
+	StaticMode
+	UnknownMode
+)
+
+// BuildsExecutable is true for modes that build an executable instead of a .so file
+func (mode Mode) BuildsExecutable() bool {
+	return mode == RPCMode || mode == StaticMode
+}
+
+func (mode Mode) String() string {
+	switch mode {
+	case GoPluginMode:
+		return "plugin"
+	case RPCMode:
+		return "rpc"
+	case StaticMode:
+		return "static"
+	default:
+		return "Unknown"
+	}
+}
+
+// GetMode returns the mode with the given name, defaulting to a Go plugin
+func GetMode(str string) Mode {
+	switch str {
+	case "", GoPluginMode.String():
+		return GoPluginMode
+	case RPCMode.String():
+		return RPCMode
+	case StaticMode.String():
+		return StaticMode
+	default:
+		return UnknownMode
+	}
+}
+
 func anyDupes(list map[uint64]string) bool {
 	seen := make([]string, 0, len(list))
 	for _, str := range list {
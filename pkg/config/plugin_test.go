@@ -28,25 +28,28 @@ import (
 var allDifferentPathsConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path2",
-			MigrationRank:  2,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path2",
+			MigrationRank:       2,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -54,25 +57,28 @@ var allDifferentPathsConfig = config.Plugin{
 var overlappingPathsConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -80,25 +86,28 @@ var overlappingPathsConfig = config.Plugin{
 var conflictErrorConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path2",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path2",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -106,25 +115,28 @@ var conflictErrorConfig = config.Plugin{
 var gapErrorConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path2",
-			MigrationRank:  3,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path2",
+			MigrationRank:       3,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -132,24 +144,27 @@ var gapErrorConfig = config.Plugin{
 var missingRankErrorConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path2",
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path2",
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -157,25 +172,28 @@ var missingRankErrorConfig = config.Plugin{
 var duplicateErrorConfig = config.Plugin{
 	Transformers: map[string]config.Transformer{
 		"transformer1": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			MigrationRank:  0,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			MigrationRank:       0,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 		"transformer2": {
-			Path:           "test/init/path",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path1",
-			RepositoryPath: "test/repo/path",
-			MigrationRank:  2,
+			Path:                "test/init/path",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path1",
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
+			MigrationRank:       2,
 		},
 		"transformer3": {
-			Path:           "test/init/path2",
-			Type:           config.EthEvent,
-			MigrationPath:  "test/migration/path3",
-			MigrationRank:  1,
-			RepositoryPath: "test/repo/path",
+			Path:                "test/init/path2",
+			Type:                config.EthEvent,
+			MigrationPath:       "test/migration/path3",
+			MigrationRank:       1,
+			RepositoryPath:      "test/repo/path",
+			RepositoryDirectory: "$GOPATH/src/test/repo/path",
 		},
 	},
 }
@@ -188,9 +206,9 @@ var _ = Describe("GetMigrationsPaths", func() {
 		Expect(len(migrationPaths)).To(Equal(3))
 
 		env := os.Getenv("GOPATH")
-		path1 := filepath.Join(env, "src/vendor/test/repo/path/test/migration/path1")
-		path2 := filepath.Join(env, "src/vendor/test/repo/path/test/migration/path3")
-		path3 := filepath.Join(env, "src/vendor/test/repo/path/test/migration/path2")
+		path1 := filepath.Join(env, "src/test/repo/path/test/migration/path1")
+		path2 := filepath.Join(env, "src/test/repo/path/test/migration/path3")
+		path3 := filepath.Join(env, "src/test/repo/path/test/migration/path2")
 		expectedMigrationPaths := []string{path1, path2, path3}
 		Expect(migrationPaths).To(Equal(expectedMigrationPaths))
 	})
@@ -202,8 +220,8 @@ var _ = Describe("GetMigrationsPaths", func() {
 		Expect(len(migrationPaths)).To(Equal(2))
 
 		env := os.Getenv("GOPATH")
-		path1 := filepath.Join(env, "src/vendor/test/repo/path/test/migration/path1")
-		path2 := filepath.Join(env, "src/vendor/test/repo/path/test/migration/path3")
+		path1 := filepath.Join(env, "src/test/repo/path/test/migration/path1")
+		path2 := filepath.Join(env, "src/test/repo/path/test/migration/path3")
 		expectedMigrationPaths := []string{path1, path2}
 		Expect(migrationPaths).To(Equal(expectedMigrationPaths))
 	})
@@ -240,3 +258,144 @@ var _ = Describe("GetMigrationsPaths", func() {
 		Expect(err.Error()).To(ContainSubstring("duplicate paths with different ranks present"))
 	})
 })
+
+var _ = Describe("GetRepoVersions", func() {
+	It("returns the version each repo is pinned at", func() {
+		plugin := config.Plugin{Transformers: map[string]config.Transformer{
+			"transformer1": {RepositoryPath: "test/repo/path", RepositoryVersion: "v1.2.3"},
+			"transformer2": {RepositoryPath: "test/repo/path", RepositoryVersion: "v1.2.3"},
+			"transformer3": {RepositoryPath: "test/repo/path2"},
+		}}
+
+		versions, err := plugin.GetRepoVersions()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(Equal(map[string]string{"test/repo/path": "v1.2.3", "test/repo/path2": ""}))
+	})
+
+	It("Fails if a repo is pinned at different versions", func() {
+		plugin := config.Plugin{Transformers: map[string]config.Transformer{
+			"transformer1": {RepositoryPath: "test/repo/path", RepositoryVersion: "v1.2.3"},
+			"transformer2": {RepositoryPath: "test/repo/path", RepositoryVersion: "v1.3.0"},
+		}}
+
+		_, err := plugin.GetRepoVersions()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("test/repo/path"))
+	})
+
+	It("fails if a repo is read from more than one directory", func() {
+		plugin := config.Plugin{Transformers: map[string]config.Transformer{
+			"transformer1": {RepositoryPath: "test/repo/path", RepositoryDirectory: "/src/path"},
+			"transformer2": {RepositoryPath: "test/repo/path", RepositoryDirectory: "/src/other"},
+		}}
+
+		_, err := plugin.GetRepoVersions()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("test/repo/path"))
+	})
+})
+
+var _ = Describe("RepositoryDir", func() {
+	It("finds repos without a version in their directory", func() {
+		transformer := config.Transformer{RepositoryPath: "github.com/account/repo", RepositoryDirectory: "$GOPATH/src/github.com/account/repo"}
+
+		dir, err := transformer.RepositoryDir()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dir).To(Equal(filepath.Join(os.Getenv("GOPATH"), "src/github.com/account/repo")))
+	})
+
+	It("returns error for repos without a version or directory", func() {
+		transformer := config.Transformer{RepositoryPath: "github.com/account/repo"}
+
+		_, err := transformer.RepositoryDir()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("github.com/account/repo"))
+	})
+
+	It("finds pinned repos in the module cache", func() {
+		if os.Getenv("GOMODCACHE") != "" {
+			Skip("module cache location is overridden")
+		}
+		transformer := config.Transformer{RepositoryPath: "github.com/Account/repo", RepositoryVersion: "v1.2.3"}
+
+		dir, err := transformer.RepositoryDir()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dir).To(Equal(filepath.Join(os.Getenv("GOPATH"), "pkg/mod/github.com/!account/repo@v1.2.3")))
+	})
+})
+
+var _ = Describe("GetPluginPaths", func() {
+	It("builds a Go plugin into a .so file", func() {
+		plugin := config.Plugin{FilePath: "/plugins", FileName: "exporter"}
+
+		goFile, soFile, err := plugin.GetPluginPaths()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(goFile).To(Equal("/plugins/exporter.go"))
+		Expect(soFile).To(Equal("/plugins/exporter.so"))
+	})
+
+	It("builds an RPC plugin into an executable", func() {
+		plugin := config.Plugin{FilePath: "/plugins", FileName: "exporter", Mode: config.RPCMode}
+
+		_, executable, err := plugin.GetPluginPaths()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executable).To(Equal("/plugins/exporter"))
+	})
+
+	It("builds a static binary into an executable", func() {
+		plugin := config.Plugin{FilePath: "/plugins", FileName: "exporter", Mode: config.StaticMode}
+
+		_, executable, err := plugin.GetPluginPaths()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executable).To(Equal("/plugins/exporter"))
+	})
+})
+
+var _ = Describe("GetMode", func() {
+	It("defaults to a Go plugin", func() {
+		Expect(config.GetMode("")).To(Equal(config.GoPluginMode))
+		Expect(config.GetMode("plugin")).To(Equal(config.GoPluginMode))
+	})
+
+	It("returns RPC mode", func() {
+		Expect(config.GetMode("rpc")).To(Equal(config.RPCMode))
+	})
+
+	It("returns static mode", func() {
+		Expect(config.GetMode("static")).To(Equal(config.StaticMode))
+	})
+
+	It("returns an unknown mode for other names", func() {
+		Expect(config.GetMode("grpc")).To(Equal(config.UnknownMode))
+	})
+})
+
+var _ = Describe("InitializerSymbol", func() {
+	It("returns the initializer each transformer type is exported as", func() {
+		Expect(config.EthEvent.InitializerSymbol()).To(Equal("EventTransformerInitializer"))
+		Expect(config.EthStorage.InitializerSymbol()).To(Equal("StorageTransformerInitializer"))
+		Expect(config.EthContract.InitializerSymbol()).To(Equal("ContractTransformerInitializer"))
+		Expect(config.EthSuperNode.InitializerSymbol()).To(Equal("SuperNodeTransformerInitializer"))
+		Expect(config.UnknownTransformerType.InitializerSymbol()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("GetTransformerType", func() {
+	It("returns the transformer type with the given name", func() {
+		Expect(config.GetTransformerType("eth_super_node")).To(Equal(config.EthSuperNode))
+		Expect(config.EthSuperNode.String()).To(Equal("eth_super_node"))
+	})
+
+	It("returns an unknown transformer type for other names", func() {
+		Expect(config.GetTransformerType("eth_trace")).To(Equal(config.UnknownTransformerType))
+	})
+})
@@ -57,6 +57,7 @@ type StateFilter struct {
 type StorageFilter struct {
 	Off               bool
 	Addresses         []string
+	StateKeys         []string // keccak256 hashes of contract addresses, for subscribers that only know those
 	StorageKeys       []string
 	IntermediateNodes bool
 }
@@ -63,8 +63,14 @@ type Transformer struct {
 
 // NewTransformer takes in contract config, blockchain, and database, and returns a new Transformer
 func NewTransformer(con config.ContractConfig, BC core.BlockChain, DB *postgres.DB) *Transformer {
+	pollerSettings := poller.Settings{
+		Multicall:   con.Multicall,
+		BatchSize:   con.PollingBatchSize,
+		Concurrency: con.PollingConcurrency,
+	}
+
 	return &Transformer{
-		Poller:                     poller.NewPoller(BC, DB, types.FullSync),
+		Poller:                     poller.NewPoller(BC, DB, types.FullSync, pollerSettings),
 		Parser:                     parser.NewParser(con.Network),
 		Retriever:                  retriever.NewBlockRetriever(DB),
 		Converter:                  &converter.Converter{},
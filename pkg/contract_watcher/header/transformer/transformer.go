@@ -80,9 +80,14 @@ type Transformer struct {
 
 // NewTransformer takes in a contract config, blockchain, and database, and returns a new Transformer
 func NewTransformer(con config.ContractConfig, bc core.BlockChain, db *postgres.DB) *Transformer {
+	pollerSettings := poller.Settings{
+		Multicall:   con.Multicall,
+		BatchSize:   con.PollingBatchSize,
+		Concurrency: con.PollingConcurrency,
+	}
 
 	return &Transformer{
-		Poller:           poller.NewPoller(bc, db, types.HeaderSync),
+		Poller:           poller.NewPoller(bc, db, types.HeaderSync, pollerSettings),
 		Fetcher:          fetcher.NewFetcher(bc),
 		Parser:           parser.NewParser(con.Network),
 		HeaderRepository: repository.NewHeaderRepository(db),
@@ -18,6 +18,9 @@ package contract
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -166,6 +169,13 @@ func StringifyArg(arg interface{}) (str string) {
 	case []byte:
 		a := arg.([]byte)
 		str = hexutil.Encode(a)
+	case *big.Int:
+		a := arg.(*big.Int)
+		str = a.String()
+	case bool:
+		str = strconv.FormatBool(arg.(bool))
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		str = fmt.Sprintf("%d", arg)
 	}
 
 	return
@@ -17,6 +17,9 @@
 package contract_test
 
 import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -233,4 +236,19 @@ var _ = Describe("Contract", func() {
 			Expect(b).To(Equal(false))
 		})
 	})
+
+	Describe("StringifyArg", func() {
+		It("Stringifies address, hash and byte arguments as hex", func() {
+			Expect(contract.StringifyArg(common.HexToAddress("0xfE9e8709d3215310075d67E3ed32A380CCf451C8"))).To(Equal("0xfE9e8709d3215310075d67E3ed32A380CCf451C8"))
+			Expect(contract.StringifyArg(common.HexToHash("0x01"))).To(Equal("0x0000000000000000000000000000000000000000000000000000000000000001"))
+			Expect(contract.StringifyArg([]byte{1, 2})).To(Equal("0x0102"))
+		})
+
+		It("Stringifies numeric and boolean arguments", func() {
+			Expect(contract.StringifyArg(big.NewInt(1000000))).To(Equal("1000000"))
+			Expect(contract.StringifyArg(uint8(18))).To(Equal("18"))
+			Expect(contract.StringifyArg(int64(-5))).To(Equal("-5"))
+			Expect(contract.StringifyArg(true)).To(Equal("true"))
+		})
+	})
 })
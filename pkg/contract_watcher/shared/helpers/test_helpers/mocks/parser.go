@@ -122,15 +122,17 @@ func stringInSlice(list []string, s string) bool {
 }
 
 func okTypes(m abi.Method, wanted []string) bool {
-	// Only return method if it has less than 3 arguments, a single output value, and it is a method we want or we want all methods (empty 'wanted' slice)
-	if len(m.Inputs) < 3 && len(m.Outputs) == 1 && (len(wanted) == 0 || stringInSlice(wanted, m.Name)) {
-		// Only return methods if inputs are all of accepted types and output is of the accepted types
-		if !okReturnType(m.Outputs[0]) {
-			return false
+	// Only return method if it has at least one output value, and it is a method we want or we want all methods (empty 'wanted' slice)
+	if len(m.Outputs) > 0 && (len(wanted) == 0 || stringInSlice(wanted, m.Name)) {
+		// Only return methods if inputs are all of accepted types and outputs are of the accepted types
+		for _, output := range m.Outputs {
+			if !okReturnType(output) {
+				return false
+			}
 		}
 		for _, input := range m.Inputs {
 			switch input.Type.T {
-			case abi.AddressTy, abi.HashTy, abi.BytesTy, abi.FixedBytesTy:
+			case abi.AddressTy, abi.HashTy, abi.BytesTy, abi.FixedBytesTy, abi.UintTy, abi.IntTy, abi.BoolTy, abi.StringTy:
 			default:
 				return false
 			}
@@ -153,6 +155,9 @@ func okReturnType(arg abi.Argument) bool {
 		abi.BytesTy,
 		abi.FixedBytesTy,
 		abi.FixedPointTy,
+		abi.ArrayTy,
+		abi.SliceTy,
+		abi.TupleTy,
 	}
 
 	for _, ty := range wantedTypes {
@@ -172,6 +172,9 @@ func okReturnType(arg abi.Argument) bool {
 		abi.BytesTy,
 		abi.FixedBytesTy,
 		abi.FixedPointTy,
+		abi.ArrayTy,
+		abi.SliceTy,
+		abi.TupleTy,
 	}
 
 	for _, ty := range wantedTypes {
@@ -184,18 +187,21 @@ func okReturnType(arg abi.Argument) bool {
 }
 
 func okTypes(m abi.Method, wanted []string) bool {
-	// Only return method if it has less than 3 arguments, a single output value, and it is a method we want or we want all methods (empty 'wanted' slice)
-	if len(m.Inputs) < 3 && len(m.Outputs) == 1 && (len(wanted) == 0 || stringInSlice(wanted, m.Name)) {
-		// Only return methods if inputs are all of accepted types and output is of the accepted types
-		if !okReturnType(m.Outputs[0]) {
-			return false
+	// Only return method if it has at least one output value, and it is a method we want or we want all methods (empty 'wanted' slice)
+	if len(m.Outputs) > 0 && (len(wanted) == 0 || stringInSlice(wanted, m.Name)) {
+		// Only return methods if inputs are all of accepted types and outputs are of the accepted types
+		for _, output := range m.Outputs {
+			if !okReturnType(output) {
+				return false
+			}
 		}
 		for _, input := range m.Inputs {
 			switch input.Type.T {
 			// Addresses are properly labeled and caught
 			// But hashes tend to not be explicitly labeled and caught
 			// Instead bytes32 are assumed to be hashes
-			case abi.AddressTy, abi.HashTy:
+			// Addresses and hashes are collected from emitted values, the rest are sourced from the configured method args
+			case abi.AddressTy, abi.HashTy, abi.UintTy, abi.IntTy, abi.BoolTy, abi.StringTy:
 			case abi.FixedBytesTy:
 				if input.Type.Size != 32 {
 					return false
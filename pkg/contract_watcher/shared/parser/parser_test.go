@@ -174,6 +174,40 @@ var _ = Describe("Parser", func() {
 			Expect(selectMethods).To(Equal(nilArr))
 		})
 
+		It("Returns methods with any number of arguments and return values", func() {
+			err = p.ParseAbiStr(naryAbi)
+			Expect(err).ToNot(HaveOccurred())
+
+			selectMethods := p.GetSelectMethods([]string{"position", "reserves", "checkpoint"})
+			Expect(len(selectMethods)).To(Equal(3))
+
+			position := selectMethods[0]
+			Expect(position.Name).To(Equal("position"))
+			Expect(len(position.Args)).To(Equal(3))
+			Expect(position.Args[2].PgType).To(Equal("BOOLEAN"))
+			Expect(len(position.Return)).To(Equal(1))
+			Expect(position.ReturnColumns()).To(Equal([]string{"returned"}))
+
+			reserves := selectMethods[1]
+			Expect(reserves.Name).To(Equal("reserves"))
+			Expect(len(reserves.Return)).To(Equal(3))
+			Expect(reserves.Return[0].PgType).To(Equal("NUMERIC"))
+			Expect(reserves.ReturnColumns()).To(Equal([]string{"returned_reserve0", "returned_reserve1", "returned_2"}))
+
+			checkpoint := selectMethods[2]
+			Expect(checkpoint.Name).To(Equal("checkpoint"))
+			Expect(checkpoint.Return[0].Type.T).To(Equal(abi.TupleTy))
+			Expect(checkpoint.Return[0].PgType).To(Equal("JSONB"))
+		})
+
+		It("Does not return methods with unsupported argument types", func() {
+			err = p.ParseAbiStr(naryAbi)
+			Expect(err).ToNot(HaveOccurred())
+
+			selectMethods := p.GetSelectMethods([]string{"batch"})
+			Expect(len(selectMethods)).To(Equal(1))
+			Expect(selectMethods[0].Name).To(Equal(""))
+		})
 	})
 
 	Describe("GetMethods", func() {
@@ -224,3 +258,10 @@ var _ = Describe("Parser", func() {
 		})
 	})
 })
+
+var naryAbi = `[
+	{"constant":true,"inputs":[{"name":"owner","type":"address"},{"name":"id","type":"uint256"},{"name":"open","type":"bool"}],"name":"position","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"reserves","outputs":[{"name":"reserve0","type":"uint112"},{"name":"reserve1","type":"uint112"},{"name":"","type":"uint32"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[{"name":"account","type":"address"}],"name":"checkpoint","outputs":[{"components":[{"name":"fromBlock","type":"uint32"},{"name":"votes","type":"uint96"}],"name":"","type":"tuple"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[{"name":"accounts","type":"address[]"}],"name":"batch","outputs":[{"name":"","type":"uint256[]"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
@@ -0,0 +1,37 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package poller
+
+import (
+	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/contract"
+	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/types"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+)
+
+// Exposes the poller's helpers to its specs
+var (
+	Combine         = combine
+	ParseArg        = parseArg
+	StringifyReturn = stringifyReturn
+)
+
+// FetchBatches makes the calls for the contract with a poller using the given settings
+func FetchBatches(blockChain core.BlockChain, con contract.Contract, settings Settings, calls []core.ContractCall, blockNumber int64) error {
+	p := NewPoller(blockChain, nil, types.FullSync, settings).(*poller)
+	p.contract = con
+	return p.fetchBatches(calls, blockNumber)
+}
@@ -17,10 +17,15 @@
 package poller
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -31,6 +36,7 @@ import (
 	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/types"
 	"github.com/vulcanize/vulcanizedb/pkg/core"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
 )
 
 // Poller is the interface for polling public contract methods
@@ -40,17 +46,44 @@ type Poller interface {
 	FetchContractData(contractAbi, contractAddress, method string, methodArgs []interface{}, result interface{}, blockNumber int64) error
 }
 
+// Default batching of contract calls, used when not set in the Settings
+const (
+	DefaultBatchSize   = 100
+	DefaultConcurrency = 4
+)
+
+// Settings configures how the poller batches its contract calls
+type Settings struct {
+	Multicall   string // Address of a Multicall aggregator contract to make batches through; JSON-RPC batch requests are used if empty
+	BatchSize   int    // Maximum number of calls in a single batch
+	Concurrency int    // Maximum number of batches in flight at once
+}
+
 type poller struct {
 	repository.MethodRepository
-	bc       core.BlockChain
-	contract contract.Contract
+	bc          core.BlockChain
+	contract    contract.Contract
+	batchSize   int
+	concurrency int
 }
 
 // NewPoller returns a new Poller
-func NewPoller(blockChain core.BlockChain, db *postgres.DB, mode types.Mode) Poller {
+func NewPoller(blockChain core.BlockChain, db *postgres.DB, mode types.Mode, settings Settings) Poller {
+	if settings.Multicall != "" {
+		blockChain = eth.NewMulticallBlockChain(blockChain, settings.Multicall)
+	}
+	if settings.BatchSize <= 0 {
+		settings.BatchSize = DefaultBatchSize
+	}
+	if settings.Concurrency <= 0 {
+		settings.Concurrency = DefaultConcurrency
+	}
+
 	return &poller{
 		MethodRepository: repository.NewMethodRepository(db, mode),
 		bc:               blockChain,
+		batchSize:        settings.BatchSize,
+		concurrency:      settings.Concurrency,
 	}
 }
 
@@ -66,176 +99,155 @@ func (p *poller) PollContract(con contract.Contract, lastBlock int64) error {
 }
 
 // PollContractAt polls a contract's public getter methods at the specified block height
+// and persists the results of all of them in bulk
 func (p *poller) PollContractAt(con contract.Contract, blockNumber int64) error {
 	p.contract = con
+	results := make([]types.Result, 0)
 	for _, m := range con.Methods {
-		switch len(m.Args) {
-		case 0:
-			if err := p.pollNoArgAt(m, blockNumber); err != nil {
-				return err
-			}
-		case 1:
-			if err := p.pollSingleArgAt(m, blockNumber); err != nil {
-				return err
-			}
-		case 2:
-			if err := p.pollDoubleArgAt(m, blockNumber); err != nil {
-				return err
-			}
-		default:
-			return errors.New("poller error: too many arguments to handle")
-
+		methodResults, err := p.pollMethodAt(m, blockNumber)
+		if err != nil {
+			return err
 		}
+		results = append(results, methodResults...)
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	// Persist result set as batch
+	err := p.PersistBlockResults(results, p.contract.Address, p.contract.Name)
+	if err != nil {
+		return fmt.Errorf("poller error persisting method results\r\nblock: %d, contract: %s\r\nerr: %v", blockNumber, p.contract.Address, err)
 	}
 
 	return nil
 }
 
-func (p *poller) pollNoArgAt(m types.Method, bn int64) error {
-	result := types.Result{
-		Block:  bn,
-		Method: m,
-		Inputs: nil,
-		PgType: m.Return[0].PgType,
+// Poll the method with every combination of the argument values available for its inputs
+// (e.g. token holder addresses for balanceOf, owner and spender addresses for allowance)
+func (p *poller) pollMethodAt(m types.Method, bn int64) ([]types.Result, error) {
+	// Depending on the type of each arg choose
+	// the correct argument set to iterate over
+	argSets := make([][]interface{}, len(m.Args))
+	for i, arg := range m.Args {
+		argSets[i] = p.argValues(arg)
+		if len(argSets[i]) == 0 { // If we haven't collected any args by now we can't call the method
+			return nil, nil
+		}
 	}
 
-	var out interface{}
-	err := p.bc.FetchContractData(p.contract.Abi, p.contract.Address, m.Name, nil, &out, bn)
-	if err != nil {
-		return fmt.Errorf("poller error calling 0 argument method\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
+	combinations := combine(argSets)
+	calls := make([]core.ContractCall, len(combinations))
+	returnValues := make([][]reflect.Value, len(combinations))
+	for i, in := range combinations {
+		calls[i].Method = m.Name
+		calls[i].MethodArgs = in
+		calls[i].Result, returnValues[i] = newReturnValues(m)
 	}
-	strOut, err := stringify(out)
+	err := p.fetchBatches(calls, bn)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("poller error calling %d argument method\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", len(m.Args), bn, m.Name, p.contract.Address, err)
 	}
 
-	// Cache returned value if piping is turned on
-	p.cache(out)
-	result.Output = strOut
+	results := make([]types.Result, 0, len(calls))
+	for i, call := range calls {
+		if call.Error != nil {
+			return nil, fmt.Errorf("poller error calling %d argument method\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", len(m.Args), bn, m.Name, p.contract.Address, call.Error)
+		}
+		var strIn []interface{}
+		for _, arg := range call.MethodArgs {
+			strIn = append(strIn, contract.StringifyArg(arg))
+		}
+		strOuts := make([]interface{}, len(m.Return))
+		for j, value := range returnValues[i] {
+			out := value.Elem().Interface()
+			strOuts[j], err = stringifyReturn(m.Return[j], out)
+			if err != nil {
+				return nil, err
+			}
+			// Cache returned value if piping is turned on
+			p.cache(out)
+		}
 
-	// Persist result immediately
-	err = p.PersistResults([]types.Result{result}, m, p.contract.Address, p.contract.Name)
-	if err != nil {
-		return fmt.Errorf("poller error persisting 0 argument method result\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
+		// Write inputs and outputs to result and append result to growing set
+		results = append(results, types.Result{
+			Block:   bn,
+			Method:  m,
+			Inputs:  strIn,
+			Outputs: strOuts,
+		})
 	}
 
-	return nil
+	return results, nil
 }
 
-// Use token holder address to poll methods that take 1 address argument (e.g. balanceOf)
-func (p *poller) pollSingleArgAt(m types.Method, bn int64) error {
-	result := types.Result{
-		Block:  bn,
-		Method: m,
-		Inputs: make([]interface{}, 1),
-		PgType: m.Return[0].PgType,
+// Makes the calls in batches of at most batchSize calls, with at most concurrency batches in flight at once
+func (p *poller) fetchBatches(calls []core.ContractCall, bn int64) error {
+	batches := make([][]core.ContractCall, 0, len(calls)/p.batchSize+1)
+	for start := 0; start < len(calls); start += p.batchSize {
+		end := start + p.batchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		batches = append(batches, calls[start:end])
 	}
 
-	// Depending on the type of the arg choose
-	// the correct argument set to iterate over
-	var args map[interface{}]bool
-	switch m.Args[0].Type.T {
-	case abi.HashTy, abi.FixedBytesTy:
-		args = p.contract.EmittedHashes
-	case abi.AddressTy:
-		args = p.contract.EmittedAddrs
-	}
-	if len(args) == 0 { // If we haven't collected any args by now we can't call the method
-		return nil
+	errs := make([]error, len(batches))
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, batch []core.ContractCall) {
+			defer wg.Done()
+			errs[i] = p.bc.FetchContractDataBatch(p.contract.Abi, p.contract.Address, batch, bn)
+			<-sem
+		}(i, batch)
 	}
+	wg.Wait()
 
-	results := make([]types.Result, 0, len(args))
-	for arg := range args {
-		in := []interface{}{arg}
-		strIn := []interface{}{contract.StringifyArg(arg)}
-
-		var out interface{}
-		err := p.bc.FetchContractData(p.contract.Abi, p.contract.Address, m.Name, in, &out, bn)
-		if err != nil {
-			return fmt.Errorf("poller error calling 1 argument method\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
-		}
-		strOut, err := stringify(out)
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
-		p.cache(out)
-
-		// Write inputs and outputs to result and append result to growing set
-		result.Inputs = strIn
-		result.Output = strOut
-		results = append(results, result)
-	}
-	// Persist result set as batch
-	err := p.PersistResults(results, m, p.contract.Address, p.contract.Name)
-	if err != nil {
-		return fmt.Errorf("poller error persisting 1 argument method result\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
 	}
 
 	return nil
 }
 
-// Use token holder address to poll methods that take 2 address arguments (e.g. allowance)
-func (p *poller) pollDoubleArgAt(m types.Method, bn int64) error {
-	result := types.Result{
-		Block:  bn,
-		Method: m,
-		Inputs: make([]interface{}, 2),
-		PgType: m.Return[0].PgType,
-	}
-
-	// Depending on the type of the args choose
-	// the correct argument sets to iterate over
-	var firstArgs map[interface{}]bool
-	switch m.Args[0].Type.T {
-	case abi.HashTy, abi.FixedBytesTy:
-		firstArgs = p.contract.EmittedHashes
-	case abi.AddressTy:
-		firstArgs = p.contract.EmittedAddrs
-	}
-	if len(firstArgs) == 0 {
-		return nil
-	}
-
-	var secondArgs map[interface{}]bool
-	switch m.Args[1].Type.T {
+// Returns the values the given method argument can be polled with
+// Addresses and hashes are collected from emitted event values, any other
+// type is sourced from the configured method args that parse as that type
+func (p *poller) argValues(arg types.Field) []interface{} {
+	var emitted map[interface{}]bool
+	switch arg.Type.T {
 	case abi.HashTy, abi.FixedBytesTy:
-		secondArgs = p.contract.EmittedHashes
+		emitted = p.contract.EmittedHashes
 	case abi.AddressTy:
-		secondArgs = p.contract.EmittedAddrs
-	}
-	if len(secondArgs) == 0 {
-		return nil
-	}
-
-	results := make([]types.Result, 0, len(firstArgs)*len(secondArgs))
-	for arg1 := range firstArgs {
-		for arg2 := range secondArgs {
-			in := []interface{}{arg1, arg2}
-			strIn := []interface{}{contract.StringifyArg(arg1), contract.StringifyArg(arg2)}
-
-			var out interface{}
-			err := p.bc.FetchContractData(p.contract.Abi, p.contract.Address, m.Name, in, &out, bn)
-			if err != nil {
-				return fmt.Errorf("poller error calling 2 argument method\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
+		emitted = p.contract.EmittedAddrs
+	default:
+		configured := make([]string, 0, len(p.contract.MethodArgs))
+		for methodArg, wanted := range p.contract.MethodArgs {
+			if wanted {
+				configured = append(configured, methodArg)
 			}
-			strOut, err := stringify(out)
-			if err != nil {
-				return err
+		}
+		sort.Strings(configured)
+		values := make([]interface{}, 0, len(configured))
+		for _, methodArg := range configured {
+			if value, ok := parseArg(methodArg, arg.Type); ok {
+				values = append(values, value)
 			}
-			p.cache(out)
-
-			result.Output = strOut
-			result.Inputs = strIn
-			results = append(results, result)
 		}
+		return values
 	}
 
-	err := p.PersistResults(results, m, p.contract.Address, p.contract.Name)
-	if err != nil {
-		return fmt.Errorf("poller error persisting 2 argument method result\r\nblock: %d, method: %s, contract: %s\r\nerr: %v", bn, m.Name, p.contract.Address, err)
+	values := make([]interface{}, 0, len(emitted))
+	for value := range emitted {
+		values = append(values, value)
 	}
 
-	return nil
+	return values
 }
 
 // FetchContractData is just a wrapper around the poller blockchain's FetchContractData method
@@ -255,6 +267,10 @@ func (p *poller) cache(out interface{}) {
 			if p.contract.EmittedHashes != nil && len(out.([]byte)) == 32 {
 				p.contract.AddEmittedHash(common.BytesToHash(out.([]byte)))
 			}
+		case [32]byte:
+			if p.contract.EmittedHashes != nil {
+				p.contract.AddEmittedHash(common.Hash(out.([32]byte)))
+			}
 		case common.Address:
 			if p.contract.EmittedAddrs != nil {
 				p.contract.AddEmittedAddr(out.(common.Address))
@@ -264,6 +280,158 @@ func (p *poller) cache(out interface{}) {
 	}
 }
 
+// Returns a new pointer to a value of the given return type for the abi to unpack into
+func newReturnValue(ret types.Field) reflect.Value {
+	if ret.Type.Type == nil {
+		return reflect.New(reflect.TypeOf((*interface{})(nil)).Elem())
+	}
+
+	return reflect.New(ret.Type.Type)
+}
+
+// Returns a destination for the method's return values to be unpacked into, along with the pointer to each value
+// A single return value is unpacked directly into a pointer of its type, multiple into a slice of such pointers
+func newReturnValues(m types.Method) (interface{}, []reflect.Value) {
+	values := make([]reflect.Value, len(m.Return))
+	for i, ret := range m.Return {
+		values[i] = newReturnValue(ret)
+	}
+	if len(values) == 1 {
+		return values[0].Interface(), values
+	}
+	out := make([]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value.Interface()
+	}
+
+	return &out, values
+}
+
+// Builds every combination of one value from each of the argument sets
+func combine(argSets [][]interface{}) [][]interface{} {
+	combinations := [][]interface{}{nil}
+	for _, set := range argSets {
+		next := make([][]interface{}, 0, len(combinations)*len(set))
+		for _, combination := range combinations {
+			for _, value := range set {
+				in := make([]interface{}, len(combination), len(combination)+1)
+				copy(in, combination)
+				next = append(next, append(in, value))
+			}
+		}
+		combinations = next
+	}
+
+	return combinations
+}
+
+// Converts a configured method arg into a value of the given abi type
+func parseArg(methodArg string, t abi.Type) (interface{}, bool) {
+	switch t.T {
+	case abi.UintTy, abi.IntTy:
+		n, ok := new(big.Int).SetString(methodArg, 0)
+		if !ok || (t.T == abi.UintTy && n.Sign() < 0) {
+			return nil, false
+		}
+		if t.Type == reflect.TypeOf(n) {
+			return n, true
+		}
+		value := reflect.New(t.Type).Elem()
+		if t.T == abi.UintTy {
+			if !n.IsUint64() || value.OverflowUint(n.Uint64()) {
+				return nil, false
+			}
+			value.SetUint(n.Uint64())
+		} else {
+			if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+				return nil, false
+			}
+			value.SetInt(n.Int64())
+		}
+		return value.Interface(), true
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(methodArg)
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	case abi.StringTy:
+		return methodArg, true
+	default:
+		return nil, false
+	}
+}
+
+// Composite return values (tuples, arrays and slices) are stored as json, everything else as a string
+func stringifyReturn(ret types.Field, out interface{}) (string, error) {
+	switch ret.Type.T {
+	case abi.TupleTy, abi.ArrayTy, abi.SliceTy:
+		doc, err := jsonify(reflect.ValueOf(out))
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return stringify(out)
+	}
+}
+
+// Converts a composite return value into a json friendly document,
+// keying tuple components by their abi names and stringifying their values
+func jsonify(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	switch value.Interface().(type) {
+	case common.Address, common.Hash, *big.Int, []byte:
+		return stringify(value.Interface())
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return jsonify(value.Elem())
+	case reflect.Struct:
+		doc := make(map[string]interface{}, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			component, err := jsonify(value.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			doc[name] = component
+		}
+		return doc, nil
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return stringify(value.Interface())
+		}
+		doc := make([]interface{}, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			elem, err := jsonify(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			doc[i] = elem
+		}
+		return doc, nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	default:
+		return stringify(value.Interface())
+	}
+}
+
 func stringify(input interface{}) (string, error) {
 	switch input.(type) {
 	case *big.Int:
@@ -280,12 +448,24 @@ func stringify(input interface{}) (string, error) {
 	case []byte:
 		b := hexutil.Encode(input.([]byte))
 		return b, nil
-	case byte:
-		b := input.(byte)
-		return string(b), nil
 	case bool:
 		return strconv.FormatBool(input.(bool)), nil
-	default:
-		return "", errors.New("error: unhandled return type")
 	}
+
+	// Sized integers and fixed size byte arrays are resolved by kind
+	value := reflect.ValueOf(input)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(b), value)
+			return hexutil.Encode(b), nil
+		}
+	}
+
+	return "", errors.New("error: unhandled return type")
 }
@@ -14,36 +14,22 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package fetcher
+package poller_test
 
 import (
-	"strings"
+	"io/ioutil"
+	"testing"
 
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 	"github.com/sirupsen/logrus"
-	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
-	"github.com/vulcanize/vulcanizedb/pkg/fs"
 )
 
-type CsvTailStorageFetcher struct {
-	tailer fs.Tailer
+func TestPoller(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Shared Poller Suite Test")
 }
 
-func NewCsvTailStorageFetcher(tailer fs.Tailer) CsvTailStorageFetcher {
-	return CsvTailStorageFetcher{tailer: tailer}
-}
-
-func (storageFetcher CsvTailStorageFetcher) FetchStorageDiffs(out chan<- utils.StorageDiffInput, errs chan<- error) {
-	t, tailErr := storageFetcher.tailer.Tail()
-	if tailErr != nil {
-		errs <- tailErr
-	}
-	logrus.Debug("fetching storage diffs...")
-	for line := range t.Lines {
-		diff, parseErr := utils.FromParityCsvRow(strings.Split(line.Text, ","))
-		if parseErr != nil {
-			errs <- parseErr
-		} else {
-			out <- diff
-		}
-	}
-}
+var _ = BeforeSuite(func() {
+	logrus.SetOutput(ioutil.Discard)
+})
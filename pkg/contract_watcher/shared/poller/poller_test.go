@@ -0,0 +1,257 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package poller_test
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/contract"
+	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/poller"
+	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/types"
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+// batchRecordingBlockChain records the size of each batch fetched and the most batches in flight at once
+type batchRecordingBlockChain struct {
+	*fakes.MockBlockChain
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	batchSizes  []int
+	blocks      []int64
+	err         error
+}
+
+func (chain *batchRecordingBlockChain) FetchContractDataBatch(abiJSON string, address string, calls []core.ContractCall, blockNumber int64) error {
+	chain.lock.Lock()
+	chain.inFlight++
+	if chain.inFlight > chain.maxInFlight {
+		chain.maxInFlight = chain.inFlight
+	}
+	chain.batchSizes = append(chain.batchSizes, len(calls))
+	chain.blocks = append(chain.blocks, blockNumber)
+	chain.lock.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	chain.lock.Lock()
+	chain.inFlight--
+	chain.lock.Unlock()
+	return chain.err
+}
+
+func newType(t string, components []abi.ArgumentMarshaling) abi.Type {
+	typ, err := abi.NewType(t, components)
+	Expect(err).NotTo(HaveOccurred())
+	return typ
+}
+
+var _ = Describe("Poller", func() {
+	Describe("combine", func() {
+		It("builds every combination of one value from each argument set", func() {
+			combinations := poller.Combine([][]interface{}{{"a", "b"}, {1, 2, 3}})
+
+			Expect(combinations).To(Equal([][]interface{}{
+				{"a", 1}, {"a", 2}, {"a", 3},
+				{"b", 1}, {"b", 2}, {"b", 3},
+			}))
+		})
+
+		It("combines any number of argument sets", func() {
+			combinations := poller.Combine([][]interface{}{{"a", "b"}, {1}, {true, false}})
+
+			Expect(combinations).To(Equal([][]interface{}{
+				{"a", 1, true}, {"a", 1, false},
+				{"b", 1, true}, {"b", 1, false},
+			}))
+		})
+
+		It("returns a single empty combination for a method without arguments", func() {
+			combinations := poller.Combine(nil)
+
+			Expect(combinations).To(HaveLen(1))
+			Expect(combinations[0]).To(BeEmpty())
+		})
+
+		It("returns no combinations if an argument set is empty", func() {
+			combinations := poller.Combine([][]interface{}{{"a", "b"}, {}})
+
+			Expect(combinations).To(BeEmpty())
+		})
+	})
+
+	Describe("parseArg", func() {
+		It("parses decimal and hex integers into big ints for large integer types", func() {
+			value, ok := poller.ParseArg("1000", newType("uint256", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(big.NewInt(1000)))
+
+			value, ok = poller.ParseArg("0x10", newType("int256", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(big.NewInt(16)))
+		})
+
+		It("parses integers into the sized type of small integer types", func() {
+			value, ok := poller.ParseArg("200", newType("uint8", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(uint8(200)))
+
+			value, ok = poller.ParseArg("-5", newType("int16", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(int16(-5)))
+		})
+
+		It("rejects integers that don't fit the type", func() {
+			_, ok := poller.ParseArg("256", newType("uint8", nil))
+			Expect(ok).To(BeFalse())
+
+			_, ok = poller.ParseArg("-1", newType("uint256", nil))
+			Expect(ok).To(BeFalse())
+
+			_, ok = poller.ParseArg("not a number", newType("uint256", nil))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("parses bools and strings", func() {
+			value, ok := poller.ParseArg("true", newType("bool", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(true))
+
+			_, ok = poller.ParseArg("yes", newType("bool", nil))
+			Expect(ok).To(BeFalse())
+
+			value, ok = poller.ParseArg("name", newType("string", nil))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("name"))
+		})
+
+		It("rejects types that aren't configured as method args", func() {
+			_, ok := poller.ParseArg("0xfE9e8709d3215310075d67E3ed32A380CCf451C8", newType("address", nil))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("stringifyReturn", func() {
+		It("stores a tuple as a json object keyed by its component names", func() {
+			tupleType := newType("tuple", []abi.ArgumentMarshaling{
+				{Name: "owner", Type: "address"},
+				{Name: "balance", Type: "uint256"},
+				{Name: "active", Type: "bool"},
+			})
+			tuple := reflect.New(tupleType.Type).Elem()
+			tuple.FieldByName("Owner").Set(reflect.ValueOf(common.HexToAddress("0xfE9e8709d3215310075d67E3ed32A380CCf451C8")))
+			tuple.FieldByName("Balance").Set(reflect.ValueOf(big.NewInt(10)))
+			tuple.FieldByName("Active").SetBool(true)
+
+			stored, err := poller.StringifyReturn(types.Field{Argument: abi.Argument{Type: tupleType}}, tuple.Interface())
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(MatchJSON(`{"owner": "0xfE9e8709d3215310075d67E3ed32A380CCf451C8", "balance": "10", "active": true}`))
+		})
+
+		It("stores fixed size arrays and slices as json arrays", func() {
+			stored, err := poller.StringifyReturn(types.Field{Argument: abi.Argument{Type: newType("uint256[2]", nil)}},
+				[2]*big.Int{big.NewInt(1), big.NewInt(2)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(MatchJSON(`["1", "2"]`))
+
+			stored, err = poller.StringifyReturn(types.Field{Argument: abi.Argument{Type: newType("address[]", nil)}},
+				[]common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(MatchJSON(`["0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002"]`))
+		})
+
+		It("stores byte arrays in a composite value as hex", func() {
+			stored, err := poller.StringifyReturn(types.Field{Argument: abi.Argument{Type: newType("bytes32[]", nil)}},
+				[][32]byte{{1}})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(MatchJSON(`["0x0100000000000000000000000000000000000000000000000000000000000000"]`))
+		})
+
+		It("stores other values as strings", func() {
+			stored, err := poller.StringifyReturn(types.Field{Argument: abi.Argument{Type: newType("uint256", nil)}}, big.NewInt(42))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(Equal("42"))
+		})
+	})
+
+	Describe("fetchBatches", func() {
+		var (
+			blockChain *batchRecordingBlockChain
+			con        contract.Contract
+		)
+
+		BeforeEach(func() {
+			blockChain = &batchRecordingBlockChain{MockBlockChain: fakes.NewMockBlockChain()}
+			con = contract.Contract{Address: "0xfE9e8709d3215310075d67E3ed32A380CCf451C8"}
+		})
+
+		It("makes the calls in batches of at most the batch size", func() {
+			calls := make([]core.ContractCall, 250)
+
+			err := poller.FetchBatches(blockChain, con, poller.Settings{BatchSize: 100, Concurrency: 4}, calls, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			sort.Ints(blockChain.batchSizes)
+			Expect(blockChain.batchSizes).To(Equal([]int{50, 100, 100}))
+			Expect(blockChain.blocks).To(Equal([]int64{10, 10, 10}))
+		})
+
+		It("has at most the concurrency limit of batches in flight at once", func() {
+			calls := make([]core.ContractCall, 60)
+
+			err := poller.FetchBatches(blockChain, con, poller.Settings{BatchSize: 10, Concurrency: 2}, calls, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockChain.batchSizes).To(HaveLen(6))
+			Expect(blockChain.maxInFlight).To(Equal(2))
+		})
+
+		It("uses the default batching if none is configured", func() {
+			calls := make([]core.ContractCall, poller.DefaultBatchSize*(poller.DefaultConcurrency+1))
+
+			err := poller.FetchBatches(blockChain, con, poller.Settings{}, calls, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockChain.batchSizes).To(HaveLen(poller.DefaultConcurrency + 1))
+			Expect(blockChain.batchSizes[0]).To(Equal(poller.DefaultBatchSize))
+			Expect(blockChain.maxInFlight).To(BeNumerically("<=", poller.DefaultConcurrency))
+		})
+
+		It("returns an error if a batch fails", func() {
+			blockChain.err = fakes.FakeError
+			calls := make([]core.ContractCall, 30)
+
+			err := poller.FetchBatches(blockChain, con, poller.Settings{BatchSize: 10, Concurrency: 2}, calls, 10)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+			Expect(blockChain.batchSizes).To(HaveLen(3))
+		})
+	})
+})
@@ -22,17 +22,22 @@ import (
 	"strings"
 
 	"github.com/hashicorp/golang-lru"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/vulcanizedb/pkg/contract_watcher/shared/types"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 )
 
-const methodCacheSize = 1000
+const (
+	methodCacheSize = 1000
+	maxInsertParams = 65535 // Postgres limit on the number of parameters in a single statement
+)
 
 // MethodRepository is used to persist public getter method data
 type MethodRepository interface {
 	PersistResults(results []types.Result, methodInfo types.Method, contractAddr, contractName string) error
+	PersistBlockResults(results []types.Result, contractAddr, contractName string) error
 	CreateMethodTable(contractAddr string, method types.Method) (bool, error)
 	CreateContractSchema(contractAddr string) (bool, error)
 	CheckSchemaCache(key string) (interface{}, bool)
@@ -75,45 +80,55 @@ func (r *methodRepository) PersistResults(results []types.Result, methodInfo typ
 		return err
 	}
 
-	return r.persistResults(results, methodInfo, contractAddr, contractName)
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	err = r.persistResults(tx, results, methodInfo, contractAddr, contractName)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction: %s", rollbackErr.Error())
+		}
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Creates a custom postgres command to persist logs for the given event
-func (r *methodRepository) persistResults(results []types.Result, methodInfo types.Method, contractAddr, contractName string) error {
-	tx, err := r.DB.Beginx()
+// PersistBlockResults creates a schema for the contract and tables for the polled methods if needed
+// Persists the results of every method polled at a block in a single transaction
+func (r *methodRepository) PersistBlockResults(results []types.Result, contractAddr, contractName string) error {
+	if len(results) == 0 {
+		return errors.New("method repository error: passed empty results slice")
+	}
+	_, err := r.CreateContractSchema(contractAddr)
 	if err != nil {
 		return err
 	}
 
+	// Group results by method, keeping the order the methods were polled in
+	methods := make([]types.Method, 0)
+	methodResults := make(map[string][]types.Result)
 	for _, result := range results {
-		// Begin postgres string
-		pgStr := fmt.Sprintf("INSERT INTO %s_%s.%s_method ", r.mode.String(), strings.ToLower(contractAddr), strings.ToLower(result.Name))
-		pgStr = pgStr + "(token_name, block"
-		ml := len(result.Args)
-
-		// Preallocate slice of needed capacity and proceed to pack variables into it in same order they appear in string
-		data := make([]interface{}, 0, 3+ml)
-		data = append(data,
-			contractName,
-			result.Block)
-
-		// Iterate over method args and return value, adding names
-		// to the string and pushing values to the slice
-		for i, arg := range result.Args {
-			pgStr = pgStr + fmt.Sprintf(", %s_", strings.ToLower(arg.Name)) // Add underscore after to avoid any collisions with reserved pg words
-			data = append(data, result.Inputs[i])
+		if _, ok := methodResults[result.Name]; !ok {
+			methods = append(methods, result.Method)
 		}
-		pgStr = pgStr + ", returned) VALUES ($1, $2"
-		data = append(data, result.Output)
-
-		// For each input entry we created we add its postgres command variable to the string
-		for i := 0; i <= ml; i++ {
-			pgStr = pgStr + fmt.Sprintf(", $%d", i+3)
+		methodResults[result.Name] = append(methodResults[result.Name], result)
+	}
+	for _, method := range methods {
+		_, err = r.CreateMethodTable(contractAddr, method)
+		if err != nil {
+			return err
 		}
-		pgStr = pgStr + ")"
+	}
 
-		// Add this query to the transaction
-		_, err = tx.Exec(pgStr, data...)
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, method := range methods {
+		err = r.persistResults(tx, methodResults[method.Name], method, contractAddr, contractName)
 		if err != nil {
 			rollbackErr := tx.Rollback()
 			if rollbackErr != nil {
@@ -126,6 +141,59 @@ func (r *methodRepository) persistResults(results []types.Result, methodInfo typ
 	return tx.Commit()
 }
 
+// Creates custom postgres commands to persist the results for the given method,
+// inserting as many rows per command as the postgres parameter limit allows
+func (r *methodRepository) persistResults(tx *sqlx.Tx, results []types.Result, methodInfo types.Method, contractAddr, contractName string) error {
+	// Column names for the method args and return values; add underscore after
+	// arg names to avoid any collisions with reserved pg words
+	returnColumns := methodInfo.ReturnColumns()
+	columns := make([]string, 0, 2+len(methodInfo.Args)+len(returnColumns))
+	columns = append(columns, "token_name", "block")
+	for _, arg := range methodInfo.Args {
+		columns = append(columns, strings.ToLower(arg.Name)+"_")
+	}
+	columns = append(columns, returnColumns...)
+
+	rowsPerInsert := maxInsertParams / len(columns)
+	for start := 0; start < len(results); start += rowsPerInsert {
+		end := start + rowsPerInsert
+		if end > len(results) {
+			end = len(results)
+		}
+
+		// Begin postgres string
+		pgStr := fmt.Sprintf("INSERT INTO %s_%s.%s_method (%s) VALUES ", r.mode.String(), strings.ToLower(contractAddr), strings.ToLower(methodInfo.Name), strings.Join(columns, ", "))
+
+		// Preallocate slice of needed capacity and proceed to pack variables into it in same order they appear in string
+		data := make([]interface{}, 0, (end-start)*len(columns))
+		rows := make([]string, 0, end-start)
+		for _, result := range results[start:end] {
+			if len(result.Inputs) != len(methodInfo.Args) || len(result.Outputs) != len(returnColumns) {
+				return fmt.Errorf("method repository error: %s result has %d inputs and %d outputs, expected %d and %d", methodInfo.Name, len(result.Inputs), len(result.Outputs), len(methodInfo.Args), len(returnColumns))
+			}
+
+			// For each value we add its postgres command variable to the row
+			params := make([]string, len(columns))
+			for i := range params {
+				params[i] = fmt.Sprintf("$%d", len(data)+i+1)
+			}
+			rows = append(rows, "("+strings.Join(params, ", ")+")")
+			data = append(data, contractName, result.Block)
+			data = append(data, result.Inputs...)
+			data = append(data, result.Outputs...)
+		}
+		pgStr = pgStr + strings.Join(rows, ", ")
+
+		// Add this command to the transaction
+		_, err := tx.Exec(pgStr, data...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CreateMethodTable checks for event table and creates it if it does not already exist
 func (r *methodRepository) CreateMethodTable(contractAddr string, method types.Method) (bool, error) {
 	tableID := fmt.Sprintf("%s_%s.%s_method", r.mode.String(), strings.ToLower(contractAddr), strings.ToLower(method.Name))
@@ -141,9 +209,11 @@ func (r *methodRepository) CreateMethodTable(contractAddr string, method types.M
 	}
 	if !tableExists {
 		err = r.newMethodTable(tableID, method)
-		if err != nil {
-			return false, err
-		}
+	} else {
+		err = r.migrateMethodTable(contractAddr, tableID, method)
+	}
+	if err != nil {
+		return false, err
 	}
 
 	// Add schema name to cache
@@ -163,13 +233,68 @@ func (r *methodRepository) newMethodTable(tableID string, method types.Method) e
 		pgStr = pgStr + fmt.Sprintf(" %s_ %s NOT NULL,", strings.ToLower(arg.Name), arg.PgType)
 	}
 
-	pgStr = pgStr + fmt.Sprintf(" returned %s NOT NULL)", method.Return[0].PgType)
+	for i, column := range method.ReturnColumns() {
+		pgStr = pgStr + fmt.Sprintf(" %s %s NOT NULL,", column, method.Return[i].PgType)
+	}
+	pgStr = strings.TrimSuffix(pgStr, ",") + ")"
 
 	_, err := r.DB.Exec(pgStr)
 
 	return err
 }
 
+// Adds the return columns missing from a table created by an earlier version, such as the "returned_<name>" columns of
+// a method with several return values, and fails if a return column has a different type than its values are stored as
+func (r *methodRepository) migrateMethodTable(contractAddr, tableID string, method types.Method) error {
+	var existing []struct {
+		Name     string `db:"column_name"`
+		DataType string `db:"data_type"`
+	}
+	err := r.DB.Select(&existing, `SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2`,
+		r.mode.String()+"_"+strings.ToLower(contractAddr), strings.ToLower(method.Name)+"_method")
+	if err != nil {
+		return err
+	}
+	dataTypes := make(map[string]string, len(existing))
+	for _, column := range existing {
+		dataTypes[column.Name] = column.DataType
+	}
+
+	returnColumns := method.ReturnColumns()
+	for i, column := range returnColumns {
+		dataType, exists := dataTypes[column]
+		if !exists {
+			// Rows persisted before the column was added have no value for it
+			_, err = r.DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableID, column, method.Return[i].PgType))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if dataType != pgDataType(method.Return[i].PgType) {
+			return fmt.Errorf("method repository error: column %s of %s is %s, but %s results are stored as %s; "+
+				"convert or drop the column to poll %s", column, tableID, dataType, method.Name, method.Return[i].PgType, method.Name)
+		}
+	}
+	// A single "returned" column is left behind when a method's results move to a column per return value
+	if _, ok := dataTypes["returned"]; ok && len(returnColumns) > 0 && returnColumns[0] != "returned" {
+		_, err = r.DB.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN returned DROP NOT NULL", tableID))
+	}
+	return err
+}
+
+// pgDataType is how information_schema.columns reports a column created with the given type
+func pgDataType(pgType string) string {
+	if strings.HasSuffix(pgType, "[]") {
+		return "ARRAY"
+	}
+	if i := strings.Index(pgType, "("); i >= 0 {
+		pgType = pgType[:i]
+	}
+	return strings.ToLower(pgType)
+}
+
 // Checks if a table already exists for the given contract and event
 func (r *methodRepository) checkForTable(contractAddr string, methodName string) (bool, error) {
 	pgStr := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = '%s_%s' AND table_name = '%s_method')", r.mode.String(), strings.ToLower(contractAddr), strings.ToLower(methodName))
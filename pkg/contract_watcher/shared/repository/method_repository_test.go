@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -44,14 +45,13 @@ var _ = Describe("Repository", func() {
 		Expect(len(con.Methods)).To(Equal(1))
 		method = con.Methods[0]
 		mockResult = types.Result{
-			Method: method,
-			PgType: method.Return[0].PgType,
-			Inputs: make([]interface{}, 1),
-			Output: new(interface{}),
-			Block:  6707323,
+			Method:  method,
+			Inputs:  make([]interface{}, 1),
+			Outputs: make([]interface{}, 1),
+			Block:   6707323,
 		}
 		mockResult.Inputs[0] = "0xfE9e8709d3215310075d67E3ed32A380CCf451C8"
-		mockResult.Output = "66386309548896882859581786"
+		mockResult.Outputs[0] = "66386309548896882859581786"
 		db, _ = test_helpers.SetupDBandBC()
 		dataStore = repository.NewMethodRepository(db, types.FullSync)
 	})
@@ -122,6 +122,44 @@ var _ = Describe("Repository", func() {
 				Expect(ok).To(Equal(true))
 				Expect(v).To(Equal(true))
 			})
+
+			It("Adds the return columns missing from an existing table", func() {
+				_, err := dataStore.CreateContractSchema(con.Address)
+				Expect(err).ToNot(HaveOccurred())
+				tableID := fmt.Sprintf("%s_%s.%s_method", types.FullSync, strings.ToLower(con.Address), strings.ToLower(method.Name))
+				_, err = db.Exec(fmt.Sprintf(`CREATE TABLE %s (id SERIAL, token_name CHARACTER VARYING(66) NOT NULL,
+					block INTEGER NOT NULL, who_ CHARACTER VARYING(66) NOT NULL, returned NUMERIC NOT NULL)`, tableID))
+				Expect(err).ToNot(HaveOccurred())
+				multiReturn := method
+				multiReturn.Return = []types.Field{
+					{Argument: abi.Argument{Name: "balance"}, PgType: "NUMERIC"},
+					{Argument: abi.Argument{Name: "holders"}, PgType: "JSONB"},
+				}
+
+				created, err := dataStore.CreateMethodTable(con.Address, multiReturn)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(created).To(Equal(false))
+
+				result := mockResult
+				result.Outputs = []interface{}{"1000", `["0xfE9e8709d3215310075d67E3ed32A380CCf451C8"]`}
+				err = dataStore.PersistResults([]types.Result{result}, multiReturn, con.Address, con.Name)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("Fails if an existing return column has a different type", func() {
+				_, err := dataStore.CreateContractSchema(con.Address)
+				Expect(err).ToNot(HaveOccurred())
+				tableID := fmt.Sprintf("%s_%s.%s_method", types.FullSync, strings.ToLower(con.Address), strings.ToLower(method.Name))
+				_, err = db.Exec(fmt.Sprintf(`CREATE TABLE %s (id SERIAL, token_name CHARACTER VARYING(66) NOT NULL,
+					block INTEGER NOT NULL, who_ CHARACTER VARYING(66) NOT NULL, returned TEXT[] NOT NULL)`, tableID))
+				Expect(err).ToNot(HaveOccurred())
+				arrayReturn := method
+				arrayReturn.Return = []types.Field{{Argument: abi.Argument{Name: "holders"}, PgType: "JSONB"}}
+
+				_, err = dataStore.CreateMethodTable(con.Address, arrayReturn)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("column returned of " + tableID + " is ARRAY"))
+			})
 		})
 
 		Describe("PersistResult", func() {
@@ -146,6 +184,36 @@ var _ = Describe("Repository", func() {
 				err = dataStore.PersistResults([]types.Result{}, method, con.Address, con.Name)
 				Expect(err).To(HaveOccurred())
 			})
+
+			It("Fails if a result does not have a value for every return column", func() {
+				mockResult.Outputs = nil
+				err = dataStore.PersistResults([]types.Result{mockResult}, method, con.Address, con.Name)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("PersistBlockResults", func() {
+			It("Persists the results polled at a block in bulk", func() {
+				secondResult := mockResult
+				secondResult.Inputs = []interface{}{"0x4bbd1D4Cb0c1a2C6A0bC7b2C6A0b1D4Cb0c1a2C6"}
+				secondResult.Outputs = []interface{}{"1000"}
+				err = dataStore.PersistBlockResults([]types.Result{mockResult, secondResult}, con.Address, con.Name)
+				Expect(err).ToNot(HaveOccurred())
+
+				var scanStructs []test_helpers.BalanceOf
+				err = db.Select(&scanStructs, fmt.Sprintf("SELECT * FROM full_%s.balanceof_method ORDER BY id", constants.TusdContractAddress))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(len(scanStructs)).To(Equal(2))
+				Expect(scanStructs[0].Address).To(Equal("0xfE9e8709d3215310075d67E3ed32A380CCf451C8"))
+				Expect(scanStructs[0].Balance).To(Equal("66386309548896882859581786"))
+				Expect(scanStructs[1].Address).To(Equal("0x4bbd1D4Cb0c1a2C6A0bC7b2C6A0b1D4Cb0c1a2C6"))
+				Expect(scanStructs[1].Balance).To(Equal("1000"))
+			})
+
+			It("Fails with empty results", func() {
+				err = dataStore.PersistBlockResults([]types.Result{}, con.Address, con.Name)
+				Expect(err).To(HaveOccurred())
+			})
 		})
 	})
 
@@ -235,6 +303,12 @@ var _ = Describe("Repository", func() {
 				err = dataStore.PersistResults([]types.Result{}, method, con.Address, con.Name)
 				Expect(err).To(HaveOccurred())
 			})
+
+			It("Fails if a result does not have a value for every return column", func() {
+				mockResult.Outputs = nil
+				err = dataStore.PersistResults([]types.Result{mockResult}, method, con.Address, con.Name)
+				Expect(err).To(HaveOccurred())
+			})
 		})
 	})
 })
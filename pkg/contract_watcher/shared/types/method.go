@@ -18,6 +18,7 @@ package types
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -36,10 +37,9 @@ type Method struct {
 // Result is used to hold instance of result from method call with given inputs and block
 type Result struct {
 	Method
-	Inputs []interface{} // Will only use addresses
-	Output interface{}
-	PgType string // Holds output pg type
-	Block  int64
+	Inputs  []interface{}
+	Outputs []interface{} // One entry per method return value, in the order of ReturnColumns
+	Block   int64
 }
 
 // NewMethod unpacks abi.Method into our custom Method struct
@@ -83,8 +83,8 @@ func NewMethod(m abi.Method) Method {
 			outputs[i].PgType = "BOOLEAN"
 		case abi.BytesTy, abi.FixedBytesTy:
 			outputs[i].PgType = "BYTEA"
-		case abi.ArrayTy:
-			outputs[i].PgType = "TEXT[]"
+		case abi.ArrayTy, abi.SliceTy, abi.TupleTy:
+			outputs[i].PgType = "JSONB" // composite return values are stored as json documents
 		case abi.FixedPointTy:
 			outputs[i].PgType = "MONEY" // use shopspring/decimal for fixed point numbers in go and money type in postgres?
 		default:
@@ -111,3 +111,24 @@ func (m Method) Sig() common.Hash {
 
 	return crypto.Keccak256Hash([]byte(fmt.Sprintf("%v(%v)", m.Name, strings.Join(types, ","))))
 }
+
+// ReturnColumns returns the names of the columns the method's return values are persisted to
+// A single return value keeps the "returned" column, multiple values each get a "returned_<name>" column,
+// falling back to their position when a value is unnamed or its name collides with another's
+func (m Method) ReturnColumns() []string {
+	if len(m.Return) == 1 {
+		return []string{"returned"}
+	}
+	columns := make([]string, len(m.Return))
+	seen := make(map[string]bool, len(m.Return))
+	for i, ret := range m.Return {
+		name := strings.ToLower(ret.Name)
+		if name == "" || seen[name] {
+			name = strconv.Itoa(i)
+		}
+		seen[name] = true
+		columns[i] = "returned_" + name
+	}
+
+	return columns
+}
@@ -40,6 +40,16 @@ type BlockChain interface {
 
 type ContractDataFetcher interface {
 	FetchContractData(abiJSON string, address string, method string, methodArgs []interface{}, result interface{}, blockNumber int64) error
+	FetchContractDataBatch(abiJSON string, address string, calls []ContractCall, blockNumber int64) error
+}
+
+// ContractCall is a single contract method call made as part of a batch
+// Its return values are unpacked into Result, or the reason the call failed is recorded in Error
+type ContractCall struct {
+	Method     string
+	MethodArgs []interface{}
+	Result     interface{}
+	Error      error
 }
 
 type AccountDataFetcher interface {
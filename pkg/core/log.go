@@ -32,4 +32,5 @@ type HeaderSyncLog struct {
 	HeaderID    int64 `db:"header_id"`
 	Log         types.Log
 	Transformed bool
+	Provisional bool // Set when the log's header has not yet reached the transformer's confirmation depth
 }
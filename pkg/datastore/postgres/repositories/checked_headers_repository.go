@@ -70,3 +70,22 @@ func (repo CheckedHeadersRepository) UncheckedHeaders(startingBlockNumber, endin
 
 	return result, err
 }
+
+// Return the block number of the last checked header from startingBlockNumber that isn't preceded by an unchecked header,
+// or the block before startingBlockNumber if there is none
+func (repo CheckedHeadersRepository) LastCheckedBlock(startingBlockNumber int64) (int64, error) {
+	var blockNumber int64
+	err := repo.db.Get(&blockNumber, `SELECT COALESCE(MAX(block_number), $1 - 1)
+			FROM public.headers
+			WHERE check_count > 0
+			AND block_number >= $1
+			AND eth_node_fingerprint = $2
+			AND NOT EXISTS (
+				SELECT 1 FROM public.headers unchecked
+				WHERE unchecked.check_count = 0
+				AND unchecked.block_number >= $1
+				AND unchecked.block_number < headers.block_number
+				AND unchecked.eth_node_fingerprint = $2
+			)`, startingBlockNumber, repo.db.Node.ID)
+	return blockNumber, err
+}
@@ -119,6 +119,57 @@ var _ = Describe("Checked Headers repository", func() {
 		})
 	})
 
+	Describe("LastCheckedBlock", func() {
+		var (
+			headerRepository    datastore.HeaderRepository
+			startingBlockNumber int64
+		)
+
+		BeforeEach(func() {
+			headerRepository = repositories.NewHeaderRepository(db)
+			startingBlockNumber = rand.Int63n(1000000) + 1
+		})
+
+		createHeader := func(blockNumber int64, checked bool) {
+			headerID, headerErr := headerRepository.CreateOrUpdateHeader(fakes.GetFakeHeader(blockNumber))
+			Expect(headerErr).NotTo(HaveOccurred())
+			if checked {
+				Expect(repo.MarkHeaderChecked(headerID)).To(Succeed())
+			}
+		}
+
+		It("returns the last checked header", func() {
+			createHeader(startingBlockNumber, true)
+			createHeader(startingBlockNumber+1, true)
+
+			blockNumber, err := repo.LastCheckedBlock(startingBlockNumber)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockNumber).To(Equal(startingBlockNumber + 1))
+		})
+
+		It("does not return headers checked after an unchecked header", func() {
+			createHeader(startingBlockNumber, true)
+			createHeader(startingBlockNumber+1, false)
+			createHeader(startingBlockNumber+2, true)
+
+			blockNumber, err := repo.LastCheckedBlock(startingBlockNumber)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockNumber).To(Equal(startingBlockNumber))
+		})
+
+		It("returns the block before the starting block if no header is checked", func() {
+			createHeader(startingBlockNumber-1, true)
+			createHeader(startingBlockNumber, false)
+
+			blockNumber, err := repo.LastCheckedBlock(startingBlockNumber)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockNumber).To(Equal(startingBlockNumber - 1))
+		})
+	})
+
 	Describe("UncheckedHeaders", func() {
 		var (
 			headerRepository      datastore.HeaderRepository
@@ -0,0 +1,116 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repositories
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+)
+
+const insertProvisionalLogQuery = `INSERT INTO public.provisional_header_sync_logs
+		(log_id, header_id, transformer_name, block_number, raw) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`
+
+// ProvisionalLogRepository records logs that were delegated to a transformer before reaching their confirmation depth
+type ProvisionalLogRepository struct {
+	db *postgres.DB
+}
+
+func NewProvisionalLogRepository(db *postgres.DB) ProvisionalLogRepository {
+	return ProvisionalLogRepository{db: db}
+}
+
+type provisionalLog struct {
+	LogID     int64 `db:"log_id"`
+	HeaderID  int64 `db:"header_id"`
+	Raw       []byte
+	Canonical bool
+}
+
+func (repository ProvisionalLogRepository) CreateProvisionalLogs(transformerName string, logs []core.HeaderSyncLog) error {
+	tx, txErr := repository.db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	for _, log := range logs {
+		err := insertProvisionalLog(tx, transformerName, log)
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logrus.Errorf("failed to rollback provisional log insert: %s", rollbackErr.Error())
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertProvisionalLog(tx *sqlx.Tx, transformerName string, log core.HeaderSyncLog) error {
+	raw, jsonErr := log.Log.MarshalJSON()
+	if jsonErr != nil {
+		return jsonErr
+	}
+	_, insertErr := tx.Exec(insertProvisionalLogQuery, log.ID, log.HeaderID, transformerName, log.Log.BlockNumber, raw)
+	return insertErr
+}
+
+// GetResolvableProvisionalLogs returns the transformer's provisional logs that are still present in header_sync_logs
+// at or below the given block number, and all of its provisional logs that have been removed with a reorged header
+func (repository ProvisionalLogRepository) GetResolvableProvisionalLogs(transformerName string, confirmedBlockNumber int64) ([]core.HeaderSyncLog, []core.HeaderSyncLog, error) {
+	var rows []provisionalLog
+	selectErr := repository.db.Select(&rows, `SELECT p.log_id, p.header_id, p.raw, (l.id IS NOT NULL) AS canonical
+		FROM public.provisional_header_sync_logs p
+		LEFT JOIN public.header_sync_logs l ON l.id = p.log_id
+		WHERE p.transformer_name = $1 AND (p.block_number <= $2 OR l.id IS NULL)
+		ORDER BY p.block_number, p.log_id`, transformerName, confirmedBlockNumber)
+	if selectErr != nil {
+		return nil, nil, selectErr
+	}
+
+	var canonical, removed []core.HeaderSyncLog
+	for _, row := range rows {
+		var log types.Log
+		unmarshalErr := log.UnmarshalJSON(row.Raw)
+		if unmarshalErr != nil {
+			return nil, nil, unmarshalErr
+		}
+		log.Removed = !row.Canonical
+		headerSyncLog := core.HeaderSyncLog{
+			ID:          row.LogID,
+			HeaderID:    row.HeaderID,
+			Log:         log,
+			Transformed: true,
+		}
+		if row.Canonical {
+			canonical = append(canonical, headerSyncLog)
+		} else {
+			removed = append(removed, headerSyncLog)
+		}
+	}
+	return canonical, removed, nil
+}
+
+func (repository ProvisionalLogRepository) DeleteProvisionalLogs(transformerName string, logIDs []int64) error {
+	_, deleteErr := repository.db.Exec(`DELETE FROM public.provisional_header_sync_logs
+		WHERE transformer_name = $1 AND log_id = ANY($2)`, transformerName, pq.Array(logIDs))
+	return deleteErr
+}
@@ -0,0 +1,51 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
+)
+
+// StorageDiffCheckpointRepository records how far each CSV storage diff source has been read
+type StorageDiffCheckpointRepository struct {
+	db *postgres.DB
+}
+
+func NewStorageDiffCheckpointRepository(db *postgres.DB) StorageDiffCheckpointRepository {
+	return StorageDiffCheckpointRepository{db: db}
+}
+
+// GetCheckpoint returns the position to resume reading the source from, which is the start if it has no checkpoint
+func (repository StorageDiffCheckpointRepository) GetCheckpoint(source string) (fs.Position, error) {
+	var position fs.Position
+	err := repository.db.QueryRowx(`SELECT segment, byte_offset FROM public.storage_diff_checkpoints WHERE source = $1`,
+		source).Scan(&position.Segment, &position.Offset)
+	if err == sql.ErrNoRows {
+		return fs.Position{}, nil
+	}
+	return position, err
+}
+
+func (repository StorageDiffCheckpointRepository) SaveCheckpoint(source string, position fs.Position) error {
+	_, err := repository.db.Exec(`INSERT INTO public.storage_diff_checkpoints (source, segment, byte_offset) VALUES ($1, $2, $3)
+		ON CONFLICT (source) DO UPDATE SET segment = $2, byte_offset = $3, updated_at = NOW()`,
+		source, position.Segment, position.Offset)
+	return err
+}
@@ -0,0 +1,84 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repositories_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
+	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
+	"github.com/vulcanize/vulcanizedb/test_config"
+)
+
+var _ = Describe("Storage diff checkpoint repository", func() {
+	var (
+		db     *postgres.DB
+		repo   repositories.StorageDiffCheckpointRepository
+		source = "/data/diffs"
+	)
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		repo = repositories.NewStorageDiffCheckpointRepository(db)
+	})
+
+	It("returns the start of the source if it has no checkpoint", func() {
+		position, err := repo.GetCheckpoint(source)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(position).To(Equal(fs.Position{}))
+	})
+
+	It("returns the saved checkpoint", func() {
+		saveErr := repo.SaveCheckpoint(source, fs.Position{Segment: "diffs.1.csv", Offset: 123})
+		Expect(saveErr).NotTo(HaveOccurred())
+
+		position, err := repo.GetCheckpoint(source)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(position).To(Equal(fs.Position{Segment: "diffs.1.csv", Offset: 123}))
+	})
+
+	It("replaces the source's previous checkpoint", func() {
+		firstErr := repo.SaveCheckpoint(source, fs.Position{Segment: "diffs.1.csv", Offset: 123})
+		Expect(firstErr).NotTo(HaveOccurred())
+		secondErr := repo.SaveCheckpoint(source, fs.Position{Segment: "diffs.2.csv", Offset: 45})
+		Expect(secondErr).NotTo(HaveOccurred())
+
+		position, err := repo.GetCheckpoint(source)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(position).To(Equal(fs.Position{Segment: "diffs.2.csv", Offset: 45}))
+		var count int
+		Expect(db.Get(&count, `SELECT COUNT(*) FROM public.storage_diff_checkpoints`)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+
+	It("keeps checkpoints for each source", func() {
+		firstErr := repo.SaveCheckpoint(source, fs.Position{Segment: "diffs.1.csv", Offset: 123})
+		Expect(firstErr).NotTo(HaveOccurred())
+		secondErr := repo.SaveCheckpoint("/data/other.csv", fs.Position{Segment: "other.csv", Offset: 45})
+		Expect(secondErr).NotTo(HaveOccurred())
+
+		position, err := repo.GetCheckpoint(source)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(position).To(Equal(fs.Position{Segment: "diffs.1.csv", Offset: 123}))
+	})
+})
@@ -19,6 +19,9 @@ package repositories
 import (
 	"database/sql"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/lib/pq"
+
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 )
@@ -45,3 +48,51 @@ func (repository StorageDiffRepository) CreateStorageDiff(input utils.StorageDif
 	}
 	return storageDiffID, err
 }
+
+// GetNonCanonicalDiffs returns unflagged diffs within depth blocks of the latest header whose block hash does not
+// match any header at their height, in block order
+func (repository StorageDiffRepository) GetNonCanonicalDiffs(depth int64, limit int) ([]utils.PersistedStorageDiff, error) {
+	var result []utils.PersistedStorageDiff
+	err := repository.db.Select(&result, `SELECT id, hashed_address, block_height, block_hash, storage_key, storage_value
+		FROM public.storage_diff
+		WHERE NOT non_canonical
+			AND block_height >= (SELECT MAX(block_number) FROM public.headers) - $1
+			AND EXISTS (SELECT 1 FROM public.headers WHERE headers.block_number = storage_diff.block_height)
+			AND NOT EXISTS (SELECT 1 FROM public.headers WHERE headers.block_number = storage_diff.block_height
+				AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex'))
+		ORDER BY block_height, id
+		LIMIT $2`, depth, limit)
+	return result, err
+}
+
+// GetCanonicalDiffs returns the diffs for a contract at a block height whose block hash matches a header
+func (repository StorageDiffRepository) GetCanonicalDiffs(blockHeight int, hashedAddress common.Hash) ([]utils.PersistedStorageDiff, error) {
+	var result []utils.PersistedStorageDiff
+	err := repository.db.Select(&result, `SELECT id, hashed_address, block_height, block_hash, storage_key, storage_value
+		FROM public.storage_diff
+		WHERE block_height = $1 AND hashed_address = $2 AND NOT non_canonical
+			AND EXISTS (SELECT 1 FROM public.headers WHERE headers.block_number = storage_diff.block_height
+				AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex'))
+		ORDER BY id`, blockHeight, hashedAddress.Bytes())
+	return result, err
+}
+
+// MarkNonCanonical flags a diff as belonging to a block removed by a reorg
+func (repository StorageDiffRepository) MarkNonCanonical(diffID int64) error {
+	_, err := repository.db.Exec(`UPDATE public.storage_diff SET non_canonical = TRUE WHERE id = $1`, diffID)
+	return err
+}
+
+// GetLatestDiffs returns the latest canonical diff of each of a contract's storage keys at or before the block height
+func (repository StorageDiffRepository) GetLatestDiffs(hashedAddress common.Hash, storageKeys []common.Hash, blockHeight int) ([]utils.PersistedStorageDiff, error) {
+	keys := make(pq.ByteaArray, 0, len(storageKeys))
+	for _, key := range storageKeys {
+		keys = append(keys, key.Bytes())
+	}
+	var result []utils.PersistedStorageDiff
+	err := repository.db.Select(&result, `SELECT DISTINCT ON (storage_key) id, hashed_address, block_height, block_hash, storage_key, storage_value
+		FROM public.storage_diff
+		WHERE hashed_address = $1 AND storage_key = ANY($2) AND block_height <= $3 AND NOT non_canonical
+		ORDER BY storage_key, block_height DESC, id DESC`, hashedAddress.Bytes(), keys, blockHeight)
+	return result, err
+}
@@ -20,12 +20,14 @@ import (
 	"database/sql"
 	"math/rand"
 
+	"github.com/ethereum/go-ethereum/common"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/test_data"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres"
 	"github.com/vulcanize/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
 	"github.com/vulcanize/vulcanizedb/test_config"
 )
 
@@ -56,7 +58,7 @@ var _ = Describe("Storage diffs repository", func() {
 			Expect(createErr).NotTo(HaveOccurred())
 			Expect(id).NotTo(BeZero())
 			var persisted utils.PersistedStorageDiff
-			getErr := db.Get(&persisted, `SELECT * FROM public.storage_diff`)
+			getErr := db.Get(&persisted, `SELECT id, block_height, block_hash, hashed_address, storage_key, storage_value FROM public.storage_diff`)
 			Expect(getErr).NotTo(HaveOccurred())
 			Expect(persisted.ID).To(Equal(id))
 			Expect(persisted.HashedAddress).To(Equal(fakeStorageDiff.HashedAddress))
@@ -80,4 +82,156 @@ var _ = Describe("Storage diffs repository", func() {
 			Expect(count).To(Equal(1))
 		})
 	})
+	Describe("checking diffs against headers", func() {
+		var (
+			headerRepository repositories.HeaderRepository
+			canonicalHash    = common.HexToHash("0xabc")
+			orphanedHash     = common.HexToHash("0xdef")
+			blockHeight      = 100
+		)
+
+		BeforeEach(func() {
+			headerRepository = repositories.NewHeaderRepository(db)
+			header := fakes.GetFakeHeader(int64(blockHeight))
+			header.Hash = canonicalHash.Hex()
+			_, headerErr := headerRepository.CreateOrUpdateHeader(header)
+			Expect(headerErr).NotTo(HaveOccurred())
+		})
+
+		createDiff := func(blockHash common.Hash, blockHeight int) utils.PersistedStorageDiff {
+			diff := fakeStorageDiff
+			diff.BlockHash = blockHash
+			diff.BlockHeight = blockHeight
+			id, createErr := repo.CreateStorageDiff(diff)
+			Expect(createErr).NotTo(HaveOccurred())
+			return utils.ToPersistedDiff(diff, id)
+		}
+
+		Describe("GetNonCanonicalDiffs", func() {
+			It("returns diffs whose block hash does not match the header at their height", func() {
+				createDiff(canonicalHash, blockHeight)
+				orphanedDiff := createDiff(orphanedHash, blockHeight)
+
+				diffs, err := repo.GetNonCanonicalDiffs(10, 100)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(Equal([]utils.PersistedStorageDiff{orphanedDiff}))
+			})
+
+			It("does not return diffs without a header at their height", func() {
+				createDiff(orphanedHash, blockHeight+1)
+
+				diffs, err := repo.GetNonCanonicalDiffs(10, 100)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(BeEmpty())
+			})
+
+			It("does not return diffs deeper than the given depth behind the latest header", func() {
+				createDiff(orphanedHash, blockHeight)
+				latestHeader := fakes.GetFakeHeader(int64(blockHeight + 11))
+				_, headerErr := headerRepository.CreateOrUpdateHeader(latestHeader)
+				Expect(headerErr).NotTo(HaveOccurred())
+
+				diffs, err := repo.GetNonCanonicalDiffs(10, 100)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(BeEmpty())
+			})
+
+			It("does not return diffs that have been flagged", func() {
+				orphanedDiff := createDiff(orphanedHash, blockHeight)
+				markErr := repo.MarkNonCanonical(orphanedDiff.ID)
+				Expect(markErr).NotTo(HaveOccurred())
+
+				diffs, err := repo.GetNonCanonicalDiffs(10, 100)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(BeEmpty())
+			})
+		})
+
+		Describe("GetCanonicalDiffs", func() {
+			It("returns the contract's diffs matching the header at the height", func() {
+				canonicalDiff := createDiff(canonicalHash, blockHeight)
+				createDiff(orphanedHash, blockHeight)
+
+				diffs, err := repo.GetCanonicalDiffs(blockHeight, fakeStorageDiff.HashedAddress)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(Equal([]utils.PersistedStorageDiff{canonicalDiff}))
+			})
+
+			It("does not return other contracts' diffs", func() {
+				createDiff(canonicalHash, blockHeight)
+
+				diffs, err := repo.GetCanonicalDiffs(blockHeight, test_data.FakeHash())
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diffs).To(BeEmpty())
+			})
+		})
+
+		Describe("MarkNonCanonical", func() {
+			It("flags the diff", func() {
+				orphanedDiff := createDiff(orphanedHash, blockHeight)
+
+				err := repo.MarkNonCanonical(orphanedDiff.ID)
+
+				Expect(err).NotTo(HaveOccurred())
+				var nonCanonical bool
+				getErr := db.Get(&nonCanonical, `SELECT non_canonical FROM public.storage_diff WHERE id = $1`, orphanedDiff.ID)
+				Expect(getErr).NotTo(HaveOccurred())
+				Expect(nonCanonical).To(BeTrue())
+			})
+		})
+	})
+	Describe("GetLatestDiffs", func() {
+		createDiff := func(storageKey common.Hash, blockHeight int) utils.PersistedStorageDiff {
+			diff := fakeStorageDiff
+			diff.BlockHash = test_data.FakeHash()
+			diff.BlockHeight = blockHeight
+			diff.StorageKey = storageKey
+			diff.StorageValue = test_data.FakeHash()
+			id, createErr := repo.CreateStorageDiff(diff)
+			Expect(createErr).NotTo(HaveOccurred())
+			return utils.ToPersistedDiff(diff, id)
+		}
+
+		It("returns the latest diff of each key at or before the block height", func() {
+			firstKey, secondKey := test_data.FakeHash(), test_data.FakeHash()
+			createDiff(firstKey, 1)
+			firstKeyDiff := createDiff(firstKey, 2)
+			createDiff(firstKey, 3)
+			secondKeyDiff := createDiff(secondKey, 1)
+			createDiff(test_data.FakeHash(), 1)
+
+			diffs, err := repo.GetLatestDiffs(fakeStorageDiff.HashedAddress, []common.Hash{firstKey, secondKey}, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(ConsistOf(firstKeyDiff, secondKeyDiff))
+		})
+
+		It("does not return non-canonical diffs", func() {
+			key := test_data.FakeHash()
+			canonicalDiff := createDiff(key, 1)
+			nonCanonicalDiff := createDiff(key, 2)
+			Expect(repo.MarkNonCanonical(nonCanonicalDiff.ID)).To(Succeed())
+
+			diffs, err := repo.GetLatestDiffs(fakeStorageDiff.HashedAddress, []common.Hash{key}, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(ConsistOf(canonicalDiff))
+		})
+
+		It("does not return other contracts' diffs", func() {
+			key := test_data.FakeHash()
+			createDiff(key, 1)
+
+			diffs, err := repo.GetLatestDiffs(test_data.FakeHash(), []common.Hash{key}, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+	})
 })
@@ -17,11 +17,13 @@
 package datastore
 
 import (
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/jmoiron/sqlx"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 	"github.com/vulcanize/vulcanizedb/pkg/core"
 	"github.com/vulcanize/vulcanizedb/pkg/filters"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
 )
 
 type AddressRepository interface {
@@ -39,6 +41,7 @@ type CheckedHeadersRepository interface {
 	MarkHeaderChecked(headerID int64) error
 	MarkHeadersUnchecked(startingBlockNumber int64) error
 	UncheckedHeaders(startingBlockNumber, endingBlockNumber, checkCount int64) ([]core.Header, error)
+	LastCheckedBlock(startingBlockNumber int64) (int64, error)
 }
 
 type CheckedLogsRepository interface {
@@ -74,6 +77,12 @@ type HeaderSyncLogRepository interface {
 	CreateHeaderSyncLogs(headerID int64, logs []types.Log) error
 }
 
+type ProvisionalLogRepository interface {
+	CreateProvisionalLogs(transformerName string, logs []core.HeaderSyncLog) error
+	GetResolvableProvisionalLogs(transformerName string, confirmedBlockNumber int64) (canonical, removed []core.HeaderSyncLog, err error)
+	DeleteProvisionalLogs(transformerName string, logIDs []int64) error
+}
+
 type FullSyncReceiptRepository interface {
 	CreateReceiptsAndLogs(blockID int64, receipts []core.Receipt) error
 	CreateFullSyncReceiptInTx(blockID int64, receipt core.Receipt, tx *sqlx.Tx) (int64, error)
@@ -86,6 +95,14 @@ type HeaderSyncReceiptRepository interface {
 
 type StorageDiffRepository interface {
 	CreateStorageDiff(input utils.StorageDiffInput) (int64, error)
+	GetNonCanonicalDiffs(depth int64, limit int) ([]utils.PersistedStorageDiff, error)
+	GetCanonicalDiffs(blockHeight int, hashedAddress common.Hash) ([]utils.PersistedStorageDiff, error)
+	MarkNonCanonical(diffID int64) error
+}
+
+type StorageDiffCheckpointRepository interface {
+	GetCheckpoint(source string) (fs.Position, error)
+	SaveCheckpoint(source string, position fs.Position) error
 }
 
 type WatchedEventRepository interface {
@@ -233,6 +233,40 @@ var _ = Describe("Geth blockchain", func() {
 		})
 	})
 
+	Describe("fetching contract data in a batch", func() {
+		It("makes an eth_call for each contract call in a single batch", func() {
+			mockRpcClient.SetReturnContractData(common.LeftPadBytes(big.NewInt(1000).Bytes(), 32))
+			var firstBalance, secondBalance *big.Int
+			calls := []vulcCore.ContractCall{
+				{Method: "balanceOf", MethodArgs: []interface{}{common.HexToAddress("0x40")}, Result: &firstBalance},
+				{Method: "balanceOf", MethodArgs: []interface{}{common.HexToAddress("0x41")}, Result: &secondBalance},
+			}
+
+			err := blockChain.FetchContractDataBatch(balanceOfAbi, "0x50", calls, 100)
+
+			Expect(err).NotTo(HaveOccurred())
+			mockRpcClient.AssertBatchCalledWith("eth_call", 2)
+			Expect(calls[0].Error).NotTo(HaveOccurred())
+			Expect(calls[1].Error).NotTo(HaveOccurred())
+			Expect(firstBalance).To(Equal(big.NewInt(1000)))
+			Expect(secondBalance).To(Equal(big.NewInt(1000)))
+		})
+
+		It("records an error for a call that can't be packed without making it", func() {
+			var balance *big.Int
+			calls := []vulcCore.ContractCall{
+				{Method: "balanceOf", MethodArgs: []interface{}{"not an address"}, Result: &balance},
+				{Method: "balanceOf", MethodArgs: []interface{}{common.HexToAddress("0x41")}, Result: &balance},
+			}
+
+			err := blockChain.FetchContractDataBatch(balanceOfAbi, "0x50", calls, 100)
+
+			Expect(err).NotTo(HaveOccurred())
+			mockRpcClient.AssertBatchCalledWith("eth_call", 1)
+			Expect(calls[0].Error).To(HaveOccurred())
+		})
+	})
+
 	Describe("getting the most recent block number", func() {
 		It("fetches latest header from ethClient", func() {
 			blockNumber := int64(100)
@@ -270,3 +304,5 @@ var _ = Describe("Geth blockchain", func() {
 		})
 	})
 })
+
+var balanceOfAbi = `[{"constant":true,"inputs":[{"name":"who","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`
@@ -76,7 +76,11 @@ func (client RPCClient) BatchCall(batch []BatchElem) error {
 		}
 		rpcBatch = append(rpcBatch, newBatchElem)
 	}
-	return client.client.BatchCall(rpcBatch)
+	batchErr := client.client.BatchCall(rpcBatch)
+	for i := range rpcBatch {
+		batch[i].Error = rpcBatch[i].Error
+	}
+	return batchErr
 }
 
 // Subscribe subscribes to an rpc "namespace_subscribe" subscription with the given channel
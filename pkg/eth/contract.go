@@ -23,6 +23,10 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/eth/client"
 )
 
 var (
@@ -54,6 +58,64 @@ func (blockChain *BlockChain) FetchContractData(abiJSON string, address string,
 	return parsed.Unpack(result, method, output)
 }
 
+// FetchContractDataBatch makes the given calls to the contract in JSON-RPC batch requests of at most MAX_BATCH_SIZE calls
+// A call that fails has its Error set, an error is only returned if a batch request itself fails
+func (blockChain *BlockChain) FetchContractDataBatch(abiJSON string, address string, calls []core.ContractCall, blockNumber int64) error {
+	parsed, err := ParseAbi(abiJSON)
+	if err != nil {
+		return err
+	}
+	to := common.HexToAddress(address)
+	blockNumberArg := "latest"
+	if blockNumber > 0 {
+		blockNumberArg = hexutil.EncodeBig(big.NewInt(blockNumber))
+	}
+
+	for start := 0; start < len(calls); start += MAX_BATCH_SIZE {
+		end := start + MAX_BATCH_SIZE
+		if end > len(calls) {
+			end = len(calls)
+		}
+		outputs := make([]hexutil.Bytes, end-start)
+		batch := make([]client.BatchElem, 0, end-start)
+		batched := make([]int, 0, end-start) // Index of each batch element's call
+		for i := start; i < end; i++ {
+			input, packErr := parsed.Pack(calls[i].Method, calls[i].MethodArgs...)
+			if packErr != nil {
+				calls[i].Error = packErr
+				continue
+			}
+			msg := map[string]interface{}{
+				"to":   to,
+				"data": hexutil.Bytes(input),
+			}
+			batch = append(batch, client.BatchElem{
+				Method: "eth_call",
+				Result: &outputs[i-start],
+				Args:   []interface{}{msg, blockNumberArg},
+			})
+			batched = append(batched, i)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		err = blockChain.rpcClient.BatchCall(batch)
+		if err != nil {
+			return err
+		}
+		for j, i := range batched {
+			if batch[j].Error != nil {
+				calls[i].Error = batch[j].Error
+				continue
+			}
+			calls[i].Error = parsed.Unpack(calls[i].Result, calls[i].Method, outputs[i-start])
+		}
+	}
+
+	return nil
+}
+
 func (blockChain *BlockChain) callContract(contractHash string, input []byte, blockNumber *big.Int) ([]byte, error) {
 	to := common.HexToAddress(contractHash)
 	msg := ethereum.CallMsg{To: &to, Data: input}
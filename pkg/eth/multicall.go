@@ -0,0 +1,93 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/vulcanizedb/pkg/core"
+)
+
+// MulticallAbi is the abi of the Multicall aggregator contract's aggregate method
+const MulticallAbi = `[{"constant":false,"inputs":[{"components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate","outputs":[{"name":"blockNumber","type":"uint256"},{"name":"returnData","type":"bytes[]"}],"payable":false,"stateMutability":"nonpayable","type":"function"}]`
+
+// multicall is a single call passed to the aggregate method
+type multicall struct {
+	Target   common.Address
+	CallData []byte
+}
+
+// MulticallBlockChain is a BlockChain which batches contract calls
+// into a single call to an on-chain Multicall aggregator contract
+type MulticallBlockChain struct {
+	core.BlockChain
+	Aggregator string // Address of the Multicall aggregator contract
+}
+
+// NewMulticallBlockChain wraps the given BlockChain to batch its contract calls through the given aggregator contract
+func NewMulticallBlockChain(blockChain core.BlockChain, aggregator string) *MulticallBlockChain {
+	return &MulticallBlockChain{
+		BlockChain: blockChain,
+		Aggregator: aggregator,
+	}
+}
+
+// FetchContractDataBatch aggregates the given calls into a single call to the Multicall contract
+// The aggregate call reverts if any one of its calls does, in which case the calls are
+// made through the wrapped BlockChain instead so that only the failing calls have their Error set
+func (chain *MulticallBlockChain) FetchContractDataBatch(abiJSON string, address string, calls []core.ContractCall, blockNumber int64) error {
+	parsed, err := ParseAbi(abiJSON)
+	if err != nil {
+		return err
+	}
+	target := common.HexToAddress(address)
+	aggregated := make([]multicall, 0, len(calls))
+	batched := make([]int, 0, len(calls)) // Index of each aggregated call
+	for i, call := range calls {
+		input, packErr := parsed.Pack(call.Method, call.MethodArgs...)
+		if packErr != nil {
+			calls[i].Error = packErr
+			continue
+		}
+		aggregated = append(aggregated, multicall{Target: target, CallData: input})
+		batched = append(batched, i)
+	}
+	if len(aggregated) == 0 {
+		return nil
+	}
+
+	var aggregateBlock *big.Int
+	var returnData [][]byte
+	out := []interface{}{&aggregateBlock, &returnData}
+	err = chain.BlockChain.FetchContractData(MulticallAbi, chain.Aggregator, "aggregate", []interface{}{aggregated}, &out, blockNumber)
+	if err != nil {
+		logrus.Debugf("multicall aggregate failed at block %d, falling back to batched calls: %s", blockNumber, err.Error())
+		return chain.BlockChain.FetchContractDataBatch(abiJSON, address, calls, blockNumber)
+	}
+	if len(returnData) != len(aggregated) {
+		return fmt.Errorf("multicall returned %d results for %d calls", len(returnData), len(aggregated))
+	}
+	for j, i := range batched {
+		calls[i].Error = parsed.Unpack(calls[i].Result, calls[i].Method, returnData[j])
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vulcCore "github.com/vulcanize/vulcanizedb/pkg/core"
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+	"github.com/vulcanize/vulcanizedb/pkg/fakes"
+)
+
+var _ = Describe("Multicall blockchain", func() {
+	var (
+		mockClient    *fakes.MockEthClient
+		mockRpcClient *fakes.MockRPCClient
+		blockChain    *eth.MulticallBlockChain
+		calls         []vulcCore.ContractCall
+		balances      []*big.Int
+	)
+
+	BeforeEach(func() {
+		mockClient = fakes.NewMockEthClient()
+		mockRpcClient = fakes.NewMockRPCClient()
+		node := vulcCore.Node{}
+		blockChain = eth.NewMulticallBlockChain(eth.NewBlockChain(mockClient, mockRpcClient, node, fakes.NewMockTransactionConverter()), "0x60")
+		balances = make([]*big.Int, 2)
+		calls = []vulcCore.ContractCall{
+			{Method: "balanceOf", MethodArgs: []interface{}{common.HexToAddress("0x40")}, Result: &balances[0]},
+			{Method: "balanceOf", MethodArgs: []interface{}{common.HexToAddress("0x41")}, Result: &balances[1]},
+		}
+	})
+
+	It("aggregates the calls into a single call to the multicall contract", func() {
+		multicallAbi, err := eth.ParseAbi(eth.MulticallAbi)
+		Expect(err).NotTo(HaveOccurred())
+		returnData := [][]byte{
+			common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+			common.LeftPadBytes(big.NewInt(2000).Bytes(), 32),
+		}
+		aggregateOutput, err := multicallAbi.Methods["aggregate"].Outputs.Pack(big.NewInt(100), returnData)
+		Expect(err).NotTo(HaveOccurred())
+		mockClient.SetCallContractReturnBytes(aggregateOutput)
+
+		err = blockChain.FetchContractDataBatch(balanceOfAbi, "0x50", calls, 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls[0].Error).NotTo(HaveOccurred())
+		Expect(calls[1].Error).NotTo(HaveOccurred())
+		Expect(balances[0]).To(Equal(big.NewInt(1000)))
+		Expect(balances[1]).To(Equal(big.NewInt(2000)))
+	})
+
+	It("falls back to a JSON-RPC batch if the aggregate call fails", func() {
+		mockClient.SetCallContractErr(errors.New("execution reverted"))
+		mockRpcClient.SetReturnContractData(common.LeftPadBytes(big.NewInt(1000).Bytes(), 32))
+
+		err := blockChain.FetchContractDataBatch(balanceOfAbi, "0x50", calls, 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		mockRpcClient.AssertBatchCalledWith("eth_call", 2)
+		Expect(balances[0]).To(Equal(big.NewInt(1000)))
+		Expect(balances[1]).To(Equal(big.NewInt(1000)))
+	})
+})
@@ -18,6 +18,7 @@ package fakes
 
 import (
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 
@@ -36,6 +37,8 @@ type MockBlockChain struct {
 	fetchContractDataPassedMethodArgs  []interface{}
 	fetchContractDataPassedResult      interface{}
 	fetchContractDataPassedBlockNumber int64
+	fetchContractDataBatchMutex        sync.Mutex
+	FetchContractDataBatchPassedCalls  []core.ContractCall
 	getBlockByNumberErr                error
 	GetTransactionsCalled              bool
 	GetTransactionsError               error
@@ -86,6 +89,16 @@ func (chain *MockBlockChain) FetchContractData(abiJSON string, address string, m
 	return chain.fetchContractDataErr
 }
 
+func (chain *MockBlockChain) FetchContractDataBatch(abiJSON string, address string, calls []core.ContractCall, blockNumber int64) error {
+	chain.fetchContractDataBatchMutex.Lock()
+	defer chain.fetchContractDataBatchMutex.Unlock()
+	chain.fetchContractDataPassedAbi = abiJSON
+	chain.fetchContractDataPassedAddress = address
+	chain.FetchContractDataBatchPassedCalls = append(chain.FetchContractDataBatchPassedCalls, calls...)
+	chain.fetchContractDataPassedBlockNumber = blockNumber
+	return chain.fetchContractDataErr
+}
+
 func (chain *MockBlockChain) GetBlockByNumber(blockNumber int64) (core.Block, error) {
 	return core.Block{Number: blockNumber}, chain.getBlockByNumberErr
 }
@@ -21,6 +21,9 @@ import (
 )
 
 type MockCheckedHeadersRepository struct {
+	LastCheckedBlockNumber                  int64
+	LastCheckedBlockReturnError             error
+	LastCheckedBlockStartingBlockNumber     int64
 	MarkHeaderCheckedHeaderID               int64
 	MarkHeaderCheckedReturnError            error
 	MarkHeadersUncheckedCalled              bool
@@ -44,6 +47,11 @@ func (repository *MockCheckedHeadersRepository) MarkHeaderChecked(headerID int64
 	return repository.MarkHeaderCheckedReturnError
 }
 
+func (repository *MockCheckedHeadersRepository) LastCheckedBlock(startingBlockNumber int64) (int64, error) {
+	repository.LastCheckedBlockStartingBlockNumber = startingBlockNumber
+	return repository.LastCheckedBlockNumber, repository.LastCheckedBlockReturnError
+}
+
 func (repository *MockCheckedHeadersRepository) UncheckedHeaders(startingBlockNumber, endingBlockNumber, checkCount int64) ([]core.Header, error) {
 	repository.UncheckedHeadersStartingBlockNumber = startingBlockNumber
 	repository.UncheckedHeadersEndingBlockNumber = endingBlockNumber
@@ -0,0 +1,51 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import "github.com/vulcanize/vulcanizedb/pkg/core"
+
+type MockProvisionalLogRepository struct {
+	CreateError         error
+	CreatePassedLogs    map[string][]core.HeaderSyncLog
+	DeleteError         error
+	DeletePassedLogIDs  map[string][]int64
+	GetError            error
+	GetPassedBlock      int64
+	ReturnCanonicalLogs []core.HeaderSyncLog
+	ReturnRemovedLogs   []core.HeaderSyncLog
+}
+
+func (repository *MockProvisionalLogRepository) CreateProvisionalLogs(transformerName string, logs []core.HeaderSyncLog) error {
+	if repository.CreatePassedLogs == nil {
+		repository.CreatePassedLogs = make(map[string][]core.HeaderSyncLog)
+	}
+	repository.CreatePassedLogs[transformerName] = append(repository.CreatePassedLogs[transformerName], logs...)
+	return repository.CreateError
+}
+
+func (repository *MockProvisionalLogRepository) GetResolvableProvisionalLogs(transformerName string, confirmedBlockNumber int64) ([]core.HeaderSyncLog, []core.HeaderSyncLog, error) {
+	repository.GetPassedBlock = confirmedBlockNumber
+	return repository.ReturnCanonicalLogs, repository.ReturnRemovedLogs, repository.GetError
+}
+
+func (repository *MockProvisionalLogRepository) DeleteProvisionalLogs(transformerName string, logIDs []int64) error {
+	if repository.DeletePassedLogIDs == nil {
+		repository.DeletePassedLogIDs = make(map[string][]int64)
+	}
+	repository.DeletePassedLogIDs[transformerName] = append(repository.DeletePassedLogIDs[transformerName], logIDs...)
+	return repository.DeleteError
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/ethereum/go-ethereum/statediff"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -43,6 +44,7 @@ type MockRPCClient struct {
 	passedPayloadChan   chan statediff.Payload
 	passedSubscribeArgs []interface{}
 	lengthOfBatch       int
+	returnContractData  hexutil.Bytes
 	returnPOAHeader     core.POAHeader
 	returnPOAHeaders    []core.POAHeader
 	returnPOWHeaders    []*types.Header
@@ -96,6 +98,9 @@ func (client *MockRPCClient) BatchCall(batch []client.BatchElem) error {
 
 			*p = client.returnPOAHeader
 		}
+		if p, ok := batchElem.Result.(*hexutil.Bytes); ok {
+			*p = client.returnContractData
+		}
 	}
 
 	return nil
@@ -162,6 +167,10 @@ func (client *MockRPCClient) SetCallContextErr(err error) {
 	client.callContextErr = err
 }
 
+func (client *MockRPCClient) SetReturnContractData(data hexutil.Bytes) {
+	client.returnContractData = data
+}
+
 func (client *MockRPCClient) SetReturnPOAHeader(header core.POAHeader) {
 	client.returnPOAHeader = header
 }
@@ -17,16 +17,65 @@
 package fakes
 
 import (
+	"github.com/ethereum/go-ethereum/common"
+
 	"github.com/vulcanize/vulcanizedb/libraries/shared/storage/utils"
 )
 
 type MockStorageDiffRepository struct {
-	CreatePassedInputs []utils.StorageDiffInput
-	CreateReturnID     int64
-	CreateReturnError  error
+	CreatePassedInputs         []utils.StorageDiffInput
+	CreateReturnID             int64
+	CreateReturnError          error
+	NonCanonicalDiffs          []utils.PersistedStorageDiff
+	GetNonCanonicalErr         error
+	GetNonCanonicalPassedDepth int64
+	CanonicalDiffs             []utils.PersistedStorageDiff
+	GetCanonicalErr            error
+	GetCanonicalPassedHeights  []int
+	MarkNonCanonicalErr        error
+	MarkedNonCanonicalIDs      []int64
 }
 
 func (repository *MockStorageDiffRepository) CreateStorageDiff(input utils.StorageDiffInput) (int64, error) {
 	repository.CreatePassedInputs = append(repository.CreatePassedInputs, input)
 	return repository.CreateReturnID, repository.CreateReturnError
 }
+
+func (repository *MockStorageDiffRepository) GetNonCanonicalDiffs(depth int64, limit int) ([]utils.PersistedStorageDiff, error) {
+	repository.GetNonCanonicalPassedDepth = depth
+	var diffs []utils.PersistedStorageDiff
+	for _, diff := range repository.NonCanonicalDiffs {
+		if !repository.marked(diff.ID) && len(diffs) < limit {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, repository.GetNonCanonicalErr
+}
+
+func (repository *MockStorageDiffRepository) GetCanonicalDiffs(blockHeight int, hashedAddress common.Hash) ([]utils.PersistedStorageDiff, error) {
+	repository.GetCanonicalPassedHeights = append(repository.GetCanonicalPassedHeights, blockHeight)
+	var diffs []utils.PersistedStorageDiff
+	for _, diff := range repository.CanonicalDiffs {
+		if diff.BlockHeight == blockHeight && diff.HashedAddress == hashedAddress {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, repository.GetCanonicalErr
+}
+
+func (repository *MockStorageDiffRepository) MarkNonCanonical(diffID int64) error {
+	if repository.MarkNonCanonicalErr != nil {
+		return repository.MarkNonCanonicalErr
+	}
+	repository.MarkedNonCanonicalIDs = append(repository.MarkedNonCanonicalIDs, diffID)
+	return nil
+}
+
+func (repository *MockStorageDiffRepository) marked(diffID int64) bool {
+	for _, id := range repository.MarkedNonCanonicalIDs {
+		if id == diffID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package fs
+
+import "os"
+
+type Appender interface {
+	Append(line string) error
+}
+
+// FileAppender appends lines to a file, creating it if needed
+type FileAppender struct {
+	Path string
+}
+
+func (appender FileAppender) Append(line string) error {
+	file, openErr := os.OpenFile(appender.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return openErr
+	}
+	_, writeErr := file.WriteString(line + "\n")
+	if writeErr != nil {
+		file.Close()
+		return writeErr
+	}
+	return file.Close()
+}
@@ -0,0 +1,305 @@
+package fs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+const DefaultFollowPollingInterval = time.Second
+
+// Position is where reading resumes: a segment's name and the byte offset within its uncompressed content
+type Position struct {
+	Segment string
+	Offset  int64
+}
+
+// Line is a line of text and the position just after it
+type Line struct {
+	Text     string
+	Position Position
+}
+
+type Follower interface {
+	Follow(from Position, lines chan<- Line) error
+}
+
+// SegmentFollower reads lines from a file, following it as it grows and after it is rotated or truncated.
+// If Path is a directory, the segments in it are read in numeric order, e.g. diffs.1.csv, diffs.2.csv.gz, diffs.3.csv,
+// and the last uncompressed segment is followed until a later one appears. Gzip compressed segments end in .gz.
+type SegmentFollower struct {
+	Path            string
+	PollingInterval time.Duration
+}
+
+func NewSegmentFollower(path string) SegmentFollower {
+	return SegmentFollower{Path: path, PollingInterval: DefaultFollowPollingInterval}
+}
+
+// Follow sends lines from the given position until reading fails, or a compressed file has been read
+func (follower SegmentFollower) Follow(from Position, lines chan<- Line) error {
+	info, statErr := os.Stat(follower.Path)
+	if statErr != nil {
+		return statErr
+	}
+	if !info.IsDir() {
+		return follower.followFile(from, lines)
+	}
+	return follower.followDirectory(from, lines)
+}
+
+func (follower SegmentFollower) followFile(from Position, lines chan<- Line) error {
+	segment := filepath.Base(follower.Path)
+	offset := from.Offset
+	if !sameSegment(from.Segment, segment) {
+		offset = 0
+	}
+	if isCompressed(segment) {
+		return follower.readSegment(follower.Path, segment, offset, lines, nil)
+	}
+	for {
+		readErr := follower.readSegment(follower.Path, segment, offset, lines, follower.fileRotated)
+		if readErr != errRotated {
+			return readErr
+		}
+		logrus.Infof("following rotated file %s", follower.Path)
+		offset = 0
+	}
+}
+
+func (follower SegmentFollower) followDirectory(from Position, lines chan<- Line) error {
+	var completed string
+	for {
+		segments, listErr := follower.segments()
+		if listErr != nil {
+			return listErr
+		}
+		var next string
+		offset := int64(0)
+		if completed == "" && from.Segment != "" {
+			next = segmentFrom(segments, from.Segment)
+			if sameSegment(next, from.Segment) {
+				offset = from.Offset
+			}
+		} else {
+			next = segmentAfter(segments, completed)
+		}
+		if next == "" {
+			time.Sleep(follower.PollingInterval)
+			continue
+		}
+		var done func(opened os.FileInfo, offset int64) (bool, error)
+		if !isCompressed(next) {
+			done = follower.laterSegmentExists(next)
+		}
+		readErr := follower.readSegment(filepath.Join(follower.Path, next), next, offset, lines, done)
+		if readErr != nil {
+			return readErr
+		}
+		completed = next
+	}
+}
+
+var errRotated = errors.New("file rotated")
+
+// readSegment sends lines from a segment. At the end of the segment, done is polled until it reports the segment is
+// complete, or returns errRotated; a segment without a done func is complete at its end.
+func (follower SegmentFollower) readSegment(path, segment string, offset int64, lines chan<- Line,
+	done func(opened os.FileInfo, offset int64) (bool, error)) error {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	opened, statErr := file.Stat()
+	if statErr != nil {
+		return statErr
+	}
+
+	var reader io.Reader = file
+	if isCompressed(segment) {
+		gzipReader, gzipErr := gzip.NewReader(file)
+		if gzipErr != nil {
+			return gzipErr
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+		_, skipErr := io.CopyN(ioutil.Discard, reader, offset)
+		if skipErr != nil {
+			return skipErr
+		}
+	} else {
+		if opened.Size() < offset {
+			logrus.Warnf("%s is shorter than offset %d, reading from the start", path, offset)
+			offset = 0
+		}
+		_, seekErr := file.Seek(offset, io.SeekStart)
+		if seekErr != nil {
+			return seekErr
+		}
+	}
+
+	buffered := bufio.NewReader(reader)
+	partial := ""
+	for {
+		text, readErr := buffered.ReadString('\n')
+		partial += text
+		if readErr == nil {
+			offset += int64(len(partial))
+			sendLine(lines, partial, Position{Segment: segment, Offset: offset})
+			partial = ""
+			continue
+		}
+		if readErr != io.EOF {
+			return readErr
+		}
+		complete := true
+		var doneErr error
+		if done != nil {
+			complete, doneErr = done(opened, offset+int64(len(partial)))
+			if doneErr != nil && doneErr != errRotated {
+				return doneErr
+			}
+		}
+		if complete || doneErr == errRotated {
+			// read anything written before the segment was complete or rotated
+			rest, restErr := ioutil.ReadAll(buffered)
+			if restErr != nil {
+				return restErr
+			}
+			for _, text := range strings.SplitAfter(partial+string(rest), "\n") {
+				offset += int64(len(text))
+				sendLine(lines, text, Position{Segment: segment, Offset: offset})
+			}
+			return doneErr
+		}
+		time.Sleep(follower.PollingInterval)
+	}
+}
+
+func sendLine(lines chan<- Line, text string, position Position) {
+	text = strings.TrimRight(text, "\r\n")
+	if text != "" {
+		lines <- Line{Text: text, Position: position}
+	}
+}
+
+// fileRotated returns errRotated once the followed path is a different file or has been truncated
+func (follower SegmentFollower) fileRotated(opened os.FileInfo, offset int64) (bool, error) {
+	current, statErr := os.Stat(follower.Path)
+	if os.IsNotExist(statErr) {
+		return false, nil
+	}
+	if statErr != nil {
+		return false, statErr
+	}
+	if !os.SameFile(opened, current) || current.Size() < offset {
+		return false, errRotated
+	}
+	return false, nil
+}
+
+func (follower SegmentFollower) laterSegmentExists(segment string) func(os.FileInfo, int64) (bool, error) {
+	return func(os.FileInfo, int64) (bool, error) {
+		segments, listErr := follower.segments()
+		if listErr != nil {
+			return false, listErr
+		}
+		return len(segments) > 0 && segmentLess(segment, segments[len(segments)-1]), nil
+	}
+}
+
+// segments returns the names of the files in the directory, ordered by the numbers in their names
+func (follower SegmentFollower) segments() ([]string, error) {
+	infos, readErr := ioutil.ReadDir(follower.Path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var segments []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+			segments = append(segments, info.Name())
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segmentLess(segments[i], segments[j])
+	})
+	return segments, nil
+}
+
+// segmentFrom returns the given segment if it exists, otherwise the first segment after it
+func segmentFrom(segments []string, segment string) string {
+	for _, candidate := range segments {
+		if sameSegment(candidate, segment) || segmentLess(segment, candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// segmentAfter returns the first segment after the given one, or the first segment if none is given
+func segmentAfter(segments []string, segment string) string {
+	for _, candidate := range segments {
+		if segment == "" || segmentLess(segment, candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// sameSegment is true for the same segment before and after it was compressed
+func sameSegment(a, b string) bool {
+	return strings.TrimSuffix(a, ".gz") == strings.TrimSuffix(b, ".gz")
+}
+
+// segmentLess compares names by their runs of digits as numbers, and the rest of the names as text,
+// ignoring whether a segment has been compressed
+func segmentLess(a, b string) bool {
+	a, b = strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz")
+	for a != "" && b != "" {
+		aChunk, aRest := nextChunk(a)
+		bChunk, bRest := nextChunk(b)
+		if aChunk != bChunk {
+			if isDigits(aChunk) && isDigits(bChunk) {
+				aNumber, bNumber := strings.TrimLeft(aChunk, "0"), strings.TrimLeft(bChunk, "0")
+				if len(aNumber) != len(bNumber) {
+					return len(aNumber) < len(bNumber)
+				}
+				if aNumber != bNumber {
+					return aNumber < bNumber
+				}
+			}
+			return aChunk < bChunk
+		}
+		a, b = aRest, bRest
+	}
+	return len(a) < len(b)
+}
+
+func nextChunk(s string) (string, string) {
+	digits := unicode.IsDigit(rune(s[0]))
+	for i, r := range s {
+		if unicode.IsDigit(r) != digits {
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+func isDigits(s string) bool {
+	return s != "" && unicode.IsDigit(rune(s[0]))
+}
+
+func isCompressed(segment string) bool {
+	return strings.HasSuffix(segment, ".gz")
+}
@@ -0,0 +1,161 @@
+package fs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/pkg/fs"
+)
+
+var _ = Describe("Segment follower", func() {
+	var (
+		dir   string
+		lines chan fs.Line
+	)
+
+	BeforeEach(func() {
+		var dirErr error
+		dir, dirErr = ioutil.TempDir("", "follower")
+		Expect(dirErr).NotTo(HaveOccurred())
+		lines = make(chan fs.Line)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	follow := func(path string, from fs.Position) {
+		follower := fs.SegmentFollower{Path: path, PollingInterval: time.Millisecond}
+		go follower.Follow(from, lines)
+	}
+
+	Describe("following a file", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(dir, "diffs.csv")
+			writeFile(path, "a\nbc\n")
+		})
+
+		It("sends each line with the position after it", func(done Done) {
+			follow(path, fs.Position{})
+
+			Expect(<-lines).To(Equal(fs.Line{Text: "a", Position: fs.Position{Segment: "diffs.csv", Offset: 2}}))
+			Expect(<-lines).To(Equal(fs.Line{Text: "bc", Position: fs.Position{Segment: "diffs.csv", Offset: 5}}))
+			close(done)
+		})
+
+		It("resumes from a position", func(done Done) {
+			follow(path, fs.Position{Segment: "diffs.csv", Offset: 2})
+
+			Expect((<-lines).Text).To(Equal("bc"))
+			close(done)
+		})
+
+		It("sends lines appended to the file", func(done Done) {
+			follow(path, fs.Position{Segment: "diffs.csv", Offset: 5})
+			appendFile(path, "de")
+			appendFile(path, "f\n")
+
+			Expect(<-lines).To(Equal(fs.Line{Text: "def", Position: fs.Position{Segment: "diffs.csv", Offset: 9}}))
+			close(done)
+		})
+
+		It("follows the file after it is rotated", func(done Done) {
+			follow(path, fs.Position{Segment: "diffs.csv", Offset: 2})
+			Expect((<-lines).Text).To(Equal("bc"))
+			Expect(os.Rename(path, filepath.Join(dir, "diffs.csv.1"))).To(Succeed())
+			appendFile(filepath.Join(dir, "diffs.csv.1"), "old\n")
+			writeFile(path, "new\n")
+
+			Expect((<-lines).Text).To(Equal("old"))
+			Expect(<-lines).To(Equal(fs.Line{Text: "new", Position: fs.Position{Segment: "diffs.csv", Offset: 4}}))
+			close(done)
+		})
+
+		It("reads a compressed file", func(done Done) {
+			compressedPath := filepath.Join(dir, "diffs.csv.gz")
+			writeCompressedFile(compressedPath, "a\nbc\n")
+			follower := fs.SegmentFollower{Path: compressedPath, PollingInterval: time.Millisecond}
+			errs := make(chan error)
+
+			go func() {
+				errs <- follower.Follow(fs.Position{Segment: "diffs.csv", Offset: 2}, lines)
+			}()
+
+			Expect(<-lines).To(Equal(fs.Line{Text: "bc", Position: fs.Position{Segment: "diffs.csv.gz", Offset: 5}}))
+			Expect(<-errs).NotTo(HaveOccurred())
+			close(done)
+		})
+
+		It("returns error if the file doesn't exist", func() {
+			follower := fs.SegmentFollower{Path: filepath.Join(dir, "missing.csv")}
+
+			err := follower.Follow(fs.Position{}, lines)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("following a directory of segments", func() {
+		BeforeEach(func() {
+			writeCompressedFile(filepath.Join(dir, "diffs.2.csv.gz"), "two\n")
+			writeFile(filepath.Join(dir, "diffs.10.csv"), "ten\n")
+			writeFile(filepath.Join(dir, "diffs.1.csv"), "one\n")
+		})
+
+		It("reads segments in numeric order", func(done Done) {
+			follow(dir, fs.Position{})
+
+			Expect(<-lines).To(Equal(fs.Line{Text: "one", Position: fs.Position{Segment: "diffs.1.csv", Offset: 4}}))
+			Expect(<-lines).To(Equal(fs.Line{Text: "two", Position: fs.Position{Segment: "diffs.2.csv.gz", Offset: 4}}))
+			Expect(<-lines).To(Equal(fs.Line{Text: "ten", Position: fs.Position{Segment: "diffs.10.csv", Offset: 4}}))
+			close(done)
+		})
+
+		It("resumes from a segment that has since been compressed", func(done Done) {
+			follow(dir, fs.Position{Segment: "diffs.2.csv", Offset: 4})
+
+			Expect((<-lines).Text).To(Equal("ten"))
+			close(done)
+		})
+
+		It("moves on from the last segment once a later one appears", func(done Done) {
+			follow(dir, fs.Position{Segment: "diffs.10.csv", Offset: 4})
+			appendFile(filepath.Join(dir, "diffs.10.csv"), "ten again\n")
+			Expect((<-lines).Text).To(Equal("ten again"))
+
+			writeFile(filepath.Join(dir, "diffs.11.csv"), "eleven\n")
+
+			Expect((<-lines).Text).To(Equal("eleven"))
+			close(done)
+		})
+	})
+})
+
+func writeFile(path, content string) {
+	Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+}
+
+func appendFile(path, content string) {
+	file, openErr := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	Expect(openErr).NotTo(HaveOccurred())
+	defer file.Close()
+	_, writeErr := file.WriteString(content)
+	Expect(writeErr).NotTo(HaveOccurred())
+}
+
+func writeCompressedFile(path, content string) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, writeErr := writer.Write([]byte(content))
+	Expect(writeErr).NotTo(HaveOccurred())
+	Expect(writer.Close()).To(Succeed())
+	Expect(ioutil.WriteFile(path, compressed.Bytes(), 0644)).To(Succeed())
+}
@@ -0,0 +1,19 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestFs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fs Suite")
+}
+
+var _ = BeforeSuite(func() {
+	log.SetOutput(ioutil.Discard)
+})
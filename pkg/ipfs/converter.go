@@ -93,6 +93,7 @@ func (pc *Converter) Convert(payload statediff.Payload) (*IPLDPayload, error) {
 	if deriveErr != nil {
 		return nil, deriveErr
 	}
+	var logIndex uint
 	for i, receipt := range receipts {
 		// If the transaction for this receipt has a "to" address, the above DeriveFields() fails to assign it to the receipt's ContractAddress
 		// If it doesn't have a "to" address, it correctly derives it and assigns it to to the receipt's ContractAddress
@@ -104,7 +105,10 @@ func (pc *Converter) Convert(payload statediff.Payload) (*IPLDPayload, error) {
 		rctMeta := &ReceiptMetaData{
 			Topic0s:         make([]string, 0, len(receipt.Logs)),
 			ContractAddress: receipt.ContractAddress.Hex(),
+			TxIndex:         uint(i),
+			LogIndex:        logIndex,
 		}
+		logIndex += uint(len(receipt.Logs))
 		for _, log := range receipt.Logs {
 			if len(log.Topics) < 1 {
 				continue
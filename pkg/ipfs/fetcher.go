@@ -159,6 +159,7 @@ func (f *EthIPLDFetcher) fetchRcts(cids CIDWrapper, blocks *IPLDWrapper) error {
 		rctCids = append(rctCids, dc)
 	}
 	blocks.Receipts = f.fetchBatch(rctCids)
+	blocks.ReceiptLogs = cids.ReceiptLogs
 	if len(blocks.Receipts) != len(rctCids) {
 		log.Errorf("ipfs fetcher: number of receipt blocks returned (%d) does not match number expected (%d)", len(blocks.Receipts), len(rctCids))
 	}
@@ -79,6 +79,8 @@ var (
 				"0x0000000000000000000000000000000000000000000000000000000000000005",
 			},
 			ContractAddress: "0x0000000000000000000000000000000000000001",
+			TxIndex:         1,
+			LogIndex:        1,
 		},
 	}
 
@@ -198,6 +200,8 @@ var (
 					"0x0000000000000000000000000000000000000000000000000000000000000005",
 				},
 				ContractAddress: "0x0000000000000000000000000000000000000001",
+				TxIndex:         1,
+				LogIndex:        1,
 			},
 		},
 		StorageNodes: MockStorageNodes,
@@ -231,6 +235,8 @@ var (
 				CID:             "mockRctCID2",
 				Topic0s:         []string{"0x0000000000000000000000000000000000000000000000000000000000000005"},
 				ContractAddress: "0x0000000000000000000000000000000000000001",
+				TxIndex:         1,
+				LogIndex:        1,
 			},
 		},
 		StateNodeCIDs: map[common.Hash]ipfs.StateNodeCID{
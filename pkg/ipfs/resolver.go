@@ -17,8 +17,13 @@
 package ipfs
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ipfs/go-block-format"
+	log "github.com/sirupsen/logrus"
 	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
 )
 
@@ -45,7 +50,7 @@ func (eir *EthIPLDResolver) ResolveIPLDs(ipfsBlocks IPLDWrapper) streamer.SuperN
 	eir.resolveHeaders(ipfsBlocks.Headers, response)
 	eir.resolveUncles(ipfsBlocks.Uncles, response)
 	eir.resolveTransactions(ipfsBlocks.Transactions, response)
-	eir.resolveReceipts(ipfsBlocks.Receipts, response)
+	eir.resolveReceipts(ipfsBlocks.Receipts, ipfsBlocks.ReceiptLogs, response)
 	eir.resolveState(ipfsBlocks.StateNodes, response)
 	eir.resolveStorage(ipfsBlocks.StorageNodes, response)
 	return *response
@@ -72,11 +77,42 @@ func (eir *EthIPLDResolver) resolveTransactions(blocks []blocks.Block, response
 	}
 }
 
-func (eir *EthIPLDResolver) resolveReceipts(blocks []blocks.Block, response *streamer.SuperNodePayload) {
+// Receipts are passed on as their raw IPLD, alongside their logs encoded with streamer.EncodeReceiptLogs, like those
+// streamed as they are synced, if the position of their logs is known; the raw IPLD doesn't include the logs' metadata
+func (eir *EthIPLDResolver) resolveReceipts(blocks []blocks.Block, receiptLogs map[string]ReceiptLogMetaData, response *streamer.SuperNodePayload) {
 	for _, block := range blocks {
 		raw := block.RawData()
+		var logsRlp []byte
+		logMeta, ok := receiptLogs[block.Cid().String()]
+		if ok && logMeta.TxIndex != nil && logMeta.LogIndex != nil {
+			encoded, encodeErr := encodeReceiptLogs(raw, logMeta, response.BlockNumber)
+			if encodeErr != nil {
+				log.Errorf("ipfs resolver: error encoding logs of receipt %s: %s", block.Cid().String(), encodeErr.Error())
+			} else {
+				logsRlp = encoded
+			}
+		}
 		response.ReceiptsRlp = append(response.ReceiptsRlp, raw)
+		response.ReceiptLogsRlp = append(response.ReceiptLogsRlp, logsRlp)
+	}
+}
+
+func encodeReceiptLogs(raw []byte, logMeta ReceiptLogMetaData, blockNumber *big.Int) ([]byte, error) {
+	var receipt types.ReceiptForStorage
+	decodeErr := rlp.DecodeBytes(raw, &receipt)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	for i, receiptLog := range receipt.Logs {
+		if blockNumber != nil {
+			receiptLog.BlockNumber = blockNumber.Uint64()
+		}
+		receiptLog.BlockHash = common.HexToHash(logMeta.BlockHash)
+		receiptLog.TxHash = common.HexToHash(logMeta.TxHash)
+		receiptLog.TxIndex = uint(*logMeta.TxIndex)
+		receiptLog.Index = uint(*logMeta.LogIndex) + uint(i)
 	}
+	return streamer.EncodeReceiptLogs(receipt.Logs)
 }
 
 func (eir *EthIPLDResolver) resolveState(blocks map[common.Hash]blocks.Block, response *streamer.SuperNodePayload) {
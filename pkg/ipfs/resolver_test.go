@@ -17,9 +17,16 @@
 package ipfs_test
 
 import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ipfs/go-block-format"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
 	"github.com/vulcanize/vulcanizedb/pkg/ipfs"
 	"github.com/vulcanize/vulcanizedb/pkg/ipfs/mocks"
 	"github.com/vulcanize/vulcanizedb/pkg/super_node"
@@ -48,5 +55,59 @@ var _ = Describe("Resolver", func() {
 			Expect(len(superNodePayload.StateNodesRlp)).To(Equal(2))
 			Expect(superNodePayload.StorageNodesRlp).To(Equal(mocks.MockSeeNodePayload.StorageNodesRlp))
 		})
+
+		It("Encodes the logs of receipts with their metadata when it is known", func() {
+			receipt := types.NewReceipt(nil, false, 50)
+			receipt.Logs = []*types.Log{
+				{Address: common.HexToAddress("0x1"), Topics: []common.Hash{common.HexToHash("0x4")}},
+				{Address: common.HexToAddress("0x2"), Topics: []common.Hash{common.HexToHash("0x5")}},
+			}
+			raw, encodeErr := rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+			Expect(encodeErr).NotTo(HaveOccurred())
+			receiptBlock := blocks.NewBlock(raw)
+			txIndex, logIndex := uint64(2), uint64(5)
+			wrapper := ipfs.IPLDWrapper{
+				BlockNumber: big.NewInt(10),
+				Receipts:    []blocks.Block{receiptBlock},
+				ReceiptLogs: map[string]ipfs.ReceiptLogMetaData{
+					receiptBlock.Cid().String(): {
+						BlockHash: common.HexToHash("0xabc").Hex(),
+						TxHash:    common.HexToHash("0xdef").Hex(),
+						TxIndex:   &txIndex,
+						LogIndex:  &logIndex,
+					},
+				},
+			}
+
+			superNodePayload := resolver.ResolveIPLDs(wrapper)
+
+			Expect(superNodePayload.ReceiptsRlp).To(Equal([][]byte{raw}))
+			Expect(len(superNodePayload.ReceiptLogsRlp)).To(Equal(1))
+			logs, decodeErr := streamer.DecodeReceiptLogs(superNodePayload.ReceiptLogsRlp[0])
+			Expect(decodeErr).NotTo(HaveOccurred())
+			Expect(len(logs)).To(Equal(2))
+			for i, log := range logs {
+				Expect(log.Address).To(Equal(receipt.Logs[i].Address))
+				Expect(log.BlockNumber).To(Equal(uint64(10)))
+				Expect(log.BlockHash).To(Equal(common.HexToHash("0xabc")))
+				Expect(log.TxHash).To(Equal(common.HexToHash("0xdef")))
+				Expect(log.TxIndex).To(Equal(uint(2)))
+				Expect(log.Index).To(Equal(uint(5 + i)))
+			}
+		})
+
+		It("Leaves out the logs of receipts when their metadata is unknown", func() {
+			receiptBlock := blocks.NewBlock(mocks.MockReceipts.GetRlp(0))
+			wrapper := ipfs.IPLDWrapper{
+				BlockNumber: big.NewInt(10),
+				Receipts:    []blocks.Block{receiptBlock},
+				ReceiptLogs: map[string]ipfs.ReceiptLogMetaData{receiptBlock.Cid().String(): {}},
+			}
+
+			superNodePayload := resolver.ResolveIPLDs(wrapper)
+
+			Expect(superNodePayload.ReceiptsRlp).To(Equal([][]byte{mocks.MockReceipts.GetRlp(0)}))
+			Expect(superNodePayload.ReceiptLogsRlp).To(Equal([][]byte{nil}))
+		})
 	})
 })
@@ -31,6 +31,7 @@ type CIDWrapper struct {
 	Uncles       []string
 	Transactions []string
 	Receipts     []string
+	ReceiptLogs  map[string]ReceiptLogMetaData // receipt cid => position of its logs
 	StateNodes   []StateNodeCID
 	StorageNodes []StorageNodeCID
 }
@@ -42,6 +43,7 @@ type IPLDWrapper struct {
 	Uncles       []blocks.Block
 	Transactions []blocks.Block
 	Receipts     []blocks.Block
+	ReceiptLogs  map[string]ReceiptLogMetaData // receipt cid => position of its logs
 	StateNodes   map[common.Hash]blocks.Block
 	StorageNodes map[common.Hash]map[common.Hash]blocks.Block
 }
@@ -104,6 +106,17 @@ type ReceiptMetaData struct {
 	CID             string
 	Topic0s         []string
 	ContractAddress string
+	TxIndex         uint
+	LogIndex        uint // index of the receipt's first log in the block
+}
+
+// ReceiptLogMetaData locates a receipt's logs in their block, which receipt IPLDs don't include
+// The indexes are nil for receipts indexed before they were recorded
+type ReceiptLogMetaData struct {
+	BlockHash string  `db:"block_hash"`
+	TxHash    string  `db:"tx_hash"`
+	TxIndex   *uint64 `db:"tx_index"`
+	LogIndex  *uint64 `db:"log_index"`
 }
 
 // TrxMetaData wraps some additional data around our transaction CID for indexing
@@ -17,38 +17,94 @@
 package builder
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
 
 	"github.com/vulcanize/vulcanizedb/pkg/config"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 )
 
+const vulcanizedbModule = "github.com/vulcanize/vulcanizedb"
+
 // Interface for compile Go code written by the
 // PluginWriter into a shared object (.so file)
 // which can be used loaded as a plugin
 type PluginBuilder interface {
 	BuildPlugin() error
+	Check() ([]helpers.Check, error)
 	CleanUp() error
 }
 
 type builder struct {
-	GenConfig    config.Plugin
-	dependencies []string
-	tmpVenDirs   []string // Keep track of temp vendor directories
-	goFile       string   // Keep track of goFile name
+	GenConfig config.Plugin
+	buildInfo func() (*debug.BuildInfo, bool) // modules the running binary was built with
+	goFile    string                          // Keep track of goFile name
+	modDir    string                          // Keep track of the temporary module the plugin is built in
 }
 
 // Requires populated plugin config
 func NewPluginBuilder(gc config.Plugin) PluginBuilder {
 	return &builder{
-		GenConfig:  gc,
-		tmpVenDirs: make([]string, 0),
+		GenConfig: gc,
+		buildInfo: debug.ReadBuildInfo,
+	}
+}
+
+// Module is a module in a build list, as reported by go list -m -json
+type Module struct {
+	Path    string
+	Version string
+	Replace *Module
+}
+
+// Describes the version of the module that is built, e.g. v1.2.0 or => github.com/fork/module v1.2.0
+func (module Module) BuiltVersion() string {
+	if module.Replace != nil {
+		return strings.TrimSpace("=> " + module.Replace.Path + " " + module.Replace.Version)
+	}
+	return module.Version
+}
+
+// ErrDependencyConflict is returned when the plugin's dependencies resolve to different versions than the binary
+// that will load it was built with, which would fail to open as a plugin
+type ErrDependencyConflict struct {
+	Conflicts []DependencyConflict
+}
+
+type DependencyConflict struct {
+	Host       Module
+	Plugin     Module
+	RequiredBy []string
+}
+
+func (e ErrDependencyConflict) Error() string {
+	var message strings.Builder
+	message.WriteString("plugin dependencies conflict with the vulcanizedb binary:")
+	for _, conflict := range e.Conflicts {
+		message.WriteString(fmt.Sprintf("\n\t%s: binary has %s, plugin resolves %s", conflict.Host.Path,
+			conflict.Host.BuiltVersion(), conflict.Plugin.BuiltVersion()))
+		if len(conflict.RequiredBy) > 0 {
+			message.WriteString(fmt.Sprintf(" (required by %s)", strings.Join(conflict.RequiredBy, ", ")))
+		}
 	}
+	return message.String()
 }
 
+// BuildPlugin builds the .go file as the main package of a temporary module, which requires the configured transformer
+// repositories at their pinned versions and vulcanizedb and its dependencies at the versions in the running binary.
+// In RPC and static modes it is built into an executable, and other dependency versions are allowed.
 func (b *builder) BuildPlugin() error {
 	// Get plugin .go and .so file paths
 	var err error
@@ -58,60 +114,281 @@ func (b *builder) BuildPlugin() error {
 		return err
 	}
 
-	// setup env to build plugin
-	setupErr := b.setupBuildEnv()
+	hostModules, hostErr := b.hostModules()
+	if hostErr != nil {
+		return hostErr
+	}
+	goMod, goModErr := GoMod(b.GenConfig, hostModules)
+	if goModErr != nil {
+		return goModErr
+	}
+
+	// setup temporary module to build plugin
+	setupErr := b.setupBuildEnv(goMod)
 	if setupErr != nil {
 		return setupErr
 	}
 
+	pluginModules, listErr := b.goCommand("list", "-m", "-json", "all")
+	if listErr != nil {
+		return fmt.Errorf("unable to resolve plugin dependencies: %s", listErr.Error())
+	}
+	resolved, decodeErr := DecodeModules(bytes.NewReader(pluginModules))
+	if decodeErr != nil {
+		return decodeErr
+	}
+	versionErr := b.checkPinnedVersions(resolved)
+	if versionErr != nil {
+		return versionErr
+	}
+	if b.GenConfig.Mode.BuildsExecutable() {
+		// The transformers aren't linked into the running binary, so their dependencies needn't match its
+		args := []string{"build", "-o", soFile}
+		if b.GenConfig.Race {
+			args = append(args, "-race")
+		}
+		_, buildErr := b.goCommand(append(args, ".")...)
+		if buildErr != nil {
+			return fmt.Errorf("unable to build transformer executable: %s", buildErr.Error())
+		}
+		return nil
+	}
+	conflicts := FindConflicts(hostModules, resolved)
+	if len(conflicts) > 0 {
+		graph, graphErr := b.goCommand("mod", "graph")
+		if graphErr == nil {
+			AddRequirers(conflicts, bytes.NewReader(graph))
+		}
+		return ErrDependencyConflict{Conflicts: conflicts}
+	}
+
 	// Build the .go file into a .so plugin
-	execErr := exec.Command("go", "build", "-buildmode=plugin", "-o", soFile, b.goFile).Run()
-	if execErr != nil {
-		return fmt.Errorf("unable to build .so file: %s", execErr.Error())
+	_, buildErr := b.goCommand("build", "-buildmode=plugin", "-o", soFile, ".")
+	if buildErr != nil {
+		return fmt.Errorf("unable to build .so file: %s", buildErr.Error())
 	}
 	return nil
 }
 
-// Sets up temporary vendor libs needed for plugin build
-// This is to work around a conflict between plugins and vendoring (https://github.com/golang/go/issues/20481)
-func (b *builder) setupBuildEnv() error {
-	// TODO: Less hacky way of handling plugin build deps
-	vendorPath, err := helpers.CleanPath(filepath.Join("$GOPATH/src", b.GenConfig.Home, "vendor"))
+// hostModules returns the modules the running binary was built with, including vulcanizedb. A development build of
+// vulcanizedb is replaced with the source directory it was built from
+func (b *builder) hostModules() ([]Module, error) {
+	info, ok := b.buildInfo()
+	if !ok {
+		return nil, errors.New("vulcanizedb was not built with module support, so plugin dependencies can't be matched to it")
+	}
+	var modules []Module
+	if info.Main.Path == vulcanizedbModule {
+		host := toModule(&info.Main)
+		if host.Replace == nil && isDevelopmentVersion(host.Version) {
+			dir, dirErr := hostSourceDir()
+			if dirErr != nil {
+				return nil, dirErr
+			}
+			host.Replace = &Module{Path: dir}
+		}
+		modules = append(modules, host)
+	}
+	for _, dep := range info.Deps {
+		modules = append(modules, toModule(dep))
+	}
+	return modules, nil
+}
+
+// hostSourceDir returns the root of the vulcanizedb module this file was compiled from
+func hostSourceDir() (string, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok || !filepath.IsAbs(file) {
+		return "", errors.New("vulcanizedb is a development build whose source directory is unknown, " +
+			"build it from a checkout without -trimpath or install a released version")
+	}
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "..")
+	if _, statErr := os.Stat(filepath.Join(dir, "go.mod")); statErr != nil {
+		return "", fmt.Errorf("vulcanizedb is a development build whose source directory %s is missing: %s", dir, statErr.Error())
+	}
+	return dir, nil
+}
+
+func isDevelopmentVersion(version string) bool {
+	return version == "" || version == "(devel)"
+}
+
+func toModule(module *debug.Module) Module {
+	converted := Module{Path: module.Path, Version: module.Version}
+	if module.Replace != nil {
+		replace := toModule(module.Replace)
+		converted.Replace = &replace
+	}
+	return converted
+}
+
+// GoMod returns the go.mod of the module a plugin is built in. It requires the binary's modules at the versions it was
+// built with, or from the directories they're replaced with, and the transformer repositories at their pinned
+// versions, or from their configured directories if they aren't pinned.
+func GoMod(gc config.Plugin, hostModules []Module) (string, error) {
+	repoVersions, versionsErr := gc.GetRepoVersions()
+	if versionsErr != nil {
+		return "", versionsErr
+	}
+	var requires, replaces []string
+	for _, module := range hostModules {
+		if _, isTransformerRepo := repoVersions[module.Path]; isTransformerRepo {
+			continue
+		}
+		switch {
+		case module.Replace != nil:
+			requires = append(requires, module.Path+" "+versionOrZero(module.Version))
+			replaces = append(replaces, strings.TrimSpace(module.Path+" => "+module.Replace.Path+" "+module.Replace.Version))
+		case !isDevelopmentVersion(module.Version):
+			requires = append(requires, module.Path+" "+module.Version)
+		}
+	}
+	required := make(map[string]bool)
+	for _, transformer := range gc.Transformers {
+		repo := transformer.RepositoryPath
+		if required[repo] {
+			continue
+		}
+		required[repo] = true
+		if transformer.RepositoryVersion != "" {
+			requires = append(requires, repo+" "+transformer.RepositoryVersion)
+			continue
+		}
+		dir, dirErr := transformer.RepositoryDir()
+		if dirErr != nil {
+			return "", dirErr
+		}
+		requires = append(requires, repo+" v0.0.0")
+		replaces = append(replaces, repo+" => "+dir)
+	}
+	sort.Strings(requires)
+	sort.Strings(replaces)
+
+	var goMod strings.Builder
+	goMod.WriteString("module vulcanizedb-plugin\n\ngo 1.12\n")
+	if len(requires) > 0 {
+		goMod.WriteString("\nrequire (\n\t" + strings.Join(requires, "\n\t") + "\n)\n")
+	}
+	if len(replaces) > 0 {
+		goMod.WriteString("\nreplace (\n\t" + strings.Join(replaces, "\n\t") + "\n)\n")
+	}
+	return goMod.String(), nil
+}
+
+func versionOrZero(version string) string {
+	if isDevelopmentVersion(version) {
+		return "v0.0.0"
+	}
+	return version
+}
+
+// Sets up a temporary module with the plugin's .go file as its main package
+func (b *builder) setupBuildEnv(goMod string) error {
+	var err error
+	b.modDir, err = ioutil.TempDir("", "vulcanizedb-plugin")
 	if err != nil {
 		return err
 	}
+	writeErr := ioutil.WriteFile(filepath.Join(b.modDir, "go.mod"), []byte(goMod), 0644)
+	if writeErr != nil {
+		return writeErr
+	}
+	return helpers.CopyFile(b.goFile, filepath.Join(b.modDir, "main.go"))
+}
 
-	repoPaths := b.GenConfig.GetRepoPaths()
-
-	// Import transformer dependencies so that we can build our plugin
-	for importPath := range repoPaths {
-		dst := filepath.Join(vendorPath, importPath)
-		src, cleanErr := helpers.CleanPath(filepath.Join("$GOPATH/src", importPath))
-		if cleanErr != nil {
-			return cleanErr
+// checkPinnedVersions fails if a transformer repository resolved to a different version than it is pinned at,
+// e.g. if the pin was a branch name or a lower version than another dependency requires
+func (b *builder) checkPinnedVersions(resolved []Module) error {
+	repoVersions, versionsErr := b.GenConfig.GetRepoVersions()
+	if versionsErr != nil {
+		return versionsErr
+	}
+	for _, module := range resolved {
+		version, ok := repoVersions[module.Path]
+		if ok && version != "" && module.Version != version {
+			return fmt.Errorf("transformer repository %s is pinned at %s but resolved to %s, pin it at %s",
+				module.Path, version, module.Version, module.Version)
 		}
+	}
+	return nil
+}
 
-		copyErr := helpers.CopyDir(src, dst, "vendor")
-		if copyErr != nil {
-			return fmt.Errorf("unable to copy transformer dependency from %s to %s: %v", src, dst, copyErr)
+func (b *builder) goCommand(args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.modDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// DecodeModules decodes the output of go list -m -json
+func DecodeModules(r io.Reader) ([]Module, error) {
+	var modules []Module
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var module Module
+		decodeErr := decoder.Decode(&module)
+		if decodeErr != nil {
+			return nil, decodeErr
 		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
 
-		// Have to clear out the copied over vendor lib or plugin won't build (see issue above)
-		removeErr := os.RemoveAll(filepath.Join(dst, "vendor"))
-		if removeErr != nil {
-			return removeErr
+// FindConflicts returns the modules the plugin resolves to a different version or replacement than the binary
+func FindConflicts(hostModules, pluginModules []Module) []DependencyConflict {
+	hostByPath := make(map[string]Module)
+	for _, module := range hostModules {
+		hostByPath[module.Path] = module
+	}
+	var conflicts []DependencyConflict
+	for _, module := range pluginModules {
+		host, ok := hostByPath[module.Path]
+		if !ok || host.Path == vulcanizedbModule {
+			continue
 		}
-		// Keep track of this vendor directory to clear later
-		b.tmpVenDirs = append(b.tmpVenDirs, dst)
+		if sameModule(host, module) {
+			continue
+		}
+		conflicts = append(conflicts, DependencyConflict{Host: host, Plugin: module})
 	}
+	return conflicts
+}
 
-	return nil
+func sameModule(host, plugin Module) bool {
+	if (host.Replace == nil) != (plugin.Replace == nil) {
+		return false
+	}
+	if host.Replace != nil {
+		return host.Replace.Path == plugin.Replace.Path && host.Replace.Version == plugin.Replace.Version
+	}
+	return host.Version == plugin.Version
 }
 
-// Used to clear all of the tmp vendor libs used to build the plugin
+// AddRequirers adds the modules requiring each conflicting version, from the output of go mod graph
+func AddRequirers(conflicts []DependencyConflict, graph io.Reader) {
+	scanner := bufio.NewScanner(graph)
+	for scanner.Scan() {
+		edge := strings.Fields(scanner.Text())
+		if len(edge) != 2 {
+			continue
+		}
+		for i, conflict := range conflicts {
+			if edge[1] == conflict.Plugin.Path+"@"+conflict.Plugin.Version {
+				conflicts[i].RequiredBy = append(conflicts[i].RequiredBy, edge[0])
+			}
+		}
+	}
+}
+
+// Used to clear the temporary module used to build the plugin
 // Also clears the go file if saving it has not been specified in the config
-// Do not call until after the MigrationManager has performed its operations
-// as it needs to pull the db migrations from the tmpVenDirs
 func (b *builder) CleanUp() error {
 	if !b.GenConfig.Save {
 		err := helpers.ClearFiles(b.goFile)
@@ -119,13 +396,8 @@ func (b *builder) CleanUp() error {
 			return err
 		}
 	}
-
-	for _, venDir := range b.tmpVenDirs {
-		err := os.RemoveAll(venDir)
-		if err != nil {
-			return err
-		}
+	if b.modDir != "" {
+		return os.RemoveAll(b.modDir)
 	}
-
 	return nil
 }
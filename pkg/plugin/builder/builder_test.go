@@ -0,0 +1,199 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package builder_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/builder"
+)
+
+var _ = Describe("Plugin builder", func() {
+	Describe("GoMod", func() {
+		var pluginConfig config.Plugin
+
+		BeforeEach(func() {
+			pluginConfig = config.Plugin{
+				Home: "github.com/vulcanize/vulcanizedb",
+				Transformers: map[string]config.Transformer{
+					"transformer1": {RepositoryPath: "github.com/account/repo", RepositoryVersion: "v1.2.3"},
+					"transformer2": {RepositoryPath: "github.com/account/local", RepositoryDirectory: "/src/local"},
+				},
+			}
+		})
+
+		It("requires the binary's modules and the pinned transformer repositories", func() {
+			hostModules := []builder.Module{
+				{Path: "github.com/vulcanize/vulcanizedb", Version: "v0.0.10"},
+				{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5",
+					Replace: &builder.Module{Path: "github.com/vulcanize/go-ethereum", Version: "v1.9.5-statediff"}},
+				{Path: "github.com/sirupsen/logrus", Version: "v1.2.0"},
+			}
+
+			goMod, err := builder.GoMod(pluginConfig, hostModules)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(goMod).To(Equal(strings.Join([]string{
+				"module vulcanizedb-plugin",
+				"",
+				"go 1.12",
+				"",
+				"require (",
+				"\tgithub.com/account/local v0.0.0",
+				"\tgithub.com/account/repo v1.2.3",
+				"\tgithub.com/ethereum/go-ethereum v1.9.5",
+				"\tgithub.com/sirupsen/logrus v1.2.0",
+				"\tgithub.com/vulcanize/vulcanizedb v0.0.10",
+				")",
+				"",
+				"replace (",
+				"\tgithub.com/account/local => /src/local",
+				"\tgithub.com/ethereum/go-ethereum => github.com/vulcanize/go-ethereum v1.9.5-statediff",
+				")",
+				"",
+			}, "\n")))
+		})
+
+		It("replaces a development build of vulcanizedb with its source directory", func() {
+			hostModules := []builder.Module{{Path: "github.com/vulcanize/vulcanizedb", Version: "(devel)",
+				Replace: &builder.Module{Path: "/src/vulcanizedb"}}}
+
+			goMod, err := builder.GoMod(pluginConfig, hostModules)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(goMod).To(ContainSubstring("\tgithub.com/vulcanize/vulcanizedb v0.0.0\n"))
+			Expect(goMod).To(ContainSubstring("\tgithub.com/vulcanize/vulcanizedb => /src/vulcanizedb\n"))
+		})
+
+		It("returns error if a repository has neither a version nor a directory", func() {
+			pluginConfig.Transformers["transformer2"] = config.Transformer{RepositoryPath: "github.com/account/local"}
+
+			_, err := builder.GoMod(pluginConfig, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("github.com/account/local"))
+		})
+
+		It("requires transformer repositories at their pinned version rather than the binary's", func() {
+			hostModules := []builder.Module{{Path: "github.com/account/repo", Version: "v1.0.0"}}
+
+			goMod, err := builder.GoMod(pluginConfig, hostModules)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(goMod).To(ContainSubstring("\tgithub.com/account/repo v1.2.3\n"))
+			Expect(goMod).NotTo(ContainSubstring("v1.0.0"))
+		})
+
+		It("returns error if a repository is pinned at more than one version", func() {
+			pluginConfig.Transformers["transformer3"] = config.Transformer{
+				RepositoryPath:    "github.com/account/repo",
+				RepositoryVersion: "v1.3.0",
+			}
+
+			_, err := builder.GoMod(pluginConfig, nil)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("DecodeModules", func() {
+		It("decodes the output of go list -m -json", func() {
+			output := `{
+	"Path": "vulcanizedb-plugin",
+	"Main": true
+}
+{
+	"Path": "github.com/ethereum/go-ethereum",
+	"Version": "v1.9.5",
+	"Replace": {
+		"Path": "github.com/vulcanize/go-ethereum",
+		"Version": "v1.9.5-statediff"
+	}
+}
+`
+
+			modules, err := builder.DecodeModules(strings.NewReader(output))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(modules).To(Equal([]builder.Module{
+				{Path: "vulcanizedb-plugin"},
+				{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5",
+					Replace: &builder.Module{Path: "github.com/vulcanize/go-ethereum", Version: "v1.9.5-statediff"}},
+			}))
+		})
+	})
+
+	Describe("FindConflicts", func() {
+		hostModules := []builder.Module{
+			{Path: "github.com/vulcanize/vulcanizedb", Version: "(devel)"},
+			{Path: "github.com/sirupsen/logrus", Version: "v1.2.0"},
+			{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5",
+				Replace: &builder.Module{Path: "github.com/vulcanize/go-ethereum", Version: "v1.9.5-statediff"}},
+		}
+
+		It("returns nothing if the plugin resolves the binary's versions", func() {
+			pluginModules := []builder.Module{
+				{Path: "vulcanizedb-plugin"},
+				{Path: "github.com/vulcanize/vulcanizedb", Version: "v0.0.0",
+					Replace: &builder.Module{Path: "/go/src/github.com/vulcanize/vulcanizedb"}},
+				{Path: "github.com/sirupsen/logrus", Version: "v1.2.0"},
+				{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5",
+					Replace: &builder.Module{Path: "github.com/vulcanize/go-ethereum", Version: "v1.9.5-statediff"}},
+				{Path: "github.com/account/repo", Version: "v1.2.3"},
+			}
+
+			Expect(builder.FindConflicts(hostModules, pluginModules)).To(BeEmpty())
+		})
+
+		It("returns modules resolved to a different version or replacement", func() {
+			pluginModules := []builder.Module{
+				{Path: "github.com/sirupsen/logrus", Version: "v1.4.2"},
+				{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5"},
+			}
+
+			conflicts := builder.FindConflicts(hostModules, pluginModules)
+
+			Expect(conflicts).To(Equal([]builder.DependencyConflict{
+				{Host: hostModules[1], Plugin: pluginModules[0]},
+				{Host: hostModules[2], Plugin: pluginModules[1]},
+			}))
+		})
+	})
+
+	Describe("ErrDependencyConflict", func() {
+		It("lists each conflict with the modules requiring the plugin's version", func() {
+			conflicts := []builder.DependencyConflict{{
+				Host:   builder.Module{Path: "github.com/sirupsen/logrus", Version: "v1.2.0"},
+				Plugin: builder.Module{Path: "github.com/sirupsen/logrus", Version: "v1.4.2"},
+			}}
+			graph := strings.Join([]string{
+				"vulcanizedb-plugin github.com/account/repo@v1.2.3",
+				"github.com/account/repo@v1.2.3 github.com/sirupsen/logrus@v1.4.2",
+				"github.com/vulcanize/vulcanizedb@v0.0.0 github.com/sirupsen/logrus@v1.2.0",
+			}, "\n")
+
+			builder.AddRequirers(conflicts, strings.NewReader(graph))
+			err := builder.ErrDependencyConflict{Conflicts: conflicts}
+
+			Expect(err.Error()).To(Equal("plugin dependencies conflict with the vulcanizedb binary:\n" +
+				"\tgithub.com/sirupsen/logrus: binary has v1.2.0, plugin resolves v1.4.2 (required by github.com/account/repo@v1.2.3)"))
+		})
+	})
+})
@@ -0,0 +1,249 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
+)
+
+const transformerPackage = vulcanizedbModule + "/libraries/shared/transformer"
+
+// Check resolves the plugin's dependencies and transformer packages in a temporary module, and type checks
+// that each package exports the initializer for its transformer type, without writing or building the plugin
+func (b *builder) Check() ([]helpers.Check, error) {
+	hostModules, hostErr := b.hostModules()
+	if hostErr != nil {
+		return nil, hostErr
+	}
+	goMod, goModErr := GoMod(b.GenConfig, hostModules)
+	if goModErr != nil {
+		return []helpers.Check{{Subject: "plugin", Description: "resolve dependencies", Problem: goModErr.Error()}}, nil
+	}
+	setupErr := b.setupCheckEnv(goMod)
+	if setupErr != nil {
+		return nil, setupErr
+	}
+	defer os.RemoveAll(b.modDir)
+
+	checks, resolved := b.checkModules(hostModules)
+	if !resolved {
+		return checks, nil
+	}
+	names, packageChecks, packagesErr := b.checkPackages()
+	if packagesErr != nil {
+		return nil, packagesErr
+	}
+	checks = append(checks, packageChecks...)
+	initializerChecks, initializersErr := b.checkInitializers(names)
+	if initializersErr != nil {
+		return nil, initializersErr
+	}
+	return append(checks, initializerChecks...), nil
+}
+
+// Sets up a temporary module with only a go.mod, the check adds its own main package
+func (b *builder) setupCheckEnv(goMod string) error {
+	var err error
+	b.modDir, err = ioutil.TempDir("", "vulcanizedb-plugin-check")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(b.modDir, "go.mod"), []byte(goMod), 0644)
+}
+
+// checkModules checks the plugin's module graph resolves, to the pinned versions and, for a plugin, to the binary's versions
+func (b *builder) checkModules(hostModules []Module) ([]helpers.Check, bool) {
+	check := helpers.Check{Subject: "plugin", Description: "resolve dependencies"}
+	pluginModules, listErr := b.goCommand("list", "-m", "-json", "all")
+	if listErr != nil {
+		check.Problem = listErr.Error()
+		return []helpers.Check{check}, false
+	}
+	resolved, decodeErr := DecodeModules(bytes.NewReader(pluginModules))
+	if decodeErr != nil {
+		check.Problem = decodeErr.Error()
+		return []helpers.Check{check}, false
+	}
+	checks := []helpers.Check{check}
+
+	versionCheck := helpers.Check{Subject: "plugin", Description: "pinned repository versions"}
+	versionErr := b.checkPinnedVersions(resolved)
+	if versionErr != nil {
+		versionCheck.Problem = versionErr.Error()
+	}
+	checks = append(checks, versionCheck)
+
+	if !b.GenConfig.Mode.BuildsExecutable() {
+		conflictCheck := helpers.Check{Subject: "plugin", Description: "dependencies match vulcanizedb"}
+		conflicts := FindConflicts(hostModules, resolved)
+		if len(conflicts) > 0 {
+			graph, graphErr := b.goCommand("mod", "graph")
+			if graphErr == nil {
+				AddRequirers(conflicts, bytes.NewReader(graph))
+			}
+			conflictCheck.Problem = ErrDependencyConflict{Conflicts: conflicts}.Error()
+		}
+		checks = append(checks, conflictCheck)
+	}
+	return checks, true
+}
+
+// Package is a package, as reported by go list -e -json
+type Package struct {
+	ImportPath string
+	Error      *PackageError
+}
+
+type PackageError struct {
+	Err string
+}
+
+// DecodePackages decodes the output of go list -e -json
+func DecodePackages(r io.Reader) ([]Package, error) {
+	var packages []Package
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var pkg Package
+		decodeErr := decoder.Decode(&pkg)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// checkPackages resolves each transformer's package, returning the names of the transformers whose package resolved
+func (b *builder) checkPackages() ([]string, []helpers.Check, error) {
+	names := b.transformerNames()
+	importPaths := make(map[string]bool)
+	args := []string{"list", "-e", "-json"}
+	for _, name := range names {
+		importPath := b.GenConfig.Transformers[name].ImportPath()
+		if !importPaths[importPath] {
+			importPaths[importPath] = true
+			args = append(args, importPath)
+		}
+	}
+	output, listErr := b.goCommand(args...)
+	if listErr != nil {
+		return nil, nil, listErr
+	}
+	packages, decodeErr := DecodePackages(bytes.NewReader(output))
+	if decodeErr != nil {
+		return nil, nil, decodeErr
+	}
+	packageErrs := make(map[string]string)
+	for _, pkg := range packages {
+		if pkg.Error != nil {
+			packageErrs[pkg.ImportPath] = pkg.Error.Err
+		}
+	}
+
+	var resolved []string
+	var checks []helpers.Check
+	for _, name := range names {
+		importPath := b.GenConfig.Transformers[name].ImportPath()
+		check := helpers.Check{Subject: name, Description: "resolve package " + importPath, Problem: packageErrs[importPath]}
+		if check.OK() {
+			resolved = append(resolved, name)
+		}
+		checks = append(checks, check)
+	}
+	return resolved, checks, nil
+}
+
+var probeError = regexp.MustCompile(`^(?:\./)?probe_(\d+)\.go:\d+:\d+: (.*)$`)
+
+// checkInitializers type checks a main package assigning each transformer's initializer to its transformer type's
+// initializer type. Each transformer gets its own file, so that compile errors can be attributed to it.
+func (b *builder) checkInitializers(names []string) ([]helpers.Check, error) {
+	if len(names) < 1 {
+		return nil, nil
+	}
+	mainErr := ioutil.WriteFile(filepath.Join(b.modDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	if mainErr != nil {
+		return nil, mainErr
+	}
+	for i, name := range names {
+		probe := InitializerProbe(b.GenConfig.Transformers[name])
+		writeErr := ioutil.WriteFile(filepath.Join(b.modDir, fmt.Sprintf("probe_%d.go", i)), []byte(probe), 0644)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+	}
+
+	problems := make(map[int][]string)
+	_, buildErr := b.goCommand("build", "-gcflags=-e", "-o", os.DevNull, ".")
+	if buildErr != nil {
+		attributed := false
+		for _, line := range strings.Split(buildErr.Error(), "\n") {
+			match := probeError.FindStringSubmatch(strings.TrimSpace(line))
+			if match == nil {
+				continue
+			}
+			var i int
+			fmt.Sscan(match[1], &i)
+			problems[i] = append(problems[i], match[2])
+			attributed = true
+		}
+		if !attributed {
+			// The transformers' packages or their dependencies fail to compile
+			return []helpers.Check{{Subject: "plugin", Description: "compile transformer packages", Problem: buildErr.Error()}}, nil
+		}
+	}
+
+	checks := make([]helpers.Check, 0, len(names))
+	for i, name := range names {
+		transformer := b.GenConfig.Transformers[name]
+		checks = append(checks, helpers.Check{
+			Subject:     name,
+			Description: fmt.Sprintf("exports %s for type %s", transformer.Type.InitializerSymbol(), transformer.Type),
+			Problem:     strings.Join(problems[i], "; "),
+		})
+	}
+	return checks, nil
+}
+
+// InitializerProbe is a file of the check's main package that only compiles if the transformer's package
+// exports the initializer for its type
+func InitializerProbe(transformer config.Transformer) string {
+	symbol := transformer.Type.InitializerSymbol()
+	return fmt.Sprintf("package main\n\nimport (\n\ttransformer %q\n\tpkg %q\n)\n\nvar _ transformer.%s = pkg.%s\n",
+		transformerPackage, transformer.ImportPath(), symbol, symbol)
+}
+
+func (b *builder) transformerNames() []string {
+	names := make([]string, 0, len(b.GenConfig.Transformers))
+	for name := range b.GenConfig.Transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,65 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package builder_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/builder"
+)
+
+var _ = Describe("Plugin check", func() {
+	Describe("InitializerProbe", func() {
+		It("assigns the package's initializer to the initializer type of the transformer's type", func() {
+			probe := builder.InitializerProbe(config.Transformer{
+				Path:           "transformers/storage",
+				Type:           config.EthStorage,
+				RepositoryPath: "github.com/account/repo",
+			})
+
+			Expect(probe).To(ContainSubstring(`pkg "github.com/account/repo/transformers/storage"`))
+			Expect(probe).To(ContainSubstring(`transformer "github.com/vulcanize/vulcanizedb/libraries/shared/transformer"`))
+			Expect(probe).To(ContainSubstring("var _ transformer.StorageTransformerInitializer = pkg.StorageTransformerInitializer"))
+		})
+	})
+
+	Describe("DecodePackages", func() {
+		It("decodes the output of go list -e -json", func() {
+			output := `{
+	"ImportPath": "github.com/account/repo/transformers/event",
+	"Name": "event"
+}
+{
+	"ImportPath": "github.com/account/repo/transformers/missing",
+	"Error": {
+		"Err": "cannot find module providing package github.com/account/repo/transformers/missing"
+	}
+}
+`
+			packages, err := builder.DecodePackages(strings.NewReader(output))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packages).To(HaveLen(2))
+			Expect(packages[0].Error).To(BeNil())
+			Expect(packages[1].ImportPath).To(Equal("github.com/account/repo/transformers/missing"))
+			Expect(packages[1].Error.Err).To(ContainSubstring("cannot find module"))
+		})
+	})
+})
@@ -21,6 +21,7 @@ import (
 
 	"github.com/vulcanize/vulcanizedb/pkg/config"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/builder"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/manager"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/writer"
 )
@@ -28,6 +29,7 @@ import (
 // Generator is the top-level interface for creating transformer plugins
 type Generator interface {
 	GenerateExporterPlugin() error
+	DryRun() ([]helpers.Check, error)
 }
 
 type generator struct {
@@ -67,3 +69,18 @@ func (g *generator) GenerateExporterPlugin() error {
 	// Perform db migrations for the transformers
 	return g.MigrationManager.RunMigrations()
 }
+
+// Checks the plugin for the transformer initializers specified in the generator config could be generated,
+// without writing or building it: resolves and type checks the transformer packages => parses the migrations and
+// applies them in a transaction that is rolled back
+func (g *generator) DryRun() ([]helpers.Check, error) {
+	checks, err := g.PluginBuilder.Check()
+	if err != nil {
+		return nil, err
+	}
+	migrationChecks, err := g.MigrationManager.DryRun()
+	if err != nil {
+		return nil, err
+	}
+	return append(checks, migrationChecks...), nil
+}
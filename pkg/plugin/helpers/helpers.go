@@ -114,3 +114,15 @@ func CopyDir(src string, dst string, excludeRecursiveDir string) error {
 	}
 	return nil
 }
+
+// Check is the result of checking part of a plugin without composing it
+type Check struct {
+	Subject     string // e.g. a transformer or migration group
+	Description string
+	Problem     string // empty if the check passed
+	Warning     string // reported without failing the check
+}
+
+func (check Check) OK() bool {
+	return check.Problem == ""
+}
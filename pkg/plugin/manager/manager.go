@@ -17,28 +17,37 @@
 package manager
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/lib/pq"
-	"github.com/pressly/goose"
+	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/vulcanizedb/pkg/config"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
 )
 
 // Interface for managing the db migrations for plugin transformers
+// Each transformer repository's migration directory is a group, versioned in its own table in the plugin_migrations
+// schema rather than in goose's table, so that it can be migrated and rolled back independently of other groups.
+// A group's migrations run with its schema first on the search path, so that its unqualified objects don't collide
+// with those of other groups
 type MigrationManager interface {
 	RunMigrations() error
+	Status() ([]GroupStatus, error)
+	Baseline() error
+	Down(group string, steps int) error
+	DryRun() ([]helpers.Check, error)
 }
 
 type manager struct {
 	GenConfig config.Plugin
 	DBConfig  config.Database
-	tmpMigDir string
 	db        *sql.DB
 }
 
@@ -51,6 +60,9 @@ func NewMigrationManager(gc config.Plugin, dbc config.Database) MigrationManager
 }
 
 func (m *manager) setDB() error {
+	if m.db != nil {
+		return nil
+	}
 	var pgStr string
 	if len(m.DBConfig.User) > 0 && len(m.DBConfig.Password) > 0 {
 		pgStr = fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=disable",
@@ -66,102 +78,488 @@ func (m *manager) setDB() error {
 	return nil
 }
 
+// RunMigrations applies each group's pending migrations, in rank order
 func (m *manager) RunMigrations() error {
-	// Get paths to db migrations from the plugin config
-	paths, err := m.GenConfig.GetMigrationsPaths()
-	if err != nil {
+	return m.up(false)
+}
+
+// Baseline records each group's pending migrations as applied without running them,
+// for databases whose plugin migrations were applied into goose's table
+func (m *manager) Baseline() error {
+	return m.up(true)
+}
+
+func (m *manager) up(baseline bool) error {
+	groups, err := m.groups()
+	if err != nil || len(groups) < 1 {
 		return err
 	}
-	if len(paths) < 1 {
-		return nil
+	if !baseline {
+		unversionedErr := m.checkUnversioned(groups)
+		if unversionedErr != nil {
+			return unversionedErr
+		}
+	}
+	for _, group := range groups {
+		applied, appliedErr := m.applied(group, true)
+		if appliedErr != nil {
+			return appliedErr
+		}
+		pending, pendingErr := PendingMigrations(group, applied)
+		if pendingErr != nil {
+			return pendingErr
+		}
+		for _, migration := range pending {
+			applyErr := m.apply(group, migration, baseline)
+			if applyErr != nil {
+				return fmt.Errorf("db migration %s for plugin transformers in %s failed: %s", migration.Name, group.Dir, applyErr.Error())
+			}
+			logrus.Infof("applied migration %s of %s", migration.Name, group.Name)
+		}
 	}
-	// Init directory for temporary copies of migrations
-	err = m.setupMigrationEnv()
+	return nil
+}
+
+// Status reports whether each group's migrations are applied, pending, changed since they were applied or missing
+func (m *manager) Status() ([]GroupStatus, error) {
+	groups, err := m.groups()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer m.cleanUp()
-	// Creates copies of migrations for all the plugin's transformers in a tmp dir
-	err = m.createMigrationCopies(paths)
+	statuses := make([]GroupStatus, 0, len(groups))
+	for _, group := range groups {
+		applied, appliedErr := m.applied(group, false)
+		if appliedErr != nil {
+			return nil, appliedErr
+		}
+		statuses = append(statuses, Status(group, applied))
+	}
+	return statuses, nil
+}
+
+// Down rolls back the latest steps migrations of a group, given by its name or repository,
+// or of the highest ranked group if none is given
+func (m *manager) Down(groupName string, steps int) error {
+	groups, err := m.groups()
 	if err != nil {
 		return err
 	}
-
+	group, groupErr := findGroup(groups, groupName)
+	if groupErr != nil {
+		return groupErr
+	}
+	applied, appliedErr := m.applied(group, false)
+	if appliedErr != nil {
+		return appliedErr
+	}
+	rollback, rollbackErr := RollbackMigrations(group, applied, steps)
+	if rollbackErr != nil {
+		return rollbackErr
+	}
+	for _, migration := range rollback {
+		revertErr := m.revert(group, migration)
+		if revertErr != nil {
+			return fmt.Errorf("rolling back db migration %s for plugin transformers in %s failed: %s", migration.Name, group.Dir, revertErr.Error())
+		}
+		logrus.Infof("rolled back migration %s of %s", migration.Name, group.Name)
+	}
 	return nil
 }
 
-// Setup a temporary directory to hold transformer db migrations
-func (m *manager) setupMigrationEnv() error {
-	var err error
-	m.tmpMigDir, err = helpers.CleanPath(filepath.Join("$GOPATH/src", m.GenConfig.Home, ".plugin_migrations"))
-	if err != nil {
-		return err
+// DryRun parses each group's migrations and applies the pending ones in a transaction that is rolled back.
+// Migrations that opt out of transactions are parsed but not applied.
+func (m *manager) DryRun() ([]helpers.Check, error) {
+	var checks []helpers.Check
+	rankCheck := helpers.Check{Subject: "migrations", Description: "migration ranks"}
+	_, pathsErr := m.GenConfig.GetMigrationsPaths()
+	if pathsErr != nil {
+		rankCheck.Problem = pathsErr.Error()
 	}
-	removeErr := os.RemoveAll(m.tmpMigDir)
-	if removeErr != nil {
-		removeErrString := "unable to remove file found at %s where tmp directory needs to be written: %s"
-		return fmt.Errorf(removeErrString, m.tmpMigDir, removeErr.Error())
+	checks = append(checks, rankCheck)
+
+	groups, parseChecks := m.parseGroups()
+	checks = append(checks, parseChecks...)
+	if len(groups) < 1 {
+		return checks, nil
 	}
-	mkdirErr := os.Mkdir(m.tmpMigDir, os.FileMode(os.ModePerm))
-	if mkdirErr != nil {
-		mkdirErrString := "unable to create temporary migration directory %s: %s"
-		return fmt.Errorf(mkdirErrString, m.tmpMigDir, mkdirErr.Error())
+	shared := SharedSchemas(groups)
+	for _, group := range groups {
+		check := helpers.Check{Subject: group.Name, Description: "schema " + group.Schema}
+		if owner, ok := shared[group.Name]; ok {
+			// Groups migrated before schemas were configured share the public schema, as groups() allows
+			message := fmt.Sprintf("shares schema %s with %s, configure a schema for each migration directory", group.Schema, owner)
+			if group.Schema == PublicSchema {
+				check.Warning = message
+			} else {
+				check.Problem = message
+			}
+		}
+		checks = append(checks, check)
 	}
 
-	return nil
+	setErr := m.setDB()
+	if setErr != nil {
+		return nil, fmt.Errorf("could not open db: %s", setErr.Error())
+	}
+	tx, beginErr := m.db.Begin()
+	if beginErr != nil {
+		return append(checks, helpers.Check{Subject: "migrations", Description: "connect to db", Problem: beginErr.Error()}), nil
+	}
+	defer tx.Rollback()
+	failed := false
+	for _, group := range groups {
+		applied, appliedErr := m.applied(group, false)
+		if appliedErr != nil {
+			return nil, appliedErr
+		}
+		pending, pendingErr := PendingMigrations(group, applied)
+		if pendingErr != nil {
+			checks = append(checks, helpers.Check{Subject: group.Name, Description: "applied migrations", Problem: pendingErr.Error()})
+			continue
+		}
+		if len(pending) > 0 && !failed {
+			schemaErr := useSchema(tx, group, true)
+			if schemaErr != nil {
+				checks = append(checks, helpers.Check{Subject: group.Name, Description: "use schema " + group.Schema, Problem: schemaErr.Error()})
+				failed = true
+			}
+		}
+		for _, migration := range pending {
+			check := helpers.Check{Subject: group.Name, Description: "apply " + migration.Name}
+			switch {
+			case failed:
+				check.Problem = "not applied, an earlier migration failed"
+			case migration.NoTransaction:
+				check.Description = "apply " + migration.Name + " (skipped, runs outside a transaction)"
+			default:
+				_, upErr := tx.Exec(migration.Up)
+				if upErr != nil {
+					check.Problem = upErr.Error()
+					failed = true
+				}
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks, nil
 }
 
-// Create copies of db migrations from vendored libs
-func (m *manager) createMigrationCopies(paths []string) error {
-	// Iterate through migration paths to find migration directory
-	for _, path := range paths {
-		dir, err := ioutil.ReadDir(path)
-		if err != nil {
-			return err
-		}
-		// For each file in the directory check if it is a migration
-		for _, file := range dir {
-			if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
-				continue
+// parseGroups parses the migrations of each group, in rank order, reporting each group that fails to parse
+func (m *manager) parseGroups() ([]Group, []helpers.Check) {
+	var groups []Group
+	var checks []helpers.Check
+	parsed := make(map[string]string) // migration directory => schema
+	for _, transformer := range m.GenConfig.Transformers {
+		check := helpers.Check{Subject: GroupName(transformer.RepositoryPath, transformer.MigrationPath), Description: "parse migrations"}
+		repoDir, dirErr := transformer.RepositoryDir()
+		if dirErr != nil {
+			check.Problem = dirErr.Error()
+			checks = append(checks, check)
+			continue
+		}
+		dir := filepath.Join(repoDir, transformer.MigrationPath)
+		if schema, ok := parsed[dir]; ok {
+			if schema != GroupSchema(transformer) {
+				check.Description = "schema " + GroupSchema(transformer)
+				check.Problem = fmt.Sprintf("transformers migrating %s configure both schema %s and %s", dir, schema, GroupSchema(transformer))
+				checks = append(checks, check)
 			}
-			src := filepath.Join(path, file.Name())
-			dst := filepath.Join(m.tmpMigDir, file.Name())
-			//  and if it is make a copy of it to our tmp migration directory
-			err = helpers.CopyFile(src, dst)
-			if err != nil {
-				return err
+			continue
+		}
+		parsed[dir] = GroupSchema(transformer)
+		group, groupErr := NewGroup(transformer.RepositoryPath, transformer.MigrationPath, dir, transformer.MigrationRank)
+		group.Schema = GroupSchema(transformer)
+		if groupErr != nil {
+			check.Problem = groupErr.Error()
+		} else {
+			check.Description = fmt.Sprintf("parse %d migrations in %s", len(group.Migrations), dir)
+			groups = append(groups, group)
+		}
+		checks = append(checks, check)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Rank < groups[j].Rank
+	})
+	sort.SliceStable(checks, func(i, j int) bool {
+		return checks[i].Subject < checks[j].Subject
+	})
+	return groups, checks
+}
+
+func findGroup(groups []Group, name string) (Group, error) {
+	if len(groups) < 1 {
+		return Group{}, errors.New("plugin has no migrations")
+	}
+	if name == "" {
+		return groups[len(groups)-1], nil
+	}
+	var found []Group
+	for _, group := range groups {
+		if group.Name == name || group.Repository == name {
+			found = append(found, group)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Group{}, fmt.Errorf("plugin has no migrations for %s", name)
+	case 1:
+		return found[0], nil
+	default:
+		return Group{}, fmt.Errorf("%s has several migration directories, name one of their groups instead", name)
+	}
+}
+
+// groups returns the migration groups of the plugin's transformers, in rank order
+func (m *manager) groups() ([]Group, error) {
+	// Validates the ranks of the migration paths
+	paths, err := m.GenConfig.GetMigrationsPaths()
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]Group)
+	for _, transformer := range m.GenConfig.Transformers {
+		repoDir, dirErr := transformer.RepositoryDir()
+		if dirErr != nil {
+			return nil, dirErr
+		}
+		dir := filepath.Join(repoDir, transformer.MigrationPath)
+		if group, ok := groups[dir]; ok {
+			if group.Schema != GroupSchema(transformer) {
+				return nil, fmt.Errorf("transformers migrating %s configure both schema %s and %s", dir, group.Schema, GroupSchema(transformer))
 			}
+			continue
 		}
-		err = m.fixAndRun(path)
-		if err != nil {
-			return err
+		group, groupErr := NewGroup(transformer.RepositoryPath, transformer.MigrationPath, dir, transformer.MigrationRank)
+		if groupErr != nil {
+			return nil, groupErr
+		}
+		group.Schema = GroupSchema(transformer)
+		groups[dir] = group
+	}
+	ranked := make([]Group, 0, len(paths))
+	for _, path := range paths {
+		ranked = append(ranked, groups[path])
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Rank < ranked[j].Rank
+	})
+	// Groups migrated before schemas were configured share the public schema
+	for name, owner := range SharedSchemas(ranked) {
+		schema := groupNamed(ranked, name).Schema
+		if schema != PublicSchema {
+			return nil, fmt.Errorf("migration groups %s and %s share schema %s", owner, name, schema)
 		}
+		logrus.Warnf("migration groups %s and %s share the %s schema", owner, name, schema)
 	}
+	return ranked, nil
+}
 
-	return nil
+func groupNamed(groups []Group, name string) Group {
+	for _, group := range groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return Group{}
+}
+
+func versionTable(group Group) string {
+	return pq.QuoteIdentifier(MigrationsSchema) + "." + pq.QuoteIdentifier(group.Name)
 }
 
-func (m *manager) fixAndRun(path string) error {
-	// Setup DB if not set
-	if m.db == nil {
-		setErr := m.setDB()
-		if setErr != nil {
-			return fmt.Errorf("could not open db: %s", setErr.Error())
+// applied returns the migrations applied from a group, creating its version table if create is set
+func (m *manager) applied(group Group, create bool) ([]AppliedMigration, error) {
+	setErr := m.setDB()
+	if setErr != nil {
+		return nil, fmt.Errorf("could not open db: %s", setErr.Error())
+	}
+	if create {
+		_, schemaErr := m.db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + pq.QuoteIdentifier(MigrationsSchema))
+		if schemaErr != nil {
+			return nil, schemaErr
+		}
+		_, tableErr := m.db.Exec(`CREATE TABLE IF NOT EXISTS ` + versionTable(group) + ` (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`)
+		if tableErr != nil {
+			return nil, tableErr
+		}
+	} else {
+		exists, existsErr := m.hasVersionTable(group)
+		if existsErr != nil || !exists {
+			return nil, existsErr
 		}
 	}
-	// Fix the migrations
-	fixErr := goose.Fix(m.tmpMigDir)
-	if fixErr != nil {
-		return fmt.Errorf("version fixing for plugin migrations at %s failed: %s", path, fixErr.Error())
+
+	rows, queryErr := m.db.Query(`SELECT version, name, checksum FROM ` + versionTable(group) + ` ORDER BY version`)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+	var applied []AppliedMigration
+	for rows.Next() {
+		var migration AppliedMigration
+		scanErr := rows.Scan(&migration.Version, &migration.Name, &migration.Checksum)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		applied = append(applied, migration)
 	}
-	// Run the copied migrations with goose
-	upErr := goose.Up(m.db, m.tmpMigDir)
-	if upErr != nil {
-		return fmt.Errorf("db migrations for plugin transformers at %s failed: %s", path, upErr.Error())
+	return applied, rows.Err()
+}
+
+func (m *manager) hasVersionTable(group Group) (bool, error) {
+	var exists bool
+	err := m.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM information_schema.tables
+		WHERE table_schema = $1 AND table_name = $2)`, MigrationsSchema, group.Name).Scan(&exists)
+	return exists, err
+}
+
+// checkUnversioned refuses to migrate groups without a version table whose first migration has already been applied,
+// e.g. through goose's table before groups were versioned separately, since their migrations would be applied again.
+// The first migration is tried in a transaction that is rolled back, and is taken to be applied if it fails because an
+// object it creates already exists
+func (m *manager) checkUnversioned(groups []Group) error {
+	setErr := m.setDB()
+	if setErr != nil {
+		return fmt.Errorf("could not open db: %s", setErr.Error())
+	}
+	for _, group := range groups {
+		if len(group.Migrations) < 1 || group.Migrations[0].NoTransaction {
+			continue
+		}
+		exists, existsErr := m.hasVersionTable(group)
+		if existsErr != nil {
+			return existsErr
+		}
+		if exists {
+			continue
+		}
+		tx, beginErr := m.db.Begin()
+		if beginErr != nil {
+			return beginErr
+		}
+		upErr := useSchema(tx, group, true)
+		if upErr == nil {
+			_, upErr = tx.Exec(group.Migrations[0].Up)
+		}
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		if pqErr, ok := upErr.(*pq.Error); ok && strings.HasPrefix(pqErr.Code.Name(), "duplicate_") {
+			return fmt.Errorf("migration group %s has no version table in %s, but its migration %s was already applied (%s); "+
+				"if its migrations were applied through goose's table, run \"vulcanizedb compose migrate up --baseline\" "+
+				"to record them as applied", group.Name, MigrationsSchema, group.Migrations[0].Name, pqErr.Message)
+		}
 	}
 	return nil
 }
 
-func (m *manager) cleanUp() error {
-	return os.RemoveAll(m.tmpMigDir)
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// apply runs a migration's up statements and records it, in one transaction unless the migration opts out
+func (m *manager) apply(group Group, migration Migration, recordOnly bool) error {
+	return m.inSchema(group, !migration.NoTransaction, func(db execer) error {
+		if !recordOnly {
+			_, upErr := db.Exec(migration.Up)
+			if upErr != nil {
+				return upErr
+			}
+		}
+		_, insertErr := db.Exec(`INSERT INTO `+versionTable(group)+` (version, name, checksum) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, migration.Checksum)
+		return insertErr
+	})
+}
+
+// revert runs a migration's down statements and removes its record
+func (m *manager) revert(group Group, migration Migration) error {
+	return m.inSchema(group, !migration.NoTransaction, func(db execer) error {
+		_, downErr := db.Exec(migration.Down)
+		if downErr != nil {
+			return downErr
+		}
+		_, deleteErr := db.Exec(`DELETE FROM `+versionTable(group)+` WHERE version = $1`, migration.Version)
+		return deleteErr
+	})
+}
+
+// inSchema runs statements with the group's schema first on the search path, in a transaction if transaction is set
+func (m *manager) inSchema(group Group, transaction bool, run func(db execer) error) error {
+	if !transaction {
+		return m.inSession(group, run)
+	}
+	tx, beginErr := m.db.Begin()
+	if beginErr != nil {
+		return beginErr
+	}
+	runErr := useSchema(tx, group, true)
+	if runErr == nil {
+		runErr = run(tx)
+	}
+	if runErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Errorf("failed to rollback transaction: %s", rollbackErr.Error())
+		}
+		return runErr
+	}
+	return tx.Commit()
+}
+
+// inSession sets the search path for a single connection, since it can't be scoped to a transaction
+func (m *manager) inSession(group Group, run func(db execer) error) error {
+	ctx := context.Background()
+	conn, connErr := m.db.Conn(ctx)
+	if connErr != nil {
+		return connErr
+	}
+	defer conn.Close()
+	session := connExecer{ctx: ctx, conn: conn}
+	schemaErr := useSchema(session, group, false)
+	if schemaErr != nil {
+		return schemaErr
+	}
+	defer func() {
+		_, resetErr := session.Exec(`RESET search_path`)
+		if resetErr != nil {
+			logrus.Errorf("failed to reset search path: %s", resetErr.Error())
+		}
+	}()
+	return run(session)
+}
+
+type connExecer struct {
+	ctx  context.Context
+	conn *sql.Conn
+}
+
+func (c connExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, query, args...)
+}
+
+// useSchema creates the group's schema and puts it first on the search path, so that unqualified objects are created in
+// it; public stays on the path for vulcanizedb's own tables
+func useSchema(db execer, group Group, local bool) error {
+	schema := pq.QuoteIdentifier(group.Schema)
+	searchPath := schema
+	if group.Schema != PublicSchema {
+		_, createErr := db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + schema)
+		if createErr != nil {
+			return createErr
+		}
+		searchPath += `, public`
+	}
+	set := `SET search_path TO `
+	if local {
+		set = `SET LOCAL search_path TO `
+	}
+	_, setErr := db.Exec(set + searchPath)
+	return setErr
 }
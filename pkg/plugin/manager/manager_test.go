@@ -0,0 +1,126 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manager"
+)
+
+var _ = Describe("Migration manager", func() {
+	Describe("DryRun", func() {
+		var gopath, originalGopath string
+
+		BeforeEach(func() {
+			originalGopath = os.Getenv("GOPATH")
+			var err error
+			gopath, err = ioutil.TempDir("", "gopath")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Setenv("GOPATH", gopath)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("GOPATH", originalGopath)).To(Succeed())
+			Expect(os.RemoveAll(gopath)).To(Succeed())
+		})
+
+		It("reports every migration directory that fails to parse", func() {
+			badDir := filepath.Join(gopath, "src", "github.com", "account", "bad", "db", "migrations")
+			Expect(os.MkdirAll(badDir, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(badDir, "00001_no_annotation.sql"), []byte("CREATE TABLE example ();"), 0644)).To(Succeed())
+			pluginConfig := config.Plugin{Transformers: map[string]config.Transformer{
+				"bad":     {RepositoryPath: "github.com/account/bad", RepositoryDirectory: "$GOPATH/src/github.com/account/bad", MigrationPath: "db/migrations", MigrationRank: 0},
+				"missing": {RepositoryPath: "github.com/account/missing", RepositoryDirectory: "$GOPATH/src/github.com/account/missing", MigrationPath: "db/migrations", MigrationRank: 1},
+			}}
+
+			checks, err := manager.NewMigrationManager(pluginConfig, config.Database{}).DryRun()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checks).To(HaveLen(3))
+			Expect(checks[0].OK()).To(BeTrue())
+			Expect(checks[1].Subject).To(Equal("github_com_account_bad_db_migrations"))
+			Expect(checks[1].Problem).To(ContainSubstring("has no '-- +goose Up' annotation"))
+			Expect(checks[2].Subject).To(Equal("github_com_account_missing_db_migrations"))
+			Expect(checks[2].Problem).To(ContainSubstring("no such file or directory"))
+		})
+
+		It("reports migration groups that share a schema", func() {
+			for _, repo := range []string{"first", "second", "third"} {
+				dir := filepath.Join(gopath, "src", "github.com", "account", repo, "db", "migrations")
+				Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(dir, "00001_create_example.sql"),
+					[]byte("-- +goose Up\nCREATE TABLE example ();\n-- +goose Down\nDROP TABLE example;\n"), 0644)).To(Succeed())
+			}
+			pluginConfig := config.Plugin{Transformers: map[string]config.Transformer{
+				"first":  {RepositoryPath: "github.com/account/first", RepositoryDirectory: "$GOPATH/src/github.com/account/first", MigrationPath: "db/migrations", MigrationRank: 0, MigrationSchema: "repo"},
+				"second": {RepositoryPath: "github.com/account/second", RepositoryDirectory: "$GOPATH/src/github.com/account/second", MigrationPath: "db/migrations", MigrationRank: 1, MigrationSchema: "repo"},
+				"third":  {RepositoryPath: "github.com/account/third", RepositoryDirectory: "$GOPATH/src/github.com/account/third", MigrationPath: "db/migrations", MigrationRank: 2, MigrationSchema: "third"},
+			}}
+
+			checks, err := manager.NewMigrationManager(pluginConfig, config.Database{}).DryRun()
+
+			Expect(err).NotTo(HaveOccurred())
+			var schemaChecks []helpers.Check
+			for _, check := range checks {
+				if strings.HasPrefix(check.Description, "schema ") {
+					schemaChecks = append(schemaChecks, check)
+				}
+			}
+			Expect(schemaChecks).To(HaveLen(3))
+			Expect(schemaChecks[0].OK()).To(BeTrue())
+			Expect(schemaChecks[1].Subject).To(Equal("github_com_account_second_db_migrations"))
+			Expect(schemaChecks[1].Problem).To(ContainSubstring("shares schema repo with github_com_account_first_db_migrations"))
+			Expect(schemaChecks[2].OK()).To(BeTrue())
+		})
+
+		It("warns about migration groups that share the public schema", func() {
+			for _, repo := range []string{"first", "second"} {
+				dir := filepath.Join(gopath, "src", "github.com", "account", repo, "db", "migrations")
+				Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(dir, "00001_create_example.sql"),
+					[]byte("-- +goose Up\nCREATE TABLE example ();\n-- +goose Down\nDROP TABLE example;\n"), 0644)).To(Succeed())
+			}
+			pluginConfig := config.Plugin{Transformers: map[string]config.Transformer{
+				"first":  {RepositoryPath: "github.com/account/first", RepositoryDirectory: "$GOPATH/src/github.com/account/first", MigrationPath: "db/migrations", MigrationRank: 0},
+				"second": {RepositoryPath: "github.com/account/second", RepositoryDirectory: "$GOPATH/src/github.com/account/second", MigrationPath: "db/migrations", MigrationRank: 1},
+			}}
+
+			checks, err := manager.NewMigrationManager(pluginConfig, config.Database{}).DryRun()
+
+			Expect(err).NotTo(HaveOccurred())
+			var schemaChecks []helpers.Check
+			for _, check := range checks {
+				if strings.HasPrefix(check.Description, "schema ") {
+					schemaChecks = append(schemaChecks, check)
+				}
+			}
+			Expect(schemaChecks).To(HaveLen(2))
+			Expect(schemaChecks[0].OK()).To(BeTrue())
+			Expect(schemaChecks[1].OK()).To(BeTrue())
+			Expect(schemaChecks[1].Warning).To(ContainSubstring("shares schema public with github_com_account_first_db_migrations"))
+		})
+	})
+})
@@ -0,0 +1,293 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pressly/goose"
+
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+)
+
+// MigrationsSchema is the schema holding each migration group's version table
+const MigrationsSchema = "plugin_migrations"
+
+// PublicSchema is the schema of groups that don't configure their own
+const PublicSchema = "public"
+
+const (
+	gooseUp            = "-- +goose Up"
+	gooseDown          = "-- +goose Down"
+	gooseNoTransaction = "-- +goose NO TRANSACTION"
+	maxIdentifierLen   = 63
+)
+
+// Migration is a goose .sql migration file; goose annotations separate its up and down statements
+type Migration struct {
+	Version       int64
+	Name          string
+	Checksum      string // sha256 of the file's contents
+	Up            string
+	Down          string
+	NoTransaction bool
+}
+
+// ParseMigration reads a migration file named like goose's, e.g. 00001_create_table.sql
+func ParseMigration(path string) (Migration, error) {
+	version, versionErr := goose.NumericComponent(path)
+	if versionErr != nil {
+		return Migration{}, fmt.Errorf("migration %s: %s", path, versionErr.Error())
+	}
+	contents, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return Migration{}, readErr
+	}
+	checksum := sha256.Sum256(contents)
+	migration := Migration{
+		Version:  version,
+		Name:     filepath.Base(path),
+		Checksum: hex.EncodeToString(checksum[:]),
+	}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(nil, len(contents)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch strings.TrimSpace(line) {
+		case gooseUp:
+			section = &up
+			continue
+		case gooseDown:
+			section = &down
+			continue
+		case gooseNoTransaction:
+			migration.NoTransaction = true
+			continue
+		}
+		if section != nil {
+			section.WriteString(line + "\n")
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return Migration{}, scanErr
+	}
+	if section == nil {
+		return Migration{}, fmt.Errorf("migration %s has no '%s' annotation", path, gooseUp)
+	}
+	migration.Up, migration.Down = up.String(), down.String()
+	return migration, nil
+}
+
+// Group is the migrations of one transformer repository's migration directory, which are versioned in their own table
+type Group struct {
+	Name       string // also the name of the group's version table in MigrationsSchema
+	Repository string
+	Dir        string
+	Rank       uint64
+	Schema     string      // schema unqualified objects of the group's migrations are created in
+	Migrations []Migration // ordered by version
+}
+
+// NewGroup reads the migrations in a directory
+func NewGroup(repository, migrationPath, dir string, rank uint64) (Group, error) {
+	group := Group{Name: GroupName(repository, migrationPath), Repository: repository, Dir: dir, Rank: rank}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return Group{}, err
+	}
+	versions := make(map[int64]string)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		migration, parseErr := ParseMigration(filepath.Join(dir, file.Name()))
+		if parseErr != nil {
+			return Group{}, parseErr
+		}
+		if other, ok := versions[migration.Version]; ok {
+			return Group{}, fmt.Errorf("migrations %s and %s in %s have the same version", other, migration.Name, dir)
+		}
+		versions[migration.Version] = migration.Name
+		group.Migrations = append(group.Migrations, migration)
+	}
+	sort.Slice(group.Migrations, func(i, j int) bool {
+		return group.Migrations[i].Version < group.Migrations[j].Version
+	})
+	return group, nil
+}
+
+// GroupSchema returns the schema of a transformer's migration group
+func GroupSchema(transformer config.Transformer) string {
+	if transformer.MigrationSchema == "" {
+		return PublicSchema
+	}
+	return transformer.MigrationSchema
+}
+
+// SharedSchemas returns, for each group whose schema is also used by a group ranked before it, the name of that group
+func SharedSchemas(groups []Group) map[string]string {
+	owners := make(map[string]string)
+	shared := make(map[string]string)
+	for _, group := range groups {
+		if owner, ok := owners[group.Schema]; ok {
+			shared[group.Name] = owner
+			continue
+		}
+		owners[group.Schema] = group.Name
+	}
+	return shared
+}
+
+// GroupName derives a table name from a repository and its migration path, e.g. github_com_account_repo_db_migrations
+func GroupName(repository, migrationPath string) string {
+	var name strings.Builder
+	for _, r := range strings.ToLower(repository + "/" + filepath.Clean(migrationPath)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			name.WriteRune(r)
+		} else {
+			name.WriteRune('_')
+		}
+	}
+	groupName := strings.Trim(name.String(), "_")
+	if len(groupName) > maxIdentifierLen {
+		hash := sha256.Sum256([]byte(groupName))
+		groupName = groupName[:maxIdentifierLen-9] + "_" + hex.EncodeToString(hash[:4])
+	}
+	return groupName
+}
+
+// AppliedMigration is a row of a group's version table
+type AppliedMigration struct {
+	Version  int64
+	Name     string
+	Checksum string
+}
+
+// Migration states reported by status
+const (
+	Applied = "applied"
+	Pending = "pending"
+	Changed = "changed" // applied, but the file has changed since
+	Missing = "missing" // applied, but the file no longer exists
+)
+
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	State   string
+}
+
+type GroupStatus struct {
+	Group      string
+	Repository string
+	Migrations []MigrationStatus
+}
+
+// Status compares a group's migration files with the migrations applied from it
+func Status(group Group, applied []AppliedMigration) GroupStatus {
+	status := GroupStatus{Group: group.Name, Repository: group.Repository}
+	appliedVersions := make(map[int64]AppliedMigration)
+	for _, migration := range applied {
+		appliedVersions[migration.Version] = migration
+	}
+	fileVersions := make(map[int64]bool)
+	for _, migration := range group.Migrations {
+		fileVersions[migration.Version] = true
+		state := Pending
+		if appliedMigration, ok := appliedVersions[migration.Version]; ok {
+			state = Applied
+			if appliedMigration.Checksum != migration.Checksum {
+				state = Changed
+			}
+		}
+		status.Migrations = append(status.Migrations, MigrationStatus{Version: migration.Version, Name: migration.Name, State: state})
+	}
+	for _, migration := range applied {
+		if !fileVersions[migration.Version] {
+			status.Migrations = append(status.Migrations, MigrationStatus{Version: migration.Version, Name: migration.Name, State: Missing})
+		}
+	}
+	sort.Slice(status.Migrations, func(i, j int) bool {
+		return status.Migrations[i].Version < status.Migrations[j].Version
+	})
+	return status
+}
+
+// PendingMigrations returns the migrations to apply, in order. It fails if an applied migration has changed or is missing,
+// or if a pending migration is older than an applied one.
+func PendingMigrations(group Group, applied []AppliedMigration) ([]Migration, error) {
+	var latest int64
+	for _, migration := range applied {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	var pending []Migration
+	for _, migrationStatus := range Status(group, applied).Migrations {
+		switch migrationStatus.State {
+		case Changed:
+			return nil, fmt.Errorf("migration %s of %s has changed since it was applied", migrationStatus.Name, group.Name)
+		case Missing:
+			return nil, fmt.Errorf("migration %s of %s was applied but is not in %s", migrationStatus.Name, group.Name, group.Dir)
+		case Pending:
+			if migrationStatus.Version < latest {
+				return nil, fmt.Errorf("migration %s of %s is older than the latest applied migration", migrationStatus.Name, group.Name)
+			}
+			pending = append(pending, group.migration(migrationStatus.Version))
+		}
+	}
+	return pending, nil
+}
+
+// RollbackMigrations returns up to steps of the latest applied migrations, latest first.
+// It fails if one of them has changed or is missing, since its down statements can't be trusted.
+func RollbackMigrations(group Group, applied []AppliedMigration, steps int) ([]Migration, error) {
+	statuses := Status(group, applied).Migrations
+	var rollback []Migration
+	for i := len(statuses) - 1; i >= 0 && len(rollback) < steps; i-- {
+		switch statuses[i].State {
+		case Changed:
+			return nil, fmt.Errorf("migration %s of %s has changed since it was applied", statuses[i].Name, group.Name)
+		case Missing:
+			return nil, fmt.Errorf("migration %s of %s was applied but is not in %s", statuses[i].Name, group.Name, group.Dir)
+		case Applied:
+			rollback = append(rollback, group.migration(statuses[i].Version))
+		}
+	}
+	return rollback, nil
+}
+
+func (group Group) migration(version int64) Migration {
+	for _, migration := range group.Migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return Migration{}
+}
@@ -0,0 +1,253 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manager"
+)
+
+const createTable = `-- +goose Up
+CREATE TABLE example (id SERIAL PRIMARY KEY);
+
+-- +goose Down
+DROP TABLE example;
+`
+
+var _ = Describe("Plugin migrations", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "migrations")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeMigration := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+
+	Describe("ParseMigration", func() {
+		It("splits a migration into its up and down statements", func() {
+			path := writeMigration("00002_create_example.sql", createTable)
+
+			migration, err := manager.ParseMigration(path)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migration.Version).To(Equal(int64(2)))
+			Expect(migration.Name).To(Equal("00002_create_example.sql"))
+			Expect(migration.Up).To(Equal("CREATE TABLE example (id SERIAL PRIMARY KEY);\n\n"))
+			Expect(migration.Down).To(Equal("DROP TABLE example;\n"))
+			Expect(migration.NoTransaction).To(BeFalse())
+			Expect(migration.Checksum).To(HaveLen(64))
+		})
+
+		It("keeps statement blocks and notes migrations that run outside a transaction", func() {
+			path := writeMigration("00001_create_index.sql", strings.Join([]string{
+				"-- +goose NO TRANSACTION",
+				"-- +goose Up",
+				"-- +goose StatementBegin",
+				"CREATE INDEX CONCURRENTLY example_index ON example (id);",
+				"-- +goose StatementEnd",
+				"-- +goose Down",
+				"DROP INDEX example_index;",
+			}, "\n"))
+
+			migration, err := manager.ParseMigration(path)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migration.NoTransaction).To(BeTrue())
+			Expect(migration.Up).To(ContainSubstring("CREATE INDEX CONCURRENTLY"))
+			Expect(migration.Down).To(Equal("DROP INDEX example_index;\n"))
+		})
+
+		It("changes the checksum when the file changes", func() {
+			path := writeMigration("00001_create_example.sql", createTable)
+			before, err := manager.ParseMigration(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			writeMigration("00001_create_example.sql", createTable+"-- comment\n")
+			after, err := manager.ParseMigration(path)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(after.Checksum).NotTo(Equal(before.Checksum))
+		})
+
+		It("fails without an up annotation", func() {
+			path := writeMigration("00001_create_example.sql", "CREATE TABLE example (id INTEGER);")
+
+			_, err := manager.ParseMigration(path)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails without a version", func() {
+			path := writeMigration("create_example.sql", createTable)
+
+			_, err := manager.ParseMigration(path)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewGroup", func() {
+		It("reads the .sql migrations in a directory in version order", func() {
+			writeMigration("00010_second.sql", createTable)
+			writeMigration("00002_first.sql", createTable)
+			writeMigration("README.md", "not a migration")
+
+			group, err := manager.NewGroup("github.com/account/repo", "db/migrations", dir, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(group.Name).To(Equal("github_com_account_repo_db_migrations"))
+			Expect(group.Rank).To(Equal(uint64(1)))
+			Expect(group.Migrations).To(HaveLen(2))
+			Expect(group.Migrations[0].Name).To(Equal("00002_first.sql"))
+			Expect(group.Migrations[1].Name).To(Equal("00010_second.sql"))
+		})
+
+		It("fails if two migrations have the same version", func() {
+			writeMigration("00001_first.sql", createTable)
+			writeMigration("00001_second.sql", createTable)
+
+			_, err := manager.NewGroup("github.com/account/repo", "db/migrations", dir, 0)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GroupName", func() {
+		It("shortens names to the length of a postgres identifier", func() {
+			name := manager.GroupName("github.com/an-account-with-a-long-name/a-repository-with-a-long-name", "db/migrations")
+			otherName := manager.GroupName("github.com/an-account-with-a-long-name/a-repository-with-a-long-name", "db/other_migrations")
+
+			Expect(len(name)).To(Equal(63))
+			Expect(name).NotTo(Equal(otherName))
+		})
+	})
+
+	Describe("GroupSchema", func() {
+		It("defaults to the public schema", func() {
+			Expect(manager.GroupSchema(config.Transformer{})).To(Equal(manager.PublicSchema))
+			Expect(manager.GroupSchema(config.Transformer{MigrationSchema: "repo"})).To(Equal("repo"))
+		})
+	})
+
+	Describe("SharedSchemas", func() {
+		It("maps each group to the earlier group sharing its schema", func() {
+			groups := []manager.Group{
+				{Name: "first", Schema: "repo"},
+				{Name: "second", Schema: "other_repo"},
+				{Name: "third", Schema: "repo"},
+				{Name: "fourth", Schema: "repo"},
+			}
+
+			Expect(manager.SharedSchemas(groups)).To(Equal(map[string]string{"third": "first", "fourth": "first"}))
+		})
+	})
+
+	Describe("status and planning", func() {
+		var group manager.Group
+
+		BeforeEach(func() {
+			writeMigration("00001_first.sql", createTable)
+			writeMigration("00002_second.sql", createTable)
+			writeMigration("00003_third.sql", createTable)
+			var err error
+			group, err = manager.NewGroup("github.com/account/repo", "db/migrations", dir, 0)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		applied := func(migrations ...manager.Migration) []manager.AppliedMigration {
+			var result []manager.AppliedMigration
+			for _, migration := range migrations {
+				result = append(result, manager.AppliedMigration{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum})
+			}
+			return result
+		}
+
+		It("reports applied, pending, changed and missing migrations", func() {
+			appliedMigrations := applied(group.Migrations[0], group.Migrations[1])
+			appliedMigrations[1].Checksum = "old"
+			appliedMigrations = append(appliedMigrations, manager.AppliedMigration{Version: 4, Name: "00004_removed.sql"})
+
+			status := manager.Status(group, appliedMigrations)
+
+			Expect(status.Group).To(Equal(group.Name))
+			Expect(status.Migrations).To(Equal([]manager.MigrationStatus{
+				{Version: 1, Name: "00001_first.sql", State: manager.Applied},
+				{Version: 2, Name: "00002_second.sql", State: manager.Changed},
+				{Version: 3, Name: "00003_third.sql", State: manager.Pending},
+				{Version: 4, Name: "00004_removed.sql", State: manager.Missing},
+			}))
+		})
+
+		It("plans the migrations after the latest applied one", func() {
+			pending, err := manager.PendingMigrations(group, applied(group.Migrations[0]))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(Equal(group.Migrations[1:]))
+		})
+
+		It("refuses to migrate when an applied migration changed", func() {
+			appliedMigrations := applied(group.Migrations[0])
+			appliedMigrations[0].Checksum = "old"
+
+			_, err := manager.PendingMigrations(group, appliedMigrations)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("00001_first.sql"))
+		})
+
+		It("refuses to apply a migration older than the latest applied one", func() {
+			_, err := manager.PendingMigrations(group, applied(group.Migrations[0], group.Migrations[2]))
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("00002_second.sql"))
+		})
+
+		It("rolls back the latest applied migrations first", func() {
+			rollback, err := manager.RollbackMigrations(group, applied(group.Migrations[0], group.Migrations[1]), 5)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollback).To(Equal([]manager.Migration{group.Migrations[1], group.Migrations[0]}))
+		})
+
+		It("refuses to roll back a changed migration", func() {
+			appliedMigrations := applied(group.Migrations[0], group.Migrations[1])
+			appliedMigrations[1].Checksum = "old"
+
+			_, err := manager.RollbackMigrations(group, appliedMigrations, 1)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
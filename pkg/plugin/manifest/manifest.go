@@ -0,0 +1,221 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+)
+
+const (
+	vulcanizedbModule = "github.com/vulcanize/vulcanizedb"
+	// Marker precedes the JSON manifest embedded in a plugin, so that it can be found without linking the plugin
+	Marker = "vulcanizedb-plugin-manifest:"
+	// Terminator follows the JSON manifest embedded in a plugin
+	Terminator = "\x00"
+)
+
+var ErrNoManifest = errors.New("plugin has no manifest")
+
+// Manifest describes what a plugin was composed with. A plugin can only be linked by a vulcanizedb binary built with
+// the same Go version and dependencies.
+type Manifest struct {
+	VulcanizeDBVersion string            `json:"vulcanizedbVersion"`
+	GoVersion          string            `json:"goVersion"`
+	Dependencies       map[string]string `json:"dependencies"` // module path => version and hash
+	Transformers       []Transformer     `json:"transformers"` // ordered by name
+}
+
+type Transformer struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Repository    string `json:"repository"`
+	Version       string `json:"version,omitempty"`
+	MigrationRank uint64 `json:"migrationRank"`
+}
+
+// New describes a binary built with the given build info, which may be nil, running the given plugin config
+func New(gc config.Plugin, info *debug.BuildInfo) Manifest {
+	manifest := Manifest{
+		GoVersion:    runtime.Version(),
+		Dependencies: make(map[string]string),
+		Transformers: []Transformer{},
+	}
+	if info != nil {
+		if info.Main.Path == vulcanizedbModule {
+			manifest.VulcanizeDBVersion = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == vulcanizedbModule {
+				manifest.VulcanizeDBVersion = dep.Version
+			}
+			manifest.Dependencies[dep.Path] = describe(dep)
+		}
+	}
+	for name, transformer := range gc.Transformers {
+		manifest.Transformers = append(manifest.Transformers, Transformer{
+			Name:          name,
+			Type:          transformer.Type.String(),
+			Repository:    transformer.RepositoryPath,
+			Version:       transformer.RepositoryVersion,
+			MigrationRank: transformer.MigrationRank,
+		})
+	}
+	sort.Slice(manifest.Transformers, func(i, j int) bool {
+		return manifest.Transformers[i].Name < manifest.Transformers[j].Name
+	})
+	return manifest
+}
+
+// describe returns the version and hash of the module that is built, e.g. v1.2.0 h1:...
+func describe(module *debug.Module) string {
+	if module.Replace != nil {
+		return strings.TrimSpace(fmt.Sprintf("%s => %s %s %s", module.Version, module.Replace.Path,
+			module.Replace.Version, module.Replace.Sum))
+	}
+	return strings.TrimSpace(module.Version + " " + module.Sum)
+}
+
+// Encode returns the manifest as it is embedded in a plugin, between Marker and Terminator
+func (manifest Manifest) Encode() (string, error) {
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	return Marker + string(encoded) + Terminator, nil
+}
+
+// Read finds the manifest embedded in a plugin file without linking it
+func Read(pluginPath string) (Manifest, error) {
+	contents, err := ioutil.ReadFile(pluginPath)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return Find(contents)
+}
+
+// Find returns the first manifest embedded in the given bytes
+func Find(contents []byte) (Manifest, error) {
+	marker := []byte(Marker)
+	for start := bytes.Index(contents, marker); start >= 0; {
+		rest := contents[start+len(marker):]
+		end := bytes.Index(rest, []byte(Terminator))
+		if end < 0 {
+			break
+		}
+		var manifest Manifest
+		if json.Unmarshal(rest[:end], &manifest) == nil {
+			return manifest, nil
+		}
+		next := bytes.Index(rest, marker)
+		if next < 0 {
+			break
+		}
+		start += len(marker) + next
+	}
+	return Manifest{}, ErrNoManifest
+}
+
+// Mismatch is something a plugin was composed with that differs from what the running binary expects
+type Mismatch struct {
+	Name   string
+	Plugin string
+	Host   string
+	Fix    string
+}
+
+func (mismatch Mismatch) String() string {
+	return fmt.Sprintf("%s: plugin has %s, vulcanizedb has %s (%s)", mismatch.Name, orNone(mismatch.Plugin),
+		orNone(mismatch.Host), mismatch.Fix)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// Compare returns how the plugin's manifest differs from the running binary's. Transformers in the plugin but not in
+// the host's config are ignored, since transformers can be dropped from exporter.transformerNames while executing.
+func Compare(plugin, host Manifest) []Mismatch {
+	const recompose = "recompose the plugin with this vulcanizedb binary"
+	var mismatches []Mismatch
+	if plugin.VulcanizeDBVersion != host.VulcanizeDBVersion {
+		mismatches = append(mismatches, Mismatch{Name: "vulcanizedb version", Plugin: plugin.VulcanizeDBVersion,
+			Host: host.VulcanizeDBVersion, Fix: recompose})
+	}
+	if plugin.GoVersion != host.GoVersion {
+		mismatches = append(mismatches, Mismatch{Name: "go version", Plugin: plugin.GoVersion, Host: host.GoVersion,
+			Fix: recompose})
+	}
+
+	paths := make([]string, 0, len(host.Dependencies))
+	for path := range host.Dependencies {
+		paths = append(paths, path)
+	}
+	for path := range plugin.Dependencies {
+		if _, ok := host.Dependencies[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if plugin.Dependencies[path] != host.Dependencies[path] {
+			mismatches = append(mismatches, Mismatch{Name: "dependency " + path, Plugin: plugin.Dependencies[path],
+				Host: host.Dependencies[path], Fix: recompose})
+		}
+	}
+
+	pluginTransformers := make(map[string]Transformer)
+	for _, transformer := range plugin.Transformers {
+		pluginTransformers[transformer.Name] = transformer
+	}
+	for _, expected := range host.Transformers {
+		actual, ok := pluginTransformers[expected.Name]
+		name := "transformer " + expected.Name
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Name: name, Host: expected.Type,
+				Fix: "add it to the plugin by recomposing, or remove it from exporter.transformerNames"})
+			continue
+		}
+		const fix = "recompose the plugin with the current exporter config"
+		if actual.Type != expected.Type {
+			mismatches = append(mismatches, Mismatch{Name: name + " type", Plugin: actual.Type, Host: expected.Type,
+				Fix: fix})
+		}
+		if actual.Repository != expected.Repository || actual.Version != expected.Version {
+			mismatches = append(mismatches, Mismatch{Name: name + " repository",
+				Plugin: strings.TrimSpace(actual.Repository + " " + actual.Version),
+				Host:   strings.TrimSpace(expected.Repository + " " + expected.Version), Fix: fix})
+		}
+		if actual.MigrationRank != expected.MigrationRank {
+			mismatches = append(mismatches, Mismatch{Name: name + " migration rank",
+				Plugin: fmt.Sprint(actual.MigrationRank), Host: fmt.Sprint(expected.MigrationRank), Fix: fix})
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,190 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package manifest_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manifest"
+)
+
+var _ = Describe("Plugin manifest", func() {
+	var (
+		pluginConfig config.Plugin
+		buildInfo    *debug.BuildInfo
+	)
+
+	BeforeEach(func() {
+		pluginConfig = config.Plugin{
+			Transformers: map[string]config.Transformer{
+				"transformer2": {Type: config.EthStorage, RepositoryPath: "github.com/account/repo", RepositoryVersion: "v1.2.3", MigrationRank: 1},
+				"transformer1": {Type: config.EthEvent, RepositoryPath: "github.com/account/repo", RepositoryVersion: "v1.2.3", MigrationRank: 0},
+			},
+		}
+		buildInfo = &debug.BuildInfo{
+			Main: debug.Module{Path: "github.com/vulcanize/vulcanizedb", Version: "v0.0.10"},
+			Deps: []*debug.Module{
+				{Path: "github.com/sirupsen/logrus", Version: "v1.2.0", Sum: "h1:logrus"},
+				{Path: "github.com/ethereum/go-ethereum", Version: "v1.9.5",
+					Replace: &debug.Module{Path: "github.com/vulcanize/go-ethereum", Version: "v1.9.5-statediff", Sum: "h1:geth"}},
+			},
+		}
+	})
+
+	Describe("New", func() {
+		It("describes the binary's versions and dependencies and the configured transformers", func() {
+			result := manifest.New(pluginConfig, buildInfo)
+
+			Expect(result).To(Equal(manifest.Manifest{
+				VulcanizeDBVersion: "v0.0.10",
+				GoVersion:          runtime.Version(),
+				Dependencies: map[string]string{
+					"github.com/sirupsen/logrus":      "v1.2.0 h1:logrus",
+					"github.com/ethereum/go-ethereum": "v1.9.5 => github.com/vulcanize/go-ethereum v1.9.5-statediff h1:geth",
+				},
+				Transformers: []manifest.Transformer{
+					{Name: "transformer1", Type: "eth_event", Repository: "github.com/account/repo", Version: "v1.2.3", MigrationRank: 0},
+					{Name: "transformer2", Type: "eth_storage", Repository: "github.com/account/repo", Version: "v1.2.3", MigrationRank: 1},
+				},
+			}))
+		})
+
+		It("takes the vulcanizedb version from the dependencies of a binary built from another module", func() {
+			buildInfo.Main = debug.Module{Path: "github.com/account/binary"}
+			buildInfo.Deps = append(buildInfo.Deps, &debug.Module{Path: "github.com/vulcanize/vulcanizedb", Version: "v0.0.11"})
+
+			result := manifest.New(pluginConfig, buildInfo)
+
+			Expect(result.VulcanizeDBVersion).To(Equal("v0.0.11"))
+		})
+
+		It("describes a binary without build info", func() {
+			result := manifest.New(pluginConfig, nil)
+
+			Expect(result.VulcanizeDBVersion).To(BeEmpty())
+			Expect(result.Dependencies).To(BeEmpty())
+			Expect(result.Transformers).To(HaveLen(2))
+		})
+	})
+
+	Describe("Read", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "manifest")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(Succeed())
+		})
+
+		It("finds a manifest embedded in a plugin file", func() {
+			expected := manifest.New(pluginConfig, buildInfo)
+			encoded, encodeErr := expected.Encode()
+			Expect(encodeErr).NotTo(HaveOccurred())
+			pluginPath := filepath.Join(tempDir, "plugin.so")
+			contents := "\x7fELF" + manifest.Marker + "\x00 other data " + encoded + " more data"
+			Expect(ioutil.WriteFile(pluginPath, []byte(contents), 0644)).To(Succeed())
+
+			result, err := manifest.Read(pluginPath)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		})
+
+		It("returns ErrNoManifest if the plugin has no manifest", func() {
+			pluginPath := filepath.Join(tempDir, "plugin.so")
+			Expect(ioutil.WriteFile(pluginPath, []byte("\x7fELF no manifest"), 0644)).To(Succeed())
+
+			_, err := manifest.Read(pluginPath)
+
+			Expect(err).To(MatchError(manifest.ErrNoManifest))
+		})
+
+		It("returns an error if the plugin can't be read", func() {
+			_, err := manifest.Read(filepath.Join(tempDir, "missing.so"))
+
+			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(MatchError(manifest.ErrNoManifest))
+		})
+	})
+
+	Describe("Compare", func() {
+		var pluginManifest, hostManifest manifest.Manifest
+
+		BeforeEach(func() {
+			pluginManifest = manifest.New(pluginConfig, buildInfo)
+			hostManifest = manifest.New(pluginConfig, buildInfo)
+		})
+
+		It("returns nothing when the plugin matches", func() {
+			Expect(manifest.Compare(pluginManifest, hostManifest)).To(BeEmpty())
+		})
+
+		It("returns differences in versions and dependencies", func() {
+			hostManifest.VulcanizeDBVersion = "v0.0.11"
+			hostManifest.GoVersion = "go1.99"
+			hostManifest.Dependencies = map[string]string{
+				"github.com/sirupsen/logrus":      "v1.4.0 h1:logrus2",
+				"github.com/ethereum/go-ethereum": pluginManifest.Dependencies["github.com/ethereum/go-ethereum"],
+				"github.com/pkg/errors":           "v0.8.1 h1:errors",
+			}
+
+			mismatches := manifest.Compare(pluginManifest, hostManifest)
+
+			Expect(mismatches).To(HaveLen(4))
+			Expect(mismatches[0]).To(Equal(manifest.Mismatch{Name: "vulcanizedb version", Plugin: "v0.0.10",
+				Host: "v0.0.11", Fix: "recompose the plugin with this vulcanizedb binary"}))
+			Expect(mismatches[1].Name).To(Equal("go version"))
+			Expect(mismatches[2].Name).To(Equal("dependency github.com/pkg/errors"))
+			Expect(mismatches[2].String()).To(Equal("dependency github.com/pkg/errors: plugin has none, " +
+				"vulcanizedb has v0.8.1 h1:errors (recompose the plugin with this vulcanizedb binary)"))
+			Expect(mismatches[3]).To(Equal(manifest.Mismatch{Name: "dependency github.com/sirupsen/logrus",
+				Plugin: "v1.2.0 h1:logrus", Host: "v1.4.0 h1:logrus2", Fix: "recompose the plugin with this vulcanizedb binary"}))
+		})
+
+		It("returns configured transformers that are missing or differ from the plugin's", func() {
+			hostConfig := config.Plugin{Transformers: map[string]config.Transformer{
+				"transformer1": {Type: config.EthStorage, RepositoryPath: "github.com/account/repo", RepositoryVersion: "v1.2.4", MigrationRank: 2},
+				"transformer3": {Type: config.EthEvent, RepositoryPath: "github.com/account/repo", RepositoryVersion: "v1.2.3"},
+			}}
+			hostManifest = manifest.New(hostConfig, buildInfo)
+
+			mismatches := manifest.Compare(pluginManifest, hostManifest)
+
+			Expect(mismatches).To(ConsistOf(
+				manifest.Mismatch{Name: "transformer transformer1 type", Plugin: "eth_event", Host: "eth_storage",
+					Fix: "recompose the plugin with the current exporter config"},
+				manifest.Mismatch{Name: "transformer transformer1 repository", Plugin: "github.com/account/repo v1.2.3",
+					Host: "github.com/account/repo v1.2.4", Fix: "recompose the plugin with the current exporter config"},
+				manifest.Mismatch{Name: "transformer transformer1 migration rank", Plugin: "0", Host: "2",
+					Fix: "recompose the plugin with the current exporter config"},
+				manifest.Mismatch{Name: "transformer transformer3", Host: "eth_event",
+					Fix: "add it to the plugin by recomposing, or remove it from exporter.transformerNames"},
+			))
+		})
+	})
+})
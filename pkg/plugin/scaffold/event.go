@@ -0,0 +1,431 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package scaffold
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+
+	"github.com/vulcanize/vulcanizedb/libraries/shared/factories/event"
+)
+
+// eventGenerator generates a package with a converter unpacking logs of an event into an entity with a field per input,
+// and a repository persisting a row per log with a column per input
+type eventGenerator struct {
+	event  abi.Event
+	fields []eventField
+}
+
+type eventField struct {
+	argument abi.Argument
+	field    string // name of the entity field unpacked into
+	column   column
+	goType   Code
+	toValue  func(value *Statement) *Statement // converts the field to a value the postgres driver accepts
+	json     bool                              // the field is persisted as JSON
+}
+
+func newEventGenerator(abiEvent abi.Event) (eventGenerator, error) {
+	generator := eventGenerator{event: abiEvent}
+	columns := make(map[string]string)
+	for i := range abiEvent.Inputs {
+		// Unnamed inputs can't be unpacked into a struct, so they are named by position in the scaffolded ABI
+		if abiEvent.Inputs[i].Name == "" {
+			abiEvent.Inputs[i].Name = fmt.Sprintf("arg%d", i)
+		}
+		input := abiEvent.Inputs[i]
+		field, fieldErr := newEventField(input)
+		if fieldErr != nil {
+			return eventGenerator{}, fmt.Errorf("can't scaffold input %s of event %s: %s", input.Name, abiEvent.Name, fieldErr.Error())
+		}
+		if other, ok := columns[field.column.name]; ok {
+			return eventGenerator{}, fmt.Errorf("inputs %s and %s of event %s would both be stored in column %s",
+				other, input.Name, abiEvent.Name, field.column.name)
+		}
+		columns[field.column.name] = input.Name
+		generator.fields = append(generator.fields, field)
+	}
+	generator.event = abiEvent
+	return generator, nil
+}
+
+func newEventField(input abi.Argument) (eventField, error) {
+	field := eventField{
+		argument: input,
+		field:    abi.ToCamelCase(input.Name),
+		column:   column{name: columnName(input.Name)},
+	}
+	// Indexed dynamic values are only available as the hash stored in their topic
+	if input.Indexed && event.HashedWhenIndexed(input.Type) {
+		field.goType = Qual(commonPackage, "Hash")
+		field.column.pgType = "CHARACTER VARYING(66)"
+		field.toValue = hex
+		return field, nil
+	}
+	goType, typeErr := goTypeCode(input.Type.Type)
+	if typeErr != nil {
+		return eventField{}, typeErr
+	}
+	field.goType = goType
+	switch input.Type.T {
+	case abi.AddressTy, abi.HashTy:
+		field.column.pgType = "CHARACTER VARYING(66)"
+		field.toValue = hex
+	case abi.IntTy, abi.UintTy:
+		field.column.pgType = "NUMERIC"
+		switch input.Type.Type.Kind() {
+		case reflect.Ptr:
+			field.toValue = func(value *Statement) *Statement { return value.Dot("String").Call() }
+		case reflect.Uint64:
+			field.toValue = func(value *Statement) *Statement { return Qual("strconv", "FormatUint").Call(value, Lit(10)) }
+		default:
+			field.toValue = func(value *Statement) *Statement { return Int64().Call(value) }
+		}
+	case abi.BoolTy:
+		field.column.pgType = "BOOLEAN"
+		field.toValue = same
+	case abi.StringTy:
+		field.column.pgType = "TEXT"
+		field.toValue = same
+	case abi.BytesTy:
+		field.column.pgType = "BYTEA"
+		field.toValue = same
+	case abi.FixedBytesTy, abi.FunctionTy:
+		field.column.pgType = "BYTEA"
+		field.toValue = func(value *Statement) *Statement { return value.Index(Op(":")) }
+	case abi.SliceTy, abi.ArrayTy:
+		field.column.pgType = "JSONB"
+		field.json = true
+	default:
+		return eventField{}, fmt.Errorf("unsupported type %s", input.Type.String())
+	}
+	return field, nil
+}
+
+func hex(value *Statement) *Statement {
+	return value.Dot("Hex").Call()
+}
+
+func same(value *Statement) *Statement {
+	return value
+}
+
+// goTypeCode is the code of the type geth unpacks an ABI type into
+func goTypeCode(t reflect.Type) (Code, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := goTypeCode(t.Elem())
+		return Op("*").Add(elem), err
+	case reflect.Slice:
+		elem, err := goTypeCode(t.Elem())
+		return Index().Add(elem), err
+	case reflect.Array:
+		if t.PkgPath() != "" {
+			return Qual(t.PkgPath(), t.Name()), nil
+		}
+		elem, err := goTypeCode(t.Elem())
+		return Index(Lit(t.Len())).Add(elem), err
+	case reflect.Struct:
+		if t.PkgPath() == "" {
+			return nil, fmt.Errorf("tuples are not supported")
+		}
+		return Qual(t.PkgPath(), t.Name()), nil
+	case reflect.Bool, reflect.String, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Id(t.Kind().String()), nil
+	default:
+		return nil, fmt.Errorf("unsupported go type %s", t.String())
+	}
+}
+
+func (generator eventGenerator) table() table {
+	t := table{
+		name: snakeCase(generator.event.Name),
+		columns: []column{
+			{name: "header_id", pgType: "INTEGER NOT NULL REFERENCES public.headers (id) ON DELETE CASCADE"},
+			{name: "log_id", pgType: "INTEGER NOT NULL REFERENCES public.header_sync_logs (id) ON DELETE CASCADE"},
+		},
+		unique: []string{"header_id", "log_id"},
+	}
+	for _, field := range generator.fields {
+		t.columns = append(t.columns, field.column)
+	}
+	return t
+}
+
+func (generator eventGenerator) tables(schema string) []table {
+	return []table{generator.table()}
+}
+
+func (generator eventGenerator) files(config Config, spec transformerSpec) (map[string]generatedFile, error) {
+	contractAbi, abiErr := minimalAbi(generator.event)
+	if abiErr != nil {
+		return nil, abiErr
+	}
+	return map[string]generatedFile{
+		"config.go":                  generator.configFile(config, spec, contractAbi),
+		"converter.go":               generator.converterFile(config, spec),
+		"repository.go":              generator.repositoryFile(spec),
+		"initializer.go":             generator.initializerFile(spec),
+		spec.name + "_suite_test.go": suiteFile(spec),
+		"converter_test.go":          generator.converterTestFile(spec),
+	}, nil
+}
+
+func newFile(spec transformerSpec) *File {
+	f := NewFile(spec.name)
+	f.HeaderComment(generatedComment)
+	return f
+}
+
+func (generator eventGenerator) configFile(config Config, spec transformerSpec, contractAbi string) *File {
+	f := newFile(spec)
+	f.Comment("ContractAbi is the ABI of the " + generator.event.Name + " event")
+	f.Const().Id("ContractAbi").Op("=").Lit(contractAbi)
+	f.Line()
+	addresses := make([]Code, 0, len(config.Addresses))
+	for _, address := range config.Addresses {
+		addresses = append(addresses, Lit(address))
+	}
+	f.Var().Id("Config").Op("=").Qual(transformerPackage, "EventTransformerConfig").Values(fields(
+		Id("TransformerName"), Lit(spec.name),
+		Id("ContractAddresses"), Index().String().Values(addresses...),
+		Id("ContractAbi"), Id("ContractAbi"),
+		Id("Topic"), Lit(generator.event.Id().Hex()),
+		Id("StartingBlockNumber"), Lit(int(config.StartingBlock)),
+		Id("EndingBlockNumber"), Lit(-1),
+	)...)
+	return f
+}
+
+func (generator eventGenerator) converterFile(config Config, spec transformerSpec) *File {
+	f := newFile(spec)
+	t := generator.table()
+	f.Const().Defs(
+		Id("SchemaName").Qual(eventPackage, "SchemaName").Op("=").Lit(schemaName(config.Name)),
+		Id("TableName").Qual(eventPackage, "TableName").Op("=").Lit(t.name),
+	)
+	f.Line()
+
+	entityFields := make([]Code, 0, len(generator.fields))
+	for _, field := range generator.fields {
+		entityFields = append(entityFields, Id(field.field).Add(field.goType))
+	}
+	f.Comment("Entity is a " + generator.event.Name + " log unpacked into a field per event input")
+	f.Type().Id("Entity").Struct(entityFields...)
+	f.Line()
+
+	f.Comment("Converter converts " + generator.event.Name + " logs into models with a column per event input")
+	f.Type().Id("Converter").Struct()
+	f.Line()
+
+	orderedColumns := []Code{Qual(eventPackage, "HeaderFK"), Qual(eventPackage, "LogFK")}
+	columnValues := []Code{
+		Qual(eventPackage, "HeaderFK"), Id("log").Dot("HeaderID"),
+		Qual(eventPackage, "LogFK"), Id("log").Dot("ID"),
+	}
+	var marshalling []Code
+	for _, field := range generator.fields {
+		orderedColumns = append(orderedColumns, Lit(field.column.name))
+		value := Id("entity").Dot(field.field)
+		if field.json {
+			encoded := Id(lowerFirst(field.field) + "JSON")
+			marshalErr := Id(lowerFirst(field.field) + "Err")
+			marshalling = append(marshalling,
+				List(encoded, marshalErr).Op(":=").Qual("encoding/json", "Marshal").Call(value),
+				If(marshalErr.Clone().Op("!=").Nil()).Block(Return(Nil(), marshalErr.Clone())),
+			)
+			columnValues = append(columnValues, Lit(field.column.name), String().Call(encoded.Clone()))
+			continue
+		}
+		columnValues = append(columnValues, Lit(field.column.name), field.toValue(value))
+	}
+
+	loop := []Code{
+		If(Len(Id("log").Dot("Log").Dot("Topics")).Op("<").Lit(1).Op("||").Id("log").Dot("Log").Dot("Topics").Index(Lit(0)).Op("!=").Id("parsedAbi").Dot("Events").Index(Lit(generator.event.Name)).Dot("Id").Call()).Block(
+			Return(Nil(), Qual("fmt", "Errorf").Call(Lit("log %d is not a "+generator.event.Name+" event"), Id("log").Dot("ID"))),
+		),
+		Var().Id("entity").Id("Entity"),
+		Id("unpackErr").Op(":=").Id("contract").Dot("UnpackLog").Call(Op("&").Id("entity"), Lit(generator.event.Name), Id("log").Dot("Log")),
+		If(Id("unpackErr").Op("!=").Nil()).Block(
+			Return(Nil(), Qual("fmt", "Errorf").Call(Lit("error unpacking "+generator.event.Name+" log %d: %s"), Id("log").Dot("ID"), Id("unpackErr").Dot("Error").Call())),
+		),
+	}
+	loop = append(loop, marshalling...)
+	loop = append(loop, Id("models").Op("=").Append(Id("models"), Qual(eventPackage, "InsertionModel").Values(fields(
+		Id("SchemaName"), Id("SchemaName"),
+		Id("TableName"), Id("TableName"),
+		Id("OrderedColumns"), Index().Qual(eventPackage, "ColumnName").Values(orderedColumns...),
+		Id("ColumnValues"), Qual(eventPackage, "ColumnValues").Values(fields(columnValues...)...),
+	)...)))
+
+	f.Func().Params(Id("converter").Id("Converter")).Id("ToModels").Params(
+		Id("contractAbi").String(), Id("logs").Index().Qual(corePackage, "HeaderSyncLog"),
+	).Params(Index().Qual(eventPackage, "InsertionModel"), Error()).Block(
+		List(Id("parsedAbi"), Id("parseErr")).Op(":=").Qual(ethPackage, "ParseAbi").Call(Id("contractAbi")),
+		If(Id("parseErr").Op("!=").Nil()).Block(Return(Nil(), Id("parseErr"))),
+		Id("contract").Op(":=").Qual(bindPackage, "NewBoundContract").Call(Qual(commonPackage, "Address").Values(), Id("parsedAbi"), Nil(), Nil(), Nil()),
+		Id("models").Op(":=").Make(Index().Qual(eventPackage, "InsertionModel"), Lit(0), Len(Id("logs"))),
+		For(List(Id("_"), Id("log")).Op(":=").Range().Id("logs")).Block(loop...),
+		Return(Id("models"), Nil()),
+	)
+	f.Line()
+	f.Func().Params(Id("converter").Id("Converter")).Id("SetDB").Params(Id("db").Op("*").Qual(postgresPackage, "DB")).Block()
+	return f
+}
+
+func (generator eventGenerator) repositoryFile(spec transformerSpec) *File {
+	f := newFile(spec)
+	f.Comment("Repository persists " + generator.event.Name + " models")
+	f.Type().Id("Repository").Struct(Id("db").Op("*").Qual(postgresPackage, "DB"))
+	f.Line()
+	f.Func().Params(Id("repository").Op("*").Id("Repository")).Id("Create").Params(
+		Id("models").Index().Qual(eventPackage, "InsertionModel"),
+	).Error().Block(
+		Return(Qual(eventPackage, "Create").Call(Id("models"), Id("repository").Dot("db"))),
+	)
+	f.Line()
+	f.Func().Params(Id("repository").Op("*").Id("Repository")).Id("SetDB").Params(Id("db").Op("*").Qual(postgresPackage, "DB")).Block(
+		Id("repository").Dot("db").Op("=").Id("db"),
+	)
+	return f
+}
+
+func (generator eventGenerator) initializerFile(spec transformerSpec) *File {
+	f := newFile(spec)
+	f.Var().Id("EventTransformerInitializer").Qual(transformerPackage, "EventTransformerInitializer").Op("=").Qual(eventPackage, "Transformer").Values(fields(
+		Id("Config"), Id("Config"),
+		Id("Converter"), Id("Converter").Values(),
+		Id("Repository"), Op("&").Id("Repository").Values(),
+	)...).Dot("NewTransformer")
+	return f
+}
+
+func suiteFile(spec transformerSpec) *File {
+	f := NewFilePathName("", spec.name+"_test")
+	f.HeaderComment(generatedComment)
+	f.ImportName(ginkgo, "ginkgo")
+	f.ImportName(gomega, "gomega")
+	f.Func().Id("Test"+abi.ToCamelCase(spec.name)).Params(Id("t").Op("*").Qual("testing", "T")).Block(
+		Qual(gomega, "RegisterFailHandler").Call(Qual(ginkgo, "Fail")),
+		Qual(ginkgo, "RunSpecs").Call(Id("t"), Lit(abi.ToCamelCase(spec.name)+" Suite")),
+	)
+	f.Line()
+	f.Var().Id("_").Op("=").Qual(ginkgo, "BeforeSuite").Call(Func().Params().Block(
+		Qual("github.com/sirupsen/logrus", "SetOutput").Call(Qual("io/ioutil", "Discard")),
+	))
+	return f
+}
+
+// zeroDataLength is the length of the data of a log whose non-indexed inputs are all zero values
+func (generator eventGenerator) zeroDataLength() int {
+	length := 0
+	for _, input := range generator.event.Inputs.NonIndexed() {
+		length += staticSize(input.Type)
+	}
+	return length
+}
+
+// Static values are encoded in place, dynamic values as an offset to their length, which in zero data is also zero
+func staticSize(t abi.Type) int {
+	if t.T == abi.ArrayTy && t.Elem.T != abi.SliceTy && t.Elem.T != abi.StringTy && t.Elem.T != abi.BytesTy {
+		return t.Size * staticSize(*t.Elem)
+	}
+	return 32
+}
+
+func (generator eventGenerator) converterTestFile(spec transformerSpec) *File {
+	f := NewFilePathName("", spec.name+"_test")
+	f.HeaderComment(generatedComment)
+	pkg := spec.importPath
+	f.ImportName(pkg, spec.name)
+	f.ImportName(ginkgo, "ginkgo")
+	f.ImportName(gomega, "gomega")
+	f.ImportName(testDataPackage, "test_data")
+	expect := func(actual Code) *Statement { return Qual(gomega, "Expect").Call(actual) }
+	matcher := func(name string, args ...Code) *Statement { return Qual(gomega, name).Call(args...) }
+
+	topics := []Code{Qual(commonPackage, "HexToHash").Call(Qual(pkg, "Config").Dot("Topic"))}
+	for _, input := range generator.event.Inputs {
+		if input.Indexed {
+			topics = append(topics, Qual(testDataPackage, "FakeHash").Call())
+		}
+	}
+	columns := []Code{Qual(eventPackage, "HeaderFK"), Qual(eventPackage, "LogFK")}
+	for _, field := range generator.fields {
+		columns = append(columns, Lit(field.column.name))
+	}
+
+	f.Var().Id("_").Op("=").Qual(ginkgo, "Describe").Call(Lit(generator.event.Name+" converter"), Func().Params().Block(
+		Var().Defs(
+			Id("converter").Qual(pkg, "Converter"),
+			Id("log").Qual(corePackage, "HeaderSyncLog"),
+		),
+		Line(),
+		Qual(ginkgo, "BeforeEach").Call(Func().Params().Block(
+			Id("converter").Op("=").Qual(pkg, "Converter").Values(),
+			Id("log").Op("=").Qual(corePackage, "HeaderSyncLog").Values(fields(
+				Id("ID"), Qual("math/rand", "Int63").Call(),
+				Id("HeaderID"), Qual("math/rand", "Int63").Call(),
+				Id("Log"), Qual(testDataPackage, "GenericTestLog").Call(),
+			)...),
+			Comment("A log of the event with zero values, since a random topic or data wouldn't unpack"),
+			Id("log").Dot("Log").Dot("Topics").Op("=").Index().Qual(commonPackage, "Hash").Values(topics...),
+			Id("log").Dot("Log").Dot("Data").Op("=").Make(Index().Byte(), Lit(generator.zeroDataLength())),
+		)),
+		Line(),
+		Qual(ginkgo, "It").Call(Lit("converts a log to a model"), Func().Params().Block(
+			List(Id("models"), Id("err")).Op(":=").Id("converter").Dot("ToModels").Call(Qual(pkg, "ContractAbi"), Index().Qual(corePackage, "HeaderSyncLog").Values(Id("log"))),
+			Line(),
+			expect(Id("err")).Dot("NotTo").Call(matcher("HaveOccurred")),
+			expect(Id("models")).Dot("To").Call(matcher("HaveLen", Lit(1))),
+			expect(Id("models").Index(Lit(0)).Dot("SchemaName")).Dot("To").Call(matcher("Equal", Qual(pkg, "SchemaName"))),
+			expect(Id("models").Index(Lit(0)).Dot("TableName")).Dot("To").Call(matcher("Equal", Qual(pkg, "TableName"))),
+			expect(Id("models").Index(Lit(0)).Dot("OrderedColumns")).Dot("To").Call(matcher("Equal", Index().Qual(eventPackage, "ColumnName").Values(columns...))),
+			expect(Id("models").Index(Lit(0)).Dot("ColumnValues")).Dot("To").Call(matcher("HaveLen", Lit(len(columns)))),
+			expect(Id("models").Index(Lit(0)).Dot("ColumnValues").Index(Qual(eventPackage, "HeaderFK"))).Dot("To").Call(matcher("Equal", Id("log").Dot("HeaderID"))),
+			expect(Id("models").Index(Lit(0)).Dot("ColumnValues").Index(Qual(eventPackage, "LogFK"))).Dot("To").Call(matcher("Equal", Id("log").Dot("ID"))),
+		)),
+		Line(),
+		Qual(ginkgo, "It").Call(Lit("returns an error if a log isn't a "+generator.event.Name+" event"), Func().Params().Block(
+			Id("log").Dot("Log").Dot("Topics").Index(Lit(0)).Op("=").Qual(testDataPackage, "FakeHash").Call(),
+			Line(),
+			List(Id("_"), Id("err")).Op(":=").Id("converter").Dot("ToModels").Call(Qual(pkg, "ContractAbi"), Index().Qual(corePackage, "HeaderSyncLog").Values(Id("log"))),
+			Line(),
+			expect(Id("err")).Dot("To").Call(matcher("HaveOccurred")),
+		)),
+		Line(),
+		Qual(ginkgo, "It").Call(Lit("returns an error if the ABI can't be parsed"), Func().Params().Block(
+			List(Id("_"), Id("err")).Op(":=").Id("converter").Dot("ToModels").Call(Lit("invalid abi"), Index().Qual(corePackage, "HeaderSyncLog").Values(Id("log"))),
+			Line(),
+			expect(Id("err")).Dot("To").Call(matcher("HaveOccurred")),
+		)),
+	))
+	return f
+}
+
+func lowerFirst(name string) string {
+	for i, r := range name {
+		return string(unicode.ToLower(r)) + name[i+utf8.RuneLen(r):]
+	}
+	return name
+}
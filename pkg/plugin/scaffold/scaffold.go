@@ -0,0 +1,399 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package scaffold
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/pressly/goose"
+
+	"github.com/vulcanize/vulcanizedb/pkg/eth"
+)
+
+const (
+	vulcanizedb        = "github.com/vulcanize/vulcanizedb"
+	transformerPackage = vulcanizedb + "/libraries/shared/transformer"
+	eventPackage       = vulcanizedb + "/libraries/shared/factories/event"
+	storagePackage     = vulcanizedb + "/libraries/shared/factories/storage"
+	utilsPackage       = vulcanizedb + "/libraries/shared/storage/utils"
+	testDataPackage    = vulcanizedb + "/libraries/shared/test_data"
+	mocksPackage       = vulcanizedb + "/libraries/shared/mocks"
+	corePackage        = vulcanizedb + "/pkg/core"
+	postgresPackage    = vulcanizedb + "/pkg/datastore/postgres"
+	ethPackage         = vulcanizedb + "/pkg/eth"
+	commonPackage      = "github.com/ethereum/go-ethereum/common"
+	bindPackage        = "github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ginkgo             = "github.com/onsi/ginkgo"
+	gomega             = "github.com/onsi/gomega"
+	generatedComment   = "Scaffolded by vulcanizedb scaffold, edit as needed."
+)
+
+// Config describes the transformers to scaffold for a contract
+type Config struct {
+	Name          string // name of the contract, used as the schema of its tables
+	Abi           string
+	Events        []string
+	Variables     []Variable
+	Addresses     []string
+	StartingBlock int64
+	Repository    string // import path of the transformer repository, e.g. github.com/account/repo
+	RepositoryDir string // where the repository is checked out
+	OutputPath    string // directory of the transformer packages, relative to the repository
+	MigrationPath string // directory of the repository's migrations, relative to the repository
+}
+
+// Variable is a public state variable; its slot is the order it was given in unless set
+type Variable struct {
+	Name string
+	Slot *big.Int
+}
+
+// ParseVariable parses a variable given as name or name:slot
+func ParseVariable(str string) (Variable, error) {
+	parts := strings.SplitN(str, ":", 2)
+	variable := Variable{Name: parts[0]}
+	if len(parts) == 2 {
+		slot, ok := new(big.Int).SetString(parts[1], 0)
+		if !ok || slot.Sign() < 0 {
+			return Variable{}, fmt.Errorf("invalid slot %s for variable %s", parts[1], parts[0])
+		}
+		variable.Slot = slot
+	}
+	return variable, nil
+}
+
+// Result is what was scaffolded
+type Result struct {
+	Files []string // paths of the written files
+	Toml  string   // exporter config for compose
+}
+
+// transformerSpec is a transformer package to generate
+type transformerSpec struct {
+	name       string // transformer and package name
+	dir        string
+	importPath string
+	kind       string // eth_event or eth_storage
+	pkg        packageGenerator
+}
+
+type packageGenerator interface {
+	tables(schema string) []table
+	files(config Config, spec transformerSpec) (map[string]generatedFile, error)
+}
+
+type generatedFile interface {
+	Save(path string) error
+}
+
+// table is a table the scaffolded migration creates
+type table struct {
+	name    string
+	columns []column // including those linking rows to the data they were transformed from
+	unique  []string // columns identifying the data a row was transformed from, which are each indexed
+}
+
+type column struct {
+	name   string
+	pgType string
+}
+
+// Generate writes a package for each event and, if variables are given, a storage transformer package,
+// along with a migration for their tables. Nothing is written if any of them can't be scaffolded.
+func Generate(config Config) (Result, error) {
+	specs, err := transformerSpecs(config)
+	if err != nil {
+		return Result{}, err
+	}
+	migrationDir := filepath.Join(config.RepositoryDir, config.MigrationPath)
+	migrationFile, migrationErr := nextMigration(migrationDir, config.Name)
+	if migrationErr != nil {
+		return Result{}, migrationErr
+	}
+	files := make(map[string]generatedFile)
+	var tables []table
+	tableNames := make(map[string]string)
+	for _, spec := range specs {
+		if _, statErr := os.Stat(spec.dir); statErr == nil {
+			return Result{}, fmt.Errorf("%s already exists", spec.dir)
+		}
+		for _, t := range spec.pkg.tables(schemaName(config.Name)) {
+			if other, ok := tableNames[t.name]; ok {
+				return Result{}, fmt.Errorf("transformers %s and %s both need a table named %s", other, spec.name, t.name)
+			}
+			tableNames[t.name] = spec.name
+			tables = append(tables, t)
+		}
+		packageFiles, filesErr := spec.pkg.files(config, spec)
+		if filesErr != nil {
+			return Result{}, filesErr
+		}
+		for name, file := range packageFiles {
+			files[filepath.Join(spec.dir, name)] = file
+		}
+	}
+
+	var result Result
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		mkdirErr := os.MkdirAll(filepath.Dir(path), 0755)
+		if mkdirErr != nil {
+			return Result{}, mkdirErr
+		}
+		saveErr := files[path].Save(path)
+		if saveErr != nil {
+			return Result{}, fmt.Errorf("failed to save generated file %s: %s", path, saveErr.Error())
+		}
+		result.Files = append(result.Files, path)
+	}
+	mkdirErr := os.MkdirAll(migrationDir, 0755)
+	if mkdirErr != nil {
+		return Result{}, mkdirErr
+	}
+	writeErr := ioutil.WriteFile(migrationFile, []byte(migration(schemaName(config.Name), tables)), 0644)
+	if writeErr != nil {
+		return Result{}, writeErr
+	}
+	result.Files = append(result.Files, migrationFile)
+	result.Toml = tomlSnippet(config, specs)
+	return result, nil
+}
+
+func transformerSpecs(config Config) ([]transformerSpec, error) {
+	if len(config.Events) < 1 && len(config.Variables) < 1 {
+		return nil, errors.New("scaffold needs events or variables")
+	}
+	if schemaName(config.Name) == "" {
+		return nil, errors.New("scaffold needs the name of the contract")
+	}
+	if config.Repository == "" {
+		return nil, errors.New("scaffold needs the import path of the transformer repository")
+	}
+	parsedAbi, parseErr := eth.ParseAbi(config.Abi)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	var specs []transformerSpec
+	for _, eventName := range config.Events {
+		abiEvent, ok := parsedAbi.Events[eventName]
+		if !ok {
+			return nil, fmt.Errorf("event %s not found in abi", eventName)
+		}
+		generator, eventErr := newEventGenerator(abiEvent)
+		if eventErr != nil {
+			return nil, eventErr
+		}
+		name := snakeCase(eventName)
+		specs = append(specs, transformerSpec{
+			name:       name,
+			dir:        filepath.Join(config.RepositoryDir, config.OutputPath, name),
+			importPath: packagePath(config, name),
+			kind:       "eth_event",
+			pkg:        generator,
+		})
+	}
+	if len(config.Variables) > 0 {
+		generator, storageErr := newStorageGenerator(parsedAbi, config.Variables)
+		if storageErr != nil {
+			return nil, storageErr
+		}
+		name := schemaName(config.Name) + "_storage"
+		specs = append(specs, transformerSpec{
+			name:       name,
+			dir:        filepath.Join(config.RepositoryDir, config.OutputPath, name),
+			importPath: packagePath(config, name),
+			kind:       "eth_storage",
+			pkg:        generator,
+		})
+	}
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		if names[spec.name] {
+			return nil, fmt.Errorf("more than one transformer would be named %s", spec.name)
+		}
+		names[spec.name] = true
+	}
+	return specs, nil
+}
+
+func packagePath(config Config, name string) string {
+	return path.Join(config.Repository, filepath.ToSlash(config.OutputPath), name)
+}
+
+// minimalAbi is the JSON of an ABI with only the given event, so that scaffolded configs don't embed the whole ABI
+func minimalAbi(abiEvent abi.Event) (string, error) {
+	type abiInput struct {
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		Indexed bool   `json:"indexed"`
+	}
+	inputs := make([]abiInput, 0, len(abiEvent.Inputs))
+	for _, input := range abiEvent.Inputs {
+		inputs = append(inputs, abiInput{Name: input.Name, Type: input.Type.String(), Indexed: input.Indexed})
+	}
+	encoded, err := json.Marshal([]interface{}{struct {
+		Type      string     `json:"type"`
+		Name      string     `json:"name"`
+		Inputs    []abiInput `json:"inputs"`
+		Anonymous bool       `json:"anonymous"`
+	}{"event", abiEvent.Name, inputs, abiEvent.Anonymous}})
+	return string(encoded), err
+}
+
+// nextMigration is the path of a migration numbered after the latest in dir
+func nextMigration(dir, name string) (string, error) {
+	var latest int64
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		version, versionErr := goose.NumericComponent(file.Name())
+		if versionErr == nil && version > latest {
+			latest = version
+		}
+	}
+	return filepath.Join(dir, fmt.Sprintf("%05d_create_%s_tables.sql", latest+1, schemaName(name))), nil
+}
+
+// migration creates the schema, if it doesn't exist, and the scaffolded tables
+func migration(schema string, tables []table) string {
+	var up, down strings.Builder
+	fmt.Fprintf(&up, "-- +goose Up\nCREATE SCHEMA IF NOT EXISTS %s;\n", schema)
+	for _, t := range tables {
+		tableID := schema + "." + t.name
+		fmt.Fprintf(&up, "\nCREATE TABLE %s\n(\n", tableID)
+		columns := append([]column{{name: "id", pgType: "SERIAL PRIMARY KEY"}}, t.columns...)
+		for _, c := range columns {
+			fmt.Fprintf(&up, "    %-*s %s,\n", t.width(), c.name, c.pgType)
+		}
+		fmt.Fprintf(&up, "    UNIQUE (%s)\n);\n\n", strings.Join(t.unique, ", "))
+		for _, indexColumn := range t.unique {
+			fmt.Fprintf(&up, "CREATE INDEX %s_%s_index ON %s (%s);\n", t.name, strings.TrimSuffix(indexColumn, "_id"), tableID, indexColumn)
+		}
+		fmt.Fprintf(&down, "DROP TABLE %s;\n", tableID)
+	}
+	return up.String() + "\n-- +goose Down\n" + down.String()
+}
+
+func (t table) width() int {
+	width := len("id")
+	for _, c := range t.columns {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	return width
+}
+
+// tomlSnippet is the exporter config for compose of the scaffolded transformers
+func tomlSnippet(config Config, specs []transformerSpec) string {
+	var toml strings.Builder
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, fmt.Sprintf("%q", spec.name))
+	}
+	fmt.Fprintf(&toml, "[exporter]\n    transformerNames = [%s]\n", strings.Join(names, ", "))
+	for _, spec := range specs {
+		fmt.Fprintf(&toml, "    [exporter.%s]\n", spec.name)
+		fmt.Fprintf(&toml, "        path = %q\n", filepath.ToSlash(filepath.Join(config.OutputPath, spec.name)))
+		fmt.Fprintf(&toml, "        type = %q\n", spec.kind)
+		fmt.Fprintf(&toml, "        repository = %q\n", config.Repository)
+		fmt.Fprintf(&toml, "        directory = %q\n", config.RepositoryDir)
+		fmt.Fprintf(&toml, "        migrations = %q\n", filepath.ToSlash(config.MigrationPath))
+		toml.WriteString("        rank = \"0\"\n")
+	}
+	return toml.String()
+}
+
+// fields is the keyed values of a struct literal, in the order given rather than sorted like a Dict
+func fields(keysAndValues ...Code) []Code {
+	values := make([]Code, 0, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		values = append(values, Line().Add(keysAndValues[i]).Op(":").Add(keysAndValues[i+1]))
+	}
+	return append(values, Line())
+}
+
+// reserved words that can't be column names without quoting, which the event repository doesn't do
+var reserved = map[string]bool{
+	"all": true, "and": true, "any": true, "array": true, "as": true, "asc": true, "case": true, "check": true,
+	"column": true, "constraint": true, "create": true, "default": true, "desc": true, "distinct": true, "do": true,
+	"else": true, "end": true, "false": true, "for": true, "foreign": true, "from": true, "grant": true, "group": true,
+	"having": true, "in": true, "into": true, "is": true, "limit": true, "not": true, "null": true, "offset": true,
+	"on": true, "only": true, "or": true, "order": true, "primary": true, "references": true, "select": true,
+	"table": true, "then": true, "to": true, "true": true, "union": true, "unique": true, "user": true, "using": true,
+	"when": true, "where": true, "with": true,
+	// columns every scaffolded table has
+	"id": true, "header_id": true, "log_id": true, "diff_id": true,
+}
+
+// columnName is the snake case of an argument or variable name, with a trailing underscore if it is reserved
+func columnName(name string) string {
+	snake := snakeCase(name)
+	if reserved[snake] {
+		return snake + "_"
+	}
+	return snake
+}
+
+func schemaName(name string) string {
+	return snakeCase(name)
+}
+
+// snakeCase converts names like _srcAddress or ERC20Transfer to src_address and erc20_transfer
+func snakeCase(name string) string {
+	var snake strings.Builder
+	runes := []rune(strings.Trim(name, "_"))
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			previousLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])
+			if (previousLower || nextLower) && !strings.HasSuffix(snake.String(), "_") {
+				snake.WriteRune('_')
+			}
+			snake.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			snake.WriteRune(r)
+		default:
+			if !strings.HasSuffix(snake.String(), "_") {
+				snake.WriteRune('_')
+			}
+		}
+	}
+	return snake.String()
+}
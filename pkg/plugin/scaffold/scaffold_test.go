@@ -0,0 +1,205 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package scaffold_test
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/scaffold"
+)
+
+const tokenAbi = `[
+{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"},
+{"anonymous":false,"inputs":[{"indexed":true,"name":"_owner","type":"address"},{"indexed":false,"name":"from","type":"uint64"}],"name":"OwnerChanged","type":"event"},
+{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[],"name":"owner","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[{"name":"","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
+
+var _ = Describe("Scaffold", func() {
+	var (
+		dir    string
+		config scaffold.Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scaffold")
+		Expect(err).NotTo(HaveOccurred())
+		config = scaffold.Config{
+			Name:          "TokenContract",
+			Abi:           tokenAbi,
+			Events:        []string{"Transfer", "OwnerChanged"},
+			Variables:     []scaffold.Variable{{Name: "totalSupply"}, {Name: "owner"}},
+			Addresses:     []string{"0x1234567890123456789012345678901234567890"},
+			StartingBlock: 100,
+			Repository:    "github.com/account/repo",
+			RepositoryDir: dir,
+			OutputPath:    "transformers",
+			MigrationPath: "db/migrations",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("ParseVariable", func() {
+		It("defaults the slot", func() {
+			variable, err := scaffold.ParseVariable("totalSupply")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(variable).To(Equal(scaffold.Variable{Name: "totalSupply"}))
+		})
+
+		It("parses decimal and hex slots", func() {
+			variable, err := scaffold.ParseVariable("owner:3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(variable).To(Equal(scaffold.Variable{Name: "owner", Slot: big.NewInt(3)}))
+
+			variable, err = scaffold.ParseVariable("owner:0x10")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(variable.Slot).To(Equal(big.NewInt(16)))
+		})
+
+		It("returns an error for an invalid slot", func() {
+			_, err := scaffold.ParseVariable("owner:-1")
+			Expect(err).To(HaveOccurred())
+			_, err = scaffold.ParseVariable("owner:slot")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Generate", func() {
+		It("writes a package per event, a storage package and a migration", func() {
+			result, err := scaffold.Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			transformers := filepath.Join(dir, "transformers")
+			Expect(result.Files).To(ConsistOf(
+				filepath.Join(transformers, "transfer", "config.go"),
+				filepath.Join(transformers, "transfer", "converter.go"),
+				filepath.Join(transformers, "transfer", "converter_test.go"),
+				filepath.Join(transformers, "transfer", "initializer.go"),
+				filepath.Join(transformers, "transfer", "repository.go"),
+				filepath.Join(transformers, "transfer", "transfer_suite_test.go"),
+				filepath.Join(transformers, "owner_changed", "config.go"),
+				filepath.Join(transformers, "owner_changed", "converter.go"),
+				filepath.Join(transformers, "owner_changed", "converter_test.go"),
+				filepath.Join(transformers, "owner_changed", "initializer.go"),
+				filepath.Join(transformers, "owner_changed", "repository.go"),
+				filepath.Join(transformers, "owner_changed", "owner_changed_suite_test.go"),
+				filepath.Join(transformers, "token_contract_storage", "initializer.go"),
+				filepath.Join(transformers, "token_contract_storage", "keys_loader.go"),
+				filepath.Join(transformers, "token_contract_storage", "keys_loader_test.go"),
+				filepath.Join(transformers, "token_contract_storage", "repository.go"),
+				filepath.Join(transformers, "token_contract_storage", "token_contract_storage_suite_test.go"),
+				filepath.Join(dir, "db", "migrations", "00001_create_token_contract_tables.sql"),
+			))
+			for _, file := range result.Files {
+				Expect(file).To(BeARegularFile())
+			}
+		})
+
+		It("writes columns for the event inputs and storage variables", func() {
+			_, err := scaffold.Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			migration, readErr := ioutil.ReadFile(filepath.Join(dir, "db", "migrations", "00001_create_token_contract_tables.sql"))
+			Expect(readErr).NotTo(HaveOccurred())
+			Expect(string(migration)).To(ContainSubstring("CREATE SCHEMA IF NOT EXISTS token_contract;"))
+			Expect(string(migration)).To(ContainSubstring("CREATE TABLE token_contract.transfer\n"))
+			Expect(string(migration)).To(ContainSubstring("    from_     CHARACTER VARYING(66),\n"))
+			Expect(string(migration)).To(ContainSubstring("    value     NUMERIC,\n"))
+			Expect(string(migration)).To(ContainSubstring("CREATE TABLE token_contract.owner_changed\n"))
+			Expect(string(migration)).To(ContainSubstring("    owner     CHARACTER VARYING(66),\n"))
+			Expect(string(migration)).To(ContainSubstring("CREATE TABLE token_contract.total_supply\n"))
+			Expect(string(migration)).To(ContainSubstring("CREATE TABLE token_contract.owner\n"))
+			Expect(string(migration)).To(ContainSubstring("-- +goose Down\nDROP TABLE token_contract.transfer;"))
+		})
+
+		It("numbers the migration after the repository's latest", func() {
+			migrations := filepath.Join(dir, "db", "migrations")
+			Expect(os.MkdirAll(migrations, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(migrations, "00007_create_other_table.sql"), nil, 0644)).To(Succeed())
+
+			result, err := scaffold.Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Files).To(ContainElement(filepath.Join(migrations, "00008_create_token_contract_tables.sql")))
+		})
+
+		It("returns the exporter config for compose", func() {
+			result, err := scaffold.Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Toml).To(HavePrefix(
+				"[exporter]\n    transformerNames = [\"transfer\", \"owner_changed\", \"token_contract_storage\"]\n"))
+			Expect(result.Toml).To(ContainSubstring(`    [exporter.transfer]
+        path = "transformers/transfer"
+        type = "eth_event"
+        repository = "github.com/account/repo"
+        directory = "` + dir + `"
+        migrations = "db/migrations"
+        rank = "0"
+`))
+			Expect(result.Toml).To(ContainSubstring(`    [exporter.token_contract_storage]
+        path = "transformers/token_contract_storage"
+        type = "eth_storage"
+`))
+		})
+
+		It("returns an error for an event not in the abi", func() {
+			config.Events = []string{"Approval"}
+			_, err := scaffold.Generate(config)
+			Expect(err).To(MatchError("event Approval not found in abi"))
+		})
+
+		It("returns an error for a mapping", func() {
+			config.Variables = []scaffold.Variable{{Name: "balanceOf"}}
+			_, err := scaffold.Generate(config)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("balanceOf is a mapping"))
+		})
+
+		It("returns an error for variables in the same slot", func() {
+			config.Variables = []scaffold.Variable{{Name: "totalSupply"}, {Name: "owner", Slot: big.NewInt(0)}}
+			_, err := scaffold.Generate(config)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("both in slot 0"))
+		})
+
+		It("requires the contract address for storage", func() {
+			config.Addresses = nil
+			_, err := scaffold.Generate(config)
+			Expect(err).To(MatchError("storage transformer token_contract_storage needs the address of the contract"))
+		})
+
+		It("writes nothing if a package already exists", func() {
+			existing := filepath.Join(dir, "transformers", "owner_changed")
+			Expect(os.MkdirAll(existing, 0755)).To(Succeed())
+
+			_, err := scaffold.Generate(config)
+			Expect(err).To(MatchError(existing + " already exists"))
+			Expect(filepath.Join(dir, "transformers", "transfer")).NotTo(BeADirectory())
+			Expect(filepath.Join(dir, "db", "migrations")).NotTo(BeADirectory())
+		})
+	})
+})
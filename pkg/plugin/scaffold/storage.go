@@ -0,0 +1,283 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package scaffold
+
+import (
+	"fmt"
+	"math/big"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// storageGenerator generates a package with a keys loader for the slots of a contract's variables,
+// and a repository persisting each variable's values to its own table
+type storageGenerator struct {
+	variables []storageVariable
+}
+
+type storageVariable struct {
+	name      string
+	constant  string // name of the constant holding the variable's name
+	slot      common.Hash
+	valueType Code // utils.ValueType the slot is decoded as
+	dynamic   bool // bytes or string, whose long values are stored after the keccak hash of the slot
+	column    column
+}
+
+// Variables are found by their public getters, which must not take arguments, so mappings and arrays can't be scaffolded
+func newStorageGenerator(parsedAbi abi.ABI, variables []Variable) (storageGenerator, error) {
+	var generator storageGenerator
+	slots := make(map[common.Hash]string)
+	for i, variable := range variables {
+		getter, ok := parsedAbi.Methods[variable.Name]
+		if !ok {
+			return storageGenerator{}, fmt.Errorf("variable %s has no getter in abi", variable.Name)
+		}
+		if len(getter.Inputs) > 0 || len(getter.Outputs) != 1 {
+			return storageGenerator{}, fmt.Errorf("variable %s is a mapping, array or struct, which can't be scaffolded", variable.Name)
+		}
+		slot := variable.Slot
+		if slot == nil {
+			slot = big.NewInt(int64(i))
+		}
+		hashedSlot := common.BigToHash(slot)
+		if other, ok := slots[hashedSlot]; ok {
+			return storageGenerator{}, fmt.Errorf("variables %s and %s are both in slot %s, packed slots can't be scaffolded",
+				other, variable.Name, slot.String())
+		}
+		slots[hashedSlot] = variable.Name
+		valueType, pgType, dynamic, typeErr := storageValueType(getter.Outputs[0].Type)
+		if typeErr != nil {
+			return storageGenerator{}, fmt.Errorf("can't scaffold variable %s: %s", variable.Name, typeErr.Error())
+		}
+		generator.variables = append(generator.variables, storageVariable{
+			name:      variable.Name,
+			constant:  abi.ToCamelCase(variable.Name),
+			slot:      hashedSlot,
+			valueType: valueType,
+			dynamic:   dynamic,
+			column:    column{name: columnName(variable.Name), pgType: pgType},
+		})
+	}
+	return generator, nil
+}
+
+func storageValueType(t abi.Type) (Code, string, bool, error) {
+	switch t.T {
+	case abi.UintTy:
+		if t.Size == 256 {
+			return Qual(utilsPackage, "Uint256"), "NUMERIC", false, nil
+		}
+		return Qual(utilsPackage, "Uint").Call(Lit(t.Size)), "NUMERIC", false, nil
+	case abi.IntTy:
+		return Qual(utilsPackage, "Int").Call(Lit(t.Size)), "NUMERIC", false, nil
+	case abi.AddressTy:
+		return Qual(utilsPackage, "Address"), "CHARACTER VARYING(66)", false, nil
+	case abi.BoolTy:
+		return Qual(utilsPackage, "Bool"), "BOOLEAN", false, nil
+	case abi.FixedBytesTy:
+		if t.Size == 32 {
+			return Qual(utilsPackage, "Bytes32"), "CHARACTER VARYING(66)", false, nil
+		}
+		return Qual(utilsPackage, "FixedBytes").Call(Lit(t.Size)), "TEXT", false, nil
+	case abi.StringTy:
+		return Qual(utilsPackage, "String"), "TEXT", true, nil
+	case abi.BytesTy:
+		return Qual(utilsPackage, "Bytes"), "TEXT", true, nil
+	default:
+		return nil, "", false, fmt.Errorf("unsupported type %s", t.String())
+	}
+}
+
+func (generator storageGenerator) tables(schema string) []table {
+	tables := make([]table, 0, len(generator.variables))
+	for _, variable := range generator.variables {
+		tables = append(tables, table{
+			name: snakeCase(variable.name),
+			columns: []column{
+				{name: "diff_id", pgType: "INTEGER NOT NULL REFERENCES public.storage_diff (id) ON DELETE CASCADE"},
+				variable.column,
+			},
+			unique: []string{"diff_id"},
+		})
+	}
+	return tables
+}
+
+func (generator storageGenerator) files(config Config, spec transformerSpec) (map[string]generatedFile, error) {
+	if len(config.Addresses) != 1 {
+		return nil, fmt.Errorf("storage transformer %s needs the address of the contract", spec.name)
+	}
+	schema := schemaName(config.Name)
+	return map[string]generatedFile{
+		"keys_loader.go":             generator.keysLoaderFile(spec),
+		"repository.go":              generator.repositoryFile(spec, schema),
+		"initializer.go":             generator.initializerFile(config, spec),
+		spec.name + "_suite_test.go": suiteFile(spec),
+		"keys_loader_test.go":        generator.keysLoaderTestFile(spec),
+	}, nil
+}
+
+func (generator storageGenerator) keysLoaderFile(spec transformerSpec) *File {
+	f := newFile(spec)
+	names := make([]Code, 0, len(generator.variables))
+	slots := make([]Code, 0, len(generator.variables))
+	mappings := Dict{}
+	for _, variable := range generator.variables {
+		names = append(names, Id(variable.constant).Op("=").Lit(variable.name))
+		slots = append(slots, Id(variable.constant+"Slot").Op("=").Lit(variable.slot.Hex()[2:]))
+		slot := Qual(commonPackage, "HexToHash").Call(Id(variable.constant + "Slot"))
+		if variable.dynamic {
+			mappings[slot] = Qual(utilsPackage, "GetStorageValueMetadataForDynamicValue").Call(Id(variable.constant), Nil(), variable.valueType, slot.Clone())
+		} else {
+			mappings[slot] = Qual(utilsPackage, "GetStorageValueMetadata").Call(Id(variable.constant), Nil(), variable.valueType)
+		}
+	}
+	f.Comment("Names of the contract's variables in their storage value metadata")
+	f.Const().Defs(names...)
+	f.Line()
+	f.Comment("Slots of the contract's variables. Unless they were given, these are the order the variables were listed in,")
+	f.Comment("which is only right if they are listed in declaration order and none of them share a slot; check them against")
+	f.Comment("the storageLayout output of solc.")
+	f.Const().Defs(slots...)
+	f.Line()
+	f.Comment("KeysLoader recognizes the slots of the contract's variables")
+	f.Type().Id("KeysLoader").Struct()
+	f.Line()
+	f.Func().Params(Id("loader").Op("*").Id("KeysLoader")).Id("LoadMappings").Params().Params(
+		Map(Qual(commonPackage, "Hash")).Qual(utilsPackage, "StorageValueMetadata"), Error(),
+	).Block(
+		Return(Map(Qual(commonPackage, "Hash")).Qual(utilsPackage, "StorageValueMetadata").Values(mappings), Nil()),
+	)
+	f.Line()
+	f.Func().Params(Id("loader").Op("*").Id("KeysLoader")).Id("SetDB").Params(Id("db").Op("*").Qual(postgresPackage, "DB")).Block()
+	return f
+}
+
+func (generator storageGenerator) repositoryFile(spec transformerSpec, schema string) *File {
+	f := newFile(spec)
+	f.Comment("Repository persists each of the contract's variables to its own table")
+	f.Type().Id("Repository").Struct(Id("db").Op("*").Qual(postgresPackage, "DB"))
+	f.Line()
+
+	var cases []Code
+	var tables []Code
+	for _, variable := range generator.variables {
+		tableID := schema + "." + snakeCase(variable.name)
+		tables = append(tables, Lit(tableID))
+		query := fmt.Sprintf("INSERT INTO %s (diff_id, %s) VALUES ($1, $2) ON CONFLICT (diff_id) DO NOTHING", tableID, variable.column.name)
+		cases = append(cases, Case(Id(variable.constant)).Block(
+			List(Id("_"), Id("err")).Op(":=").Id("repository").Dot("db").Dot("Exec").Call(Lit(query), Id("diffID"), Id("value")),
+			Return(Id("err")),
+		))
+	}
+	cases = append(cases, Default().Block(
+		Return(Qual("fmt", "Errorf").Call(Lit("unrecognized storage variable %s"), Id("metadata").Dot("Name"))),
+	))
+	f.Comment("Create persists a value, ignoring diffs that have already been transformed")
+	f.Func().Params(Id("repository").Op("*").Id("Repository")).Id("Create").Params(
+		Id("diffID").Int64(), Id("metadata").Qual(utilsPackage, "StorageValueMetadata"), Id("value").Interface(),
+	).Error().Block(
+		Switch(Id("metadata").Dot("Name")).Block(cases...),
+	)
+	f.Line()
+	f.Comment("Delete removes the values transformed from a diff, e.g. when its block is removed in a reorg")
+	f.Func().Params(Id("repository").Op("*").Id("Repository")).Id("Delete").Params(Id("diffID").Int64()).Error().Block(
+		For(List(Id("_"), Id("table")).Op(":=").Range().Index().String().Values(tables...)).Block(
+			List(Id("_"), Id("err")).Op(":=").Id("repository").Dot("db").Dot("Exec").Call(
+				Lit("DELETE FROM ").Op("+").Id("table").Op("+").Lit(" WHERE diff_id = $1"), Id("diffID")),
+			If(Id("err").Op("!=").Nil()).Block(Return(Id("err"))),
+		),
+		Return(Nil()),
+	)
+	f.Line()
+	f.Func().Params(Id("repository").Op("*").Id("Repository")).Id("SetDB").Params(Id("db").Op("*").Qual(postgresPackage, "DB")).Block(
+		Id("repository").Dot("db").Op("=").Id("db"),
+	)
+	return f
+}
+
+func (generator storageGenerator) initializerFile(config Config, spec transformerSpec) *File {
+	f := newFile(spec)
+	f.Const().Id("ContractAddress").Op("=").Lit(config.Addresses[0])
+	f.Line()
+	f.Var().Id("StorageTransformerInitializer").Qual(transformerPackage, "StorageTransformerInitializer").Op("=").Qual(storagePackage, "Transformer").Values(fields(
+		Id("HashedAddress"), Qual(utilsPackage, "HexToKeccak256Hash").Call(Id("ContractAddress")),
+		Id("StorageKeysLookup"), Qual(storagePackage, "NewKeysLookup").Call(Op("&").Id("KeysLoader").Values()),
+		Id("Repository"), Op("&").Id("Repository").Values(),
+	)...).Dot("NewTransformer")
+	return f
+}
+
+func (generator storageGenerator) keysLoaderTestFile(spec transformerSpec) *File {
+	f := NewFilePathName("", spec.name+"_test")
+	f.HeaderComment(generatedComment)
+	pkg := spec.importPath
+	f.ImportName(pkg, spec.name)
+	f.ImportName(ginkgo, "ginkgo")
+	f.ImportName(gomega, "gomega")
+	f.ImportName(testDataPackage, "test_data")
+	expect := func(actual Code) *Statement { return Qual(gomega, "Expect").Call(actual) }
+	matcher := func(name string, args ...Code) *Statement { return Qual(gomega, name).Call(args...) }
+
+	var lookups []Code
+	for _, variable := range generator.variables {
+		lookups = append(lookups, Qual(ginkgo, "It").Call(Lit("looks up "+variable.name), Func().Params().Block(
+			List(Id("metadata"), Id("err")).Op(":=").Id("lookup").Dot("Lookup").Call(Qual(commonPackage, "HexToHash").Call(Qual(pkg, variable.constant+"Slot"))),
+			Line(),
+			expect(Id("err")).Dot("NotTo").Call(matcher("HaveOccurred")),
+			expect(Id("metadata").Dot("Name")).Dot("To").Call(matcher("Equal", Qual(pkg, variable.constant))),
+		)), Line())
+	}
+	first := generator.variables[0]
+	body := []Code{
+		Var().Id("lookup").Qual(storagePackage, "KeysLookup"),
+		Line(),
+		Qual(ginkgo, "BeforeEach").Call(Func().Params().Block(
+			Id("lookup").Op("=").Qual(storagePackage, "NewKeysLookup").Call(Op("&").Qual(pkg, "KeysLoader").Values()),
+		)),
+		Line(),
+	}
+	body = append(body, lookups...)
+	body = append(body, Qual(ginkgo, "It").Call(Lit("passes a decoded diff of "+first.name+" to the repository"), Func().Params().Block(
+		Id("repository").Op(":=").Op("&").Qual(mocksPackage, "MockStorageRepository").Values(),
+		Id("transformer").Op(":=").Qual(storagePackage, "Transformer").Values(fields(
+			Id("HashedAddress"), Qual(utilsPackage, "HexToKeccak256Hash").Call(Qual(pkg, "ContractAddress")),
+			Id("StorageKeysLookup"), Id("lookup"),
+			Id("Repository"), Id("repository"),
+		)...),
+		Id("diff").Op(":=").Qual(utilsPackage, "PersistedStorageDiff").Values(fields(
+			Id("ID"), Qual("math/rand", "Int63").Call(),
+			Id("StorageDiffInput"), Qual(utilsPackage, "StorageDiffInput").Values(fields(
+				Id("HashedAddress"), Id("transformer").Dot("HashedAddress"),
+				Id("BlockHash"), Qual(testDataPackage, "FakeHash").Call(),
+				Id("StorageKey"), Qual(commonPackage, "HexToHash").Call(Qual(pkg, first.constant+"Slot")),
+				Id("StorageValue"), Qual(commonPackage, "Hash").Values(),
+			)...),
+		)...),
+		Line(),
+		Id("err").Op(":=").Id("transformer").Dot("Execute").Call(Id("diff")),
+		Line(),
+		expect(Id("err")).Dot("NotTo").Call(matcher("HaveOccurred")),
+		expect(Id("repository").Dot("PassedDiffID")).Dot("To").Call(matcher("Equal", Id("diff").Dot("ID"))),
+		expect(Id("repository").Dot("PassedMetadata").Dot("Name")).Dot("To").Call(matcher("Equal", Qual(pkg, first.constant))),
+	)))
+	f.Var().Id("_").Op("=").Qual(ginkgo, "Describe").Call(Lit(spec.name+" keys loader"), Func().Params().Block(body...))
+	return f
+}
@@ -18,11 +18,14 @@ package writer
 
 import (
 	"fmt"
+	"runtime/debug"
+	"sort"
 
 	. "github.com/dave/jennifer/jen"
 
 	"github.com/vulcanize/vulcanizedb/pkg/config"
 	"github.com/vulcanize/vulcanizedb/pkg/plugin/helpers"
+	"github.com/vulcanize/vulcanizedb/pkg/plugin/manifest"
 )
 
 // Interface for writing a .go file for a simple
@@ -34,12 +37,14 @@ type PluginWriter interface {
 
 type writer struct {
 	GenConfig config.Plugin
+	buildInfo func() (*debug.BuildInfo, bool) // modules the running binary was built with
 }
 
 // Requires populated plugin config
 func NewPluginWriter(gc config.Plugin) PluginWriter {
 	return &writer{
 		GenConfig: gc,
+		buildInfo: debug.ReadBuildInfo,
 	}
 }
 
@@ -58,22 +63,23 @@ func (w *writer) WritePlugin() error {
 	// Import pkgs for generic TransformerInitializer interface and specific TransformerInitializers specified in config
 	f.ImportAlias("github.com/vulcanize/vulcanizedb/libraries/shared/transformer", "interface")
 	for name, transformer := range w.GenConfig.Transformers {
-		f.ImportAlias(transformer.RepositoryPath+"/"+transformer.Path, name)
+		f.ImportAlias(transformer.ImportPath(), name)
 	}
 
 	// Collect initializer code
-	code, err := w.collectTransformers()
+	code, names, err := w.collectTransformers()
 	if err != nil {
 		return err
 	}
 
-	// Create Exporter variable with method to export the set of the imported storage and event transformer initializers
+	// Create Exporter variable with method to export the set of the imported transformer initializers of each type
 	f.Type().Id("exporter").String()
 	f.Var().Id("Exporter").Id("exporter")
 	f.Func().Params(Id("e").Id("exporter")).Id("Export").Params().Parens(List(
 		Index().Qual("github.com/vulcanize/vulcanizedb/libraries/shared/transformer", "EventTransformerInitializer"),
 		Index().Qual("github.com/vulcanize/vulcanizedb/libraries/shared/transformer", "StorageTransformerInitializer"),
 		Index().Qual("github.com/vulcanize/vulcanizedb/libraries/shared/transformer", "ContractTransformerInitializer"),
+		Index().Qual("github.com/vulcanize/vulcanizedb/libraries/shared/transformer", "SuperNodeTransformerInitializer"),
 	)).Block(Return(
 		Index().Qual(
 			"github.com/vulcanize/vulcanizedb/libraries/shared/transformer",
@@ -83,7 +89,41 @@ func (w *writer) WritePlugin() error {
 			"StorageTransformerInitializer").Values(code[config.EthStorage]...),
 		Index().Qual(
 			"github.com/vulcanize/vulcanizedb/libraries/shared/transformer",
-			"ContractTransformerInitializer").Values(code[config.EthContract]...))) // Exports the collected event and storage transformer initializers
+			"ContractTransformerInitializer").Values(code[config.EthContract]...),
+		Index().Qual(
+			"github.com/vulcanize/vulcanizedb/libraries/shared/transformer",
+			"SuperNodeTransformerInitializer").Values(code[config.EthSuperNode]...))) // Exports the collected transformer initializers
+	// Names of the exported initializers, index-aligned with Export, so that execute can add and drop transformers at runtime
+	f.Func().Params(Id("e").Id("exporter")).Id("ExportNames").Params().Parens(List(
+		Index().String(),
+		Index().String(),
+		Index().String(),
+		Index().String(),
+	)).Block(Return(
+		Index().String().Values(names[config.EthEvent]...),
+		Index().String().Values(names[config.EthStorage]...),
+		Index().String().Values(names[config.EthContract]...),
+		Index().String().Values(names[config.EthSuperNode]...)))
+
+	// In RPC mode the plugin is an executable that serves the exporter's transformers to vulcanizedb
+	if w.GenConfig.Mode == config.RPCMode {
+		f.Func().Id("main").Params().Block(Qual("github.com/vulcanize/vulcanizedb/libraries/shared/remote", "Serve").Call(Id("Exporter")))
+	}
+	// In static mode the plugin is a vulcanizedb binary whose commands use the compiled-in exporter
+	if w.GenConfig.Mode == config.StaticMode {
+		f.Func().Id("main").Params().Block(
+			Qual("github.com/vulcanize/vulcanizedb/cmd", "SetExporter").Call(Id("Exporter")),
+			Qual("github.com/vulcanize/vulcanizedb/cmd", "Execute").Call(),
+		)
+	}
+
+	// Embed a manifest of what the plugin is composed with, which execute checks before linking it
+	info, _ := w.buildInfo()
+	encodedManifest, err := manifest.New(w.GenConfig, info).Encode()
+	if err != nil {
+		return err
+	}
+	f.Var().Id("Manifest").Op("=").Lit(encodedManifest)
 
 	// Write code to destination file
 	err = f.Save(goFile)
@@ -93,24 +133,28 @@ func (w *writer) WritePlugin() error {
 	return nil
 }
 
-// Collect code for various types of initializers
-func (w *writer) collectTransformers() (map[config.TransformerType][]Code, error) {
+// Collect code and names for various types of initializers, ordered by transformer name
+func (w *writer) collectTransformers() (map[config.TransformerType][]Code, map[config.TransformerType][]Code, error) {
+	transformerNames := make([]string, 0, len(w.GenConfig.Transformers))
+	for name := range w.GenConfig.Transformers {
+		transformerNames = append(transformerNames, name)
+	}
+	sort.Strings(transformerNames)
+
 	code := make(map[config.TransformerType][]Code)
-	for _, transformer := range w.GenConfig.Transformers {
-		path := transformer.RepositoryPath + "/" + transformer.Path
-		switch transformer.Type {
-		case config.EthEvent:
-			code[config.EthEvent] = append(code[config.EthEvent], Qual(path, "EventTransformerInitializer"))
-		case config.EthStorage:
-			code[config.EthStorage] = append(code[config.EthStorage], Qual(path, "StorageTransformerInitializer"))
-		case config.EthContract:
-			code[config.EthContract] = append(code[config.EthContract], Qual(path, "ContractTransformerInitializer"))
-		default:
-			return nil, fmt.Errorf("invalid transformer type %s", transformer.Type)
+	names := make(map[config.TransformerType][]Code)
+	for _, name := range transformerNames {
+		transformer := w.GenConfig.Transformers[name]
+		path := transformer.ImportPath()
+		symbol := transformer.Type.InitializerSymbol()
+		if symbol == "" {
+			return nil, nil, fmt.Errorf("invalid transformer type %s", transformer.Type)
 		}
+		code[transformer.Type] = append(code[transformer.Type], Qual(path, symbol))
+		names[transformer.Type] = append(names[transformer.Type], Lit(name))
 	}
 
-	return code, nil
+	return code, names, nil
 }
 
 // Setup the .go, clear old ones if present
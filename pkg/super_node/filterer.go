@@ -137,7 +137,12 @@ func (s *Filterer) filerReceipts(streamFilters config.Subscription, response *st
 				if err != nil {
 					return err
 				}
+				logsRlp, err := streamer.EncodeReceiptLogs(receipt.Logs)
+				if err != nil {
+					return err
+				}
 				response.ReceiptsRlp = append(response.ReceiptsRlp, receiptBuffer.Bytes())
+				response.ReceiptLogsRlp = append(response.ReceiptLogsRlp, logsRlp)
 			}
 		}
 	}
@@ -221,11 +226,14 @@ func checkNodeKeys(wantedKeys []common.Hash, actualKey common.Hash) bool {
 func (s *Filterer) filterStorage(streamFilters config.Subscription, response *streamer.SuperNodePayload, payload ipfs.IPLDPayload) error {
 	if !streamFilters.StorageFilter.Off && checkRange(streamFilters.StartingBlock.Int64(), streamFilters.EndingBlock.Int64(), payload.BlockNumber.Int64()) {
 		response.StorageNodesRlp = make(map[common.Hash]map[common.Hash][]byte)
-		stateKeyFilters := make([]common.Hash, 0, len(streamFilters.StorageFilter.Addresses))
+		stateKeyFilters := make([]common.Hash, 0, len(streamFilters.StorageFilter.Addresses)+len(streamFilters.StorageFilter.StateKeys))
 		for _, addr := range streamFilters.StorageFilter.Addresses {
 			keyFilter := ipfs.AddressToKey(common.HexToAddress(addr))
 			stateKeyFilters = append(stateKeyFilters, keyFilter)
 		}
+		for _, stateKey := range streamFilters.StorageFilter.StateKeys {
+			stateKeyFilters = append(stateKeyFilters, common.HexToHash(stateKey))
+		}
 		storageKeyFilters := make([]common.Hash, 0, len(streamFilters.StorageFilter.StorageKeys))
 		for _, store := range streamFilters.StorageFilter.StorageKeys {
 			keyFilter := ipfs.HexToKey(store)
@@ -18,11 +18,14 @@ package super_node_test
 
 import (
 	"bytes"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/core/types"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/vulcanize/vulcanizedb/libraries/shared/streamer"
+	"github.com/vulcanize/vulcanizedb/pkg/config"
 	"github.com/vulcanize/vulcanizedb/pkg/ipfs/mocks"
 	"github.com/vulcanize/vulcanizedb/pkg/super_node"
 )
@@ -53,6 +56,12 @@ var _ = Describe("Filterer", func() {
 			Expect(len(superNodePayload.ReceiptsRlp)).To(Equal(2))
 			Expect(super_node.ListContainsBytes(superNodePayload.ReceiptsRlp, expectedRctForStorageRLP1)).To(BeTrue())
 			Expect(super_node.ListContainsBytes(superNodePayload.ReceiptsRlp, expectedRctForStorageRLP2)).To(BeTrue())
+			Expect(len(superNodePayload.ReceiptLogsRlp)).To(Equal(2))
+			for i, logsRlp := range superNodePayload.ReceiptLogsRlp {
+				logs, decodeErr := streamer.DecodeReceiptLogs(logsRlp)
+				Expect(decodeErr).ToNot(HaveOccurred())
+				Expect(len(logs)).To(Equal(len(mocks.MockReceipts[i].Logs)))
+			}
 			Expect(len(superNodePayload.StateNodesRlp)).To(Equal(2))
 			Expect(superNodePayload.StateNodesRlp[mocks.ContractLeafKey]).To(Equal(mocks.ValueBytes))
 			Expect(superNodePayload.StateNodesRlp[mocks.AnotherContractLeafKey]).To(Equal(mocks.AnotherValueBytes))
@@ -141,6 +150,26 @@ var _ = Describe("Filterer", func() {
 			Expect(len(superNodePayload7.StateNodesRlp)).To(Equal(1))
 			Expect(superNodePayload7.StateNodesRlp[mocks.ContractLeafKey]).To(Equal(mocks.ValueBytes))
 		})
+
+		It("Filters storage by the state keys of contracts", func() {
+			storageFilter := config.Subscription{
+				StartingBlock: big.NewInt(0),
+				EndingBlock:   big.NewInt(0),
+				HeaderFilter:  config.HeaderFilter{Off: true},
+				TrxFilter:     config.TrxFilter{Off: true},
+				ReceiptFilter: config.ReceiptFilter{Off: true},
+				StateFilter:   config.StateFilter{Off: true},
+				StorageFilter: config.StorageFilter{StateKeys: []string{mocks.ContractLeafKey.Hex()}},
+			}
+			superNodePayload, err := filterer.FilterResponse(storageFilter, *mocks.MockIPLDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(superNodePayload.StorageNodesRlp).To(Equal(mocks.MockSeeNodePayload.StorageNodesRlp))
+
+			storageFilter.StorageFilter.StateKeys = []string{mocks.AnotherContractLeafKey.Hex()}
+			superNodePayload, err = filterer.FilterResponse(storageFilter, *mocks.MockIPLDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(superNodePayload.StorageNodesRlp).To(BeEmpty())
+		})
 	})
 })
 
@@ -123,8 +123,8 @@ func (repo *Repository) indexTransactionAndReceiptCIDs(tx *sqlx.Tx, payload *ipf
 }
 
 func (repo *Repository) indexReceiptCID(tx *sqlx.Tx, cidMeta *ipfs.ReceiptMetaData, txID int64) error {
-	_, err := tx.Exec(`INSERT INTO public.receipt_cids (tx_id, cid, contract, topic0s) VALUES ($1, $2, $3, $4)`,
-		txID, cidMeta.CID, cidMeta.ContractAddress, pq.Array(cidMeta.Topic0s))
+	_, err := tx.Exec(`INSERT INTO public.receipt_cids (tx_id, cid, contract, topic0s, tx_index, log_index) VALUES ($1, $2, $3, $4, $5, $6)`,
+		txID, cidMeta.CID, cidMeta.ContractAddress, pq.Array(cidMeta.Topic0s), cidMeta.TxIndex, cidMeta.LogIndex)
 	return err
 }
 
@@ -19,6 +19,7 @@ package super_node
 import (
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
@@ -118,7 +119,7 @@ func (ecr *EthCIDRetriever) RetrieveCIDs(streamFilters config.Subscription, bloc
 	// Retrieve cached receipt CIDs
 	if !streamFilters.ReceiptFilter.Off {
 		var rctsErr error
-		cw.Receipts, rctsErr = ecr.retrieveRctCIDs(tx, streamFilters, blockNumber, trxIds)
+		cw.Receipts, cw.ReceiptLogs, rctsErr = ecr.retrieveRctCIDs(tx, streamFilters, blockNumber, trxIds)
 		if rctsErr != nil {
 			rollbackErr := tx.Rollback()
 			if rollbackErr != nil {
@@ -210,10 +211,12 @@ func (ecr *EthCIDRetriever) retrieveTrxCIDs(tx *sqlx.Tx, streamFilters config.Su
 	return cids, ids, nil
 }
 
-func (ecr *EthCIDRetriever) retrieveRctCIDs(tx *sqlx.Tx, streamFilters config.Subscription, blockNumber int64, trxIds []int64) ([]string, error) {
+// Receipts are returned with the position of their logs, since receipt IPLDs don't include it
+func (ecr *EthCIDRetriever) retrieveRctCIDs(tx *sqlx.Tx, streamFilters config.Subscription, blockNumber int64, trxIds []int64) ([]string, map[string]ipfs.ReceiptLogMetaData, error) {
 	log.Debug("retrieving receipt cids for block ", blockNumber)
 	args := make([]interface{}, 0, 4)
-	pgStr := `SELECT receipt_cids.cid FROM receipt_cids, transaction_cids, header_cids
+	pgStr := `SELECT receipt_cids.cid, header_cids.block_hash, transaction_cids.tx_hash, receipt_cids.tx_index, receipt_cids.log_index
+			FROM receipt_cids, transaction_cids, header_cids
 			WHERE receipt_cids.tx_id = transaction_cids.id 
 			AND transaction_cids.header_id = header_cids.id
 			AND header_cids.block_number = $1`
@@ -254,9 +257,22 @@ func (ecr *EthCIDRetriever) retrieveRctCIDs(tx *sqlx.Tx, streamFilters config.Su
 			args = append(args, pq.Array(trxIds))
 		}
 	}
-	receiptCids := make([]string, 0)
-	err := tx.Select(&receiptCids, pgStr, args...)
-	return receiptCids, err
+	type result struct {
+		Cid string `db:"cid"`
+		ipfs.ReceiptLogMetaData
+	}
+	results := make([]result, 0)
+	err := tx.Select(&results, pgStr, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	receiptCids := make([]string, 0, len(results))
+	receiptLogs := make(map[string]ipfs.ReceiptLogMetaData, len(results))
+	for _, res := range results {
+		receiptCids = append(receiptCids, res.Cid)
+		receiptLogs[res.Cid] = res.ReceiptLogMetaData
+	}
+	return receiptCids, receiptLogs, nil
 }
 
 func (ecr *EthCIDRetriever) retrieveStateCIDs(tx *sqlx.Tx, streamFilters config.Subscription, blockNumber int64) ([]ipfs.StateNodeCID, error) {
@@ -290,12 +306,15 @@ func (ecr *EthCIDRetriever) retrieveStorageCIDs(tx *sqlx.Tx, streamFilters confi
 			AND state_cids.header_id = header_cids.id
 			AND header_cids.block_number = $1`
 	args = append(args, blockNumber)
-	addrLen := len(streamFilters.StorageFilter.Addresses)
-	if addrLen > 0 {
-		keys := make([]string, 0, addrLen)
+	keyLen := len(streamFilters.StorageFilter.Addresses) + len(streamFilters.StorageFilter.StateKeys)
+	if keyLen > 0 {
+		keys := make([]string, 0, keyLen)
 		for _, addr := range streamFilters.StorageFilter.Addresses {
 			keys = append(keys, ipfs.HexToKey(addr).Hex())
 		}
+		for _, stateKey := range streamFilters.StorageFilter.StateKeys {
+			keys = append(keys, common.HexToHash(stateKey).Hex())
+		}
 		pgStr += ` AND state_cids.state_key = ANY($2::VARCHAR(66)[])`
 		args = append(args, pq.Array(keys))
 	}
@@ -210,6 +210,11 @@ var _ = Describe("Retriever", func() {
 			Expect(len(cidWrapper.Receipts)).To(Equal(2))
 			Expect(super_node.ListContainsString(cidWrapper.Receipts, mocks.MockCIDWrapper.Receipts[0])).To(BeTrue())
 			Expect(super_node.ListContainsString(cidWrapper.Receipts, mocks.MockCIDWrapper.Receipts[1])).To(BeTrue())
+			receiptLogs := cidWrapper.ReceiptLogs[mocks.MockCIDWrapper.Receipts[1]]
+			Expect(receiptLogs.BlockHash).To(Equal(mocks.MockBlock.Hash().Hex()))
+			Expect(receiptLogs.TxHash).To(Equal(mocks.MockTransactions[1].Hash().Hex()))
+			Expect(*receiptLogs.TxIndex).To(Equal(uint64(1)))
+			Expect(*receiptLogs.LogIndex).To(Equal(uint64(1)))
 			Expect(len(cidWrapper.StateNodes)).To(Equal(2))
 			for _, stateNode := range cidWrapper.StateNodes {
 				if stateNode.CID == "mockStateCID1" {
@@ -100,7 +100,10 @@ func CleanTestDB(db *postgres.DB) {
 	db.MustExec("DELETE FROM header_sync_transactions")
 	db.MustExec("DELETE FROM headers")
 	db.MustExec("DELETE FROM log_filters")
+	db.MustExec("DELETE FROM poisoned_storage")
 	db.MustExec("DELETE FROM queued_storage")
+	db.MustExec("DELETE FROM storage_backfill_progress")
+	db.MustExec("DELETE FROM storage_diff_checkpoints")
 	db.MustExec("DELETE FROM storage_diff")
 	db.MustExec("DELETE FROM watched_contracts")
 	db.MustExec("DELETE FROM watched_logs")